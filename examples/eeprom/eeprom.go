@@ -0,0 +1,209 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/wdevore/hardware/ftdi"
+	"github.com/wdevore/hardware/gpio"
+)
+
+// Reads, decodes and writes the FT232H EEPROM.
+//
+//	eeprom read [-serial FT0RN5XA] [-hex]
+//	eeprom write [-serial FT0RN5XA] -set-serial BENCH01 -cbus 8=gpio,9=gpio [-dry-run]
+//
+// The device must be re-plugged for written changes to take effect.
+func main() {
+	if len(os.Args) < 2 || (os.Args[1] != "read" && os.Args[1] != "write") {
+		usage()
+	}
+
+	command := os.Args[1]
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+
+	serial := flags.String("serial", "", "select the device with this serial number")
+	path := flags.String("path", "", "select the device at this USB path, for example 1-2.3")
+	hex := flags.Bool("hex", false, "read: also dump the raw image")
+
+	dryRun := flags.Bool("dry-run", false, "write: show the changes without writing")
+	factory := flags.Bool("factory", false, "write: start from the factory configuration instead of the EEPROM's")
+	flags.String("set-serial", "", "write: serial number")
+	flags.String("manufacturer", "", "write: manufacturer string")
+	flags.String("description", "", "write: product description string")
+	flags.String("vendor", "", "write: USB vendor id, for example 0x0403")
+	flags.String("product", "", "write: USB product id, for example 0x6014")
+	flags.String("cbus", "", "write: CBUS functions, for example 8=gpio,9=gpio")
+	flags.Int("max-power", 0, "write: bus current in mA")
+	flags.Bool("self-powered", false, "write: self powered")
+	flags.Bool("power-save", false, "write: suspend while C5 is low")
+	flags.Int("drive-ad", 0, "write: D0-D7 drive strength in mA (4, 8, 12 or 16)")
+	flags.Int("drive-ac", 0, "write: C0-C9 drive strength in mA (4, 8, 12 or 16)")
+	flags.Bool("slow-slew-ad", false, "write: D0-D7 slow slew")
+	flags.Bool("slow-slew-ac", false, "write: C0-C9 slow slew")
+	flags.Bool("schmitt-ad", false, "write: D0-D7 schmitt trigger inputs")
+	flags.Bool("schmitt-ac", false, "write: C0-C9 schmitt trigger inputs")
+
+	flags.Parse(os.Args[2:])
+
+	options := []ftdi.Option{}
+	if *serial != "" {
+		options = append(options, ftdi.WithSerial(*serial))
+	}
+	if *path != "" {
+		options = append(options, ftdi.WithUSBPath(*path))
+	}
+
+	fi := ftdi.NewFTDI232H(0x0403, 0x6014, options...)
+	err := fi.OpenFirst()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer fi.Close()
+
+	current, err := fi.ReadEEPROM()
+	checksumErr := errors.Is(err, ftdi.ErrEEPROMChecksum)
+	if err != nil && !checksumErr {
+		log.Fatal(err)
+	}
+	if checksumErr {
+		log.Println(err)
+	}
+
+	switch command {
+	case "read":
+		fmt.Print(current)
+		if *hex {
+			image, err := fi.ReadEEPROMImage()
+			if err != nil {
+				log.Fatal(err)
+			}
+			dump(image)
+		}
+	case "write":
+		updated := *current
+		if *factory {
+			updated = *ftdi.NewEEPROM()
+		}
+
+		flags.Visit(func(f *flag.Flag) {
+			err := apply(&updated, f.Name, f.Value.String())
+			if err != nil {
+				log.Fatal(err)
+			}
+		})
+
+		diff := updated.Diff(current)
+		for _, line := range diff {
+			fmt.Println(line)
+		}
+		if len(diff) == 0 && !checksumErr {
+			log.Println("Nothing to change.")
+			return
+		}
+
+		if *dryRun {
+			log.Println("Dry run, nothing written.")
+			return
+		}
+
+		err = fi.WriteEEPROM(&updated)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Println("EEPROM written. Re-plug the device to apply.")
+	}
+}
+
+// apply sets the EEPROM setting behind a write flag.
+func apply(e *ftdi.EEPROM, name, value string) error {
+	var err error
+
+	switch name {
+	case "set-serial":
+		e.Serial = value
+		e.SerialEnabled = value != ""
+	case "manufacturer":
+		e.Manufacturer = value
+	case "description":
+		e.Description = value
+	case "vendor":
+		e.VendorID, err = parseID(value)
+	case "product":
+		e.ProductID, err = parseID(value)
+	case "max-power":
+		e.MaxPower, err = strconv.Atoi(value)
+	case "self-powered":
+		e.SelfPowered = value == "true"
+	case "power-save":
+		e.PowerSave = value == "true"
+	case "drive-ad":
+		e.DriveAD.Current, err = parseDrive(value)
+	case "drive-ac":
+		e.DriveAC.Current, err = parseDrive(value)
+	case "slow-slew-ad":
+		e.DriveAD.SlowSlew = value == "true"
+	case "slow-slew-ac":
+		e.DriveAC.SlowSlew = value == "true"
+	case "schmitt-ad":
+		e.DriveAD.Schmitt = value == "true"
+	case "schmitt-ac":
+		e.DriveAC.Schmitt = value == "true"
+	case "cbus":
+		for _, setting := range strings.Split(value, ",") {
+			pin, function, ok := strings.Cut(setting, "=")
+			if !ok {
+				return fmt.Errorf("bad CBUS setting %q, expected pin=function", setting)
+			}
+			n, err := strconv.Atoi(strings.TrimPrefix(strings.ToUpper(pin), "C"))
+			if err != nil {
+				return fmt.Errorf("bad CBUS pin %q", pin)
+			}
+			fn, err := ftdi.ParseCBUSFunction(function)
+			if err != nil {
+				return err
+			}
+			err = e.SetCBUS(ftdi.C0+gpio.Pin(n), fn)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return err
+}
+
+func parseID(value string) (uint16, error) {
+	id, err := strconv.ParseUint(value, 0, 16)
+	return uint16(id), err
+}
+
+func parseDrive(value string) (ftdi.DriveCurrent, error) {
+	switch value {
+	case "4":
+		return ftdi.Drive4mA, nil
+	case "8":
+		return ftdi.Drive8mA, nil
+	case "12":
+		return ftdi.Drive12mA, nil
+	case "16":
+		return ftdi.Drive16mA, nil
+	}
+	return 0, fmt.Errorf("bad drive strength %smA, expected 4, 8, 12 or 16", value)
+}
+
+func dump(image []byte) {
+	for addr := 0; addr < len(image); addr += 16 {
+		fmt.Printf("%02x: % x\n", addr, image[addr:addr+16])
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: eeprom read|write [flags], see eeprom read -h")
+	os.Exit(2)
+}
@@ -87,7 +87,10 @@ func main() {
 		//
 		// So we skip the second sleep above.
 
-		level := ft232h.ReadInput(ftdi.D7)
+		level, err := ft232h.ReadInput(ftdi.D7)
+		if err != nil {
+			log.Fatal(err)
+		}
 		if level != prevLevel {
 			if level == gpio.Low {
 				println("Pin D7 is LOW!")
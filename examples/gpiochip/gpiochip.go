@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/wdevore/hardware/gpio"
+	"github.com/wdevore/hardware/gpio/gpiochip"
+)
+
+// Lists the lines of a native GPIO chip, or blinks one line while
+// reporting presses of a button on another.
+//
+//	gpiochip [-chip gpiochip0]
+//	gpiochip [-chip gpiochip0] -led 17 -button 27
+//
+// The button is wired between its line and ground, the internal pull-up
+// keeps it high.
+func main() {
+	chipName := flag.String("chip", "gpiochip0", "chip name or device node")
+	led := flag.Int("led", -1, "offset of the line to blink")
+	button := flag.Int("button", -1, "offset of the line to watch")
+	flag.Parse()
+
+	chip, err := gpiochip.Open(*chipName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer chip.Close()
+
+	if *led < 0 {
+		fmt.Printf("%s [%s] %d lines\n", chip.Name, chip.Label, chip.Lines)
+		for offset := 0; offset < chip.Lines; offset++ {
+			status, err := chip.LineStatus(gpio.Pin(offset))
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(status)
+		}
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *button >= 0 {
+		line, err := chip.ConfiguredLine(gpio.Pin(*button), gpiochip.Config{
+			Bias:      gpiochip.BiasPullUp,
+			ActiveLow: true,
+			Debounce:  10 * time.Millisecond,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		events, err := line.Watch(ctx, gpio.RisingEdge)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		go func() {
+			for event := range events {
+				log.Printf("Button pressed (%s)\n", event)
+			}
+		}()
+	}
+
+	blink, err := chip.Line(gpio.Pin(*led))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("Press Ctrl-c to quit")
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			blink.Out(gpio.Low)
+			return
+		case <-ticker.C:
+			err = blink.Toggle()
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"log"
+
+	"github.com/wdevore/hardware/i2c"
+)
+
+// Wire D1 and D2 together for SDA, D0 is SCL. Both lines need pull-ups.
+func main() {
+	bus, err := i2c.NewI2C(0x0403, 0x06014, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = bus.Configure(i2c.StandardMode)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer bus.Close()
+
+	log.Println("Scanning bus...")
+	found, err := bus.Scan()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, address := range found {
+		log.Printf("Found device at %#02x\n", address)
+	}
+
+	log.Printf("Scan complete, (%d) device(s) found.\n", len(found))
+}
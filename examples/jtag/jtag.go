@@ -0,0 +1,53 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/wdevore/hardware/jtag"
+)
+
+// D0 is TCK, D1 TDI, D2 TDO and D3 TMS.
+// Optionally pass an SVF file to play after the chain is detected,
+// for example: go run jtag.go design.svf
+func main() {
+	chain, err := jtag.NewJTAG(0x0403, 0x06014, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = chain.Configure(1000000, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer chain.Close()
+
+	log.Println("Detecting chain...")
+	ids, err := chain.DetectChain()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for idx, id := range ids {
+		log.Printf("Device %d: %v\n", idx, id)
+	}
+
+	if len(os.Args) < 2 {
+		return
+	}
+
+	file, err := os.Open(os.Args[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	log.Printf("Playing %s...\n", os.Args[1])
+	err = chain.PlaySVF(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("SVF complete.")
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"log"
+
+	"github.com/wdevore/hardware/ftdi"
+)
+
+// Lists attached FTDI devices. Use a serial or path from the list with
+// ftdi.WithSerial or ftdi.WithUSBPath to open a specific board.
+func main() {
+	devices, err := ftdi.ListDevices(0x0403, 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, d := range devices {
+		log.Println(d)
+	}
+
+	log.Printf("(%d) device(s) found.\n", len(devices))
+}
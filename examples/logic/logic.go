@@ -0,0 +1,52 @@
+package main
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/wdevore/hardware/ftdi"
+	"github.com/wdevore/hardware/logic"
+)
+
+// Samples D0-D7 at 1MHz starting at a rising edge on D0, writes capture.vcd
+// and measures the signal on D0.
+func main() {
+	an, err := logic.NewAnalyzer(0x0403, 0x06014, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = an.Configure(logic.MaxRate)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer an.Close()
+
+	log.Println("Waiting for a rising edge on D0...")
+	capture, err := an.Capture(100000, 1000, logic.RisingEdge(ftdi.D0))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Captured %v\n", capture.Duration())
+
+	file, err := os.Create("capture.vcd")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	err = capture.WriteVCD(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	m, err := an.Measure(ftdi.D0, 50*time.Millisecond)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("D0: %v\n", m)
+}
@@ -27,7 +27,10 @@ func main() {
 	log.Println("Creating SPI device")
 	quit = false
 
-	spid := spi.NewSPI(0x0403, 0x06014, false)
+	spid, err := spi.NewSPI(0x0403, 0x06014, false)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	spid.EnableTrigger()
 
@@ -162,42 +165,42 @@ const (
 func initialize2(sp *spi.FtdiSPI) {
 	var err error
 
-	err = sp.Write([]byte{modeReg})
+	_, err = sp.Write([]byte{modeReg})
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = sp.Write([]byte{noDecode})
+	_, err = sp.Write([]byte{noDecode})
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = sp.Write([]byte{intensityReg})
+	_, err = sp.Write([]byte{intensityReg})
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = sp.Write([]byte{0x01})
+	_, err = sp.Write([]byte{0x01})
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = sp.Write([]byte{scanLimitReg})
+	_, err = sp.Write([]byte{scanLimitReg})
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = sp.Write([]byte{allColumns})
+	_, err = sp.Write([]byte{allColumns})
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = sp.Write([]byte{shutdownReg}) // Normal operation
+	_, err = sp.Write([]byte{shutdownReg}) // Normal operation
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = sp.Write([]byte{normal}) // Normal operation
+	_, err = sp.Write([]byte{normal}) // Normal operation
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -206,22 +209,22 @@ func initialize2(sp *spi.FtdiSPI) {
 func initialize(sp *spi.FtdiSPI) {
 	var err error
 
-	err = sp.Write([]byte{modeReg, noDecode})
+	_, err = sp.Write([]byte{modeReg, noDecode})
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = sp.Write([]byte{intensityReg, 0x01})
+	_, err = sp.Write([]byte{intensityReg, 0x01})
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = sp.Write([]byte{scanLimitReg, allColumns})
+	_, err = sp.Write([]byte{scanLimitReg, allColumns})
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = sp.Write([]byte{shutdownReg, normal}) // Normal operation
+	_, err = sp.Write([]byte{shutdownReg, normal}) // Normal operation
 	if err != nil {
 		log.Fatal(err)
 	}
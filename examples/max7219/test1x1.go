@@ -21,9 +21,9 @@ func superSimple(sp *spi.FtdiSPI) {
 
 	for i := 0; i < 10; i++ {
 		if on {
-			err = sp.Write(packetOn)
+			_, err = sp.Write(packetOn)
 		} else {
-			err = sp.Write(packetOff)
+			_, err = sp.Write(packetOff)
 		}
 		if err != nil {
 			log.Fatal(err)
@@ -42,7 +42,7 @@ func enableCol2WithPattern(sp *spi.FtdiSPI) {
 
 	packet := []byte{digit1Reg, pattern}
 
-	err := sp.Write(packet)
+	_, err := sp.Write(packet)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -73,7 +73,7 @@ func random(sp *spi.FtdiSPI) {
 
 		packet := []byte{col, pattern}
 
-		err := sp.Write(packet)
+		_, err := sp.Write(packet)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -96,7 +96,7 @@ func random2(sp *spi.FtdiSPI) {
 
 		packet := []byte{col, row}
 
-		err := sp.Write(packet)
+		_, err := sp.Write(packet)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -117,7 +117,7 @@ func random3(sp *spi.FtdiSPI) {
 
 			packet := []byte{byte(col), row}
 
-			err := sp.Write(packet)
+			_, err := sp.Write(packet)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -138,9 +138,9 @@ func displayOn(sp *spi.FtdiSPI, on uint8) {
 	var err error
 
 	if on == 1 {
-		err = sp.Write(packetOn)
+		_, err = sp.Write(packetOn)
 	} else {
-		err = sp.Write(packetOff)
+		_, err = sp.Write(packetOff)
 	}
 	if err != nil {
 		log.Fatal(err)
@@ -165,7 +165,7 @@ func horizontalScanBar(sp *spi.FtdiSPI) {
 
 		packet := []byte{byte(col), 0xff}
 
-		err := sp.Write(packet)
+		_, err := sp.Write(packet)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -197,7 +197,7 @@ func verticalScanBar(sp *spi.FtdiSPI) {
 		for c := 1; c < 9; c++ {
 			packet := []byte{byte(c), 1 << byte(row)}
 
-			err := sp.Write(packet)
+			_, err := sp.Write(packet)
 			if err != nil {
 				log.Fatal(err)
 			}
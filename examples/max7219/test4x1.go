@@ -41,7 +41,7 @@ func initialize4(sp *spi.FtdiSPI) {
 
 	sp.AssertChipSelect()
 	for i := 0; i < 4; i++ {
-		err = sp.Write([]byte{modeReg, noDecode})
+		_, err = sp.Write([]byte{modeReg, noDecode})
 		if err != nil {
 			panic(err)
 		}
@@ -50,7 +50,7 @@ func initialize4(sp *spi.FtdiSPI) {
 
 	sp.AssertChipSelect()
 	for i := 0; i < 4; i++ {
-		err = sp.Write([]byte{intensityReg, 0x01})
+		_, err = sp.Write([]byte{intensityReg, 0x01})
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -59,7 +59,7 @@ func initialize4(sp *spi.FtdiSPI) {
 
 	sp.AssertChipSelect()
 	for i := 0; i < 4; i++ {
-		err = sp.Write([]byte{scanLimitReg, allColumns})
+		_, err = sp.Write([]byte{scanLimitReg, allColumns})
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -68,7 +68,7 @@ func initialize4(sp *spi.FtdiSPI) {
 
 	sp.AssertChipSelect()
 	for i := 0; i < 4; i++ {
-		err = sp.Write([]byte{shutdownReg, normal}) // Normal operation
+		_, err = sp.Write([]byte{shutdownReg, normal}) // Normal operation
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -137,9 +137,9 @@ func displayOn4(sp *spi.FtdiSPI, on uint8) {
 	for i := 0; i < 4; i++ {
 
 		if on == 1 {
-			err = sp.Write(packetOn)
+			_, err = sp.Write(packetOn)
 		} else {
-			err = sp.Write(packetOff)
+			_, err = sp.Write(packetOff)
 		}
 		if err != nil {
 			log.Fatal(err)
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/wdevore/hardware/ftdi"
+)
+
+// Fades a LED wired from D7 to ground (through a 330 ohm resistor) in and
+// out using the pattern generator. The adapter stays in MPSSE mode, so SPI
+// could be used at the same time.
+func main() {
+	ft232h := ftdi.NewFTDI232H(0x0403, 0x06014)
+
+	err := ft232h.Initialize(false)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = ft232h.Configure(false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer ft232h.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	led := byte(1 << (ftdi.D7 - ftdi.D0))
+
+	pattern, err := ftdi.PWM(led, 1000, 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	generator := ft232h.NewGenerator("LED", ftdi.GenerateMPSSE)
+	err = generator.Start(ctx, pattern)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("Press Ctrl-c to quit")
+
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+
+	duty, step := 0.0, 0.02
+	for generator.Running() {
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+
+		duty += step
+		if duty >= 1 || duty <= 0 {
+			step = -step
+			duty += step
+		}
+
+		pattern, _ = ftdi.PWM(led, 1000, duty)
+		generator.Set(pattern)
+	}
+
+	if err := generator.Err(); err != context.Canceled {
+		log.Println(err)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -12,7 +13,6 @@ import (
 	"github.com/wdevore/hardware/ftdi/devices/ra8875"
 )
 
-var quit = false
 var keyPressed rune
 
 func main() {
@@ -26,25 +26,25 @@ func main() {
 	// D2 - Serial data input.  This is for reading a serial signal, like the MISO line in a SPI connection.
 	// --> D3 - Serial select signal.  This is a chip select or chip enable signal to tell a connected device that the FT232H is ready to talk to it.
 
-	ra := ra8875.NewRA8875Default(devices.D800x480)
-
+	// ctx is canceled on ctrl-C which aborts initialization or, later,
+	// any polling the driver is waiting on.
 	// Note this doesn't work when termbox-go is used
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ra, err := ra8875.NewRA8875Context(ctx, devices.D800x480)
+	if err != nil {
+		log.Fatalf("Unable to create RA8875 component: %v\n", err)
+	}
+
+	ra.SetContext(ctx)
 
 	go func(ra ra8875.RA8875) {
-		<-c
+		<-ctx.Done()
 		log.Println("\nReceived ctrl-C, quiting.")
-		ra.Quit()
 		exitProg(ra)
 	}(ra)
 
-	if ra == nil {
-		panic("Unable to create RA8875 component")
-	}
-
-	defer ra.Close()
-
 	// log.Printf("Display dimensions: %d x %d\n", ra.Width, ra.Height)
 	log.Println("Beginning test.")
 
@@ -52,6 +52,8 @@ func main() {
 
 	log.Println("Press 'Enter' to continue...")
 	bufio.NewReader(os.Stdin).ReadBytes('\n')
+
+	exitProg(ra)
 }
 
 func exitProg(ra ra8875.RA8875) {
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/wdevore/hardware/ftdi"
+	"github.com/wdevore/hardware/ftdi/devices"
+	"github.com/wdevore/hardware/ftdi/devices/st7735"
+	"github.com/wdevore/hardware/gpio"
+	"github.com/wdevore/hardware/spi"
+)
+
+// Shares one FT232H between a ST7735R display and a SPI flash chip.
+//
+//	D3  display CS    D4  display reset    D5  display D/C
+//	D6  flash CS
+func main() {
+	bus, err := spi.NewBus(0x0403, 0x06014, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer bus.Close()
+
+	display, err := bus.Device(ftdi.D3, 16000000, spi.Mode0, spi.MSBFirst)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	flash, err := bus.Device(ftdi.D6, 1000000, spi.Mode3, spi.MSBFirst)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	st := st7735.NewST7735R(ftdi.D5, ftdi.D4, devices.GreenTab, devices.D128x128)
+	err = st.InitializeConn(context.Background(), display, 16000000, gpio.DefaultPin, devices.Orientation0, devices.RGBOrder)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// The display and the flash take turns, each with its own mode and clock.
+	id := make([]byte, 4)
+	for _, color := range []uint16{0xf800, 0x07e0, 0x001f} {
+		st.FillScreen(color)
+
+		// JEDEC ID: command 0x9F then manufacturer, type and capacity.
+		err = flash.Tx([]byte{0x9f, 0, 0, 0}, id)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("flash id: % x\n", id[1:])
+	}
+}
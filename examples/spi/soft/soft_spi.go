@@ -18,7 +18,10 @@ func basicTest() {
 	var err error
 
 	log.Println("Creating Soft SPI device")
-	soft := spi.NewSoftSPI(0x0403, 0x06014, false)
+	soft, err := spi.NewSoftSPI(0x0403, 0x06014, false)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	defer soft.Close()
 
@@ -40,11 +43,16 @@ func basicTest() {
 		panic(err)
 	}
 
-	// fmt.Printf("%08b\n", 0x80)
-	soft.Write(0x7b)
+	err = soft.Tx([]byte{0x7b}, nil)
+	if err != nil {
+		panic(err)
+	}
 	fmt.Println("writing done")
 
-	var pin6 byte
-	pin6, err = soft.Read()
-	fmt.Printf("pin6: %08b\n", pin6)
+	response := make([]byte, 1)
+	err = soft.Tx([]byte{0x00}, response)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("response: %08b\n", response[0])
 }
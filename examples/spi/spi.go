@@ -14,7 +14,10 @@ import (
 
 func main() {
 	log.Println("Creating SPI device")
-	spid := spi.NewSPI(0x0403, 0x06014, false)
+	spid, err := spi.NewSPI(0x0403, 0x06014, false)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// Create a SPI interface from the FT232H using pin 8 (C0) as chip select.
 	// Use a clock speed of 5mhz, SPI mode 0, and most significant bit first.
@@ -70,7 +73,7 @@ func main() {
 	// spid.OutputLow(ftdi.D3) // chip select
 
 	log.Println("WriteCommand: writing byte command")
-	err := spid.Write(data1)
+	_, err = spid.Write(data1)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -82,7 +85,7 @@ func main() {
 	fi.OutputHigh(ftdi.D5) // High = data
 	// spid.OutputLow(ftdi.D3)
 
-	err = spid.Write(data2)
+	_, err = spid.Write(data2)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -96,13 +99,13 @@ func main() {
 		// time.Sleep(time.Millisecond * 10)
 
 		fi.OutputLow(ftdi.D5)
-		err := spid.Write(data1)
+		_, err := spid.Write(data1)
 		if err != nil {
 			log.Printf("Write failed: %v\n", err)
 			break
 		}
 		fi.OutputHigh(ftdi.D5)
-		err = spid.Write(data2)
+		_, err = spid.Write(data2)
 		if err != nil {
 			log.Printf("Write failed: %v\n", err)
 			break
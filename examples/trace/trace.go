@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/wdevore/hardware/ftdi"
+	"github.com/wdevore/hardware/ftdi/sim"
+)
+
+// Prints and replays traces recorded with FTDI232H.StartTrace or
+// ftdi.WithTrace.
+//
+//	trace dump display.trc
+//	trace replay [-sim] [-serial FT0RN5XA] [-realtime] [-verify] display.trc
+func main() {
+	if len(os.Args) < 2 || (os.Args[1] != "dump" && os.Args[1] != "replay") {
+		usage()
+	}
+
+	command := os.Args[1]
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+
+	simulate := flags.Bool("sim", false, "replay: to a simulated FT232H instead of a device")
+	serial := flags.String("serial", "", "replay: to the device with this serial number")
+	path := flags.String("path", "", "replay: to the device at this USB path, for example 1-2.3")
+	realtime := flags.Bool("realtime", false, "replay: keep the recorded timing")
+	verify := flags.Bool("verify", false, "replay: stop at the first read that differs")
+	verbose := flags.Bool("v", false, "replay: print each record")
+
+	flags.Parse(os.Args[2:])
+	if flags.NArg() != 1 {
+		usage()
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	switch command {
+	case "dump":
+		reader, err := ftdi.NewTraceReader(file)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for {
+			record, err := reader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(record)
+		}
+	case "replay":
+		var device ftdi.Transport
+		if *simulate {
+			device = sim.New()
+		} else {
+			selector := ftdi.Selector{Serial: *serial, Path: *path}
+			device, err = ftdi.OpenUSBDevice(0x0403, 0x6014, selector, ftdi.ChannelAny)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		defer device.Close()
+
+		options := ftdi.ReplayOptions{Realtime: *realtime, Verify: *verify}
+		if *verbose {
+			options.Record = func(index int, record ftdi.TraceRecord) {
+				fmt.Printf("%6d %s\n", index, record)
+			}
+		}
+
+		err = ftdi.Replay(context.Background(), file, device, options)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Println("Replay complete.")
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: trace dump|replay [flags] file, see trace replay -h")
+	os.Exit(2)
+}
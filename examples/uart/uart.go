@@ -0,0 +1,43 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/wdevore/hardware/ftdi"
+	"github.com/wdevore/hardware/uart"
+)
+
+// D0 is TXD and D1 is RXD. Echoes a device's debug console to stdout.
+func main() {
+	port, err := uart.NewUART(0x0403, 0x06014, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = port.Configure(ftdi.SerialConfig{
+		Baudrate: 115200,
+		DataBits: ftdi.DataBits8,
+		Parity:   ftdi.ParityNone,
+		StopBits: ftdi.StopBits1,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer port.Close()
+
+	port.SetReadTimeout(10 * time.Second)
+
+	_, err = port.Write([]byte("\r\n"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = io.Copy(os.Stdout, port)
+	if err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/wdevore/hardware/ftdi"
+)
+
+// Prints or installs a udev rule so FTDI devices can be used without root
+// and without unloading the kernel serial driver for every adapter.
+//
+//	udev [-vendor 0x0403] [-product 0x6014] [-serial FT0RN5XA] [-group plugdev]
+//	sudo udev -install [-file /etc/udev/rules.d/99-ftdi.rules] ...
+//
+// Re-plug the device after installing if it was already open.
+func main() {
+	vendor := flag.String("vendor", "0x0403", "USB vendor id")
+	product := flag.String("product", "0x6014", "USB product id")
+	serial := flag.String("serial", "", "only match the device with this serial number")
+	group := flag.String("group", "plugdev", "group given read/write access")
+	install := flag.Bool("install", false, "write the rule and reload udev, needs root")
+	file := flag.String("file", ftdi.UdevRulesPath, "rule file written by -install")
+	flag.Parse()
+
+	vendorID, err := strconv.ParseUint(*vendor, 0, 16)
+	if err != nil {
+		log.Fatalf("vendor %q: %v", *vendor, err)
+	}
+	productID, err := strconv.ParseUint(*product, 0, 16)
+	if err != nil {
+		log.Fatalf("product %q: %v", *product, err)
+	}
+
+	rule := ftdi.UdevRule(int(vendorID), int(productID), *serial, *group)
+
+	if !*install {
+		fmt.Print(rule)
+		return
+	}
+
+	err = ftdi.InstallUdevRule(*file, rule)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Installed %s\n", *file)
+}
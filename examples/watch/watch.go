@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/wdevore/hardware/ftdi"
+	"github.com/wdevore/hardware/gpio"
+)
+
+// Reports presses of buttons wired between C0-C3 and ground. Each pin
+// needs a pull-up resistor to 3.3V.
+func main() {
+	ft232h := ftdi.NewFTDI232H(0x0403, 0x06014)
+
+	err := ft232h.Initialize(false)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = ft232h.Configure(false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer ft232h.Close()
+
+	buttons := []gpio.Pin{ftdi.C0, ftdi.C1, ftdi.C2, ftdi.C3}
+
+	watcher := gpio.NewWatcher(ft232h, 5*time.Millisecond)
+	for _, pin := range buttons {
+		err = ft232h.ConfigPin(pin, gpio.Input)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = watcher.Watch(pin, gpio.FallingEdge, 20*time.Millisecond)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Println("Press Ctrl-c to quit")
+
+	events, err := watcher.Start(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for event := range events {
+		log.Printf("Button C%d pressed (%s)\n", event.Pin-ftdi.C0, event)
+	}
+
+	if err := watcher.Err(); err != context.Canceled {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,20 @@
+package devices
+
+import (
+	"context"
+	"time"
+)
+
+// SleepContext pauses for [duration] or until [ctx] is done, whichever is
+// first. It returns the context's error if the pause was cut short.
+func SleepContext(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -1,10 +1,13 @@
 package hx8357
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log"
 	"time"
 
+	"github.com/wdevore/hardware/ftdi"
 	"github.com/wdevore/hardware/ftdi/devices"
 	"github.com/wdevore/hardware/gpio"
 	"github.com/wdevore/hardware/spi"
@@ -84,22 +87,29 @@ const (
 )
 
 var (
-	colorPush     = []byte{0x00, 0x00}
-	writeBuf      = []byte{0x00}
-	addWindowBuf  = []byte{0x00, 0x00, 0x00, 0x00}
 	bytesPerPixel = 2
 )
 
 // HX8357 represents the TFT/LCD controller chip.
 type HX8357 struct {
-	// Uses the USB FTDI232 SPI object
-	spi *spi.FtdiSPI
+	// Uses a SPI connection, usually the USB FTDI232 SPI object
+	spi spi.Conn
+
+	// queue batches D/C changes and SPI writes, into single USB writes over
+	// a FtdiSPI.
+	queue *spi.Batch
 
 	dc    gpio.Pin // Data/Command pin
 	reset gpio.Pin
+	// The D/C and reset lines, resolved from dc and reset on the FT232H
+	// unless set by SetLines.
+	dcLine    gpio.Line
+	resetLine gpio.Line
 
 	tab        devices.TabColor
 	dimensions devices.Dimensions
+	// orientation is the last SetRotation, redone after a reconnect.
+	orientation devices.RotationMode
 
 	Width  uint16
 	Height uint16
@@ -115,12 +125,10 @@ type HX8357 struct {
 	// Although 60FPS only leaves about 6ms for your code which is pretty tight.
 	pushBuffer []byte
 
-	lineBlockSize int
-	lines         int
-	chunkSize     int
-
-	// fmt.Printf("lineBlock %d, chunksize: %d\n", lines, chunkSize)
-	chunkBuf []byte
+	// Per device command buffers, so several displays can be driven at once.
+	colorPush    [2]byte
+	writeBuf     [1]byte
+	addWindowBuf [4]byte
 }
 
 // Screen initialization commands and arguments are organized in these tables
@@ -137,25 +145,20 @@ type commando struct {
 	Delay   int // 255 = 500ms delay max
 }
 
-// Initialize configures FTDI and SPI, and initializes HX8357
-// Vendor/Product example would be: 0x0403, 0x06014 for the FTDI chip
+// Initialize configures SPI over [conn], and initializes HX8357
 // A clock frequency of 0 means default to max = 30MHz
-func (hx *HX8357) initialize(vender, product, clockFreq int, chipSelect gpio.Pin) error {
-
-	// Create a SPI interface from the FT232H
-	hx.spi = spi.NewSPI(vender, product, false)
+func (hx *HX8357) initialize(ctx context.Context, conn spi.Conn, clockFreq int, chipSelect gpio.Pin) error {
+	hx.spi = conn
 	//hx.spi.DebugInit()
 
-	if hx.spi == nil {
-		return errors.New("HX8357: Failed to create SPI object")
-	}
+	hx.queue = spi.NewBatch(conn)
 
 	if clockFreq == 0 {
 		clockFreq = devices.Max30MHz
 	}
 	log.Printf("HX8357: Configuring for a clock of (%d)MHz\n", clockFreq/1000000)
 
-	err := hx.configure(chipSelect, clockFreq)
+	err := hx.configure(ctx, chipSelect, clockFreq)
 	if err != nil {
 		return err
 	}
@@ -165,10 +168,20 @@ func (hx *HX8357) initialize(vender, product, clockFreq int, chipSelect gpio.Pin
 	return nil
 }
 
+// newSPI creates the FTDI232H SPI connection of Initialize.
+// Vendor/Product example would be: 0x0403, 0x06014 for the FTDI chip
+func newSPI(vender, product int, options ...ftdi.Option) (spi.Conn, error) {
+	conn, err := spi.NewSPI(vender, product, false, options...)
+	if err != nil {
+		return nil, fmt.Errorf("HX8357: Failed to create SPI object: %w", err)
+	}
+	return conn, nil
+}
+
 // Configure sets up the SPI component and initializes the ST7735
-func (hx *HX8357) configure(chipSelect gpio.Pin, clockFreq int) error {
+func (hx *HX8357) configure(ctx context.Context, chipSelect gpio.Pin, clockFreq int) error {
 	log.Println("HX8357: Configuring SPI")
-	err := hx.spi.Configure(chipSelect, clockFreq, spi.Mode0, spi.MSBFirst)
+	err := hx.spi.ConfigureContext(ctx, chipSelect, clockFreq, spi.Mode0, spi.MSBFirst)
 
 	if err != nil {
 		log.Println("HX8357: Configure FAILED.")
@@ -182,40 +195,89 @@ func (hx *HX8357) close() error {
 	return hx.spi.Close()
 }
 
-// commonInit setups common pin configurations
-func (hx *HX8357) commonInit(cmdList []commando) error {
-	sp := hx.spi
+// SetLines drives D/C and reset through [dataCommand] and [reset], for
+// example pins of an I/O expander, instead of the pins given to the
+// constructor. [reset] may be nil. Call it before Initialize. A SPI
+// connection that isn't a gpio.Provider needs it.
+func (hx *HX8357) SetLines(dataCommand, reset gpio.Line) {
+	hx.dcLine = dataCommand
+	hx.resetLine = reset
+}
+
+// resolveLines gets the lines of the SPI connection for the pins SetLines
+// didn't replace.
+func (hx *HX8357) resolveLines() error {
+	if hx.dcLine != nil && (hx.resetLine != nil || hx.reset == gpio.NoPin) {
+		return nil
+	}
+
+	provider, ok := hx.spi.(gpio.Provider)
+	if !ok {
+		return errors.New("hx8357: the SPI connection has no pins for D/C and reset, use SetLines")
+	}
+
+	var err error
+	if hx.dcLine == nil {
+		hx.dcLine, err = provider.Line(hx.dc)
+		if err != nil {
+			return fmt.Errorf("hx8357: D/C: %w", err)
+		}
+	}
+
+	if hx.resetLine == nil && hx.reset != gpio.NoPin {
+		hx.resetLine, err = provider.Line(hx.reset)
+		if err != nil {
+			return fmt.Errorf("hx8357: reset: %w", err)
+		}
+	}
+
+	return nil
+}
 
+// commonInit setups common pin configurations
+func (hx *HX8357) commonInit(ctx context.Context, cmdList []commando) error {
 	// The HX8357 communicates with TFT device (aka HX8357D device) through the FTDI235H device
 	// via the SPI protocol. However, the SPI protocol only accounts for, at most, 4 pins, anything
 	// else needs to added manually--and controlled manually.
 
-	// Setup extra pins for D/C and Reset. For this we need to interface with the FTDI chip
-	fi := sp.GetFTDI()
+	// Setup extra pins for D/C and Reset. Unless SetLines gave others they are
+	// pins of the SPI connection, FTDI pins for a FtdiSPI.
+	err := hx.resolveLines()
+	if err != nil {
+		return err
+	}
 
-	pins := []gpio.PinConfiguration{
-		{Pin: hx.dc, Direction: gpio.Output, Value: gpio.Z},
+	err = gpio.Claim("HX8357", hx.dcLine, hx.resetLine)
+	if err != nil {
+		return err
 	}
-	fi.ConfigPins(pins, true)
+
+	q := hx.queue
+	q.Reset()
+
+	q.OutputLine(hx.dcLine, gpio.High)
 
 	// toggle RST low to reset and CS low so it'll listen to us
-	sp.AssertChipSelect()
+	q.AssertChipSelect()
 
-	if hx.reset != gpio.NoPin {
-		fi.ConfigPin(hx.reset, gpio.Output)
+	if hx.resetLine != nil {
+		q.OutputLine(hx.resetLine, gpio.High)
+		q.Delay(time.Millisecond * 100)
 
-		fi.OutputHigh(hx.reset)
-		time.Sleep(time.Millisecond * 100)
+		q.OutputLine(hx.resetLine, gpio.Low)
+		q.Delay(time.Millisecond * 100)
 
-		fi.OutputLow(hx.reset)
-		time.Sleep(time.Millisecond * 100)
+		q.OutputLine(hx.resetLine, gpio.High)
+		q.Delay(time.Millisecond * 150)
+	}
 
-		fi.OutputHigh(hx.reset)
-		time.Sleep(time.Millisecond * 150)
+	err = q.FlushContext(ctx)
+	if err != nil {
+		return err
 	}
 
 	if cmdList != nil {
-		hx.issueCommands(cmdList)
+		return hx.issueCommands(ctx, cmdList)
 	}
 
 	return nil
@@ -224,24 +286,33 @@ func (hx *HX8357) commonInit(cmdList []commando) error {
 // ----------------------------------------------------
 // Commands
 // ----------------------------------------------------
-func (hx *HX8357) issueCommands(cmdList []commando) {
+
+// issueCommands queues the whole table and sends it in as few USB writes as
+// the delays allow.
+func (hx *HX8357) issueCommands(ctx context.Context, cmdList []commando) error {
+	q := hx.queue
+	q.Reset()
+
 	for _, com := range cmdList {
-		err := hx.WriteCommand(com.Command)
-		if err != nil {
-			log.Printf("HX8357: issueCommands failed to write command: %v\n", err)
-			return
-		}
+		hx.queueCommand(q, com.Command)
 
-		for _, arg := range com.Args {
-			hx.WriteData(arg)
+		if len(com.Args) > 0 {
+			hx.queueData(q, com.Args)
 		}
 
 		if com.Delayed {
 			d := time.Millisecond * time.Duration(com.Delay)
 			// log.Printf("HX8357 issueCommands delaying for (%d)ms\n", time.Duration(com.Delay))
-			time.Sleep(d)
+			q.Delay(d)
 		}
 	}
+
+	err := q.FlushContext(ctx)
+	if err != nil {
+		log.Printf("HX8357: issueCommands failed to write commands: %v\n", err)
+	}
+
+	return err
 }
 
 // ----------------------------------------------------
@@ -251,6 +322,8 @@ func (hx *HX8357) issueCommands(cmdList []commando) {
 // SetRotation re-orients the display at 90 degree rotations.
 // Typically this method is called last during the initialization sequence.
 func (hx *HX8357) SetRotation(orieo devices.RotationMode) {
+	hx.orientation = orieo
+
 	hx.WriteCommand(MADCTL)
 
 	switch orieo {
@@ -289,18 +362,17 @@ func (hx *HX8357) InvertDisplay(inv bool) {
 // you can leave CS low for the entire time and thus save
 // on bandwidth.
 func (hx *HX8357) SetConstantCSAssert(constant bool) {
-	hx.spi.ConstantCSAssert = constant
+	hx.spi.SetConstantCSAssert(constant)
 }
 
 // WriteCommand writes a command via SPI protocol
 func (hx *HX8357) WriteCommand(command byte) error {
-	sp := hx.spi
-	fi := sp.GetFTDI()
+	q := hx.queue
+	q.Reset()
 
-	fi.OutputLow(hx.dc) // Low = command
+	hx.queueCommand(q, command)
 
-	writeBuf[0] = command
-	err := hx.spi.Write(writeBuf)
+	err := q.Flush()
 	if err != nil {
 		log.Println("Failed to write command.")
 		return err
@@ -311,21 +383,66 @@ func (hx *HX8357) WriteCommand(command byte) error {
 
 // WriteData writes data to the device via SPI
 func (hx *HX8357) WriteData(data byte) {
-	sp := hx.spi
-	fi := sp.GetFTDI()
-	fi.OutputHigh(hx.dc) // High = data
-
-	writeBuf[0] = data
-	sp.Write(writeBuf)
+	hx.writeBuf[0] = data
+	hx.WriteDataChunk(hx.writeBuf[:])
 }
 
 // WriteDataChunk is a slightly more efficient version of WriteData
 func (hx *HX8357) WriteDataChunk(data []byte) {
-	sp := hx.spi
-	fi := sp.GetFTDI()
-	fi.OutputHigh(hx.dc) // High = data
+	q := hx.queue
+	q.Reset()
+
+	hx.queueData(q, data)
+
+	q.Flush()
+}
+
+// queueCommand appends D/C low (command) and the command byte.
+func (hx *HX8357) queueCommand(q *spi.Batch, command byte) {
+	q.OutputLine(hx.dcLine, gpio.Low) // Low = command
+
+	hx.writeBuf[0] = command
+	q.Write(hx.writeBuf[:])
+}
+
+// queueData appends D/C high (data) and the data.
+func (hx *HX8357) queueData(q *spi.Batch, data []byte) {
+	q.OutputLine(hx.dcLine, gpio.High) // High = data
 
-	sp.Write(data)
+	q.Write(data)
+}
+
+// queueAddrWindow appends the column/page address and RAM write commands.
+func (hx *HX8357) queueAddrWindow(q *spi.Batch, x, y, w, h uint16) {
+	xa := (uint32(x) << 16) | uint32(x+w-1)
+	ya := (uint32(y) << 16) | uint32(y+h-1)
+
+	hx.queueCommand(q, CASET) // Column addr set
+	// -- -- -- --
+	hx.addWindowBuf[0] = byte((xa >> 24) & 0xff)
+	hx.addWindowBuf[1] = byte((xa >> 16) & 0xff)
+	hx.addWindowBuf[2] = byte((xa >> 8) & 0xff)
+	hx.addWindowBuf[3] = byte(xa & 0xff)
+	hx.queueData(q, hx.addWindowBuf[:])
+
+	hx.queueCommand(q, PASET) // Row addr set
+	hx.addWindowBuf[0] = byte((ya >> 24) & 0xff)
+	hx.addWindowBuf[1] = byte((ya >> 16) & 0xff)
+	hx.addWindowBuf[2] = byte((ya >> 8) & 0xff)
+	hx.addWindowBuf[3] = byte(ya & 0xff)
+	hx.queueData(q, hx.addWindowBuf[:])
+
+	hx.queueCommand(q, RAMWR) // write to RAM
+}
+
+// queueColorRun appends [count] pixels of the same color as one data block.
+func (hx *HX8357) queueColorRun(q *spi.Batch, color uint16, count int) {
+	run := make([]byte, count*bytesPerPixel)
+	for i := 0; i < len(run); i += bytesPerPixel {
+		run[i] = byte((color >> 8) & 0xff)
+		run[i+1] = byte(color & 0xff)
+	}
+	hx.queueData(q, run)
 }
 
 // ----------------------------------------------------
@@ -335,39 +452,26 @@ func (hx *HX8357) WriteDataChunk(data []byte) {
 // SetAddrWindow set row and column address of where pixels will be written.
 // (aka setDrawPosition)
 func (hx *HX8357) SetAddrWindow(x, y, w, h uint16) {
-	xa := (uint32(x) << 16) | uint32(x+w-1)
-	ya := (uint32(y) << 16) | uint32(y+h-1)
+	q := hx.queue
+	q.Reset()
 
-	hx.WriteCommand(CASET) // Column addr set
-	// -- -- -- --
-	addWindowBuf[0] = byte((xa >> 24) & 0xff)
-	addWindowBuf[1] = byte((xa >> 16) & 0xff)
-	addWindowBuf[2] = byte((xa >> 8) & 0xff)
-	addWindowBuf[3] = byte(xa & 0xff)
-	hx.WriteDataChunk(addWindowBuf)
-
-	hx.WriteCommand(PASET) // Row addr set
-	addWindowBuf[0] = byte((ya >> 24) & 0xff)
-	addWindowBuf[1] = byte((ya >> 16) & 0xff)
-	addWindowBuf[2] = byte((ya >> 8) & 0xff)
-	addWindowBuf[3] = byte(ya & 0xff)
-	hx.WriteDataChunk(addWindowBuf)
+	hx.queueAddrWindow(q, x, y, w, h)
 
-	hx.WriteCommand(RAMWR) // write to RAM
+	q.Flush()
 }
 
 // PushColor writes a 16bit color value based on the current cursor/draw position.
 // The "cursor" position is set by SetAddrWindow()
 // (aka writePixel)
 func (hx *HX8357) PushColor(color uint16) {
-	sp := hx.spi
-	fi := sp.GetFTDI()
+	q := hx.queue
+	q.Reset()
 
-	fi.OutputHigh(hx.dc)
+	hx.colorPush[0] = byte((color >> 8) & 0xff)
+	hx.colorPush[1] = byte(color & 0xff)
+	hx.queueData(q, hx.colorPush[:])
 
-	colorPush[0] = byte((color >> 8) & 0xff)
-	colorPush[1] = byte(color & 0xff)
-	sp.Write(colorPush)
+	q.Flush()
 }
 
 // DrawPixel draws to device only.
@@ -377,18 +481,18 @@ func (hx *HX8357) DrawPixel(x, y uint16, color uint16) {
 		return
 	}
 
-	sp := hx.spi
-	fi := sp.GetFTDI()
+	q := hx.queue
+	q.Reset()
 
 	// First set "cursor"/"draw position"
-	hx.SetAddrWindow(x, y, 1, 1)
-
-	fi.OutputHigh(hx.dc)
+	hx.queueAddrWindow(q, x, y, 1, 1)
 
 	// Now draw.
-	colorPush[0] = byte((color >> 8) & 0xff)
-	colorPush[1] = byte(color & 0xff)
-	sp.Write(colorPush)
+	hx.colorPush[0] = byte((color >> 8) & 0xff)
+	hx.colorPush[1] = byte(color & 0xff)
+	hx.queueData(q, hx.colorPush[:])
+
+	q.Flush()
 }
 
 // DrawFastVLine draws a vertical line only
@@ -404,20 +508,13 @@ func (hx *HX8357) DrawFastVLine(x, y, h uint16, color uint16) {
 		h = hx.Height - y
 	}
 
-	hx.SetAddrWindow(x, y, x, y+h)
-
-	sp := hx.spi
-	fi := sp.GetFTDI()
-
-	colorPush[0] = byte((color >> 8) & 0xff)
-	colorPush[1] = byte(color & 0xff)
+	q := hx.queue
+	q.Reset()
 
-	fi.OutputHigh(hx.dc)
+	hx.queueAddrWindow(q, x, y, x, y+h)
+	hx.queueColorRun(q, color, int(h))
 
-	for h > 0 {
-		sp.Write(colorPush)
-		h--
-	}
+	q.Flush()
 }
 
 // DrawFastHLine draws a horizontal line only
@@ -433,20 +530,13 @@ func (hx *HX8357) DrawFastHLine(x, y, w uint16, color uint16) {
 		w = hx.Width - x
 	}
 
-	hx.SetAddrWindow(x, y, x+w, y)
-
-	sp := hx.spi
-	fi := sp.GetFTDI()
-
-	colorPush[0] = byte((color >> 8) & 0xff)
-	colorPush[1] = byte(color & 0xff)
+	q := hx.queue
+	q.Reset()
 
-	fi.OutputHigh(hx.dc)
+	hx.queueAddrWindow(q, x, y, x+w, y)
+	hx.queueColorRun(q, color, int(w))
 
-	for w > 0 {
-		sp.Write(colorPush)
-		w--
-	}
+	q.Flush()
 }
 
 // FillScreen fills the entire display area with "color"
@@ -454,8 +544,7 @@ func (hx *HX8357) FillScreen(color uint16) {
 	hx.FillRectangle(0, 0, hx.Width, hx.Height, color)
 }
 
-// FillRectangle is a very slow fill.
-// Filling the entire screen takes 12+ seconds!
+// FillRectangle fills a rectangle with a single batched write.
 func (hx *HX8357) FillRectangle(x, y, w, h uint16, color uint16) {
 	// log.Printf("%d x %d\n", st.Width, st.Height)
 	// rudimentary clipping (drawChar w/big text requires this)
@@ -472,20 +561,13 @@ func (hx *HX8357) FillRectangle(x, y, w, h uint16, color uint16) {
 		h = hx.Height - y
 	}
 
-	hx.SetAddrWindow(x, y, w, h)
-
-	sp := hx.spi
-	fi := sp.GetFTDI()
+	q := hx.queue
+	q.Reset()
 
-	fi.OutputHigh(hx.dc)
+	hx.queueAddrWindow(q, x, y, w, h)
+	hx.queueColorRun(q, color, int(w)*int(h))
 
-	for y := h; y > 0; y-- {
-		for x := w; x > 0; x-- {
-			colorPush[0] = byte((color >> 8) & 0xff)
-			colorPush[1] = byte(color & 0xff)
-			sp.Write(colorPush)
-		}
-	}
+	q.Flush()
 }
 
 // ----------------------------------------------------
@@ -522,39 +604,33 @@ func (hx *HX8357) FillRectangle(x, y, w, h uint16, color uint16) {
 // |--------- overlay B ------|
 // |--------------------------|
 
-// Blit writes line chunks so it is a bit faster than Blit3
+// Blit writes the whole pushBuffer in one data write. The connection splits
+// it in chunks its SPI engine accepts, so it is a bit faster than Blit3.
 func (hx *HX8357) Blit() {
-	sp := hx.spi
-	fi := sp.GetFTDI()
-
-	chI := 0
-	lI := 0
-	// Each chunk is N "lines" of pushBuffer data
-	for lineChunk := 0; lineChunk < hx.lineBlockSize; lineChunk++ {
-		// fmt.Printf("%d, %d, %d\n", lineChunk, chI, lI)
-		// copy all bytes from pB to cB
-		for i := 0; i < hx.chunkSize; i++ {
-			hx.chunkBuf[i] = hx.pushBuffer[chI]
-			chI++
-		}
+	q := hx.queue
+	q.Reset()
 
-		// Tell the controller chip that we are sending a
-		// block/rectangle of data that starts at 0, lw and
-		// the block is width x 10 dimensions. The chip will
-		// then autoincrement the cursor.
-		hx.SetAddrWindow(0, uint16(lI), hx.Width, uint16(hx.lines))
+	hx.queueAddrWindow(q, 0, 0, hx.Width, hx.Height)
+	q.OutputLine(hx.dcLine, gpio.High) // High = data
 
-		fi.OutputHigh(hx.dc)
-		sp.Write(hx.chunkBuf)
-		lI += hx.lines
+	err := q.Flush()
+	if err != nil {
+		log.Printf("HX8357: Blit failed to set the window: %v\n", err)
+		return
+	}
+
+	// Written directly rather than queued so the frame isn't copied.
+	err = hx.spi.Tx(hx.pushBuffer, nil)
+	if err != nil {
+		log.Printf("HX8357: Blit failed to write the frame: %v\n", err)
 	}
 }
 
 // Blit3 is a bit faster in that it writes 1 horizontal line chunk
 // at a time which is certainly faster than 1 pixel at a time.
 func (hx *HX8357) Blit3() {
-	sp := hx.spi
-	fi := sp.GetFTDI()
+	q := hx.queue
+	q.Reset()
 
 	chunkSize := int(hx.Width) * bytesPerPixel
 	var chunkBuf = make([]byte, chunkSize)
@@ -563,9 +639,7 @@ func (hx *HX8357) Blit3() {
 	ie := chunkSize
 
 	for i := uint16(0); i < hx.Height; i++ {
-		hx.SetAddrWindow(0, i, hx.Width, 1)
-
-		fi.OutputHigh(hx.dc)
+		hx.queueAddrWindow(q, 0, i, hx.Width, 1)
 
 		// log.Printf("i: %d, %d, %d", i, is, ie)
 		ji := 0
@@ -576,13 +650,13 @@ func (hx *HX8357) Blit3() {
 		// chunkBuf = hx.pushBuffer[is:ie]
 		// fmt.Printf("%v\n", chunkBuf)
 		// println("------------")
-		sp.Write(chunkBuf)
-		// fi.OutputLow(hx.dc)
+		hx.queueData(q, chunkBuf)
 
 		is = ie
 		ie += chunkSize
 	}
-	// sp.Write(hx.pushBuffer)
+
+	q.Flush()
 }
 
 // Blit2 writes the contents of the displayBuffer directly to the display as fast as it can!
@@ -601,14 +675,13 @@ func (hx *HX8357) Blit2() {
 	//  st.SetAddrWindow(0,0,1,1);
 	// st.spi.TriggerPulse()
 
-	sp := hx.spi
-	fi := sp.GetFTDI()
-
-	hx.SetAddrWindow(0, 0, hx.Width, hx.Height)
+	q := hx.queue
+	q.Reset()
 
-	fi.OutputHigh(hx.dc)
+	hx.queueAddrWindow(q, 0, 0, hx.Width, hx.Height)
+	hx.queueData(q, hx.pushBuffer)
 
-	sp.Write(hx.pushBuffer)
+	q.Flush()
 }
 
 // DrawPixelToBuf draws to screen buffer only. You will need to eventually
@@ -1,11 +1,13 @@
 package hx8357
 
 import (
-	"fmt"
+	"context"
 	"log"
 
+	"github.com/wdevore/hardware/ftdi"
 	"github.com/wdevore/hardware/ftdi/devices"
 	"github.com/wdevore/hardware/gpio"
+	"github.com/wdevore/hardware/spi"
 )
 
 var (
@@ -129,7 +131,17 @@ func NewHX8357D(dataCommand, reset gpio.Pin, tab devices.TabColor, dimensions de
 	return hx
 }
 
+// NewHX8357DLines is NewHX8357D with D/C and reset on any gpio.Line, for example a
+// native GPIO from the gpiochip package. [reset] may be nil.
+func NewHX8357DLines(dataCommand, reset gpio.Line, tab devices.TabColor, dimensions devices.Dimensions) *HX8357D {
+	hx := NewHX8357D(gpio.NoPin, gpio.NoPin, tab, dimensions)
+	hx.SetLines(dataCommand, reset)
+	return hx
+}
+
 // Initialize configures and initializes HX8357D
+// [options] choose a specific device when more than one is attached,
+// for example ftdi.WithSerial("FT0RN5XA").
 // Depending on how you have physically oriented the display device the xy origin
 // will be located differently.
 // With the connections pins situated at the bottom orientation produces:
@@ -140,9 +152,27 @@ func NewHX8357D(dataCommand, reset gpio.Pin, tab devices.TabColor, dimensions de
 // decreasing y moves up
 // Thus the origin is in the top-left
 // 3 = right to left
-func (hx *HX8357D) Initialize(vender, product, clockFreq int, chipSelect gpio.Pin, orientation devices.RotationMode) error {
+func (hx *HX8357D) Initialize(vender, product, clockFreq int, chipSelect gpio.Pin, orientation devices.RotationMode, options ...ftdi.Option) error {
+	return hx.InitializeContext(context.Background(), vender, product, clockFreq, chipSelect, orientation, options...)
+}
+
+// InitializeContext is Initialize but aborts, between commands or during
+// the reset delays, when [ctx] is canceled or its deadline passes.
+func (hx *HX8357D) InitializeContext(ctx context.Context, vender, product, clockFreq int, chipSelect gpio.Pin, orientation devices.RotationMode, options ...ftdi.Option) error {
+	conn, err := newSPI(vender, product, options...)
+	if err != nil {
+		return err
+	}
+
+	return hx.InitializeConn(ctx, conn, clockFreq, chipSelect, orientation)
+}
+
+// InitializeConn is InitializeContext over any SPI connection, for example
+// a spi.SoftSPI. Unless SetLines was called [conn] must be a gpio.Provider
+// for the D/C and reset pins.
+func (hx *HX8357D) InitializeConn(ctx context.Context, conn spi.Conn, clockFreq int, chipSelect gpio.Pin, orientation devices.RotationMode) error {
 	// Initialize the device
-	err := hx.initialize(vender, product, clockFreq, chipSelect)
+	err := hx.initialize(ctx, conn, clockFreq, chipSelect)
 	if err != nil {
 		return err
 	}
@@ -153,12 +183,6 @@ func (hx *HX8357D) Initialize(vender, product, clockFreq int, chipSelect gpio.Pi
 	// protocol and must assert on each piece of data trafficking.
 	hx.SetConstantCSAssert(true)
 
-	log.Println("Issusing init commands")
-	err = hx.commonInit(rcmd1)
-	if err != nil {
-		return err
-	}
-
 	switch hx.dimensions {
 	case devices.D320x480:
 		hx.Width = 320
@@ -176,19 +200,35 @@ func (hx *HX8357D) Initialize(vender, product, clockFreq int, chipSelect gpio.Pi
 
 	hx.pushBuffer = make([]byte, pixels*bytesPerPixel)
 
-	hx.lineBlockSize = 5
-	hx.lines = int(hx.Height) / hx.lineBlockSize // 48 * 10 = 480
-	hx.chunkSize = int(hx.Width) * bytesPerPixel * hx.lines
+	if orientation == devices.OrientationDefault {
+		orientation = devices.Orientation2
+	}
+	hx.orientation = orientation
+
+	err = hx.initPanel(ctx)
+	if err != nil {
+		return err
+	}
 
-	fmt.Printf("lineBlock %d, chunksize: %d\n", hx.lines, hx.chunkSize)
-	hx.chunkBuf = make([]byte, hx.chunkSize)
+	// The panel forgets its setup when it loses power, for example with a
+	// loose USB cable.
+	if r, ok := hx.spi.(spi.Reconnector); ok {
+		r.OnReconnect(hx.initPanel)
+	}
 
-	if orientation == devices.OrientationDefault {
-		hx.SetRotation(devices.Orientation2)
-	} else {
-		hx.SetRotation(orientation)
+	return nil
+}
+
+// initPanel resets the panel and sends the init sequence.
+func (hx *HX8357D) initPanel(ctx context.Context) error {
+	log.Println("Issusing init commands")
+	err := hx.commonInit(ctx, rcmd1)
+	if err != nil {
+		return err
 	}
 
+	hx.SetRotation(hx.orientation)
+
 	return nil
 }
 
@@ -198,7 +238,7 @@ func (hx *HX8357D) Close() error {
 	err := hx.close()
 	if err != nil {
 		log.Println("Failed to close ST7735R.")
-		log.Fatal(err)
+		return err
 	}
 
 	log.Println("HX8357D closed.")
@@ -1,6 +1,9 @@
 package max
 
 import (
+	"context"
+
+	"github.com/wdevore/hardware/ftdi"
 	"github.com/wdevore/hardware/gpio"
 	"github.com/wdevore/hardware/spi"
 )
@@ -23,17 +26,29 @@ func NewMatrix1x1(speed int, intensity uint8) IMatrix {
 // ---------------------------------------------------------
 
 // Initialize configures SPI
-func (m *Matrix1x1) Initialize() error {
-	m.spi = spi.NewSPI(vender, product, false)
+// [options] choose a specific device when more than one is attached.
+func (m *Matrix1x1) Initialize(options ...ftdi.Option) error {
+	conn, err := spi.NewSPI(vender, product, false, options...)
+	if err != nil {
+		return err
+	}
+
+	return m.InitializeConn(conn)
+}
+
+// InitializeConn is Initialize over any SPI connection, for example a
+// spi.SoftSPI.
+func (m *Matrix1x1) InitializeConn(conn spi.Conn) error {
+	m.spi = conn
 
-	err := m.spi.Configure(gpio.DefaultPin, m.speed, spi.Mode0, spi.MSBFirst)
+	err := m.spi.ConfigureContext(context.Background(), gpio.DefaultPin, m.speed, spi.Mode0, spi.MSBFirst)
 
 	if err != nil {
 		return err
 	}
 
 	// Max7219 requires an active CS so we disable constant assert so CS will toggle.
-	m.spi.ConstantCSAssert = false
+	m.spi.SetConstantCSAssert(false)
 
 	// Default CS
 	m.spi.DeAssertChipSelect()
@@ -98,7 +113,7 @@ func (m *Matrix1x1) ActivateTestMode(activate bool) error {
 		m.packet[1] = off
 	}
 
-	err := m.spi.Write(m.packet)
+	err := m.spi.Tx(m.packet, nil)
 	if err != nil {
 		return err
 	}
@@ -127,7 +142,7 @@ func (m *Matrix1x1) UpdateDisplay() error {
 	// Turns on upper-left corner pixel
 	// m.packet[0] = 8    // column register address
 	// m.packet[1] = 0x80 // row pattern
-	// m.spi.Write(m.packet)
+	// m.spi.Tx(m.packet, nil)
 	// return nil
 
 	// We can only write columns not rows because the registers
@@ -151,7 +166,7 @@ func (m *Matrix1x1) UpdateDisplay() error {
 		m.packet[1] = b   // row pattern
 		b = 0
 
-		err := m.spi.Write(m.packet)
+		err := m.spi.Tx(m.packet, nil)
 		if err != nil {
 			return err
 		}
@@ -171,45 +186,45 @@ func (m *Matrix1x1) initRegisters() error {
 	m.spi.TakeControlOfCS()
 
 	m.spi.AssertChipSelect()
-	packet16[0] = shutdownReg
-	packet16[1] = shutdown
-	err = m.spi.Write(packet16) // Normal operation
+	m.packet16[0] = shutdownReg
+	m.packet16[1] = shutdown
+	err = m.spi.Tx(m.packet16[:], nil) // Normal operation
 	if err != nil {
 		return err
 	}
 	m.spi.DeAssertChipSelect()
 
 	m.spi.AssertChipSelect()
-	packet16[0] = modeReg
-	packet16[1] = noDecode
-	err = m.spi.Write(packet16)
+	m.packet16[0] = modeReg
+	m.packet16[1] = noDecode
+	err = m.spi.Tx(m.packet16[:], nil)
 	if err != nil {
 		return err
 	}
 	m.spi.DeAssertChipSelect()
 
 	m.spi.AssertChipSelect()
-	packet16[0] = intensityReg
-	packet16[1] = m.intensity
-	err = m.spi.Write(packet16)
+	m.packet16[0] = intensityReg
+	m.packet16[1] = m.intensity
+	err = m.spi.Tx(m.packet16[:], nil)
 	if err != nil {
 		return err
 	}
 	m.spi.DeAssertChipSelect()
 
 	m.spi.AssertChipSelect()
-	packet16[0] = scanLimitReg
-	packet16[1] = allColumns
-	err = m.spi.Write(packet16)
+	m.packet16[0] = scanLimitReg
+	m.packet16[1] = allColumns
+	err = m.spi.Tx(m.packet16[:], nil)
 	if err != nil {
 		return err
 	}
 	m.spi.DeAssertChipSelect()
 
 	m.spi.AssertChipSelect()
-	packet16[0] = shutdownReg
-	packet16[1] = normal
-	err = m.spi.Write(packet16)
+	m.packet16[0] = shutdownReg
+	m.packet16[1] = normal
+	err = m.spi.Tx(m.packet16[:], nil)
 	if err != nil {
 		return err
 	}
@@ -227,10 +242,10 @@ func (m *Matrix1x1) clearRegisters() error {
 	for col := byte(1); col < 9; col++ {
 		m.spi.AssertChipSelect()
 
-		packet16[0] = col  // set column id
-		packet16[1] = zero // zero 8 bit pattern = clear
+		m.packet16[0] = col  // set column id
+		m.packet16[1] = zero // zero 8 bit pattern = clear
 
-		err := m.spi.Write(packet16)
+		err := m.spi.Tx(m.packet16[:], nil)
 
 		if err != nil {
 			return err
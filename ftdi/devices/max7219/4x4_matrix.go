@@ -1,6 +1,9 @@
 package max
 
 import (
+	"context"
+
+	"github.com/wdevore/hardware/ftdi"
 	"github.com/wdevore/hardware/gpio"
 	"github.com/wdevore/hardware/spi"
 )
@@ -23,18 +26,33 @@ func NewMatrix4x4(speed int, intensity uint8) IMatrix {
 // ---------------------------------------------------------
 
 // Initialize configures SPI
-func (m *Matrix4x4) Initialize() error {
-	m.spi = spi.NewSPI(vender, product, false)
-	m.spi.EnableTrigger()
+// [options] choose a specific device when more than one is attached.
+func (m *Matrix4x4) Initialize(options ...ftdi.Option) error {
+	conn, err := spi.NewSPI(vender, product, false, options...)
+	if err != nil {
+		return err
+	}
+
+	return m.InitializeConn(conn)
+}
+
+// InitializeConn is Initialize over any SPI connection, for example a
+// spi.SoftSPI.
+func (m *Matrix4x4) InitializeConn(conn spi.Conn) error {
+	m.spi = conn
 
-	err := m.spi.Configure(gpio.DefaultPin, m.speed, spi.Mode0, spi.MSBFirst)
+	if t, ok := m.spi.(spi.Trigger); ok {
+		t.EnableTrigger()
+	}
+
+	err := m.spi.ConfigureContext(context.Background(), gpio.DefaultPin, m.speed, spi.Mode0, spi.MSBFirst)
 
 	if err != nil {
 		return err
 	}
 
 	// Max7219 requires an active CS so we disable constant assert so CS will toggle.
-	m.spi.ConstantCSAssert = false
+	m.spi.SetConstantCSAssert(false)
 
 	// Default CS
 	m.spi.DeAssertChipSelect()
@@ -94,19 +112,19 @@ func (m *Matrix4x4) ClearDevice() {
 // ActivateTestMode turns on all leds which bypasses any digit register values
 func (m *Matrix4x4) ActivateTestMode(activate bool) error {
 
-	packet16[0] = displayTestReg
+	m.packet16[0] = displayTestReg
 
 	if activate {
-		packet16[1] = on // display test mode
+		m.packet16[1] = on // display test mode
 	} else {
-		packet16[1] = off
+		m.packet16[1] = off
 	}
 
 	m.spi.TakeControlOfCS()
 
 	m.spi.AssertChipSelect()
 	for n := 0; n < 16; n++ {
-		err := m.spi.Write(packet16)
+		err := m.spi.Tx(m.packet16[:], nil)
 		if err != nil {
 			return err
 		}
@@ -124,7 +142,9 @@ func (m *Matrix4x4) ActivateTestMode(activate bool) error {
 
 // UpdateDisplay blits the pixel buffer to device
 func (m *Matrix4x4) UpdateDisplay() error {
-	m.spi.TriggerPulse()
+	if t, ok := m.spi.(spi.Trigger); ok {
+		t.TriggerPulse()
+	}
 
 	// A packet is a stream of 128 bits = 16x8.
 	//  vertical col       vertical col      vertical col     vertical col
@@ -181,7 +201,7 @@ func (m *Matrix4x4) UpdateDisplay() error {
 		col++
 
 		// fmt.Printf("bank: %d, send: %v\n", bank, m.packet)
-		m.spi.Write(m.packet)
+		m.spi.Tx(m.packet, nil)
 	}
 
 	m.spi.ReleaseControlOfCS()
@@ -213,9 +233,9 @@ func (m *Matrix4x4) initRegisters() error {
 
 	m.spi.AssertChipSelect()
 	for n := 0; n < 16; n++ {
-		packet16[0] = shutdownReg
-		packet16[1] = shutdown
-		err = m.spi.Write(packet16) // Normal operation
+		m.packet16[0] = shutdownReg
+		m.packet16[1] = shutdown
+		err = m.spi.Tx(m.packet16[:], nil) // Normal operation
 		if err != nil {
 			return err
 		}
@@ -224,9 +244,9 @@ func (m *Matrix4x4) initRegisters() error {
 
 	m.spi.AssertChipSelect()
 	for n := 0; n < 16; n++ {
-		packet16[0] = modeReg
-		packet16[1] = noDecode
-		err = m.spi.Write(packet16)
+		m.packet16[0] = modeReg
+		m.packet16[1] = noDecode
+		err = m.spi.Tx(m.packet16[:], nil)
 		if err != nil {
 			return err
 		}
@@ -235,9 +255,9 @@ func (m *Matrix4x4) initRegisters() error {
 
 	m.spi.AssertChipSelect()
 	for n := 0; n < 16; n++ {
-		packet16[0] = intensityReg
-		packet16[1] = m.intensity
-		err = m.spi.Write(packet16)
+		m.packet16[0] = intensityReg
+		m.packet16[1] = m.intensity
+		err = m.spi.Tx(m.packet16[:], nil)
 		if err != nil {
 			return err
 		}
@@ -246,9 +266,9 @@ func (m *Matrix4x4) initRegisters() error {
 
 	m.spi.AssertChipSelect()
 	for n := 0; n < 16; n++ {
-		packet16[0] = scanLimitReg
-		packet16[1] = allColumns
-		err = m.spi.Write(packet16)
+		m.packet16[0] = scanLimitReg
+		m.packet16[1] = allColumns
+		err = m.spi.Tx(m.packet16[:], nil)
 		if err != nil {
 			return err
 		}
@@ -257,9 +277,9 @@ func (m *Matrix4x4) initRegisters() error {
 
 	m.spi.AssertChipSelect()
 	for n := 0; n < 16; n++ {
-		packet16[0] = shutdownReg
-		packet16[1] = normal
-		err = m.spi.Write(packet16)
+		m.packet16[0] = shutdownReg
+		m.packet16[1] = normal
+		err = m.spi.Tx(m.packet16[:], nil)
 		if err != nil {
 			return err
 		}
@@ -282,10 +302,10 @@ func (m *Matrix4x4) clearRegisters() error {
 		// For this column shift into each matrix
 		for n := 0; n < 16; n++ {
 
-			packet16[0] = col // set column id
-			packet16[1] = 0   // zero 8 bit pattern = clear
+			m.packet16[0] = col // set column id
+			m.packet16[1] = 0   // zero 8 bit pattern = clear
 
-			err := m.spi.Write(packet16)
+			err := m.spi.Tx(m.packet16[:], nil)
 			if err != nil {
 				return err
 			}
@@ -3,6 +3,7 @@ package max
 import (
 	"fmt"
 
+	"github.com/wdevore/hardware/ftdi"
 	"github.com/wdevore/hardware/spi"
 )
 
@@ -80,7 +81,8 @@ type IMatrix interface {
 	// ---------------------------------------------------------
 	// Device methods
 	// ---------------------------------------------------------
-	Initialize() error
+	Initialize(options ...ftdi.Option) error
+	InitializeConn(conn spi.Conn) error
 	Close() error
 	GetWidth() int
 	GetHeight() int
@@ -107,13 +109,14 @@ type IMatrix interface {
 	PrintBuf()
 }
 
-var packet16 = []byte{0x00, 0x00}
-
 type matrix struct {
 	speed     int
 	intensity byte
 
-	spi *spi.FtdiSPI
+	spi spi.Conn
+
+	// packet16 is a register/value pair.
+	packet16 [2]byte
 
 	// A single strip of data sent.
 	// For example a 4x4 cascade (i.e. 32*4 pixels) requires
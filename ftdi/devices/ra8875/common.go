@@ -1,6 +1,10 @@
 package ra8875
 
-import "github.com/wdevore/hardware/ftdi/devices"
+import (
+	"context"
+
+	"github.com/wdevore/hardware/ftdi/devices"
+)
 
 const (
 	// // Colors (RGB565)
@@ -211,6 +215,7 @@ type RA8875 interface {
 	DebugTrigPulse()
 
 	Quit()
+	SetContext(ctx context.Context)
 	Close() error
 
 	DisplayOn(on bool)
@@ -237,5 +242,28 @@ type RA8875Base struct {
 
 	textScale int
 
-	quit bool
+	// ctx stops waitPoll when canceled, either by Quit or by the parent
+	// given to SetContext.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// SetContext ties the driver to [ctx], for example one canceled on SIGINT,
+// so that polling stops when it's done.
+func (rb *RA8875Base) SetContext(ctx context.Context) {
+	rb.ctx, rb.cancel = context.WithCancel(ctx)
+}
+
+// Quit signals any waiting/polling to stop
+func (rb *RA8875Base) Quit() {
+	if rb.cancel != nil {
+		rb.cancel()
+	}
+}
+
+func (rb *RA8875Base) context() context.Context {
+	if rb.ctx == nil {
+		return context.Background()
+	}
+	return rb.ctx
 }
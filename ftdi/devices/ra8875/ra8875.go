@@ -1,7 +1,8 @@
 package ra8875
 
 import (
-	"errors"
+	"context"
+	"fmt"
 	"log"
 	"time"
 
@@ -30,43 +31,80 @@ type Matrix struct {
 type RAIO8875 struct {
 	RA8875Base
 
-	// Uses the USB FTDI232 SPI object
-	spi *spi.FtdiSPI
+	// Uses a SPI connection, the USB FTDI232 SPI object or a SoftSPI
+	spi spi.Conn
 
+	// Hardware reset line, D4 of the SPI connection unless set otherwise.
+	// gpio.NoPin skips the hardware reset.
 	reset gpio.Pin
+	// resetLine replaces reset when set by SetResetLine.
+	resetLine gpio.Line
+
+	// writeBuf is the command/data buffer of this device, a cycle byte
+	// followed by the command or data byte.
+	writeBuf [2]byte
+	readBuf  [2]byte
 }
 
 // NewRA8875 creates an un-initialized RA8875 device driver
 func NewRA8875(dimensions devices.Dimensions) RA8875 {
 	ra := new(RAIO8875)
 	ra.dimensions = dimensions
+	ra.reset = gpio.DefaultPin
 	return ra
 }
 
 // NewRA8875Default creates a default/typical configuration when
 // using the FTDI232H GPIO USB device.
-func NewRA8875Default(dimensions devices.Dimensions) RA8875 {
+// [options] choose a specific device when more than one is attached.
+func NewRA8875Default(dimensions devices.Dimensions, options ...ftdi.Option) RA8875 {
+	ra, err := NewRA8875Context(context.Background(), dimensions, options...)
+
+	if err != nil {
+		panic("RA8875: Failed to default initialize.")
+	}
+
+	return ra
+}
+
+// NewRA8875Context is NewRA8875Default but returns an error instead of panicking,
+// and gives up initializing when [ctx] is canceled or its deadline passes.
+// [ctx] only bounds initialization, use SetContext to stop later polling.
+func NewRA8875Context(ctx context.Context, dimensions devices.Dimensions, options ...ftdi.Option) (RA8875, error) {
 	ra := new(RAIO8875)
 	ra.dimensions = dimensions
+	ra.reset = gpio.DefaultPin
 
-	err := ra.initialize(0x0403, 0x06014, 4000000, gpio.DefaultPin)
+	err := ra.initialize(ctx, 0x0403, 0x06014, 4000000, gpio.DefaultPin, options...)
+	if err != nil {
+		return nil, err
+	}
 
+	return ra, nil
+}
+
+// NewRA8875Conn creates a RA8875 device driver over [conn], for example a
+// spi.SoftSPI, and initializes it. Unless SetResetLine was called [conn]
+// must be a gpio.Provider for the reset pin, else there is only a software
+// reset. A clock frequency of 0 means default to max = 30MHz.
+// [ctx] only bounds initialization, use SetContext to stop later polling.
+func NewRA8875Conn(ctx context.Context, conn spi.Conn, dimensions devices.Dimensions, clockFreq int, chipSelect gpio.Pin) (RA8875, error) {
+	ra := new(RAIO8875)
+	ra.dimensions = dimensions
+	ra.reset = gpio.DefaultPin
+
+	err := ra.initializeConn(ctx, conn, clockFreq, chipSelect)
 	if err != nil {
-		panic("RA8875: Failed to default initialize.")
+		return nil, err
 	}
 
-	return ra
+	return ra, nil
 }
 
 // -----------------------------------------------------------
 // Control API BEGIN
 // -----------------------------------------------------------
 
-// Quit signals any waiting/polling to stop
-func (ra RAIO8875) Quit() {
-	ra.quit = true
-}
-
 // DisplayOn turns display on or off
 func (ra RAIO8875) DisplayOn(on bool) {
 	if on {
@@ -105,27 +143,33 @@ func (ra RAIO8875) PWM1config(on bool, clock uint8) {
 }
 
 func (ra *RAIO8875) waitPoll(regname, waitflag uint8) bool {
-	/* Wait for the command to finish */
+	return ra.waitPollContext(ra.context(), regname, waitflag) == nil
+}
+
+// waitPollContext waits for the command to finish, that is for [waitflag]
+// to clear in [regname], or until [ctx] is done.
+func (ra *RAIO8875) waitPollContext(ctx context.Context, regname, waitflag uint8) error {
 	for {
-		if ra.quit {
-			return false
+		if ctx.Err() != nil {
+			return ctx.Err()
 		}
 
 		temp, err := ra.readReg(regname)
-		log.Printf("waitPoll: %d\n", temp)
 		if err != nil {
-			log.Print(err)
+			return err
 		}
 
-		if temp&waitflag == 1 {
-			return true
+		if temp&waitflag == 0 {
+			return nil
 		}
 	}
-	// return false // MEMEFIX: yeah i know, unreached! - add timeout?
 }
 
+// DebugTrigPulse pulses the trigger pin, if the SPI connection has one.
 func (ra *RAIO8875) DebugTrigPulse() {
-	ra.spi.TriggerPulse()
+	if t, ok := ra.spi.(spi.Trigger); ok {
+		t.TriggerPulse()
+	}
 }
 
 // -----------------------------------------------------------
@@ -348,21 +392,27 @@ func (ra RAIO8875) DrawRectangle(x, y, w, h, color uint16, filled bool) {
 // Initialize configures FTDI and SPI, and initializes RA8875
 // Vendor/Product example would be: 0x0403, 0x06014 for the FTDI chip
 // A clock frequency of 0 means default to max = 30MHz
-func (ra *RAIO8875) initialize(vender, product, clockFreq int, chipSelect gpio.Pin) error {
+func (ra *RAIO8875) initialize(ctx context.Context, vender, product, clockFreq int, chipSelect gpio.Pin, options ...ftdi.Option) error {
 	// Create a SPI interface from the FT232H
-	ra.spi = spi.NewSPI(vender, product, false)
-	ra.spi.ConstantCSAssert = false
-
-	if ra.spi == nil {
-		return errors.New("RA8875: Failed to create SPI object")
+	conn, err := spi.NewSPI(vender, product, false, options...)
+	if err != nil {
+		return fmt.Errorf("RA8875: Failed to create SPI object: %w", err)
 	}
 
+	return ra.initializeConn(ctx, conn, clockFreq, chipSelect)
+}
+
+// initializeConn configures SPI over [conn] and initializes RA8875.
+func (ra *RAIO8875) initializeConn(ctx context.Context, conn spi.Conn, clockFreq int, chipSelect gpio.Pin) error {
+	ra.spi = conn
+	ra.spi.SetConstantCSAssert(false)
+
 	if clockFreq == 0 {
 		clockFreq = devices.Max30MHz
 	}
 	log.Printf("RA8875: Configuring for a clock of (%d)MHz\n", clockFreq/1000000)
 
-	err := ra.configure(chipSelect, clockFreq)
+	err := ra.configure(ctx, chipSelect, clockFreq)
 	if err != nil {
 		return err
 	}
@@ -373,12 +423,14 @@ func (ra *RAIO8875) initialize(vender, product, clockFreq int, chipSelect gpio.P
 }
 
 // Configure sets up the SPI component and initializes the RA8875
-func (ra *RAIO8875) configure(chipSelect gpio.Pin, clockFreq int) error {
+func (ra *RAIO8875) configure(ctx context.Context, chipSelect gpio.Pin, clockFreq int) error {
 	log.Println("RA8875: Configuring SPI")
-	err := ra.spi.Configure(chipSelect, clockFreq, spi.Mode0, spi.MSBFirst)
+	err := ra.spi.ConfigureContext(ctx, chipSelect, clockFreq, spi.Mode0, spi.MSBFirst)
 
 	log.Println("RA8875: config debug.")
-	ra.spi.DebugInit()
+	if t, ok := ra.spi.(spi.Trigger); ok {
+		t.EnableTrigger()
+	}
 
 	if err != nil {
 		log.Println("RA8875: Configure FAILED.")
@@ -386,16 +438,14 @@ func (ra *RAIO8875) configure(chipSelect gpio.Pin, clockFreq int) error {
 	}
 
 	log.Println("RA8875: initReset")
-	err = ra.initReset()
+	err = ra.initReset(ctx)
 	if err != nil {
 		log.Println("RA8875: Configure FAILED.")
 		return err
 	}
 
 	log.Println("RA8875: initDriver")
-	ra.initDriver()
-
-	return nil
+	return ra.initDriver(ctx)
 }
 
 // Close closes the SPI object
@@ -403,8 +453,15 @@ func (ra *RAIO8875) Close() error {
 	return ra.spi.Close()
 }
 
+// SetResetLine drives the hardware reset through [line], for example a pin
+// of an I/O expander, instead of D4. Call it before Initialize. A SPI
+// connection that isn't a gpio.Provider needs it for a hardware reset.
+func (ra *RAIO8875) SetResetLine(line gpio.Line) {
+	ra.resetLine = line
+}
+
 // Init setups common pin configurations and resets
-func (ra *RAIO8875) initReset() error {
+func (ra *RAIO8875) initReset(ctx context.Context) error {
 	sp := ra.spi
 
 	// The RA8875 communicates with TFT device (aka RA8875 device) through the FTDI235H device
@@ -412,32 +469,46 @@ func (ra *RAIO8875) initReset() error {
 	// else needs to be added manually--and controlled manually.
 
 	// Setup extra pins for Reset--The RAIO doesn't have a D/C pin.
-	// For this we need to interface with the FTDI chip.
-	fi := sp.GetFTDI()
+	// Unless SetResetLine gave another it is a pin of the SPI connection,
+	// a FTDI pin for a FtdiSPI.
 
 	// toggle RST low to reset and CS low so it'll listen to us
 	sp.DeAssertChipSelect()
 	// sp.AssertChipSelect()
 
-	if ra.reset != gpio.NoPin {
+	if ra.reset == gpio.DefaultPin {
 		ra.reset = ftdi.D4
-		fi.ConfigPin(ra.reset, gpio.Output)
+	}
 
-		fi.OutputHigh(ra.reset)
-		time.Sleep(time.Millisecond * 100)
+	provider, ok := sp.(gpio.Provider)
+	if ra.resetLine == nil && ra.reset != gpio.NoPin && ok {
+		line, err := provider.Line(ra.reset)
+		if err != nil {
+			return fmt.Errorf("ra8875: reset: %w", err)
+		}
+		ra.resetLine = line
+	}
 
-		fi.OutputLow(ra.reset)
-		time.Sleep(time.Millisecond * 100)
+	if ra.resetLine != nil {
+		err := gpio.Claim("RA8875 reset", ra.resetLine)
+		if err != nil {
+			return err
+		}
 
-		fi.OutputHigh(ra.reset)
-		time.Sleep(time.Millisecond * 100)
+		for _, level := range []gpio.PinState{gpio.High, gpio.Low, gpio.High} {
+			err = ra.resetLine.Out(level)
+			if err != nil {
+				return err
+			}
+			if err := devices.SleepContext(ctx, time.Millisecond*100); err != nil {
+				return err
+			}
+		}
 	}
 
 	// sp.DeAssertChipSelect()
 
-	ra.Reset()
-
-	return nil
+	return ra.Reset()
 }
 
 // Reset performs a SW-based reset of the RA8875
@@ -450,7 +521,7 @@ func (ra *RAIO8875) Reset() error {
 	}
 
 	log.Println("RA8875: write PWRR_SOFTRESET.")
-	ra.writeData(PWRR_SOFTRESET)
+	err = ra.writeData(PWRR_SOFTRESET)
 	if err != nil {
 		log.Println("RA8875: Failed to write PWRR_SOFTRESET.")
 		return err
@@ -461,7 +532,7 @@ func (ra *RAIO8875) Reset() error {
 	// This both completes reset cycle and switches to Normal mode
 
 	log.Println("RA8875: write PWRR_NORMAL.")
-	ra.writeData(PWRR_NORMAL)
+	err = ra.writeData(PWRR_NORMAL)
 	if err != nil {
 		log.Println("RA8875: Failed to write PWRR_NORMAL.")
 		return err
@@ -470,7 +541,7 @@ func (ra *RAIO8875) Reset() error {
 	return nil
 }
 
-func (ra *RAIO8875) initDriver() {
+func (ra *RAIO8875) initDriver(ctx context.Context) error {
 	log.Println("RA8875: init PLL")
 	ra.pLLinit()
 
@@ -558,7 +629,7 @@ func (ra *RAIO8875) initDriver() {
 	/* Clear the entire window */
 	log.Println("RA8875: initDriver: clear window")
 	ra.writeReg(MCLR, MCLR_START|MCLR_FULL)
-	time.Sleep(time.Millisecond * 500)
+	return devices.SleepContext(ctx, time.Millisecond*500)
 }
 
 // Initialise the PLL
@@ -580,11 +651,6 @@ func (ra *RAIO8875) pLLinit() {
 // Wrappers: Low level
 // ----------------------------------------------------------
 
-var (
-	writeBuf = []byte{0x00}
-	write16  = []byte{0x00, 0x00}
-)
-
 func (ra *RAIO8875) writeReg(reg, val uint8) {
 	ra.writeCommand(reg)
 	ra.writeData(val)
@@ -611,73 +677,39 @@ func (ra *RAIO8875) readReg(reg uint8) (uint8, error) {
 }
 
 // WriteData writes data to the device via SPI
-func (ra *RAIO8875) writeData(data byte) {
-	sp := ra.spi
+// The cycle byte and the data are one exchange, chip select stays asserted
+// in between.
+func (ra *RAIO8875) writeData(data byte) error {
 	// fmt.Printf("writeData: 0x00: %d\n", data)
-
-	// write16[0] = DATAWRITE
-	// write16[1] = data
-	// sp.Write(write16)
-
-	writeBuf[0] = DATAWRITE
-	sp.Write(writeBuf)
-
-	writeBuf[0] = data
-	sp.Write(writeBuf)
+	ra.writeBuf[0] = DATAWRITE
+	ra.writeBuf[1] = data
+	return ra.spi.Tx(ra.writeBuf[:], nil)
 }
 
 func (ra *RAIO8875) readData() (uint8, error) {
-	sp := ra.spi
-
-	writeBuf[0] = DATAREAD
-	sp.Write(writeBuf)
-
-	writeBuf[0] = 0
-
-	x, err := sp.Transfer(writeBuf, spi.TransferCommand)
+	ra.writeBuf[0] = DATAREAD
+	ra.writeBuf[1] = 0
 
+	// The data byte is shifted in while the dummy byte is shifted out.
+	err := ra.spi.Tx(ra.writeBuf[:], ra.readBuf[:])
 	if err != nil {
 		return 0, err
 	}
 
-	return uint8(x[0]), err
+	return ra.readBuf[1], nil
 }
 
 // WriteCommand writes a command via SPI protocol
 func (ra *RAIO8875) writeCommand(command byte) error {
-	sp := ra.spi
-
 	// fmt.Printf("writeCommand: 0x80: %d\n", command)
 	// ra.DebugTrigPulse()
-	// ra.DebugTrigPulse()
-	// ra.DebugTrigPulse()
-	// ra.DebugTrigPulse()
-	// ra.DebugTrigPulse()
-
-	// write16[0] = CMDWRITE
-	// write16[1] = command
-	// sp.Write(write16)
 
-	writeBuf[0] = CMDWRITE
-	err := sp.Write(writeBuf)
+	ra.writeBuf[0] = CMDWRITE
+	ra.writeBuf[1] = command
+	err := ra.spi.Tx(ra.writeBuf[:], nil)
 	if err != nil {
 		log.Println(err)
 	}
-	// ra.DebugTrigPulse()
 
-	writeBuf[0] = command
-	sp.Write(writeBuf)
-	// ra.DebugTrigPulse()
-	// ra.DebugTrigPulse()
-
-	// _, err := sp.Transfer(writeBuf, spi.TransferCommand)
-
-	// writeBuf[0] = command
-	// err := ra.spi.Write(writeBuf)
-	// if err != nil {
-	// 	log.Println("RA8875: Failed to write command.")
-	// 	return err
-	// }
-
-	return nil
+	return err
 }
@@ -1,10 +1,13 @@
 package ssd1351
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log"
 	"time"
 
+	"github.com/wdevore/hardware/ftdi"
 	"github.com/wdevore/hardware/ftdi/devices"
 	"github.com/wdevore/hardware/gpio"
 	"github.com/wdevore/hardware/spi"
@@ -50,19 +53,24 @@ const (
 )
 
 var (
-	colorPush     = []byte{0x00, 0x00}
-	writeBuf      = []byte{0x00}
-	addWindowBuf  = []byte{0x00, 0x00, 0x00, 0x00}
 	bytesPerPixel = 2
 )
 
 // SSD1351 represents the OLED ssd1351 controller chip.
 type SSD1351 struct {
-	// Uses the USB FTDI232 SPI object
-	spi *spi.FtdiSPI
+	// Uses a SPI connection, usually the USB FTDI232 SPI object
+	spi spi.Conn
+
+	// queue batches D/C changes and SPI writes, into single USB writes over
+	// a FtdiSPI.
+	queue *spi.Batch
 
 	dc    gpio.Pin // Data/Command pin
 	reset gpio.Pin
+	// The D/C and reset lines, resolved from dc and reset on the SPI
+	// connection's pins unless set by SetLines.
+	dcLine    gpio.Line
+	resetLine gpio.Line
 
 	dimensions devices.Dimensions
 
@@ -79,6 +87,11 @@ type SSD1351 struct {
 	// 10ms allows for a framerate between 30FPS(~33ms/frame) to 60FPS(~16ms/frame).
 	// Although 60FPS only leaves about 6ms for your code which is pretty tight.
 	pushBuffer []byte
+
+	// Per device command buffers, so several displays can be driven at once.
+	colorPush    [2]byte
+	writeBuf     [1]byte
+	addWindowBuf [4]byte
 }
 
 // Screen initialization commands and arguments are organized in these tables
@@ -121,25 +134,50 @@ func NewSSD1351(dataCommand, reset gpio.Pin, dimensions devices.Dimensions) *SSD
 	return sd
 }
 
+// NewSSD1351Lines is NewSSD1351 with D/C and reset on any gpio.Line, for example a
+// native GPIO from the gpiochip package. [reset] may be nil.
+func NewSSD1351Lines(dataCommand, reset gpio.Line, dimensions devices.Dimensions) *SSD1351 {
+	sd := NewSSD1351(gpio.NoPin, gpio.NoPin, dimensions)
+	sd.SetLines(dataCommand, reset)
+	return sd
+}
+
 // Initialize configures FTDI and SPI, and initializes HX8357
 // Vendor/Product example would be: 0x0403, 0x06014 for the FTDI chip
 // A clock frequency of 0 means default to max = 30MHz
-func (sd *SSD1351) Initialize(vender, product, clockFreq int, chipSelect gpio.Pin) error {
+// [options] choose a specific device when more than one is attached.
+func (sd *SSD1351) Initialize(vender, product, clockFreq int, chipSelect gpio.Pin, options ...ftdi.Option) error {
+	return sd.InitializeContext(context.Background(), vender, product, clockFreq, chipSelect, options...)
+}
+
+// InitializeContext is Initialize but aborts, during the reset delays or
+// before the init commands, when [ctx] is canceled or its deadline passes.
+func (sd *SSD1351) InitializeContext(ctx context.Context, vender, product, clockFreq int, chipSelect gpio.Pin, options ...ftdi.Option) error {
 
 	// Create a SPI interface from the FT232H
-	sd.spi = spi.NewSPI(vender, product, false)
+	conn, err := spi.NewSPI(vender, product, false, options...)
+	if err != nil {
+		return fmt.Errorf("SSD1351: Failed to create SPI object: %w", err)
+	}
+
+	return sd.InitializeConn(ctx, conn, clockFreq, chipSelect)
+}
+
+// InitializeConn is InitializeContext over any SPI connection, for example
+// a spi.SoftSPI. Unless SetLines was called [conn] must be a gpio.Provider
+// for the D/C and reset pins.
+func (sd *SSD1351) InitializeConn(ctx context.Context, conn spi.Conn, clockFreq int, chipSelect gpio.Pin) error {
+	sd.spi = conn
 	//sd.spi.DebugInit()
 
-	if sd.spi == nil {
-		return errors.New("SSD1351: Failed to create SPI object")
-	}
+	sd.queue = spi.NewBatch(conn)
 
 	if clockFreq == 0 {
 		clockFreq = devices.Max30MHz
 	}
 	log.Printf("SSD1351: Configuring for a clock of (%d)MHz\n", clockFreq/1000000)
 
-	err := sd.configure(chipSelect, clockFreq)
+	err := sd.configure(ctx, chipSelect, clockFreq)
 	if err != nil {
 		return err
 	}
@@ -150,18 +188,25 @@ func (sd *SSD1351) Initialize(vender, product, clockFreq int, chipSelect gpio.Pi
 }
 
 // Configure sets up the SPI component and initializes the SSD1351
-func (sd *SSD1351) configure(chipSelect gpio.Pin, clockFreq int) error {
+func (sd *SSD1351) configure(ctx context.Context, chipSelect gpio.Pin, clockFreq int) error {
 	log.Println("SSD1351: Configuring SPI")
-	err := sd.spi.Configure(chipSelect, clockFreq, spi.Mode0, spi.MSBFirst)
-
-	sd.spi.CSActiveLow = true
+	err := sd.spi.ConfigureContext(ctx, chipSelect, clockFreq, spi.Mode0, spi.MSBFirst)
 
 	if err != nil {
 		log.Println("SSD1351: Configure FAILED.")
 		return err
 	}
 
-	sd.commonInit()
+	err = sd.commonInit(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The panel forgets its setup when it loses power, for example with a
+	// loose USB cable.
+	if r, ok := sd.spi.(spi.Reconnector); ok {
+		r.OnReconnect(sd.commonInit)
+	}
 
 	return nil
 }
@@ -172,37 +217,85 @@ func (sd *SSD1351) Close() error {
 	return sd.spi.Close()
 }
 
-// commonInit setups common pin configurations
-func (sd *SSD1351) commonInit() error {
-	sp := sd.spi
+// SetLines drives D/C and reset through [dataCommand] and [reset], for
+// example pins of an I/O expander, instead of the pins given to the
+// constructor. [reset] may be nil. Call it before Initialize. A SPI
+// connection that isn't a gpio.Provider needs it.
+func (sd *SSD1351) SetLines(dataCommand, reset gpio.Line) {
+	sd.dcLine = dataCommand
+	sd.resetLine = reset
+}
 
+// resolveLines gets the lines of the SPI connection for the pins SetLines
+// didn't replace.
+func (sd *SSD1351) resolveLines() error {
+	if sd.dcLine != nil && (sd.resetLine != nil || sd.reset == gpio.NoPin) {
+		return nil
+	}
+
+	provider, ok := sd.spi.(gpio.Provider)
+	if !ok {
+		return errors.New("ssd1351: the SPI connection has no pins for D/C and reset, use SetLines")
+	}
+
+	var err error
+	if sd.dcLine == nil {
+		sd.dcLine, err = provider.Line(sd.dc)
+		if err != nil {
+			return fmt.Errorf("ssd1351: D/C: %w", err)
+		}
+	}
+
+	if sd.resetLine == nil && sd.reset != gpio.NoPin {
+		sd.resetLine, err = provider.Line(sd.reset)
+		if err != nil {
+			return fmt.Errorf("ssd1351: reset: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// commonInit setups common pin configurations
+func (sd *SSD1351) commonInit(ctx context.Context) error {
 	// The SSD1351 communicates with TFT device through the FTDI235H device
 	// via the SPI protocol. However, the SPI protocol only accounts for, at most, 4 pins, anything
 	// else needs to added manually--and controlled manually.
 
-	// Setup extra pins for D/C and Reset. For this we need to interface with the FTDI chip
-	fi := sp.GetFTDI()
+	// Setup extra pins for D/C and Reset. Unless SetLines gave others they are
+	// pins of the SPI connection, FTDI pins for a FtdiSPI.
+	err := sd.resolveLines()
+	if err != nil {
+		return err
+	}
 
-	pins := []gpio.PinConfiguration{
-		{Pin: sd.dc, Direction: gpio.Output, Value: gpio.Z},
-		{Pin: sd.reset, Direction: gpio.Output, Value: gpio.Z},
+	err = gpio.Claim("SSD1351", sd.dcLine, sd.resetLine)
+	if err != nil {
+		return err
 	}
-	fi.ConfigPins(pins, true)
+
+	q := sd.queue
+	q.Reset()
+
+	q.OutputLine(sd.dcLine, gpio.High)
 
 	// toggle RST low to reset and CS low so it'll listen to us
-	sp.AssertChipSelect()
+	q.AssertChipSelect()
 
-	if sd.reset != gpio.NoPin {
-		fi.ConfigPin(sd.reset, gpio.Output)
+	if sd.resetLine != nil {
+		q.OutputLine(sd.resetLine, gpio.High)
+		q.Delay(time.Millisecond * 500)
 
-		fi.OutputHigh(sd.reset)
-		time.Sleep(time.Millisecond * 500)
+		q.OutputLine(sd.resetLine, gpio.Low)
+		q.Delay(time.Millisecond * 500)
 
-		fi.OutputLow(sd.reset)
-		time.Sleep(time.Millisecond * 500)
+		q.OutputLine(sd.resetLine, gpio.High)
+		q.Delay(time.Millisecond * 500)
+	}
 
-		fi.OutputHigh(sd.reset)
-		time.Sleep(time.Millisecond * 500)
+	err = q.FlushContext(ctx)
+	if err != nil {
+		return err
 	}
 
 	sd.issueCommands()
@@ -453,18 +546,17 @@ func (sd *SSD1351) InvertDisplay(inv bool) {
 // you can leave CS low for the entire time and thus save
 // on bandwidth.
 func (sd *SSD1351) SetConstantCSAssert(constant bool) {
-	sd.spi.ConstantCSAssert = constant
+	sd.spi.SetConstantCSAssert(constant)
 }
 
 // WriteCommand writes a command via SPI protocol
 func (sd *SSD1351) WriteCommand(command byte) error {
-	sp := sd.spi
-	fi := sp.GetFTDI()
+	q := sd.queue
+	q.Reset()
 
-	fi.OutputLow(sd.dc) // Low = command
+	sd.queueCommand(q, command)
 
-	writeBuf[0] = command
-	err := sd.spi.Write(writeBuf)
+	err := q.Flush()
 	if err != nil {
 		log.Println("Failed to write command.")
 		return err
@@ -475,21 +567,66 @@ func (sd *SSD1351) WriteCommand(command byte) error {
 
 // WriteData writes data to the device via SPI
 func (sd *SSD1351) WriteData(data byte) {
-	sp := sd.spi
-	fi := sp.GetFTDI()
-	fi.OutputHigh(sd.dc) // High = data
-
-	writeBuf[0] = data
-	sp.Write(writeBuf)
+	sd.writeBuf[0] = data
+	sd.WriteDataChunk(sd.writeBuf[:])
 }
 
 // WriteDataChunk is a slightly more efficient version of WriteData
 func (sd *SSD1351) WriteDataChunk(data []byte) {
-	sp := sd.spi
-	fi := sp.GetFTDI()
-	fi.OutputHigh(sd.dc) // High = data
+	q := sd.queue
+	q.Reset()
+
+	sd.queueData(q, data)
+
+	q.Flush()
+}
+
+// queueCommand appends D/C low (command) and the command byte.
+func (sd *SSD1351) queueCommand(q *spi.Batch, command byte) {
+	q.OutputLine(sd.dcLine, gpio.Low) // Low = command
+
+	sd.writeBuf[0] = command
+	q.Write(sd.writeBuf[:])
+}
+
+// queueData appends D/C high (data) and the data.
+func (sd *SSD1351) queueData(q *spi.Batch, data []byte) {
+	q.OutputLine(sd.dcLine, gpio.High) // High = data
+
+	q.Write(data)
+}
+
+// queueAddrWindow appends the column/row address and RAM write commands.
+// It returns false if the window starts off screen.
+func (sd *SSD1351) queueAddrWindow(q *spi.Batch, x, y, w, h uint8) bool {
+	if (x >= sd.Width) || (y >= sd.Height) {
+		return false
+	}
+
+	// set x and y coordinate
+	sd.queueCommand(q, SETCOLUMN)
+	sd.addWindowBuf[0] = x
+	sd.addWindowBuf[1] = w - 1
+	sd.queueData(q, sd.addWindowBuf[:2])
 
-	sp.Write(data)
+	sd.queueCommand(q, SETROW)
+	sd.addWindowBuf[0] = y
+	sd.addWindowBuf[1] = h - 1
+	sd.queueData(q, sd.addWindowBuf[:2])
+
+	sd.queueCommand(q, WRITERAM)
+
+	return true
+}
+
+// queueColorRun appends [count] pixels of the same color as one data block.
+func (sd *SSD1351) queueColorRun(q *spi.Batch, color uint16, count int) {
+	run := make([]byte, count*bytesPerPixel)
+	for i := 0; i < len(run); i += bytesPerPixel {
+		run[i] = byte((color >> 8) & 0xff)
+		run[i+1] = byte(color & 0xff)
+	}
+	sd.queueData(q, run)
 }
 
 // ----------------------------------------------------
@@ -499,34 +636,26 @@ func (sd *SSD1351) WriteDataChunk(data []byte) {
 // SetAddrWindow sets row and column address of where pixels will be written.
 // (aka setDrawPosition or Goto)
 func (sd *SSD1351) SetAddrWindow(x, y, w, h uint8) {
-	if (x >= sd.Width) || (y >= sd.Height) {
-		return
-	}
-
-	// set x and y coordinate
-	sd.WriteCommand(SETCOLUMN)
-	sd.WriteData(x)
-	sd.WriteData(w - 1)
+	q := sd.queue
+	q.Reset()
 
-	sd.WriteCommand(SETROW)
-	sd.WriteData(y)
-	sd.WriteData(h - 1)
-
-	sd.WriteCommand(WRITERAM)
+	if sd.queueAddrWindow(q, x, y, w, h) {
+		q.Flush()
+	}
 }
 
 // PushColor writes a 16bit color value based on the current cursor/draw position.
 // The "cursor" position is set by SetAddrWindow()
 // (aka writePixel)
 func (sd *SSD1351) PushColor(color uint16) {
-	sp := sd.spi
-	fi := sp.GetFTDI()
+	q := sd.queue
+	q.Reset()
 
-	fi.OutputHigh(sd.dc)
+	sd.colorPush[0] = byte((color >> 8) & 0xff)
+	sd.colorPush[1] = byte(color & 0xff)
+	sd.queueData(q, sd.colorPush[:])
 
-	colorPush[0] = byte((color >> 8) & 0xff)
-	colorPush[1] = byte(color & 0xff)
-	sp.Write(colorPush)
+	q.Flush()
 }
 
 // DrawPixel draws to device only.
@@ -536,18 +665,18 @@ func (sd *SSD1351) DrawPixel(x, y uint8, color uint16) {
 		return
 	}
 
-	sp := sd.spi
-	fi := sp.GetFTDI()
+	q := sd.queue
+	q.Reset()
 
 	// First set "cursor"/"draw position"
-	sd.SetAddrWindow(x, y, 1, 1)
-
-	fi.OutputHigh(sd.dc)
+	sd.queueAddrWindow(q, x, y, 1, 1)
 
 	// Now draw.
-	colorPush[0] = byte((color >> 8) & 0xff)
-	colorPush[1] = byte(color & 0xff)
-	sp.Write(colorPush)
+	sd.colorPush[0] = byte((color >> 8) & 0xff)
+	sd.colorPush[1] = byte(color & 0xff)
+	sd.queueData(q, sd.colorPush[:])
+
+	q.Flush()
 }
 
 // DrawFastVLine draws a vertical line only
@@ -563,20 +692,13 @@ func (sd *SSD1351) DrawFastVLine(x, y, h uint8, color uint16) {
 		h = sd.Height - y
 	}
 
-	sd.SetAddrWindow(x, y, x, y+h)
+	q := sd.queue
+	q.Reset()
 
-	sp := sd.spi
-	fi := sp.GetFTDI()
+	sd.queueAddrWindow(q, x, y, x, y+h)
+	sd.queueColorRun(q, color, int(h))
 
-	colorPush[0] = byte((color >> 8) & 0xff)
-	colorPush[1] = byte(color & 0xff)
-
-	fi.OutputHigh(sd.dc)
-
-	for h > 0 {
-		sp.Write(colorPush)
-		h--
-	}
+	q.Flush()
 }
 
 // DrawFastHLine draws a horizontal line only
@@ -592,20 +714,13 @@ func (sd *SSD1351) DrawFastHLine(x, y, w uint8, color uint16) {
 		w = sd.Width - x
 	}
 
-	sd.SetAddrWindow(x, y, x+w, y)
-
-	sp := sd.spi
-	fi := sp.GetFTDI()
-
-	colorPush[0] = byte((color >> 8) & 0xff)
-	colorPush[1] = byte(color & 0xff)
+	q := sd.queue
+	q.Reset()
 
-	fi.OutputHigh(sd.dc)
+	sd.queueAddrWindow(q, x, y, x+w, y)
+	sd.queueColorRun(q, color, int(w))
 
-	for w > 0 {
-		sp.Write(colorPush)
-		w--
-	}
+	q.Flush()
 }
 
 // FillScreen fills the entire display area with "color"
@@ -613,8 +728,7 @@ func (sd *SSD1351) FillScreen(color uint16) {
 	sd.FillRectangle(0, 0, sd.Width, sd.Height, color)
 }
 
-// FillRectangle is a very slow fill.
-// Filling the entire screen takes 12+ seconds!
+// FillRectangle fills a rectangle with a single batched write.
 func (sd *SSD1351) FillRectangle(x, y, w, h uint8, color uint16) {
 	// log.Printf("%d x %d\n", st.Width, st.Height)
 	// rudimentary clipping (drawChar w/big text requires this)
@@ -631,20 +745,13 @@ func (sd *SSD1351) FillRectangle(x, y, w, h uint8, color uint16) {
 		h = sd.Height - y
 	}
 
-	sd.SetAddrWindow(x, y, w, h)
-
-	sp := sd.spi
-	fi := sp.GetFTDI()
+	q := sd.queue
+	q.Reset()
 
-	fi.OutputHigh(sd.dc)
+	sd.queueAddrWindow(q, x, y, w, h)
+	sd.queueColorRun(q, color, int(w)*int(h))
 
-	for y := h; y > 0; y-- {
-		for x := w; x > 0; x-- {
-			colorPush[0] = byte((color >> 8) & 0xff)
-			colorPush[1] = byte(color & 0xff)
-			sp.Write(colorPush)
-		}
-	}
+	q.Flush()
 }
 
 // ----------------------------------------------------
@@ -667,14 +774,13 @@ func (sd *SSD1351) Blit() {
 	//  st.SetAddrWindow(0,0,1,1);
 	// st.spi.TriggerPulse()
 
-	sp := sd.spi
-	fi := sp.GetFTDI()
-
-	sd.SetAddrWindow(0, 0, sd.Width, sd.Height)
+	q := sd.queue
+	q.Reset()
 
-	fi.OutputHigh(sd.dc)
+	sd.queueAddrWindow(q, 0, 0, sd.Width, sd.Height)
+	sd.queueData(q, sd.pushBuffer)
 
-	sp.Write(sd.pushBuffer)
+	q.Flush()
 }
 
 // DrawPixelToBuf draws to screen buffer only. You will need to eventually
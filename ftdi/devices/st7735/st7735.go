@@ -1,10 +1,13 @@
 package st7735
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log"
 	"time"
 
+	"github.com/wdevore/hardware/ftdi"
 	"github.com/wdevore/hardware/ftdi/devices"
 	"github.com/wdevore/hardware/gpio"
 	"github.com/wdevore/hardware/spi"
@@ -69,28 +72,43 @@ const (
 )
 
 var (
-	colorPush     = []byte{0x00, 0x00}
-	writeBuf      = []byte{0x00}
-	addWindowBuf  = []byte{0x00, 0x00, 0x00, 0x00}
 	bytesPerPixel = 2
 )
 
+// BacklightFrequency is the PWM frequency SetBacklight dims with.
+const BacklightFrequency = 1000
+
+// ErrNoBacklight is returned by SetBacklight before EnableBacklightControl.
+var ErrNoBacklight = errors.New("st7735: backlight control not enabled")
+
 // ST7735 represents the TFT/LCD controller chip.
 type ST7735 struct {
-	// ST7735 uses the USB FTDI232 SPI object
-	spi *spi.FtdiSPI
+	// ST7735 uses a SPI connection, usually the USB FTDI232 SPI object
+	spi spi.Conn
+
+	// queue batches D/C changes and SPI writes, into single USB writes over
+	// a FtdiSPI.
+	queue *spi.Batch
 
 	ystart   byte
 	xstart   byte
 	colstart byte
 	rowstart byte
 
-	dc        gpio.Pin // Data/Command pin
-	reset     gpio.Pin
-	backlight gpio.Pin
+	dc    gpio.Pin // Data/Command pin
+	reset gpio.Pin
+	// The D/C and reset lines, resolved from dc and reset on the SPI
+	// connection's pins unless set by SetLines.
+	dcLine    gpio.Line
+	resetLine gpio.Line
+	backlight gpio.Line
+	// dimmer plays the backlight PWM, see SetBacklight.
+	dimmer *ftdi.Generator
 
 	tab        devices.TabColor
 	dimensions devices.Dimensions
+	// orientation is the last SetRotation, redone after a reconnect.
+	orientation devices.RotationMode
 
 	Width  int
 	Height int
@@ -108,6 +126,11 @@ type ST7735 struct {
 	// 10ms allows for a framerate between 30FPS(~33ms/frame) to 60FPS(~16ms/frame).
 	// Although 60FPS only leaves about 6ms for your code which is pretty tight.
 	pushBuffer []byte
+
+	// Per device command buffers, so several displays can be driven at once.
+	colorPush    [2]byte
+	writeBuf     [1]byte
+	addWindowBuf [4]byte
 }
 
 // Screen initialization commands and arguments are organized in these tables
@@ -124,26 +147,22 @@ type commando struct {
 	Delay   int // 255 = 500ms delay max
 }
 
-// Initialize configures FTDI and SPI, and initializes ST7735
-// Vendor/Product example would be: 0x0403, 0x06014
+// Initialize configures SPI over [conn], and initializes ST7735
 // A clock frequency of 0 means default to max = 30MHz
-func (st *ST7735) initialize(vender, product, clockFreq int, chipSelect gpio.Pin, colorOrder devices.ColorOrder) error {
+func (st *ST7735) initialize(ctx context.Context, conn spi.Conn, clockFreq int, chipSelect gpio.Pin, colorOrder devices.ColorOrder) error {
 	st.colorOder = colorOrder
 
-	// Create a SPI interface from the FT232H
-	st.spi = spi.NewSPI(vender, product, false)
+	st.spi = conn
 	// st.spi.EnableTrigger()
 
-	if st.spi == nil {
-		return errors.New("ST7735 failed to create SPI object")
-	}
+	st.queue = spi.NewBatch(conn)
 
 	if clockFreq == 0 {
 		clockFreq = 30000000
 	}
 	log.Printf("Configuring ST7735 for a clock of (%d)MHz\n", clockFreq/1000000)
 
-	err := st.configure(chipSelect, clockFreq)
+	err := st.configure(ctx, chipSelect, clockFreq)
 	if err != nil {
 		return err
 	}
@@ -153,10 +172,20 @@ func (st *ST7735) initialize(vender, product, clockFreq int, chipSelect gpio.Pin
 	return nil
 }
 
+// newSPI creates the FTDI232H SPI connection of Initialize.
+// Vendor/Product example would be: 0x0403, 0x06014
+func newSPI(vender, product int, options ...ftdi.Option) (spi.Conn, error) {
+	conn, err := spi.NewSPI(vender, product, false, options...)
+	if err != nil {
+		return nil, fmt.Errorf("ST7735 failed to create SPI object: %w", err)
+	}
+	return conn, nil
+}
+
 // Configure sets up the SPI component and initializes the ST7735
-func (st *ST7735) configure(chipSelect gpio.Pin, clockFreq int) error {
+func (st *ST7735) configure(ctx context.Context, chipSelect gpio.Pin, clockFreq int) error {
 	log.Println("Configuring SPI")
-	err := st.spi.Configure(chipSelect, clockFreq, spi.Mode0, spi.MSBFirst)
+	err := st.spi.ConfigureContext(ctx, chipSelect, clockFreq, spi.Mode0, spi.MSBFirst)
 
 	if err != nil {
 		log.Println("ST7735 Configure FAILED.")
@@ -167,46 +196,62 @@ func (st *ST7735) configure(chipSelect gpio.Pin, clockFreq int) error {
 }
 
 func (st *ST7735) close() error {
+	if st.dimmer != nil {
+		err := st.dimmer.Stop()
+		if err != nil {
+			log.Printf("ST7735 backlight dimmer failed: %v\n", err)
+		}
+	}
 	return st.spi.Close()
 }
 
 // commonInit setups common pin configurations
-func (st *ST7735) commonInit(cmdList []commando) error {
+func (st *ST7735) commonInit(ctx context.Context, cmdList []commando) error {
 	st.ystart = 0
 	st.xstart = 0
 
-	sp := st.spi
-
 	// The ST7735 communicates with TFT device (aka ST7735R/S device) through the FTDI235H device
 	// via the SPI protocol. However, the SPI protocol only accounts for, at most, 4 pins, anything
 	// else needs to added manually--and controlled manually.
 
-	// Setup extra pins for D/C and Reset. For this we need to interface with the FTDI chip
-	fi := sp.GetFTDI()
+	// Setup extra pins for D/C and Reset. Unless SetLines gave others they are
+	// pins of the SPI connection, FTDI pins for a FtdiSPI.
+	err := st.resolveLines()
+	if err != nil {
+		return err
+	}
 
-	pins := []gpio.PinConfiguration{
-		{Pin: st.dc, Direction: gpio.Output, Value: gpio.Z},
+	err = gpio.Claim("ST7735", st.dcLine, st.resetLine)
+	if err != nil {
+		return err
 	}
-	fi.ConfigPins(pins, true)
+
+	q := st.queue
+	q.Reset()
+
+	q.OutputLine(st.dcLine, gpio.High)
 
 	// toggle RST low to reset and CS low so it'll listen to us
-	sp.AssertChipSelect()
+	q.AssertChipSelect()
 
-	if st.reset != gpio.NoPin {
-		fi.ConfigPin(st.reset, gpio.Output)
+	if st.resetLine != nil {
+		q.OutputLine(st.resetLine, gpio.High)
+		q.Delay(time.Millisecond * 100)
 
-		fi.OutputHigh(st.reset)
-		time.Sleep(time.Millisecond * 100)
+		q.OutputLine(st.resetLine, gpio.Low)
+		q.Delay(time.Millisecond * 100)
 
-		fi.OutputLow(st.reset)
-		time.Sleep(time.Millisecond * 100)
+		q.OutputLine(st.resetLine, gpio.High)
+		q.Delay(time.Millisecond * 100)
+	}
 
-		fi.OutputHigh(st.reset)
-		time.Sleep(time.Millisecond * 100)
+	err = q.FlushContext(ctx)
+	if err != nil {
+		return err
 	}
 
 	if cmdList != nil {
-		st.issueCommands(cmdList)
+		return st.issueCommands(ctx, cmdList)
 	}
 
 	return nil
@@ -215,24 +260,33 @@ func (st *ST7735) commonInit(cmdList []commando) error {
 // ----------------------------------------------------
 // Commands
 // ----------------------------------------------------
-func (st *ST7735) issueCommands(cmdList []commando) {
+
+// issueCommands queues the whole table and sends it in as few USB writes as
+// the delays allow.
+func (st *ST7735) issueCommands(ctx context.Context, cmdList []commando) error {
+	q := st.queue
+	q.Reset()
+
 	for _, com := range cmdList {
-		err := st.WriteCommand(com.Command)
-		if err != nil {
-			log.Printf("ST7735 issueCommands failed to write command: %v\n", err)
-			return
-		}
+		st.queueCommand(q, com.Command)
 
-		for _, arg := range com.Args {
-			st.WriteData(arg)
+		if len(com.Args) > 0 {
+			st.queueData(q, com.Args)
 		}
 
 		if com.Delayed {
 			d := time.Millisecond * time.Duration(com.Delay)
 			// log.Printf("ST7735 issueCommands delaying for (%d)ms\n", time.Duration(com.Delay))
-			time.Sleep(d)
+			q.Delay(d)
 		}
 	}
+
+	err := q.FlushContext(ctx)
+	if err != nil {
+		log.Printf("ST7735 issueCommands failed to write commands: %v\n", err)
+	}
+
+	return err
 }
 
 // ----------------------------------------------------
@@ -242,6 +296,8 @@ func (st *ST7735) issueCommands(cmdList []commando) {
 // SetRotation re-orients the display at 90 degree rotations.
 // Typically this method is called last during the initialization sequence.
 func (st *ST7735) SetRotation(orieo devices.RotationMode) {
+	st.orientation = orieo
+
 	st.WriteCommand(MADCTL)
 
 	cOrder := byte(MadctlRGB)
@@ -291,24 +347,149 @@ func (st *ST7735) InvertDisplay(inv bool) {
 	}
 }
 
-// EnableBacklightControl configures a pin for backlight control (default = high)
-func (st *ST7735) EnableBacklightControl(pin gpio.Pin) {
-	pins := []gpio.PinConfiguration{
-		{Pin: pin, Direction: gpio.Output, Value: gpio.High},
+// SetLines drives D/C and reset through [dataCommand] and [reset], for
+// example pins of an I/O expander, instead of the pins given to the
+// constructor. [reset] may be nil. Call it before Initialize. A SPI
+// connection that isn't a gpio.Provider needs it.
+func (st *ST7735) SetLines(dataCommand, reset gpio.Line) {
+	st.dcLine = dataCommand
+	st.resetLine = reset
+}
+
+// resolveLines gets the lines of the SPI connection for the pins SetLines
+// didn't replace.
+func (st *ST7735) resolveLines() error {
+	if st.dcLine != nil && (st.resetLine != nil || st.reset == gpio.NoPin) {
+		return nil
+	}
+
+	provider, ok := st.spi.(gpio.Provider)
+	if !ok {
+		return errors.New("st7735: the SPI connection has no pins for D/C and reset, use SetLines")
+	}
+
+	var err error
+	if st.dcLine == nil {
+		st.dcLine, err = provider.Line(st.dc)
+		if err != nil {
+			return fmt.Errorf("st7735: D/C: %w", err)
+		}
+	}
+
+	if st.resetLine == nil && st.reset != gpio.NoPin {
+		st.resetLine, err = provider.Line(st.reset)
+		if err != nil {
+			return fmt.Errorf("st7735: reset: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// EnableBacklightControl configures a pin of the SPI connection for
+// backlight control (default = high)
+func (st *ST7735) EnableBacklightControl(pin gpio.Pin) error {
+	provider, ok := st.spi.(gpio.Provider)
+	if !ok {
+		return errors.New("st7735: the SPI connection has no pins, use EnableBacklightLine")
+	}
+
+	line, err := provider.Line(pin)
+	if err != nil {
+		return err
+	}
+
+	return st.EnableBacklightLine(line)
+}
+
+// EnableBacklightLine is EnableBacklightControl for any line. Only a FT232H
+// pin can be dimmed, other lines are just on or off.
+func (st *ST7735) EnableBacklightLine(line gpio.Line) error {
+	err := gpio.Claim("ST7735 backlight", line)
+	if err != nil {
+		return err
+	}
+
+	err = line.Out(gpio.High)
+	if err != nil {
+		return err
+	}
+
+	st.backlight = line
+
+	if l, ok := line.(*ftdi.Line); ok {
+		st.dimmer = l.FTDI().NewGenerator("ST7735 backlight", ftdi.GenerateMPSSE)
 	}
-	st.backlight = pin
-	st.spi.ConfigurePins(pins)
+
+	return nil
 }
 
 // BacklightOn turns on or off back light
 func (st *ST7735) BacklightOn(on bool) {
-	sp := st.spi
-	fi := sp.GetFTDI()
+	if st.dimmer != nil {
+		err := st.dimmer.Stop()
+		if err != nil {
+			log.Printf("ST7735 backlight dimmer failed: %v\n", err)
+		}
+	}
+
+	if st.backlight == nil {
+		return
+	}
+
+	level := gpio.Low
 	if on {
-		fi.OutputHigh(st.backlight)
-	} else {
-		fi.OutputLow(st.backlight)
+		level = gpio.High
+	}
+
+	err := st.backlight.Out(level)
+	if err != nil {
+		log.Printf("ST7735 backlight failed: %v\n", err)
+	}
+}
+
+// SetBacklight dims the backlight from 0 (off) to 1 (full brightness).
+// Levels in between are PWM at BacklightFrequency, played in the background
+// by a pattern generator, which needs the backlight on one of D1-D7.
+func (st *ST7735) SetBacklight(brightness float64) error {
+	if st.backlight == nil {
+		return ErrNoBacklight
+	}
+
+	if brightness <= 0 || brightness >= 1 {
+		if st.dimmer != nil {
+			err := st.dimmer.Stop()
+			if err != nil {
+				return err
+			}
+		}
+
+		level := gpio.Low
+		if brightness > 0 {
+			level = gpio.High
+		}
+		return st.backlight.Out(level)
+	}
+
+	line, ok := st.backlight.(*ftdi.Line)
+	if !ok || st.dimmer == nil {
+		return errors.New("st7735: dimming needs the backlight on a FT232H pin")
+	}
+
+	if line.Pin() > ftdi.D7 {
+		return fmt.Errorf("st7735: dimming needs the backlight on D1-D7, not %s", line)
+	}
+
+	pattern, err := ftdi.PWM(1<<(line.Pin()-ftdi.D0), BacklightFrequency, brightness)
+	if err != nil {
+		return err
+	}
+
+	if st.dimmer.Running() {
+		return st.dimmer.Set(pattern)
 	}
+
+	return st.dimmer.Start(context.Background(), pattern)
 }
 
 // ----------------------------------------------------
@@ -321,50 +502,81 @@ func (st *ST7735) BacklightOn(on bool) {
 // you can leave CS low for the entire time and thus save
 // on bandwidth.
 func (st *ST7735) SetConstantCSAssert(constant bool) {
-	st.spi.ConstantCSAssert = constant
+	st.spi.SetConstantCSAssert(constant)
 }
 
 // WriteCommand writes a command via SPI protocol
 func (st *ST7735) WriteCommand(command byte) error {
 	// log.Printf("ST7735: WriteCommand (%02x)\n", command)
-	sp := st.spi
-	fi := sp.GetFTDI()
+	q := st.queue
+	q.Reset()
 
-	// log.Println("ST7735: WriteCommand: toggling dc")
-	fi.OutputLow(st.dc) // Low = command
+	st.queueCommand(q, command)
 
-	// log.Println("ST7735: WriteCommand: writing byte command")
-	writeBuf[0] = command
-	err := st.spi.Write(writeBuf)
+	err := q.Flush()
 	if err != nil {
 		log.Println("Failed to write command.")
 		return err
 	}
 
-	// log.Println("ST7735: WriteCommand: toggling cs")
-
 	return nil
 }
 
 // WriteData writes data to the device via SPI
 func (st *ST7735) WriteData(data byte) {
 	// log.Printf("ST7735: WriteData: (%02x)\n", data)
-	sp := st.spi
-	fi := sp.GetFTDI()
-	fi.OutputHigh(st.dc) // High = data
-
-	writeBuf[0] = data
-	sp.Write(writeBuf)
+	st.writeBuf[0] = data
+	st.WriteDataChunk(st.writeBuf[:])
 }
 
 // WriteDataChunk is a slightly more efficient version of WriteData
 func (st *ST7735) WriteDataChunk(data []byte) {
-	// log.Printf("ST7735: WriteData: (%02x)\n", data)
-	sp := st.spi
-	fi := sp.GetFTDI()
-	fi.OutputHigh(st.dc) // High = data
+	q := st.queue
+	q.Reset()
+
+	st.queueData(q, data)
 
-	sp.Write(data)
+	q.Flush()
+}
+
+// queueCommand appends D/C low (command) and the command byte.
+func (st *ST7735) queueCommand(q *spi.Batch, command byte) {
+	q.OutputLine(st.dcLine, gpio.Low) // Low = command
+
+	st.writeBuf[0] = command
+	q.Write(st.writeBuf[:])
+}
+
+// queueData appends D/C high (data) and the data.
+func (st *ST7735) queueData(q *spi.Batch, data []byte) {
+	q.OutputLine(st.dcLine, gpio.High) // High = data
+
+	q.Write(data)
+}
+
+// queueAddrWindow appends the column/row address and RAM write commands.
+func (st *ST7735) queueAddrWindow(q *spi.Batch, x0, y0, x1, y1 byte) {
+	st.queueCommand(q, CASET) // Column addr set
+	st.addWindowBuf[1] = x0 + st.xstart
+	st.addWindowBuf[3] = x1 + st.xstart
+	st.queueData(q, st.addWindowBuf[:])
+
+	st.queueCommand(q, RASET) // Row addr set
+	st.addWindowBuf[1] = y0 + st.ystart
+	st.addWindowBuf[3] = y1 + st.ystart
+	st.queueData(q, st.addWindowBuf[:])
+
+	st.queueCommand(q, RAMWR) // write to RAM
+}
+
+// queueColorRun appends [count] pixels of the same color as one data block.
+func (st *ST7735) queueColorRun(q *spi.Batch, color uint16, count int) {
+	run := make([]byte, count*bytesPerPixel)
+	for i := 0; i < len(run); i += bytesPerPixel {
+		run[i] = byte((color >> 8) & 0xff)
+		run[i+1] = byte(color & 0xff)
+	}
+	st.queueData(q, run)
 }
 
 // ----------------------------------------------------
@@ -387,14 +599,13 @@ func (st *ST7735) Blit(buffer []byte) {
 	//  st.SetAddrWindow(0,0,1,1);
 	// st.spi.TriggerPulse()
 
-	sp := st.spi
-	fi := sp.GetFTDI()
-
-	st.SetAddrWindow(0, 0, byte(st.Width-1), byte(st.Height-1))
+	q := st.queue
+	q.Reset()
 
-	fi.OutputHigh(st.dc)
+	st.queueAddrWindow(q, 0, 0, byte(st.Width-1), byte(st.Height-1))
+	st.queueData(q, buffer)
 
-	sp.Write(buffer)
+	q.Flush()
 }
 
 // ----------------------------------------------------
@@ -404,38 +615,25 @@ func (st *ST7735) Blit(buffer []byte) {
 // SetAddrWindow set row and column address of where a pixel will be written.
 // (aka setDrawPosition)
 func (st *ST7735) SetAddrWindow(x0, y0, x1, y1 byte) {
+	q := st.queue
+	q.Reset()
 
-	st.WriteCommand(CASET) // Column addr set
-	addWindowBuf[1] = x0 + st.xstart
-	addWindowBuf[3] = x1 + st.xstart
-	st.WriteDataChunk(addWindowBuf)
+	st.queueAddrWindow(q, x0, y0, x1, y1)
 
-	st.WriteCommand(RASET) // Row addr set
-	addWindowBuf[1] = y0 + st.ystart
-	addWindowBuf[3] = y1 + st.ystart
-	st.WriteDataChunk(addWindowBuf)
-
-	st.WriteCommand(RAMWR) // write to RAM
+	q.Flush()
 }
 
 // PushColor writes a 16bit color value based on the current draw position.
 // (aka writePixel)
 func (st *ST7735) PushColor(color uint16) {
-	sp := st.spi
-	fi := sp.GetFTDI()
-
-	fi.OutputHigh(st.dc)
+	q := st.queue
+	q.Reset()
 
-	colorPush[0] = byte((color >> 8) & 0xff)
-	colorPush[1] = byte(color & 0xff)
-	sp.Write(colorPush)
+	st.colorPush[0] = byte((color >> 8) & 0xff)
+	st.colorPush[1] = byte(color & 0xff)
+	st.queueData(q, st.colorPush[:])
 
-	// Or (slow)
-	// sp.Write([]byte{byte((color >> 8) & 0xff), byte(color)})
-
-	// Or (slowest)
-	// sp.WriteByte(byte(color>>8) & 0xff)
-	// sp.WriteByte(byte(color))
+	q.Flush()
 }
 
 // DrawPixel draws to device only.
@@ -445,19 +643,19 @@ func (st *ST7735) DrawPixel(x, y byte, color uint16) {
 		return
 	}
 
-	sp := st.spi
-	fi := sp.GetFTDI()
+	q := st.queue
+	q.Reset()
 
 	// First set "cursor"/"draw position"
 	// st.SetAddrWindow(x, y, x+1, y+1)
-	st.SetAddrWindow(x, y, 1, 1)
-
-	fi.OutputHigh(st.dc)
+	st.queueAddrWindow(q, x, y, 1, 1)
 
 	// Now draw.
-	colorPush[0] = byte((color >> 8) & 0xff)
-	colorPush[1] = byte(color & 0xff)
-	sp.Write(colorPush)
+	st.colorPush[0] = byte((color >> 8) & 0xff)
+	st.colorPush[1] = byte(color & 0xff)
+	st.queueData(q, st.colorPush[:])
+
+	q.Flush()
 }
 
 // DrawFastVLine draws a vertical line only
@@ -473,20 +671,13 @@ func (st *ST7735) DrawFastVLine(x, y, h byte, color uint16) {
 		h = byte(st.Height) - y
 	}
 
-	st.SetAddrWindow(x, y, x, y+h-1)
-
-	sp := st.spi
-	fi := sp.GetFTDI()
+	q := st.queue
+	q.Reset()
 
-	colorPush[0] = byte((color >> 8) & 0xff)
-	colorPush[1] = byte(color & 0xff)
+	st.queueAddrWindow(q, x, y, x, y+h-1)
+	st.queueColorRun(q, color, int(h))
 
-	fi.OutputHigh(st.dc)
-
-	for h > 0 {
-		sp.Write(colorPush)
-		h--
-	}
+	q.Flush()
 }
 
 // DrawFastHLine draws a horizontal line only
@@ -502,20 +693,13 @@ func (st *ST7735) DrawFastHLine(x, y, w byte, color uint16) {
 		w = byte(st.Width) - x
 	}
 
-	st.SetAddrWindow(x, y, x+w-1, y)
-
-	sp := st.spi
-	fi := sp.GetFTDI()
-
-	colorPush[0] = byte((color >> 8) & 0xff)
-	colorPush[1] = byte(color & 0xff)
+	q := st.queue
+	q.Reset()
 
-	fi.OutputHigh(st.dc)
+	st.queueAddrWindow(q, x, y, x+w-1, y)
+	st.queueColorRun(q, color, int(w))
 
-	for w > 0 {
-		sp.Write(colorPush)
-		w--
-	}
+	q.Flush()
 }
 
 // FillScreen fills the entire display area with "color"
@@ -571,7 +755,7 @@ func (st *ST7735) FillScreen(color uint16) {
 // 	// }
 // }
 
-// FillRectangle is a very slow fill.
+// FillRectangle fills a rectangle with a single batched write.
 func (st *ST7735) FillRectangle(x, y, w, h byte, color uint16) {
 	// log.Printf("%d x %d\n", st.Width, st.Height)
 	// rudimentary clipping (drawChar w/big text requires this)
@@ -588,18 +772,11 @@ func (st *ST7735) FillRectangle(x, y, w, h byte, color uint16) {
 		h = byte(st.Height) - y
 	}
 
-	st.SetAddrWindow(x, y, x+w-1, y+h-1)
-
-	sp := st.spi
-	fi := sp.GetFTDI()
+	q := st.queue
+	q.Reset()
 
-	fi.OutputHigh(st.dc)
+	st.queueAddrWindow(q, x, y, x+w-1, y+h-1)
+	st.queueColorRun(q, color, int(w)*int(h))
 
-	for y := h; y > 0; y-- {
-		for x := w; x > 0; x-- {
-			colorPush[0] = byte((color >> 8) & 0xff)
-			colorPush[1] = byte(color & 0xff)
-			sp.Write(colorPush)
-		}
-	}
+	q.Flush()
 }
@@ -1,10 +1,13 @@
 package st7735
 
 import (
+	"context"
 	"log"
 
+	"github.com/wdevore/hardware/ftdi"
 	"github.com/wdevore/hardware/ftdi/devices"
 	"github.com/wdevore/hardware/gpio"
+	"github.com/wdevore/hardware/spi"
 )
 
 var (
@@ -186,7 +189,17 @@ func NewST7735R(dataCommand, reset gpio.Pin, tab devices.TabColor, dimensions de
 	return st
 }
 
+// NewST7735RLines is NewST7735R with D/C and reset on any gpio.Line, for example a
+// native GPIO from the gpiochip package. [reset] may be nil.
+func NewST7735RLines(dataCommand, reset gpio.Line, tab devices.TabColor, dimensions devices.Dimensions) *ST7735R {
+	st := NewST7735R(gpio.NoPin, gpio.NoPin, tab, dimensions)
+	st.SetLines(dataCommand, reset)
+	return st
+}
+
 // Initialize configures and initializes ST7735
+// [options] choose a specific device when more than one is attached,
+// for example ftdi.WithSerial("FT0RN5XA").
 // Depending on how you have physically oriented the display device the xy origin
 // will be located differently.
 // With the connections pins situated at the bottom orientation produces:
@@ -197,9 +210,27 @@ func NewST7735R(dataCommand, reset gpio.Pin, tab devices.TabColor, dimensions de
 // decreasing y moves up
 // Thus the origin is in the top-left
 // 3 = right to left
-func (st *ST7735R) Initialize(vender, product, clockFreq int, chipSelect gpio.Pin, orientation devices.RotationMode, colorOder devices.ColorOrder) error {
+func (st *ST7735R) Initialize(vender, product, clockFreq int, chipSelect gpio.Pin, orientation devices.RotationMode, colorOder devices.ColorOrder, options ...ftdi.Option) error {
+	return st.InitializeContext(context.Background(), vender, product, clockFreq, chipSelect, orientation, colorOder, options...)
+}
+
+// InitializeContext is Initialize but aborts, between commands or during
+// the reset delays, when [ctx] is canceled or its deadline passes.
+func (st *ST7735R) InitializeContext(ctx context.Context, vender, product, clockFreq int, chipSelect gpio.Pin, orientation devices.RotationMode, colorOder devices.ColorOrder, options ...ftdi.Option) error {
+	conn, err := newSPI(vender, product, options...)
+	if err != nil {
+		return err
+	}
+
+	return st.InitializeConn(ctx, conn, clockFreq, chipSelect, orientation, colorOder)
+}
+
+// InitializeConn is InitializeContext over any SPI connection, for example
+// a spi.SoftSPI. Unless SetLines was called [conn] must be a gpio.Provider
+// for the D/C and reset pins.
+func (st *ST7735R) InitializeConn(ctx context.Context, conn spi.Conn, clockFreq int, chipSelect gpio.Pin, orientation devices.RotationMode, colorOder devices.ColorOrder) error {
 	// Initialize the ST7735 device
-	err := st.initialize(vender, product, clockFreq, chipSelect, colorOder)
+	err := st.initialize(ctx, conn, clockFreq, chipSelect, colorOder)
 	if err != nil {
 		return err
 	}
@@ -210,8 +241,36 @@ func (st *ST7735R) Initialize(vender, product, clockFreq int, chipSelect gpio.Pi
 	// protocol and must assert on each piece of data trafficking.
 	st.SetConstantCSAssert(true)
 
+	if orientation == devices.OrientationDefault {
+		// log.Println("ST7735R setting orientation to default")
+		orientation = devices.Orientation2
+	}
+	st.orientation = orientation
+
+	err = st.initPanel(ctx)
+	if err != nil {
+		return err
+	}
+
+	pixels := int(st.Width) * int(st.Height)
+	// log.Printf("ST7735R offset screen buffer size: (%d) bytes\n", st.screenBufferSize)
+
+	// A buffer of bytes
+	st.pushBuffer = make([]byte, pixels*2)
+
+	// The panel forgets its setup when it loses power, for example with a
+	// loose USB cable.
+	if r, ok := st.spi.(spi.Reconnector); ok {
+		r.OnReconnect(st.initPanel)
+	}
+
+	return nil
+}
+
+// initPanel resets the panel and sends the init sequence for its tab.
+func (st *ST7735R) initPanel(ctx context.Context) error {
 	// log.Println("ST7735R common init for rcmd1")
-	err = st.commonInit(rcmd1)
+	err := st.commonInit(ctx, rcmd1)
 	if err != nil {
 		return err
 	}
@@ -219,7 +278,7 @@ func (st *ST7735R) Initialize(vender, product, clockFreq int, chipSelect gpio.Pi
 	if st.tab == devices.RedTab {
 		st.colstart = 0
 		st.rowstart = 0
-		st.issueCommands(rcmd2red)
+		err = st.issueCommands(ctx, rcmd2red)
 	} else {
 		switch st.dimensions {
 		case devices.D128x128:
@@ -229,42 +288,36 @@ func (st *ST7735R) Initialize(vender, product, clockFreq int, chipSelect gpio.Pi
 			st.Width = 128
 			st.Height = 128
 			// log.Println("ST7735R issuing rcmd2green144")
-			st.issueCommands(rcmd2green144)
+			err = st.issueCommands(ctx, rcmd2green144)
 			break
 		case devices.D128x160:
 			st.colstart = 2
 			st.rowstart = 1
 			st.Width = 128
 			st.Height = 160
-			st.issueCommands(rcmd2green)
+			err = st.issueCommands(ctx, rcmd2green)
 			break
 		case devices.D160x80:
 			st.colstart = 24
 			st.rowstart = 0
 			st.Width = 160
 			st.Height = 80
-			st.issueCommands(rcmd2green160x80)
+			err = st.issueCommands(ctx, rcmd2green160x80)
 			break
 		}
 	}
-
-	pixels := int(st.Width) * int(st.Height)
-	// log.Printf("ST7735R offset screen buffer size: (%d) bytes\n", st.screenBufferSize)
-
-	// A buffer of bytes
-	st.pushBuffer = make([]byte, pixels*2)
+	if err != nil {
+		return err
+	}
 
 	// log.Println("ST7735R issuing rcmd3")
-	st.issueCommands(rcmd3)
-
-	if orientation == devices.OrientationDefault {
-		// log.Println("ST7735R setting orientation to default")
-		st.SetRotation(devices.Orientation2)
-	} else {
-		// log.Println("ST7735R setting orientation")
-		st.SetRotation(orientation)
+	err = st.issueCommands(ctx, rcmd3)
+	if err != nil {
+		return err
 	}
 
+	st.SetRotation(st.orientation)
+
 	return nil
 }
 
@@ -274,7 +327,7 @@ func (st *ST7735R) Close() error {
 	err := st.close()
 	if err != nil {
 		log.Println("Failed to close ST7735R.")
-		log.Fatal(err)
+		return err
 	}
 
 	log.Println("ST7735R closed.")
@@ -1,12 +1,14 @@
 package st7735
 
 import (
+	"context"
 	"log"
 	"time"
 
 	"github.com/wdevore/hardware/ftdi"
 	"github.com/wdevore/hardware/ftdi/devices"
 	"github.com/wdevore/hardware/gpio"
+	"github.com/wdevore/hardware/spi"
 )
 
 // Scanning methods
@@ -159,7 +161,17 @@ func NewST7735S(dataCommand, reset gpio.Pin, tab devices.TabColor, dimensions de
 	return st
 }
 
+// NewST7735SLines is NewST7735S with D/C and reset on any gpio.Line, for example a
+// native GPIO from the gpiochip package. [reset] may be nil.
+func NewST7735SLines(dataCommand, reset gpio.Line, tab devices.TabColor, dimensions devices.Dimensions) *ST7735S {
+	st := NewST7735S(gpio.NoPin, gpio.NoPin, tab, dimensions)
+	st.SetLines(dataCommand, reset)
+	return st
+}
+
 // Initialize configures and initializes ST7735
+// [options] choose a specific device when more than one is attached,
+// for example ftdi.WithSerial("FT0RN5XA").
 // Depending on how you have physically oriented the display device the xy origin
 // will be located differently.
 // With the connections pins situated at the bottom orientation produces:
@@ -170,9 +182,27 @@ func NewST7735S(dataCommand, reset gpio.Pin, tab devices.TabColor, dimensions de
 // decreasing y moves up
 // Thus the origin is in the top-left
 // 3 = right to left
-func (st *ST7735S) Initialize(vender, product, clockFreq int, chipSelect gpio.Pin, orientation devices.RotationMode, colorOrder devices.ColorOrder) error {
+func (st *ST7735S) Initialize(vender, product, clockFreq int, chipSelect gpio.Pin, orientation devices.RotationMode, colorOrder devices.ColorOrder, options ...ftdi.Option) error {
+	return st.InitializeContext(context.Background(), vender, product, clockFreq, chipSelect, orientation, colorOrder, options...)
+}
+
+// InitializeContext is Initialize but aborts, between commands or during
+// the reset delays, when [ctx] is canceled or its deadline passes.
+func (st *ST7735S) InitializeContext(ctx context.Context, vender, product, clockFreq int, chipSelect gpio.Pin, orientation devices.RotationMode, colorOrder devices.ColorOrder, options ...ftdi.Option) error {
+	conn, err := newSPI(vender, product, options...)
+	if err != nil {
+		return err
+	}
+
+	return st.InitializeConn(ctx, conn, clockFreq, chipSelect, orientation, colorOrder)
+}
+
+// InitializeConn is InitializeContext over any SPI connection, for example
+// a spi.SoftSPI. Unless SetLines was called [conn] must be a gpio.Provider
+// for the D/C and reset pins.
+func (st *ST7735S) InitializeConn(ctx context.Context, conn spi.Conn, clockFreq int, chipSelect gpio.Pin, orientation devices.RotationMode, colorOrder devices.ColorOrder) error {
 	// Initialize the ST7735 device
-	err := st.initialize(vender, product, clockFreq, chipSelect, colorOrder)
+	err := st.initialize(ctx, conn, clockFreq, chipSelect, colorOrder)
 	if err != nil {
 		return err
 	}
@@ -183,12 +213,6 @@ func (st *ST7735S) Initialize(vender, product, clockFreq int, chipSelect gpio.Pi
 	// protocol and must assert on each piece of data trafficking.
 	st.SetConstantCSAssert(true)
 
-	log.Println("ST7735S common init")
-	err = st.commonInit(initCmd)
-	if err != nil {
-		return err
-	}
-
 	switch st.dimensions {
 	case devices.D128x160:
 		st.Width = 160
@@ -200,33 +224,64 @@ func (st *ST7735S) Initialize(vender, product, clockFreq int, chipSelect gpio.Pi
 		break
 	}
 
-	time.Sleep(time.Millisecond * 200)
+	pixels := int(st.Width) * int(st.Height)
+	// log.Printf("ST7735S offset screen buffer size: (%d) bytes\n", st.screenBufferSize)
 
-	err = st.WriteCommand(SLPOUT)
+	// A buffer of bytes
+	st.pushBuffer = make([]byte, pixels*2)
+
+	if orientation == devices.OrientationDefault {
+		// log.Println("ST7735S setting orientation to default")
+		orientation = devices.Orientation2
+	}
+	st.orientation = orientation
+
+	err = st.initPanel(ctx)
 	if err != nil {
-		log.Printf("ST7735S failed to write SLPOUT: %v\n", err)
 		return err
 	}
 
-	time.Sleep(time.Millisecond * 120)
+	// The panel forgets its setup when it loses power, for example with a
+	// loose USB cable.
+	if r, ok := st.spi.(spi.Reconnector); ok {
+		r.OnReconnect(st.initPanel)
+	}
+
+	// The backlight is on D7, if the SPI connection has pins.
+	if _, ok := st.spi.(gpio.Provider); !ok {
+		return nil
+	}
 
-	// st.DisplayOn(true)
+	return st.EnableBacklightControl(ftdi.D7)
+}
 
-	pixels := int(st.Width) * int(st.Height)
-	// log.Printf("ST7735S offset screen buffer size: (%d) bytes\n", st.screenBufferSize)
+// initPanel resets the panel and sends the init sequence.
+func (st *ST7735S) initPanel(ctx context.Context) error {
+	log.Println("ST7735S common init")
+	err := st.commonInit(ctx, initCmd)
+	if err != nil {
+		return err
+	}
 
-	// A buffer of bytes
-	st.pushBuffer = make([]byte, pixels*2)
+	err = devices.SleepContext(ctx, time.Millisecond*200)
+	if err != nil {
+		return err
+	}
 
-	if orientation == devices.OrientationDefault {
-		// log.Println("ST7735S setting orientation to default")
-		st.SetRotation(devices.Orientation2)
-	} else {
-		// log.Println("ST7735S setting orientation")
-		st.SetRotation(orientation)
+	err = st.WriteCommand(SLPOUT)
+	if err != nil {
+		log.Printf("ST7735S failed to write SLPOUT: %v\n", err)
+		return err
 	}
 
-	st.EnableBacklightControl(ftdi.D7)
+	err = devices.SleepContext(ctx, time.Millisecond*120)
+	if err != nil {
+		return err
+	}
+
+	// st.DisplayOn(true)
+
+	st.SetRotation(st.orientation)
 
 	return nil
 }
@@ -324,7 +379,7 @@ func (st *ST7735S) Close() error {
 	err := st.close()
 	if err != nil {
 		log.Println("Failed to close ST7735S.")
-		log.Fatal(err)
+		return err
 	}
 
 	log.Println("ST7735S closed.")
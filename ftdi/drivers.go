@@ -0,0 +1,186 @@
+package ftdi
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// The kernel's USB serial driver claims FTDI interfaces as /dev/ttyUSBn and
+// libftdi can't use an interface while it is bound. On Linux only the
+// interface being opened is unbound, through sysfs, and Close binds it
+// again. Other USB serial adapters on the host are left alone.
+//
+// Writing the sysfs unbind/bind files needs root. A udev rule (see
+// UdevRule) does the same without root each time the device is plugged in
+// and also gives users access to the device node.
+
+// Linux lists each driver's bind and unbind files here.
+var usbDriversPath = "/sys/bus/usb/drivers"
+
+// serialDriver is the Linux driver that binds FTDI interfaces.
+const serialDriver = "ftdi_sio"
+
+// The macOS driver, unloaded as a whole as there is no per device unbind.
+const darwinDriverBundle = "com.apple.driver.AppleUSBFTDI"
+
+// UdevRulesPath is where InstallUdevRule is usually asked to write.
+const UdevRulesPath = "/etc/udev/rules.d/99-ftdi.rules"
+
+// interfaceName returns the sysfs name of [channel]'s interface on the
+// device at USB [path], for example "1-2.3:1.0".
+func interfaceName(path string, channel Channel) string {
+	number := 0
+	if channel > ChannelAny {
+		number = int(channel - ChannelA)
+	}
+	return fmt.Sprintf("%s:1.%d", path, number)
+}
+
+// boundDriver returns the name of the driver bound to the interface, "" if
+// none.
+func boundDriver(iface string) string {
+	target, err := os.Readlink(filepath.Join(usbDevicesPath, iface, "driver"))
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(target)
+}
+
+// writeDriverFile writes [iface] to the serial driver's bind or unbind file.
+func writeDriverFile(name, iface string) error {
+	path := filepath.Join(usbDriversPath, serialDriver, name)
+
+	err := os.WriteFile(path, []byte(iface), 0)
+	if errors.Is(err, os.ErrPermission) {
+		return fmt.Errorf("%w: writing %s needs root, or install a udev rule (see UdevRule)", ErrPermission, path)
+	}
+	if err != nil {
+		return fmt.Errorf("ftdi: %s %s: %w", name, iface, err)
+	}
+
+	return nil
+}
+
+// detachDriver unbinds the kernel serial driver from [channel] of [info],
+// the device about to be opened, so libftdi can claim it.
+func (f *FTDI232H) detachDriver(info DeviceInfo, channel Channel) error {
+	switch runtime.GOOS {
+	case "linux":
+		iface := interfaceName(info.Path, channel)
+		if boundDriver(iface) != serialDriver {
+			return nil
+		}
+
+		err := writeDriverFile("unbind", iface)
+		if err != nil {
+			return err
+		}
+
+		// A reconnect unbinds the re-plugged device again.
+		for _, u := range f.unbound {
+			if u == iface {
+				return nil
+			}
+		}
+		f.unbound = append(f.unbound, iface)
+	case "darwin":
+		if f.kextUnloaded {
+			return nil
+		}
+
+		err := runDriverCommand("kextunload", "-b", darwinDriverBundle)
+		if err != nil {
+			return err
+		}
+
+		f.kextUnloaded = true
+	}
+
+	return nil
+}
+
+// reattachDrivers binds the kernel serial driver to what detachDriver
+// unbound. It returns the first error but tries every interface.
+func (f *FTDI232H) reattachDrivers() error {
+	var first error
+
+	for _, iface := range f.unbound {
+		if _, err := os.Stat(filepath.Join(usbDevicesPath, iface)); os.IsNotExist(err) {
+			// Unplugged, the kernel binds it again when it comes back.
+			continue
+		}
+
+		err := writeDriverFile("bind", iface)
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	f.unbound = nil
+
+	if f.kextUnloaded {
+		err := runDriverCommand("kextload", "-b", darwinDriverBundle)
+		if err != nil && first == nil {
+			first = err
+		}
+		f.kextUnloaded = false
+	}
+
+	return first
+}
+
+func runDriverCommand(name string, args ...string) error {
+	output, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ftdi: %s %s: %w: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// UdevRule returns udev rules that let [group] use the vender/product
+// devices without root, and unbind the kernel serial driver from them when
+// they are plugged in, so Initialize(false) is enough. If [serial] isn't
+// empty only the device with that serial number is matched.
+func UdevRule(vender, product int, serial, group string) string {
+	match := fmt.Sprintf(`ATTRS{idVendor}=="%04x", ATTRS{idProduct}=="%04x"`, vender, product)
+	if serial != "" {
+		match += fmt.Sprintf(`, ATTRS{serial}=="%s"`, serial)
+	}
+
+	var rule strings.Builder
+
+	fmt.Fprintf(&rule, "# FTDI %04x:%04x", vender, product)
+	if serial != "" {
+		fmt.Fprintf(&rule, " serial %s", serial)
+	}
+	fmt.Fprintf(&rule, ": read/write for group %s, %s unbound.\n", group, serialDriver)
+
+	fmt.Fprintf(&rule, `SUBSYSTEM=="usb", ENV{DEVTYPE}=="usb_device", %s, MODE="0660", GROUP="%s"`+"\n", match, group)
+	fmt.Fprintf(&rule, `ACTION=="add|bind", SUBSYSTEM=="usb", DRIVER=="%s", %s, RUN+="/bin/sh -c 'echo -n %%k > %s'"`+"\n",
+		serialDriver, match, filepath.Join(usbDriversPath, serialDriver, "unbind"))
+
+	return rule.String()
+}
+
+// InstallUdevRule writes [rule] to [path], typically UdevRulesPath, and has
+// udev apply it to the devices already plugged in. It needs root, once.
+func InstallUdevRule(path, rule string) error {
+	err := os.WriteFile(path, []byte(rule), 0644)
+	if errors.Is(err, os.ErrPermission) {
+		return fmt.Errorf("%w: writing %s needs root", ErrPermission, path)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = runDriverCommand("udevadm", "control", "--reload-rules")
+	if err != nil {
+		return err
+	}
+
+	return runDriverCommand("udevadm", "trigger", "--subsystem-match=usb", "--action=add")
+}
@@ -0,0 +1,594 @@
+package ftdi
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/wdevore/hardware/gpio"
+)
+
+// The FT232H keeps its USB identity and pin setup in an external 93C56
+// EEPROM (128 16 bit words). The layout below matches what FT_PROG and
+// libftdi write. Changes take effect after the device is re-plugged.
+
+const (
+	// EEPROMSize is the size in bytes of the 93C56 fitted to FT232H boards.
+	EEPROMSize = 256
+	// Strings are stored as USB string descriptors from this byte address.
+	eepromStringsStart = 0xa0
+	// The last word holds the checksum.
+	eepromChecksumAddr = EEPROMSize - 2
+)
+
+// Byte addresses of the fields.
+const (
+	eepromChannel      = 0x00
+	eepromFT1284       = 0x01
+	eepromVendor       = 0x02
+	eepromProduct      = 0x04
+	eepromRelease      = 0x06
+	eepromAttributes   = 0x08
+	eepromMaxPower     = 0x09
+	eepromChipConfig   = 0x0a
+	eepromDriveAD      = 0x0c
+	eepromDriveAC      = 0x0d
+	eepromManufacturer = 0x0e
+	eepromDescription  = 0x10
+	eepromSerial       = 0x12
+	eepromCBUS         = 0x18
+	eepromChipType     = 0x1e
+)
+
+// Bits of the fields.
+const (
+	channelVCP = 0x10
+	// libftdi names this bit POWER_SAVE_DISABLE_H but sets it for its
+	// powersave setting, as D2XX does for PowerSaveEnable. Set means power
+	// save is on.
+	ft1284PowerSave    = 0x80
+	attributeBus       = 0x80
+	attributeSelf      = 0x40
+	attributeWakeup    = 0x20
+	configPullDowns    = 0x04
+	configSerialNumber = 0x08
+	driveCurrentMask   = 0x03
+	driveSlowSlew      = 0x04
+	driveSchmitt       = 0x08
+)
+
+// Errors returned by the EEPROM functions.
+var (
+	// ErrNoEEPROM is returned when the Transport can't reach the EEPROM.
+	ErrNoEEPROM = errors.New("ftdi: transport doesn't support EEPROM access")
+	// ErrEEPROMChecksum is returned when an image's checksum doesn't match.
+	ErrEEPROMChecksum = errors.New("ftdi: EEPROM checksum mismatch")
+	// ErrEEPROMStrings is returned when the strings don't fit the EEPROM.
+	ErrEEPROMStrings = errors.New("ftdi: EEPROM strings too long")
+	// ErrEEPROMVerify is returned when the EEPROM doesn't read back what was written.
+	ErrEEPROMVerify = errors.New("ftdi: EEPROM verify failed")
+)
+
+// ChecksumError reports the checksum found in an image and the one expected.
+type ChecksumError struct {
+	Expected uint16
+	Found    uint16
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("ftdi: EEPROM checksum mismatch, expected 0x%04x, found 0x%04x", e.Expected, e.Found)
+}
+
+// Is matches ErrEEPROMChecksum.
+func (e *ChecksumError) Is(target error) bool {
+	return target == ErrEEPROMChecksum
+}
+
+// EEPROMTransport is implemented by Transports that can reach the
+// configuration EEPROM. It is separate from Transport so MPSSE only
+// transports don't need it.
+type EEPROMTransport interface {
+	// ReadEEPROMWord reads the 16 bit word at word address [addr].
+	ReadEEPROMWord(addr int) (uint16, error)
+	// WriteEEPROMWord writes the 16 bit word at word address [addr].
+	WriteEEPROMWord(addr int, value uint16) error
+}
+
+// CBUSFunction selects what an ACBUS pin does outside of MPSSE mode.
+// The values match libftdi's CBUSH_xxx constants.
+type CBUSFunction byte
+
+const (
+	// CBUSTristate leaves the pin floating
+	CBUSTristate CBUSFunction = iota
+	// CBUSRXLED pulses low when receiving
+	CBUSRXLED
+	// CBUSTXLED pulses low when transmitting
+	CBUSTXLED
+	// CBUSTXRXLED pulses low when receiving or transmitting
+	CBUSTXRXLED
+	// CBUSPWREN is low once the device is configured by USB
+	CBUSPWREN
+	// CBUSSleep is low while the device is suspended
+	CBUSSleep
+	// CBUSDrive0 drives the pin low
+	CBUSDrive0
+	// CBUSDrive1 drives the pin high
+	CBUSDrive1
+	// CBUSIOMode makes the pin a GPIO, driven with CBUS bitbang (ModeCBUS)
+	CBUSIOMode
+	// CBUSTXDEN enables an RS485 transmitter
+	CBUSTXDEN
+	// CBUSClk30 outputs a 30MHz clock
+	CBUSClk30
+	// CBUSClk15 outputs a 15MHz clock
+	CBUSClk15
+	// CBUSClk7_5 outputs a 7.5MHz clock
+	CBUSClk7_5
+)
+
+var cbusFunctionNames = []string{
+	"tristate", "rxled", "txled", "txrxled", "pwren", "sleep",
+	"drive0", "drive1", "iomode", "txden", "clk30", "clk15", "clk7_5",
+}
+
+func (c CBUSFunction) String() string {
+	if int(c) < len(cbusFunctionNames) {
+		return cbusFunctionNames[c]
+	}
+	return fmt.Sprintf("CBUSFunction(%d)", c)
+}
+
+// ParseCBUSFunction converts a name as printed by String, for example
+// "iomode", to a CBUSFunction. "gpio" is accepted for CBUSIOMode.
+func ParseCBUSFunction(name string) (CBUSFunction, error) {
+	name = strings.ToLower(name)
+	if name == "gpio" {
+		return CBUSIOMode, nil
+	}
+	for i, n := range cbusFunctionNames {
+		if n == name {
+			return CBUSFunction(i), nil
+		}
+	}
+	return 0, fmt.Errorf("ftdi: unknown CBUS function %q", name)
+}
+
+// DriveCurrent is the output drive strength of a pin group.
+type DriveCurrent byte
+
+const (
+	// Drive4mA is the weakest drive strength and the chip default
+	Drive4mA DriveCurrent = iota
+	// Drive8mA is 8mA
+	Drive8mA
+	// Drive12mA is 12mA
+	Drive12mA
+	// Drive16mA is the strongest drive strength
+	Drive16mA
+)
+
+// Milliamps returns the drive strength in mA.
+func (d DriveCurrent) Milliamps() int {
+	return 4 * (int(d&driveCurrentMask) + 1)
+}
+
+func (d DriveCurrent) String() string {
+	return fmt.Sprintf("%dmA", d.Milliamps())
+}
+
+// PinDrive is the output setup of a pin group (ADBUS or ACBUS).
+type PinDrive struct {
+	Current  DriveCurrent
+	SlowSlew bool
+	Schmitt  bool
+}
+
+func (p PinDrive) String() string {
+	s := p.Current.String()
+	if p.SlowSlew {
+		s += " slow slew"
+	}
+	if p.Schmitt {
+		s += " schmitt"
+	}
+	return s
+}
+
+// EEPROM is the decoded FT232H EEPROM. Fields the decoder doesn't know
+// about are kept as read and written back unchanged.
+type EEPROM struct {
+	VendorID  uint16
+	ProductID uint16
+	// Release is the bcdDevice reported to the host, 0x0900 for the FT232H.
+	Release uint16
+
+	Manufacturer string
+	Description  string
+	Serial       string
+	// SerialEnabled reports the serial number to the host. Without it
+	// selecting a device by serial (see WithSerial) isn't possible.
+	SerialEnabled bool
+
+	SelfPowered  bool
+	RemoteWakeup bool
+	// MaxPower is the bus current requested from the host in mA (max 500).
+	MaxPower int
+	// PowerSave lets the chip suspend while its power save input
+	// (PWRSAV#) is low, for self-powered designs.
+	PowerSave bool
+	// SuspendPullDowns pulls the pins low while suspended.
+	SuspendPullDowns bool
+	// VCPDriver asks Windows to load the virtual COM port driver.
+	VCPDriver bool
+
+	// DriveAD is the setup of D0-D7 and DriveAC of C0-C9.
+	DriveAD PinDrive
+	DriveAC PinDrive
+
+	// CBUS is the function of each of C0-C9. Only C5, C6, C8 and C9
+	// support CBUSIOMode.
+	CBUS [10]CBUSFunction
+
+	// The image this was decoded from.
+	raw []byte
+}
+
+// NewEEPROM returns the factory configuration of an FT232H.
+func NewEEPROM() *EEPROM {
+	e := &EEPROM{
+		VendorID:      0x0403,
+		ProductID:     0x6014,
+		Release:       0x0900,
+		Manufacturer:  "FTDI",
+		Description:   "Single RS232-HS",
+		SerialEnabled: true,
+		MaxPower:      90,
+		VCPDriver:     true,
+	}
+	e.CBUS[0] = CBUSTXDEN
+	e.CBUS[1] = CBUSPWREN
+	e.CBUS[2] = CBUSRXLED
+	e.CBUS[3] = CBUSTXLED
+	e.CBUS[4] = CBUSTXRXLED
+	e.CBUS[5] = CBUSTristate
+	e.CBUS[6] = CBUSTristate
+	e.CBUS[7] = CBUSTristate
+	e.CBUS[8] = CBUSDrive1
+	e.CBUS[9] = CBUSDrive0
+	return e
+}
+
+// EEPROMChecksum computes the checksum of [image], stored in its last word.
+func EEPROMChecksum(image []byte) uint16 {
+	checksum := uint16(0xaaaa)
+	for i := 0; i < len(image)-2; i += 2 {
+		checksum ^= uint16(image[i]) | uint16(image[i+1])<<8
+		checksum = checksum<<1 | checksum>>15
+	}
+	return checksum
+}
+
+// VerifyEEPROMChecksum returns a *ChecksumError if [image]'s checksum is wrong.
+func VerifyEEPROMChecksum(image []byte) error {
+	n := len(image)
+	expected := EEPROMChecksum(image)
+	found := uint16(image[n-2]) | uint16(image[n-1])<<8
+	if found != expected {
+		return &ChecksumError{Expected: expected, Found: found}
+	}
+	return nil
+}
+
+// DecodeEEPROM decodes an EEPROMSize byte image. The checksum isn't
+// checked, see VerifyEEPROMChecksum.
+func DecodeEEPROM(image []byte) (*EEPROM, error) {
+	if len(image) != EEPROMSize {
+		return nil, fmt.Errorf("ftdi: EEPROM image is (%d) bytes, expected (%d)", len(image), EEPROMSize)
+	}
+
+	e := new(EEPROM)
+	e.raw = append([]byte{}, image...)
+
+	word := func(addr int) uint16 {
+		return uint16(image[addr]) | uint16(image[addr+1])<<8
+	}
+
+	e.VendorID = word(eepromVendor)
+	e.ProductID = word(eepromProduct)
+	e.Release = word(eepromRelease)
+
+	e.Manufacturer = decodeEEPROMString(image, eepromManufacturer)
+	e.Description = decodeEEPROMString(image, eepromDescription)
+	e.Serial = decodeEEPROMString(image, eepromSerial)
+	e.SerialEnabled = image[eepromChipConfig]&configSerialNumber != 0
+
+	e.SelfPowered = image[eepromAttributes]&attributeSelf != 0
+	e.RemoteWakeup = image[eepromAttributes]&attributeWakeup != 0
+	e.MaxPower = int(image[eepromMaxPower]) * 2
+	e.PowerSave = image[eepromFT1284]&ft1284PowerSave != 0
+	e.SuspendPullDowns = image[eepromChipConfig]&configPullDowns != 0
+	e.VCPDriver = image[eepromChannel]&channelVCP != 0
+
+	e.DriveAD = decodePinDrive(image[eepromDriveAD])
+	e.DriveAC = decodePinDrive(image[eepromDriveAC])
+
+	for i := range e.CBUS {
+		nibble := image[eepromCBUS+i/2] >> (4 * uint(i%2))
+		e.CBUS[i] = CBUSFunction(nibble & 0x0f)
+	}
+
+	return e, nil
+}
+
+func decodePinDrive(b byte) PinDrive {
+	return PinDrive{
+		Current:  DriveCurrent(b & driveCurrentMask),
+		SlowSlew: b&driveSlowSlew != 0,
+		Schmitt:  b&driveSchmitt != 0,
+	}
+}
+
+func encodePinDrive(p PinDrive) byte {
+	b := byte(p.Current) & driveCurrentMask
+	if p.SlowSlew {
+		b |= driveSlowSlew
+	}
+	if p.Schmitt {
+		b |= driveSchmitt
+	}
+	return b
+}
+
+// decodeEEPROMString reads the string descriptor whose byte address and
+// length are stored at [field].
+func decodeEEPROMString(image []byte, field int) string {
+	addr := int(image[field]) & (EEPROMSize - 1)
+	length := int(image[field+1])
+	if length < 2 || addr+length > len(image)-2 {
+		return ""
+	}
+
+	chars := make([]uint16, 0, (length-2)/2)
+	for i := addr + 2; i+1 < addr+length; i += 2 {
+		chars = append(chars, uint16(image[i])|uint16(image[i+1])<<8)
+	}
+	return string(utf16.Decode(chars))
+}
+
+// Encode builds the EEPROMSize byte image, checksum included.
+func (e *EEPROM) Encode() ([]byte, error) {
+	image := make([]byte, EEPROMSize)
+	copy(image, e.raw)
+
+	putWord := func(addr int, value uint16) {
+		image[addr] = byte(value)
+		image[addr+1] = byte(value >> 8)
+	}
+
+	putWord(eepromVendor, e.VendorID)
+	putWord(eepromProduct, e.ProductID)
+	putWord(eepromRelease, e.Release)
+
+	image[eepromAttributes] = setBits(image[eepromAttributes]|attributeBus, attributeSelf, e.SelfPowered)
+	image[eepromAttributes] = setBits(image[eepromAttributes], attributeWakeup, e.RemoteWakeup)
+
+	maxPower := e.MaxPower
+	if maxPower > 500 {
+		maxPower = 500
+	}
+	if maxPower < 0 {
+		maxPower = 0
+	}
+	image[eepromMaxPower] = byte(maxPower / 2)
+
+	image[eepromFT1284] = setBits(image[eepromFT1284], ft1284PowerSave, e.PowerSave)
+	image[eepromChipConfig] = setBits(image[eepromChipConfig], configPullDowns, e.SuspendPullDowns)
+	image[eepromChipConfig] = setBits(image[eepromChipConfig], configSerialNumber, e.SerialEnabled)
+	image[eepromChannel] = setBits(image[eepromChannel], channelVCP, e.VCPDriver)
+
+	image[eepromDriveAD] = encodePinDrive(e.DriveAD)
+	image[eepromDriveAC] = encodePinDrive(e.DriveAC)
+
+	for i := 0; i < len(e.CBUS); i += 2 {
+		image[eepromCBUS+i/2] = byte(e.CBUS[i]&0x0f) | byte(e.CBUS[i+1]&0x0f)<<4
+	}
+
+	// The chip type byte is 0x56 for a 93C56. A blank EEPROM reads 0xff.
+	if image[eepromChipType] == 0xff || image[eepromChipType] == 0x00 {
+		image[eepromChipType] = 0x56
+	}
+
+	// The string descriptors are packed one after the other.
+	addr := eepromStringsStart
+	for _, s := range []struct {
+		field int
+		value string
+	}{
+		{eepromManufacturer, e.Manufacturer},
+		{eepromDescription, e.Description},
+		{eepromSerial, e.Serial},
+	} {
+		chars := utf16.Encode([]rune(s.value))
+		length := 2 + 2*len(chars)
+		if addr+length > eepromChecksumAddr {
+			return nil, fmt.Errorf("%w: (%d) characters available for all three", ErrEEPROMStrings, (eepromChecksumAddr-eepromStringsStart-6)/2)
+		}
+
+		image[s.field] = byte(addr)
+		image[s.field+1] = byte(length)
+		image[addr] = byte(length)
+		image[addr+1] = 0x03 // USB string descriptor
+		for i, c := range chars {
+			putWord(addr+2+2*i, c)
+		}
+		addr += length
+	}
+
+	// Clear what's left of the string area, a previous string may have been longer.
+	for ; addr < eepromChecksumAddr; addr++ {
+		image[addr] = 0
+	}
+
+	putWord(eepromChecksumAddr, EEPROMChecksum(image))
+
+	return image, nil
+}
+
+func setBits(b, bits byte, on bool) byte {
+	if on {
+		return b | bits
+	}
+	return b &^ bits
+}
+
+// SetCBUS sets the function of one of the pins C0-C9.
+func (e *EEPROM) SetCBUS(pin gpio.Pin, function CBUSFunction) error {
+	if pin < C0 || pin > C9 {
+		return fmt.Errorf("ftdi: pin %d isn't a CBUS pin", pin)
+	}
+	e.CBUS[pin-C0] = function
+	return nil
+}
+
+// Fields returns the decoded settings as name/value pairs in a fixed order.
+func (e *EEPROM) Fields() [][2]string {
+	fields := [][2]string{
+		{"vendor", fmt.Sprintf("0x%04x", e.VendorID)},
+		{"product", fmt.Sprintf("0x%04x", e.ProductID)},
+		{"release", fmt.Sprintf("0x%04x", e.Release)},
+		{"manufacturer", fmt.Sprintf("%q", e.Manufacturer)},
+		{"description", fmt.Sprintf("%q", e.Description)},
+		{"serial", fmt.Sprintf("%q", e.Serial)},
+		{"serial enabled", fmt.Sprint(e.SerialEnabled)},
+		{"self powered", fmt.Sprint(e.SelfPowered)},
+		{"remote wakeup", fmt.Sprint(e.RemoteWakeup)},
+		{"max power", fmt.Sprintf("%dmA", e.MaxPower)},
+		{"power save", fmt.Sprint(e.PowerSave)},
+		{"suspend pull downs", fmt.Sprint(e.SuspendPullDowns)},
+		{"vcp driver", fmt.Sprint(e.VCPDriver)},
+		{"drive AD", e.DriveAD.String()},
+		{"drive AC", e.DriveAC.String()},
+	}
+	for i, c := range e.CBUS {
+		fields = append(fields, [2]string{fmt.Sprintf("C%d", i), c.String()})
+	}
+	return fields
+}
+
+func (e *EEPROM) String() string {
+	var b strings.Builder
+	for _, f := range e.Fields() {
+		fmt.Fprintf(&b, "%-20s %s\n", f[0]+":", f[1])
+	}
+	return b.String()
+}
+
+// Diff lists the settings that differ from [from] to e, one
+// "name: old -> new" line each. Handy as a dry-run before WriteEEPROM.
+func (e *EEPROM) Diff(from *EEPROM) []string {
+	old := from.Fields()
+	diff := []string{}
+	for i, f := range e.Fields() {
+		if old[i][1] != f[1] {
+			diff = append(diff, fmt.Sprintf("%s: %s -> %s", f[0], old[i][1], f[1]))
+		}
+	}
+	return diff
+}
+
+// ReadEEPROMImage reads the raw EEPROMSize byte image.
+func (f *FTDI232H) ReadEEPROMImage() ([]byte, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.readEEPROMImage()
+}
+
+func (f *FTDI232H) readEEPROMImage() ([]byte, error) {
+	err := f.openIfNeeded()
+	if err != nil {
+		return nil, err
+	}
+
+	eeprom, ok := f.device.(EEPROMTransport)
+	if !ok {
+		return nil, ErrNoEEPROM
+	}
+
+	image := make([]byte, EEPROMSize)
+	for addr := 0; addr < EEPROMSize/2; addr++ {
+		value, err := eeprom.ReadEEPROMWord(addr)
+		if err != nil {
+			return nil, err
+		}
+		image[2*addr] = byte(value)
+		image[2*addr+1] = byte(value >> 8)
+	}
+
+	return image, nil
+}
+
+// ReadEEPROM reads and decodes the EEPROM. If the checksum is wrong, as it
+// is for a blank EEPROM, the decoded EEPROM is returned together with a
+// *ChecksumError so it can still be inspected and written back.
+func (f *FTDI232H) ReadEEPROM() (*EEPROM, error) {
+	image, err := f.ReadEEPROMImage()
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := DecodeEEPROM(image)
+	if err != nil {
+		return nil, err
+	}
+
+	return e, VerifyEEPROMChecksum(image)
+}
+
+// WriteEEPROM encodes [e] and writes the words that differ from what the
+// EEPROM holds, then reads it back to verify. Re-plug the device for the
+// changes to take effect.
+func (f *FTDI232H) WriteEEPROM(e *EEPROM) error {
+	image, err := e.Encode()
+	if err != nil {
+		return err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	current, err := f.readEEPROMImage()
+	if err != nil {
+		return err
+	}
+
+	eeprom := f.device.(EEPROMTransport)
+
+	for addr := 0; addr < EEPROMSize/2; addr++ {
+		value := uint16(image[2*addr]) | uint16(image[2*addr+1])<<8
+		if value == uint16(current[2*addr])|uint16(current[2*addr+1])<<8 {
+			continue
+		}
+
+		err = eeprom.WriteEEPROMWord(addr, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	written, err := f.readEEPROMImage()
+	if err != nil {
+		return err
+	}
+
+	for i := range image {
+		if written[i] != image[i] {
+			return fmt.Errorf("%w at byte 0x%02x, wrote 0x%02x, read 0x%02x", ErrEEPROMVerify, i, image[i], written[i])
+		}
+	}
+
+	e.raw = image
+
+	return nil
+}
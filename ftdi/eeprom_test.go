@@ -0,0 +1,119 @@
+package ftdi_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/wdevore/hardware/ftdi"
+)
+
+func TestEEPROMChecksum(t *testing.T) {
+	image := make([]byte, ftdi.EEPROMSize)
+
+	// 0xaaaa rotated left once per word but the last, 127 times.
+	if sum := ftdi.EEPROMChecksum(image); sum != 0x5555 {
+		t.Errorf("blank image checksum %#04x, want 0x5555", sum)
+	}
+
+	// 0xaaab rotated 127 times is 0xaaab rotated right once.
+	image[0] = 0x01
+	if sum := ftdi.EEPROMChecksum(image); sum != 0xd555 {
+		t.Errorf("checksum %#04x, want 0xd555", sum)
+	}
+
+	image[ftdi.EEPROMSize-2] = 0x55
+	image[ftdi.EEPROMSize-1] = 0xd5
+	if err := ftdi.VerifyEEPROMChecksum(image); err != nil {
+		t.Error(err)
+	}
+
+	image[0x10] = 0xff
+	err := ftdi.VerifyEEPROMChecksum(image)
+	if !errors.Is(err, ftdi.ErrEEPROMChecksum) {
+		t.Errorf("corrupted image: %v, want ErrEEPROMChecksum", err)
+	}
+}
+
+func TestEEPROMFactoryImage(t *testing.T) {
+	image, err := ftdi.NewEEPROM().Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The factory header: VCP driver, 0403:6014 release 0x0900, bus
+	// powered at 90mA, serial number enabled, the strings from 0xa0.
+	header := []byte{
+		0x10, 0x00, 0x03, 0x04, 0x14, 0x60, 0x00, 0x09,
+		0x80, 0x2d, 0x08, 0x00, 0x00, 0x00, 0xa0, 0x0a,
+		0xaa, 0x20, 0xca, 0x02,
+	}
+	if !bytes.Equal(image[:len(header)], header) {
+		t.Errorf("header % x, want % x", image[:len(header)], header)
+	}
+
+	// TXDEN, PWREN, RXLED, TXLED, TXRXLED, tristate x3, drive 1, drive 0.
+	cbus := []byte{0x49, 0x21, 0x03, 0x00, 0x67}
+	if got := image[0x18:0x1d]; !bytes.Equal(got, cbus) {
+		t.Errorf("CBUS % x, want % x", got, cbus)
+	}
+	if image[0x1e] != 0x56 {
+		t.Errorf("chip type %#02x, want 0x56", image[0x1e])
+	}
+
+	manufacturer := []byte{0x0a, 0x03, 'F', 0, 'T', 0, 'D', 0, 'I', 0}
+	if got := image[0xa0:0xaa]; !bytes.Equal(got, manufacturer) {
+		t.Errorf("manufacturer descriptor % x, want % x", got, manufacturer)
+	}
+
+	if err := ftdi.VerifyEEPROMChecksum(image); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEEPROMRoundTrip(t *testing.T) {
+	e := ftdi.NewEEPROM()
+	e.Manufacturer = "Acme"
+	e.Description = "Probe"
+	e.Serial = "AC000042"
+	e.SelfPowered = true
+	e.RemoteWakeup = true
+	e.MaxPower = 500
+	e.PowerSave = true
+	e.SuspendPullDowns = true
+	e.VCPDriver = false
+	e.DriveAD = ftdi.PinDrive{Current: ftdi.Drive16mA, SlowSlew: true}
+	e.DriveAC = ftdi.PinDrive{Current: ftdi.Drive8mA, Schmitt: true}
+	e.SetCBUS(ftdi.C5, ftdi.CBUSIOMode)
+	e.SetCBUS(ftdi.C9, ftdi.CBUSClk15)
+
+	image, err := e.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if image[0x01]&0x80 == 0 {
+		t.Error("power save bit 0x80 of byte 0x01 isn't set")
+	}
+
+	decoded, err := ftdi.DecodeEEPROM(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := decoded.Diff(e); len(diff) != 0 {
+		t.Errorf("decoded image differs: %v", diff)
+	}
+
+	// Bits the decoder doesn't know about are written back.
+	image[0x01] |= 0x01
+	decoded, err = ftdi.DecodeEEPROM(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := decoded.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again[0x01] != image[0x01] {
+		t.Errorf("byte 0x01 is %#02x, want %#02x", again[0x01], image[0x01])
+	}
+}
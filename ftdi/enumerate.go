@@ -0,0 +1,249 @@
+package ftdi
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	libftdi "github.com/ziutek/ftdi"
+)
+
+// Linux exposes every attached USB device as a directory named after its
+// port path, for example "1-2.3" is bus 1, root port 2, hub port 3.
+var usbDevicesPath = "/sys/bus/usb/devices"
+
+// DeviceInfo describes an attached USB device.
+type DeviceInfo struct {
+	Vender  int
+	Product int
+
+	Manufacturer string
+	Description  string
+	Serial       string
+
+	// Bus and Address (aka busnum and devnum) as shown by lsusb.
+	Bus     int
+	Address int
+	// Path is the USB port path, for example "1-2.3". Unlike Address it
+	// doesn't change when the device is re-plugged into the same port.
+	Path string
+}
+
+func (d DeviceInfo) String() string {
+	return fmt.Sprintf("%04x:%04x %s bus %03d device %03d, %q %q serial %q",
+		d.Vender, d.Product, d.Path, d.Bus, d.Address, d.Manufacturer, d.Description, d.Serial)
+}
+
+// Selector chooses which of several devices with the same vender/product
+// is opened. An empty Selector picks the first device.
+type Selector struct {
+	// Serial number, for example "FT0RN5XA".
+	Serial string
+	// Description is the product string, for example "FT232H".
+	Description string
+	// Path is a USB port path as reported by ListDevices.
+	Path string
+	// Index picks the n-th device when several still match, counted in
+	// ListDevices order. Elsewhere than Linux it is libftdi's order.
+	Index uint
+}
+
+// Option configures how a FTDI232H finds its device.
+type Option func(f *FTDI232H)
+
+// WithSerial opens the device with this serial number.
+func WithSerial(serial string) Option {
+	return func(f *FTDI232H) {
+		f.selector.Serial = serial
+	}
+}
+
+// WithDescription opens the device with this product description.
+func WithDescription(description string) Option {
+	return func(f *FTDI232H) {
+		f.selector.Description = description
+	}
+}
+
+// WithUSBPath opens the device plugged into this USB port, for example "1-2.3".
+func WithUSBPath(path string) Option {
+	return func(f *FTDI232H) {
+		f.selector.Path = path
+	}
+}
+
+// WithIndex opens the n-th matching device.
+func WithIndex(index uint) Option {
+	return func(f *FTDI232H) {
+		f.selector.Index = index
+	}
+}
+
+// WithSelector replaces the whole selector.
+func WithSelector(selector Selector) Option {
+	return func(f *FTDI232H) {
+		f.selector = selector
+	}
+}
+
+// ListDevices returns the attached devices matching vender/product, in
+// port path order. A product of 0 matches any product from the vender.
+// The devices are read from sysfs, elsewhere than Linux it returns
+// ErrUnsupported.
+func ListDevices(vender, product int) ([]DeviceInfo, error) {
+	if runtime.GOOS != "linux" {
+		return nil, ErrUnsupported
+	}
+
+	entries, err := os.ReadDir(usbDevicesPath)
+	if err != nil {
+		return nil, err
+	}
+
+	devices := []DeviceInfo{}
+
+	for _, entry := range entries {
+		path := filepath.Join(usbDevicesPath, entry.Name())
+
+		// Interfaces (ex "1-2:1.0") and root hubs without ids are skipped.
+		v, err := readSysfsHex(path, "idVendor")
+		if err != nil {
+			continue
+		}
+		p, err := readSysfsHex(path, "idProduct")
+		if err != nil {
+			continue
+		}
+
+		if v != vender || (product != 0 && p != product) {
+			continue
+		}
+
+		info := DeviceInfo{
+			Vender:       v,
+			Product:      p,
+			Manufacturer: readSysfsString(path, "manufacturer"),
+			Description:  readSysfsString(path, "product"),
+			Serial:       readSysfsString(path, "serial"),
+			Path:         entry.Name(),
+		}
+		info.Bus, _ = strconv.Atoi(readSysfsString(path, "busnum"))
+		info.Address, _ = strconv.Atoi(readSysfsString(path, "devnum"))
+
+		devices = append(devices, info)
+	}
+
+	return devices, nil
+}
+
+// FindDevice returns the attached device matching vender/product and the
+// selector. Like ListDevices it is Linux only.
+func FindDevice(vender, product int, selector Selector) (DeviceInfo, error) {
+	devices, err := ListDevices(vender, product)
+	if err != nil {
+		return DeviceInfo{}, err
+	}
+
+	index := uint(0)
+	for _, d := range devices {
+		if selector.Path != "" && d.Path != selector.Path {
+			continue
+		}
+		if selector.Serial != "" && d.Serial != selector.Serial {
+			continue
+		}
+		if selector.Description != "" && d.Description != selector.Description {
+			continue
+		}
+		if index == selector.Index {
+			return d, nil
+		}
+		index++
+	}
+
+	return DeviceInfo{}, ErrDeviceNotFound
+}
+
+// OpenUSBDevice opens the device matching vender/product and the selector
+// using libftdi.
+func OpenUSBDevice(vender, product int, selector Selector, channel Channel) (Transport, error) {
+	info, resolved, err := resolveDevice(vender, product, selector)
+	if err != nil {
+		return nil, err
+	}
+	return openResolved(vender, product, info, resolved, channel)
+}
+
+// resolveDevice finds the one attached device [selector] picks, and the
+// selector libftdi opens exactly that device with. libftdi can't open by
+// port path and counts devices its own way, so the device is named by its
+// serial number, or by nothing if it is the only one.
+//
+// Without sysfs libftdi gets [selector] as is, except a USB path.
+func resolveDevice(vender, product int, selector Selector) (DeviceInfo, Selector, error) {
+	info, err := FindDevice(vender, product, selector)
+	if errors.Is(err, ErrUnsupported) && selector.Path == "" {
+		return DeviceInfo{}, selector, nil
+	}
+	if err != nil {
+		if selector.Path != "" {
+			return DeviceInfo{}, Selector{}, fmt.Errorf("%w at USB path %s", err, selector.Path)
+		}
+		return DeviceInfo{}, Selector{}, err
+	}
+
+	devices, err := ListDevices(vender, product)
+	if err != nil {
+		return DeviceInfo{}, Selector{}, err
+	}
+	if len(devices) == 1 {
+		return info, Selector{}, nil
+	}
+
+	same := 0
+	for _, d := range devices {
+		if d.Serial == info.Serial {
+			same++
+		}
+	}
+	if info.Serial == "" || same > 1 {
+		return DeviceInfo{}, Selector{}, fmt.Errorf("%w: %s, %d devices share its serial number %q", ErrAmbiguousDevice, info.Path, same, info.Serial)
+	}
+
+	return info, Selector{Serial: info.Serial}, nil
+}
+
+// openResolved opens the device [info] that resolveDevice returned
+// [selector] for.
+func openResolved(vender, product int, info DeviceInfo, selector Selector, channel Channel) (Transport, error) {
+	d, err := libftdi.Open(vender, product, selector.Description, selector.Serial, selector.Index, libftdi.Channel(channel))
+	if err != nil {
+		if accessErr := usbAccessError(vender, product); accessErr != nil {
+			return nil, accessErr
+		}
+		return nil, err
+	}
+
+	return newUSBTransport(d, vender, product, selector, info), nil
+}
+
+func readSysfsString(dir, name string) string {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func readSysfsHex(dir, name string) (int, error) {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseUint(strings.TrimSpace(string(data)), 16, 16)
+	return int(v), err
+}
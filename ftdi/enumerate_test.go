@@ -0,0 +1,102 @@
+package ftdi
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// fakeSysfs points usbDevicesPath at a directory holding [devices].
+func fakeSysfs(t *testing.T, devices ...DeviceInfo) {
+	t.Helper()
+	if runtime.GOOS != "linux" {
+		t.Skip("sysfs is Linux only")
+	}
+
+	dir := t.TempDir()
+	for _, d := range devices {
+		path := filepath.Join(dir, d.Path)
+		files := map[string]string{
+			"idVendor":  "0403",
+			"idProduct": "6014",
+			"product":   d.Description,
+			"serial":    d.Serial,
+			"busnum":    "1",
+			"devnum":    "5",
+		}
+		err := os.MkdirAll(path, 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, value := range files {
+			err := os.WriteFile(filepath.Join(path, name), []byte(value+"\n"), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	old := usbDevicesPath
+	usbDevicesPath = dir
+	t.Cleanup(func() { usbDevicesPath = old })
+}
+
+func TestFindDevice(t *testing.T) {
+	fakeSysfs(t,
+		DeviceInfo{Path: "1-3", Description: "FT232H", Serial: "B"},
+		DeviceInfo{Path: "1-2", Description: "FT232H", Serial: "A"},
+	)
+
+	devices, err := ListDevices(0x0403, 0x6014)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 2 || devices[0].Path != "1-2" || devices[1].Path != "1-3" {
+		t.Fatalf("devices %v, want 1-2 then 1-3", devices)
+	}
+
+	info, err := FindDevice(0x0403, 0x6014, Selector{Index: 1})
+	if err != nil || info.Serial != "B" {
+		t.Errorf("index 1: %v, %v, want serial B", info, err)
+	}
+
+	// Whatever picks it, libftdi is asked for the device by serial number.
+	info, selector, err := resolveDevice(0x0403, 0x6014, Selector{Path: "1-3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Path != "1-3" || selector != (Selector{Serial: "B"}) {
+		t.Errorf("resolved %v to %+v, want serial B", info, selector)
+	}
+
+	_, err = FindDevice(0x0403, 0x6014, Selector{Serial: "C"})
+	if !errors.Is(err, ErrDeviceNotFound) {
+		t.Errorf("serial C: %v, want ErrDeviceNotFound", err)
+	}
+}
+
+func TestResolveAmbiguous(t *testing.T) {
+	fakeSysfs(t,
+		DeviceInfo{Path: "1-2", Description: "FT232H", Serial: "A"},
+		DeviceInfo{Path: "1-3", Description: "FT232H", Serial: "A"},
+	)
+
+	_, _, err := resolveDevice(0x0403, 0x6014, Selector{Path: "1-3"})
+	if !errors.Is(err, ErrAmbiguousDevice) {
+		t.Errorf("shared serial: %v, want ErrAmbiguousDevice", err)
+	}
+}
+
+func TestResolveSingle(t *testing.T) {
+	fakeSysfs(t, DeviceInfo{Path: "1-2", Description: "FT232H"})
+
+	info, selector, err := resolveDevice(0x0403, 0x6014, Selector{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Path != "1-2" || selector != (Selector{}) {
+		t.Errorf("resolved %v to %+v, want the first device", info, selector)
+	}
+}
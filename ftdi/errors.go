@@ -0,0 +1,129 @@
+package ftdi
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Errors returned by FTDI232H. Compare with errors.Is, the detailed error
+// types below match their sentinel, for example:
+//
+//	if errors.Is(err, ftdi.ErrShortWrite) { ... resync ... }
+var (
+	// ErrShortWrite is returned when the chip accepts fewer bytes than written.
+	ErrShortWrite = errors.New("ftdi: short write")
+	// ErrSyncFailed is returned when the MPSSE never echoes the bad command response.
+	ErrSyncFailed = errors.New("ftdi: could not synchronize with MPSSE")
+	// ErrReadTimeout is returned when expected bytes don't arrive in time.
+	ErrReadTimeout = errors.New("ftdi: read timed out")
+	// ErrNotOpen is returned when the device hasn't been opened or was closed.
+	ErrNotOpen = errors.New("ftdi: device not open")
+	// ErrPermission is returned when the process isn't allowed to access the
+	// device or unload drivers. Typically a missing udev rule or not root.
+	ErrPermission = errors.New("ftdi: permission denied")
+	// ErrDeviceNotFound is returned when no attached device matches a selector.
+	ErrDeviceNotFound = errors.New("ftdi: no matching device found")
+	// ErrAmbiguousDevice is returned when the selected device can't be told
+	// apart from the other attached ones when it is opened, it has no
+	// unique serial number. Select it by USB path on a single device bus,
+	// or program a serial number (see WriteEEPROM).
+	ErrAmbiguousDevice = errors.New("ftdi: device can't be told apart")
+	// ErrUnsupported is returned by ListDevices, FindDevice and selection by
+	// USB path on platforms other than Linux, they read sysfs.
+	ErrUnsupported = errors.New("ftdi: not supported on this platform")
+	// ErrDisconnected is returned when the device went away, for example it
+	// was unplugged, and while FTDI232H reconnects (see WithReconnect).
+	ErrDisconnected = errors.New("ftdi: device disconnected")
+	// ErrRoutedLine is returned by Queue.Flush for a line driven through the
+	// queue's own device, see Routed.
+	ErrRoutedLine = errors.New("ftdi: line is driven through the queue's device")
+)
+
+// ShortWriteError reports how much of a write reached the chip.
+type ShortWriteError struct {
+	Expected int
+	Written  int
+}
+
+func (e *ShortWriteError) Error() string {
+	return fmt.Sprintf("ftdi: short write, expected to write (%d) bytes, however, only (%d) written", e.Expected, e.Written)
+}
+
+// Is matches ErrShortWrite.
+func (e *ShortWriteError) Is(target error) bool {
+	return target == ErrShortWrite
+}
+
+// TimeoutError reports a PollRead that didn't receive everything.
+type TimeoutError struct {
+	Expected int
+	Received int
+	Timeout  time.Duration
+	// Err is the context error that ended the read, if any.
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("ftdi: timed out after %v while polling for (%d) bytes, received (%d)", e.Timeout, e.Expected, e.Received)
+}
+
+// Unwrap returns the context error, if any.
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches ErrReadTimeout.
+func (e *TimeoutError) Is(target error) bool {
+	return target == ErrReadTimeout
+}
+
+// SyncError reports a failed MPSSE synchronization and the last response
+// seen, or the read error that stopped it.
+type SyncError struct {
+	Tries    int
+	Response []byte
+	Err      error
+}
+
+func (e *SyncError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("ftdi: could not synchronize with MPSSE: %v", e.Err)
+	}
+	return fmt.Sprintf("ftdi: could not synchronize with MPSSE after (%d) tries, last response %#v", e.Tries, e.Response)
+}
+
+// Unwrap returns the read error, if any.
+func (e *SyncError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches ErrSyncFailed.
+func (e *SyncError) Is(target error) bool {
+	return target == ErrSyncFailed
+}
+
+// usbAccessError checks whether a failed open was caused by the device node
+// permissions. libftdi only reports "usb_open() failed" so the node matching
+// each attached device is opened directly to find out.
+func usbAccessError(vender, product int) error {
+	devices, err := ListDevices(vender, product)
+	if err != nil {
+		return nil
+	}
+
+	for _, d := range devices {
+		node := filepath.Join("/dev/bus/usb", fmt.Sprintf("%03d", d.Bus), fmt.Sprintf("%03d", d.Address))
+		file, err := os.OpenFile(node, os.O_RDWR, 0)
+		if errors.Is(err, os.ErrPermission) {
+			return fmt.Errorf("%w: %s (%s)", ErrPermission, node, d.Path)
+		}
+		if err == nil {
+			file.Close()
+		}
+	}
+
+	return nil
+}
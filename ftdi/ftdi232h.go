@@ -1,24 +1,25 @@
 package ftdi
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
 	"math"
-	"os"
-	"os/exec"
-	"os/user"
-	"runtime"
-	"syscall"
+	"sync"
 	"time"
 
 	"github.com/wdevore/hardware/gpio"
 
-	"github.com/ziutek/ftdi"
+	libftdi "github.com/ziutek/ftdi"
 )
 
 const chunkSize = 65536 // bytes
 
+// defaultPollTimeout is used by PollRead when no timeout is given and by
+// reads made on behalf of a context without a deadline.
+const defaultPollTimeout = 3 * time.Second
+
 // -----------------------------------------------------------------------------
 // Pins
 // -----------------------------------------------------------------------------
@@ -39,7 +40,7 @@ const (
 	C5
 	C6
 	C7
-	// Rarely used pins. Requires EEPROM modifications.
+	// Rarely used pins. Requires EEPROM modifications, see EEPROM.SetCBUS.
 	C8
 	C9
 )
@@ -55,6 +56,8 @@ const (
 	disable3PhaseClk        = 0x8d
 )
 
+// These are never modified. Commands with parameters are built in per
+// instance buffers so several devices, or goroutines, don't share them.
 var (
 	commandReadHighLowBytes        = []byte{0x81, 0x83}
 	commandBad                     = []byte{0xab}
@@ -63,16 +66,21 @@ var (
 	commandDisableAdaptiveClocking = []byte{disableAdaptiveClocking}
 	commandEnable3PhaseClk         = []byte{enable3PhaseClk}
 	commandDisable3PhaseClk        = []byte{disable3PhaseClk}
-	commandUpdatePins              = []byte{0x80, 0, 0, 0x82, 0, 0}
-	commandBasicSPIConfig          = []byte{disableClockDivisor, disableAdaptiveClocking, disable3PhaseClk}
-	commandSetDivisor              = []byte{0x86, 0, 0}
 )
 
 // FTDI232H represents the Adafruit USB to GPIO breakout board.
 // Adafruit part number is: P2264
 //
 // The board exposes all 16 io pins: D0->D7 and C0->C7
+//
+// A FTDI232H is safe for concurrent use. Every method holds an internal
+// lock for its whole exchange with the chip, so a command and its response
+// are never interleaved with another goroutine's traffic. Use a Queue, or
+// Exchange, for sequences that must stay together.
 type FTDI232H struct {
+	// Guards the device and everything below.
+	mutex sync.Mutex
+
 	Vender  int
 	Product int
 
@@ -80,53 +88,97 @@ type FTDI232H struct {
 	// read while polling. Default is `False`
 	SleepingPoll bool
 
-	device *ftdi.Device
+	// The link to the chip. Typically a libftdi USB device, see SetTransport.
+	device Transport
+
+	// Chooses between several attached devices with the same vender/product.
+	selector Selector
 
 	// A 16 bit register representing the direction of each io pin.
 	direction uint16
 	// A 16 bit register representing the level/state of each io pin.
 	level uint16
 
-	driversUnloaded bool
+	// The MPSSE clock (TCK) in Hz, as set by SetClock.
+	clock int
+
+	// Unbind the kernel serial driver on open, see Initialize.
+	detach bool
+	// Interfaces unbound from the kernel serial driver, bound again by Close.
+	unbound []string
+	// The macOS FTDI driver was unloaded, reloaded by Close.
+	kextUnloaded bool
+
+	// Which subsystem claimed each pin, see ClaimPins.
+	claims map[gpio.Pin]PinClaim
+
+	// The UART line setup, kept so SetBreak can repeat it.
+	serial SerialConfig
+
+	// Reconnecting after the device went away, see WithReconnect.
+	policy *ReconnectPolicy
+	hooks  []func(ctx context.Context) error
+	// Why the device is gone, nil while connected.
+	lost error
+	// Closed when the running reconnect is done, nil if none.
+	reconnected     chan struct{}
+	cancelReconnect context.CancelFunc
+	reconnectErr    error
+
+	// The device that was opened and its setup, to reopen it as it was.
+	opened   DeviceInfo
+	injected Transport
+	state    deviceState
+
+	// The running trace, see StartTrace.
+	trace *traceWriter
+
+	// The GPIO update command, rebuilt from direction and level.
+	updatePins [6]byte
 
 	// A buffer used for reading pins configured as Input.
 	chunk []byte
 
-	// Track if the expected bytes being requested has changed value.
-	// This is a simple memory allocation optimization.
-	prevExpected int
-	response     []byte
+	// The last response, kept for debugging (see ToStringFullBinary).
+	response []byte
 }
 
 // NewFTDI232H creates and configures FTDI.
-// if disableDrivers = true then you need to run as root.
+// if disableDrivers = true then you need to run as root, see Initialize.
 // [vendor] is typically 0x0403
 // There are several products, for example: 0x6014 = FT232H
-func NewFTDI232H(vender, product int) *FTDI232H {
+// [options] choose a specific device when more than one is attached,
+// see WithSerial, WithDescription and WithUSBPath.
+func NewFTDI232H(vender, product int, options ...Option) *FTDI232H {
 	f := new(FTDI232H)
 	f.SetTarget(vender, product)
+	f.SetOptions(options...)
 	return f
 }
 
-// Initialize optionally disables any conflicting drivers.
-func (f *FTDI232H) Initialize(disableDrivers bool) error {
-	f.driversUnloaded = false
-
-	if disableDrivers {
-		if !f.isRunningAsRoot() {
-			log.Fatal("To disable drivers this program must be run as root.")
-			return nil
-		}
+// NewFTDI232HBySerial creates a FTDI232H that opens the device with [serial].
+func NewFTDI232HBySerial(vender, product int, serial string) *FTDI232H {
+	return NewFTDI232H(vender, product, WithSerial(serial))
+}
 
-		err := f.disableDrivers(true)
-		if err != nil {
-			log.Fatal(err.Error())
-			return nil
-		}
+// NewFTDI232HByDescription creates a FTDI232H that opens the device whose
+// product description is [description].
+func NewFTDI232HByDescription(vender, product int, description string) *FTDI232H {
+	return NewFTDI232H(vender, product, WithDescription(description))
+}
 
-		f.driversUnloaded = true
-	}
+// NewFTDI232HByPath creates a FTDI232H that opens the device plugged into
+// the USB port [path], for example "1-2.3".
+func NewFTDI232HByPath(vender, product int, path string) *FTDI232H {
+	return NewFTDI232H(vender, product, WithUSBPath(path))
+}
 
+// Initialize optionally disables any conflicting drivers. On Linux the
+// kernel serial driver is unbound from the interface being opened only,
+// when it is opened, and bound again by Close. That needs root, unless a
+// udev rule does it instead (see UdevRule), then disableDrivers = false.
+func (f *FTDI232H) Initialize(disableDrivers bool) error {
+	f.detach = disableDrivers
 	return nil
 }
 
@@ -136,31 +188,103 @@ func (f *FTDI232H) SetTarget(vender, product int) {
 	f.Product = product
 }
 
+// SetOptions applies device selection options. They take effect on the next open.
+func (f *FTDI232H) SetOptions(options ...Option) {
+	for _, option := range options {
+		option(f)
+	}
+}
+
+// Selector returns how the device is chosen when opened.
+func (f *FTDI232H) Selector() Selector {
+	return f.selector
+}
+
+// SetTransport injects the link to the chip, for example a fake or a recorder.
+// Configure and SoftConfigure use an injected transport instead of opening
+// the first USB device.
+func (f *FTDI232H) SetTransport(transport Transport) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.device = f.traced(transport)
+	f.injected = transport
+}
+
+// Transport returns the current link to the chip, or nil if not open.
+func (f *FTDI232H) Transport() Transport {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.device
+}
+
+// openIfNeeded opens the selected USB device unless a transport is already present.
+func (f *FTDI232H) openIfNeeded() error {
+	if f.device != nil {
+		return nil
+	}
+
+	if f.lost != nil {
+		return f.lost
+	}
+
+	return f.open(ChannelAny)
+}
+
+// configureBuffers sets up the USB transfer sizes and the read buffer.
+func (f *FTDI232H) configureBuffers() error {
+	// Change read & write buffers to maximum size
+	err := f.device.SetReadChunkSize(chunkSize)
+	if err != nil {
+		return err
+	}
+	err = f.device.SetWriteChunkSize(chunkSize)
+	if err != nil {
+		return err
+	}
+
+	// Pre allocate static read buffer size.
+	f.chunk = make([]byte, chunkSize)
+	return nil
+}
+
 // Configure arranges default values for MPSSE.
 func (f *FTDI232H) Configure(sleepingPoll bool) error {
+	return f.ConfigureContext(context.Background(), sleepingPoll)
+}
+
+// ConfigureContext is Configure but stops synchronizing with the MPSSE when
+// [ctx] is canceled or its deadline passes.
+func (f *FTDI232H) ConfigureContext(ctx context.Context, sleepingPoll bool) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
 	// We need to open the device now so we can configure various property below.
-	err := f.OpenFirst()
+	err := f.openIfNeeded()
 	if err != nil {
 		return err
 	}
 
-	// Change read & write buffers to maximum size
-	f.device.SetReadChunkSize(chunkSize)
-	f.device.SetWriteChunkSize(chunkSize)
-
-	// Pre allocate static read buffer size.
-	f.chunk = make([]byte, chunkSize)
+	err = f.configureBuffers()
+	if err != nil {
+		return err
+	}
 
 	f.SleepingPoll = sleepingPoll
 
 	// log.Println("FTDI232H Enabling MPSSE")
-	f.EnableMPSSE()
+	err = f.setBitmode(0xff, ModeMPSSE)
+	if err != nil {
+		return err
+	}
 
 	// log.Println("FTDI232H setting default clock, adaptive disabled, 3phase disabled")
-	f.SetClock(20000000, false, false)
+	err = f.setClock(20000000, false, false)
+	if err != nil {
+		return err
+	}
 
 	log.Println("FTDI232H MPSSE syncing")
-	err = f.mpsseSync(-1)
+	err = f.mpsseSync(ctx, -1)
 
 	if err != nil {
 		log.Println("FTDI232H MPSSE failed to sync")
@@ -174,141 +298,190 @@ func (f *FTDI232H) Configure(sleepingPoll bool) error {
 
 // SoftConfigure sets default values for BitBang.
 func (f *FTDI232H) SoftConfigure(sleepingPoll bool) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
 	// We need to open the device now so we can configure various property below.
-	err := f.OpenFirst()
+	err := f.openIfNeeded()
 	if err != nil {
 		return err
 	}
 
-	// Change read & write buffers to maximum size
-	f.device.SetReadChunkSize(chunkSize)
-	f.device.SetWriteChunkSize(chunkSize)
-
-	// Pre allocate static read buffer size.
-	f.chunk = make([]byte, chunkSize)
+	err = f.configureBuffers()
+	if err != nil {
+		return err
+	}
 
 	f.SleepingPoll = sleepingPoll
 
-	f.device.SetBitmode(0xff, ftdi.ModeBitbang)
-
-	f.device.SetBaudrate(10000)
+	err = f.setBitmode(0xff, ModeBitbang)
+	if err != nil {
+		return err
+	}
 
-	return nil
+	return f.setBaudrate(10000)
 }
 
-// Close shutdowns and reload any drivers
-func (f *FTDI232H) Close() error {
-	log.Println("FTDI232H closing device")
-	err := f.device.Close()
+// SyncConfigure sets up synchronous BitBang. D0-D7 are sampled each time a
+// byte is written, at [rate] bytes per second, and one byte of samples is
+// returned per byte written. [iomask] selects the outputs (1 = output).
+func (f *FTDI232H) SyncConfigure(iomask byte, rate int) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	err := f.openIfNeeded()
 	if err != nil {
-		log.Fatal(err)
 		return err
 	}
 
-	if f.driversUnloaded {
-		err = f.disableDrivers(false)
-		if err != nil {
-			log.Fatal(err.Error())
-			return err
-		}
+	err = f.configureBuffers()
+	if err != nil {
+		return err
 	}
 
-	log.Println("FTDI232H device closed")
-
-	return nil
-}
+	err = f.setBitmode(iomask, ModeSyncBB)
+	if err != nil {
+		return err
+	}
 
-func (f *FTDI232H) isRunningAsRoot() bool {
-	usr, _ := user.Current()
-	return usr.Uid == "0"
+	return f.setBaudrate(rate)
 }
 
-// Note: if you have authorization correctly configured via udev rules.d you don't
-// need to unload the drivers--at least under linux.
-func (f *FTDI232H) disableDrivers(disable bool) error {
-	env := os.Environ()
-	var argsFtdi []string
-	var argsUsb []string
-	var command string
-
-	if runtime.GOOS == "darwin" {
-		// Mac OS commands to disable FTDI driver.
-		command = "kextunload"
-
-		argsFtdi = []string{command, "-b com.apple.driver.AppleUSBFTDI"}
-		argsUsb = []string{command, "/System/Library/Extensions/FTDIUSBSerialDriver.kext"}
-	} else if runtime.GOOS == "linux" {
-		command := "modprobe"
+// Close shutdowns and reload any drivers
+func (f *FTDI232H) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
 
-		if disable {
-			argsFtdi = []string{command, "-r", "-q ftdi_sio"}
-			argsUsb = []string{command, "-r", "-q usbserial"}
-		} else {
-			argsFtdi = []string{command, "-q ftdi_sio"}
-			argsUsb = []string{command, "-q usbserial"}
-		}
+	if f.device == nil && f.lost == nil {
+		return ErrNotOpen
 	}
 
-	binaryPath, err := exec.LookPath(command)
-
-	if err != nil {
-		return err
+	// Stop reconnecting, a lost device has nothing left to close.
+	if f.cancelReconnect != nil {
+		f.cancelReconnect()
 	}
+	f.lost = nil
 
-	execErr := syscall.Exec(binaryPath, argsFtdi, env)
+	// The device is gone and the drivers are rebound even if closing fails,
+	// the first error is returned.
+	var first error
 
-	if execErr != nil {
-		return execErr
+	if f.device != nil {
+		log.Println("FTDI232H closing device")
+		first = f.device.Close()
+		f.device = nil
 	}
+	f.claims = nil
 
-	execErr = syscall.Exec(binaryPath, argsUsb, env)
+	if f.trace != nil {
+		// Keep what was recorded even if StopTrace is never called.
+		f.trace.flush()
+	}
 
-	if execErr != nil {
-		return execErr
+	err := f.reattachDrivers()
+	if err != nil && first == nil {
+		first = err
 	}
+	if first != nil {
+		return first
+	}
+
+	log.Println("FTDI232H device closed")
 
 	return nil
 }
 
-// Open opens the first device on a specific channel.
-func (f *FTDI232H) Open(channel ftdi.Channel) error {
-	d, err := ftdi.OpenFirst(f.Vender, f.Product, channel)
+// Open opens the selected device, or the first one if no selection options
+// were given, on a specific channel.
+func (f *FTDI232H) Open(channel Channel) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.open(channel)
+}
+
+func (f *FTDI232H) open(channel Channel) error {
+	return f.openSelected(f.selector, channel)
+}
+
+func (f *FTDI232H) openSelected(selector Selector, channel Channel) error {
+	// The device is looked up once, the driver is detached from the very
+	// device libftdi opens.
+	info, resolved, err := resolveDevice(f.Vender, f.Product, selector)
 	if err != nil {
 		return err
 	}
-	f.device = d
 
-	return nil
-}
+	if f.detach {
+		err := f.detachDriver(info, channel)
+		if err != nil {
+			return err
+		}
+	}
 
-// OpenFirst opens the first known FTDI device
-func (f *FTDI232H) OpenFirst() error {
-	d, err := ftdi.OpenFirst(f.Vender, f.Product, ftdi.ChannelAny)
+	d, err := openResolved(f.Vender, f.Product, info, resolved, channel)
 	if err != nil {
+		// Give the interface back to the kernel, Close won't be called.
+		f.reattachDrivers()
 		return err
 	}
-	f.device = d
+	f.device = f.traced(d)
+
+	f.state.channel = channel
+	if u, ok := d.(*usbTransport); ok {
+		f.opened = u.info
+	}
 
 	return nil
 }
 
+// OpenFirst opens the selected (by default the first known) FTDI device
+func (f *FTDI232H) OpenFirst() error {
+	return f.Open(ChannelAny)
+}
+
 // SetBitmode sets bit mode of device
-func (f *FTDI232H) SetBitmode(iomask byte, mode ftdi.Mode) error {
+func (f *FTDI232H) SetBitmode(iomask byte, mode BitMode) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.setBitmode(iomask, mode)
+}
+
+func (f *FTDI232H) setBitmode(iomask byte, mode BitMode) error {
+	if f.device == nil {
+		return f.notOpen()
+	}
+
 	err := f.device.SetBitmode(iomask, mode)
 	if err != nil {
-		return err
+		return f.checkDisconnect(err)
 	}
 
+	f.state.modeSet = true
+	f.state.iomask = iomask
+	f.state.mode = mode
+
 	return nil
 }
 
 // SetBaudrate sets the transfer speed
 func (f *FTDI232H) SetBaudrate(baudRate int) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.setBaudrate(baudRate)
+}
+
+func (f *FTDI232H) setBaudrate(baudRate int) error {
+	if f.device == nil {
+		return f.notOpen()
+	}
+
 	err := f.device.SetBaudrate(baudRate)
 	if err != nil {
-		return err
+		return f.checkDisconnect(err)
 	}
 
+	f.state.baudrate = baudRate
+
 	return nil
 }
 
@@ -318,6 +491,8 @@ func (f *FTDI232H) SetBaudrate(baudRate int) error {
 
 // GetLevels gets the currently defined levels
 func (f *FTDI232H) GetLevels() uint16 {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
 	return f.level
 }
 
@@ -332,59 +507,55 @@ func (f *FTDI232H) setPin(pin gpio.Pin, mode gpio.IODirection) {
 	}
 }
 
-// Set the specified pin HIGH.
-// Note: This does NOT write the value out to the device!
-// Use OutputHigh for "setting" and "writing"
-// This method allows you to set multiple pins and then perform
-// a single write.
-func (f *FTDI232H) setHigh(pin gpio.Pin) {
-	f.SetPin(pin, gpio.High)
-}
-
-// Set the specified pin LOW.
-// Note: This does NOT write the value out to the device!
-// Use OutputLow for "setting" and "writing".
-// This method allows you to set multiple pins and then perform
-// a single write.
-func (f *FTDI232H) setLow(pin gpio.Pin) {
-	f.SetPin(pin, gpio.Low)
-}
-
 // SetConfigPin sets the input or output mode for a specified pin.  Mode should be
 // either OUT or IN. Note: This does NOT write to the device.
 func (f *FTDI232H) SetConfigPin(pin gpio.Pin, mode gpio.IODirection) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
 	f.setPin(pin, mode)
 }
 
 // ConfigPinNoWrite sets the input or output mode for a specified pin
 // and does not write to pins
 func (f *FTDI232H) ConfigPinNoWrite(pin gpio.Pin, mode gpio.IODirection) {
-	f.setPin(pin, mode)
+	f.SetConfigPin(pin, mode)
 }
 
 // ConfigPin sets the input or output mode for a specified pin.  Mode should be
 // either OUT or IN.
-func (f *FTDI232H) ConfigPin(pin gpio.Pin, mode gpio.IODirection) {
+func (f *FTDI232H) ConfigPin(pin gpio.Pin, mode gpio.IODirection) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
 	f.setPin(pin, mode)
-	f.mpsseWriteGpio()
+	return f.mpsseWriteGpio()
 }
 
 // ConfigPins and write out pins
-func (f *FTDI232H) ConfigPins(pins []gpio.PinConfiguration, write bool) {
+func (f *FTDI232H) ConfigPins(pins []gpio.PinConfiguration, write bool) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
 	for _, o := range pins {
 		f.setPin(o.Pin, o.Direction)
 		if o.Value != gpio.Z {
-			f.SetPin(o.Pin, o.Value)
+			f.setLevel(o.Pin, o.Value)
 		}
 	}
 
 	if write {
-		f.mpsseWriteGpio()
+		return f.mpsseWriteGpio()
 	}
+	return nil
 }
 
 // SetPin only sets the buffer pin value. It does NOT write the pin to the device.
 func (f *FTDI232H) SetPin(pin gpio.Pin, value gpio.PinState) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.setLevel(pin, value)
+}
+
+func (f *FTDI232H) setLevel(pin gpio.Pin, value gpio.PinState) {
 	if value == gpio.High {
 		f.level |= (1 << pin) & 0xffff
 	} else {
@@ -394,12 +565,16 @@ func (f *FTDI232H) SetPin(pin gpio.Pin, value gpio.PinState) {
 
 // SetPortDPins sets D0-D7 pins.
 func (f *FTDI232H) SetPortDPins(pins byte) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
 	// D0-D7 is the lower byte
 	f.level = (f.level & 0xFF00) | uint16(pins)
 }
 
 // SetPortCPins sets C0-C7 pins.
 func (f *FTDI232H) SetPortCPins(pins byte) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
 	// C0-C7 is the upper byte
 	f.level = (f.level & 0x00FF) | (uint16(pins) << 8)
 }
@@ -407,20 +582,20 @@ func (f *FTDI232H) SetPortCPins(pins byte) {
 // Output sets AND writes the specified pin to the provided high/low value.  Value should be
 // either HIGH/LOW or a boolean (true = high).
 func (f *FTDI232H) Output(pin gpio.Pin, value gpio.PinState) error {
-	f.SetPin(pin, value)
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.setLevel(pin, value)
 	return f.mpsseWriteGpio()
 }
 
 // OutputHigh sets the pin High AND writes it to the device.
 func (f *FTDI232H) OutputHigh(pin gpio.Pin) error {
-	f.SetPin(pin, gpio.High)
-	return f.mpsseWriteGpio()
+	return f.Output(pin, gpio.High)
 }
 
 // OutputLow sets the pin Low AND writes it to the device.
 func (f *FTDI232H) OutputLow(pin gpio.Pin) error {
-	f.SetPin(pin, gpio.Low)
-	return f.mpsseWriteGpio()
+	return f.Output(pin, gpio.Low)
 }
 
 // SetHigh sets the pin High ONLY.
@@ -435,6 +610,8 @@ func (f *FTDI232H) SetLow(pin gpio.Pin) {
 
 // WriteGPIO writes pin data to actual device.
 func (f *FTDI232H) WriteGPIO() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
 	return f.mpsseWriteGpio()
 }
 
@@ -455,41 +632,54 @@ func (f *FTDI232H) WriteGPIO() error {
 
 // ReadInput reads the specified pin and returns OutputHigh/true if the pin is pulled high,
 // or OutputLow/false if pulled low.
-func (f *FTDI232H) ReadInput(pin gpio.Pin) gpio.PinState {
-	inPins := f.mpsseReadGpio()
+func (f *FTDI232H) ReadInput(pin gpio.Pin) (gpio.PinState, error) {
+	inPins, err := f.ReadInputs()
+	if err != nil {
+		return gpio.Low, err
+	}
+
 	st := (inPins >> pin) & 0x0001
 	if st == 1 {
-		return gpio.High
+		return gpio.High, nil
 	}
-	return gpio.Low
+	return gpio.Low, nil
 }
 
 // ReadInputs returns all pin data as a 16bit value
-func (f *FTDI232H) ReadInputs() (pins gpio.Pins) {
-	inPins := f.mpsseReadGpio()
-	return inPins
+func (f *FTDI232H) ReadInputs() (pins gpio.Pins, err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.mpsseReadGpio()
 }
 
 // WriteByte wraps byte in a slice, then writes.
-func (f *FTDI232H) WriteByte(data byte) (cnt int, err error) {
-	cnt, err = f.Write([]byte{data})
-	return cnt, err
+func (f *FTDI232H) WriteByte(data byte) error {
+	_, err := f.Write([]byte{data})
+	return err
 }
 
-// Write writes out a byte array of size determined by the array
+// Write writes out a byte array of size determined by the array.
+// A partial write returns a *ShortWriteError which matches ErrShortWrite.
 func (f *FTDI232H) Write(data []byte) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.write(data)
+}
+
+func (f *FTDI232H) write(data []byte) (int, error) {
+	if f.device == nil {
+		return 0, f.notOpen()
+	}
+
 	writtenCnt, err := f.device.Write(data)
 
 	if err != nil {
 		log.Printf("FTDI232H Write failed: %v", err)
-		return 0, err
+		return 0, f.checkDisconnect(err)
 	}
 
 	if writtenCnt != len(data) {
-		msg := fmt.Sprintf("Expected to write (%d) bytes, however, only (%d) written\n", len(data), writtenCnt)
-		err := errors.New(msg)
-		log.Fatal(err)
-		return 0, err
+		return writtenCnt, &ShortWriteError{Expected: len(data), Written: writtenCnt}
 	}
 
 	// log.Printf("FTDI232H Wrote (%d) bytes\n", writtenCnt)
@@ -500,28 +690,24 @@ func (f *FTDI232H) Write(data []byte) (int, error) {
 // WriteLen allows writing of variable length fixed size arrays.
 // Reduces memory allocations
 func (f *FTDI232H) WriteLen(data []byte, length int) (int, error) {
-	writtenCnt, err := f.device.Write(data)
+	return f.Write(data[:length])
+}
 
-	if err != nil {
-		// log.Printf("FTDI232H Write failed: %v", err)
-		return 0, err
+// SubmitRead sumbits an int using Transfer
+// Only the libftdi USB transport supports asynchronous reads.
+func (f *FTDI232H) SubmitRead(expected int) (*libftdi.Transfer, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	usb, ok := f.device.(*usbTransport)
+	if !ok {
+		return nil, errors.New("FTDI232H: SubmitRead requires a USB transport")
 	}
 
-	if writtenCnt != length {
-		msg := fmt.Sprintf("Expected to write (%d) bytes, however, only (%d) written\n", len(data), writtenCnt)
-		err := errors.New(msg)
-		log.Fatal(err)
-		return 0, err
+	transfer, err := usb.SubmitRead(f.chunk)
+	if err != nil {
+		return nil, err
 	}
-
-	// log.Printf("FTDI232H Wrote (%d) bytes\n", writtenCnt)
-
-	return writtenCnt, nil
-}
-
-// SubmitRead sumbits an int using Transfer
-func (f *FTDI232H) SubmitRead(expected int) (*ftdi.Transfer, error) {
-	transfer, err := f.device.SubmitRead(f.chunk)
 	println("Issusing done...")
 	result, err := transfer.Done()
 	if err != nil {
@@ -535,83 +721,126 @@ func (f *FTDI232H) SubmitRead(expected int) (*ftdi.Transfer, error) {
 
 // PollRead reads an expected number of bytes by polling for them.
 // This is a "bit-bang" type of read.
-// [timeout] is specified in seconds. If [timeout] == -1 then timeout = 10 seconds
+// [timeout] is specified in seconds. If [timeout] == -1 then timeout = 3 seconds
+// A timeout returns a *TimeoutError which matches ErrReadTimeout.
 func (f *FTDI232H) PollRead(expected int, timeout int64) ([]byte, error) {
+	duration := defaultPollTimeout
+	if timeout >= 0 {
+		duration = time.Duration(timeout) * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), duration)
+	defer cancel()
+
+	return f.PollReadContext(ctx, expected)
+}
+
+// PollReadContext reads an expected number of bytes by polling for them
+// until they arrive or [ctx] is done.
+// A passed deadline returns a *TimeoutError which matches both ErrReadTimeout
+// and context.DeadlineExceeded. Cancelation returns context.Canceled.
+func (f *FTDI232H) PollReadContext(ctx context.Context, expected int) ([]byte, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.pollReadContext(ctx, expected)
+}
+
+func (f *FTDI232H) pollReadContext(ctx context.Context, expected int) ([]byte, error) {
+	if f.device == nil {
+		return nil, f.notOpen()
+	}
+
 	// Function to continuously poll reads on the FTDI device until an
-	// expected number of bytes are returned.  Will throw a timeout error if no
-	// data is received within the specified number of timeout seconds.  Returns
-	// the read data as a string if successful, otherwise raises an execption.
+	// expected number of bytes are returned or the context ends.
 	start := time.Now()
-	if timeout < 0 {
-		timeout = 3 // 3 seconds
-	}
-	duration := time.Duration(timeout) * time.Second
-	// fmt.Printf("FTDI232H pollRead polling for (%d)s ...\n", duration/time.Second)
 
 	iResp := 0
 
-	// Reallocate if the expected buffer size is changing.
-	if f.prevExpected != expected {
-		// Start with an empty response buffer.
-		f.response = make([]byte, expected)
-		f.prevExpected = expected
-	}
+	// Each read gets its own response, another goroutine may read before
+	// the caller is done with it.
+	f.response = make([]byte, expected)
 
-	// Loop calling read until the response chunk buffer is full or a timeout occurs.
-	for time.Now().Sub(start) <= duration {
-		// log.Println("FTDI232H reading device")
+	// Loop calling read until the response chunk buffer is full or the
+	// context ends. At least one read is always attempted.
+	for {
 		// NOTE: this takes about 400ms! to read pins!!
-		// t1 := time.Now()
 		bytesRead, err := f.device.Read(f.chunk)
-		// t2 := time.Since(t1)
-		// fmt.Printf("FTDI232H: PollRead: read chunk took %f\n", float32(t2)/1000000.0)
 
 		if err != nil {
 			log.Printf("FTDI232H read err (%v)\n", err)
-			return nil, err
+			return nil, f.checkDisconnect(err)
 		}
 
 		// The response buffer is of fixed size. We copy bytes until the
 		// response buffer is filled or we copied the chunk.
-		// Copy as long as we haven't exceeded the response buffer or we haven't
-		// copied all the chunk bytes.
-		if bytesRead > 0 {
-			// fmt.Printf("FTDI232H BytesRead (%d): ", bytesRead)
-			for iChunk := 0; iChunk < bytesRead; iChunk++ {
-				// fmt.Printf("%#x,", f.chunk[iChunk])
-				if iResp >= expected {
-					break
-				}
-				// Copy/append another byte into response
-				f.response[iResp] = f.chunk[iChunk]
-				iResp++
-			}
+		for iChunk := 0; iChunk < bytesRead && iResp < expected; iChunk++ {
+			f.response[iResp] = f.chunk[iChunk]
+			iResp++
 		}
 
 		if iResp >= expected {
-			// fmt.Printf("FTDI232H Got (%d) bytes.\n", iResp)
-			// We received all the expected bytes within the duration.
+			// We received all the expected bytes in time.
 			return f.response, nil
 		}
 
+		if ctx.Err() != nil {
+			break
+		}
+
 		if f.SleepingPoll {
-			// log.Println("FTDI232H pollRead sleeping...")
-			time.Sleep(time.Millisecond)
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Millisecond):
+			}
 		}
 	}
 
-	msg := fmt.Sprintf("FTDI232H: PollRead: Timed out while polling for (%d) bytes!\n", expected)
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return nil, ctx.Err()
+	}
 
-	return nil, errors.New(msg)
+	return nil, &TimeoutError{Expected: expected, Received: iResp, Timeout: time.Since(start), Err: ctx.Err()}
+}
+
+// Exchange writes [command] then reads [expected] response bytes, holding
+// the device so no other goroutine's traffic lands in between. Without a
+// deadline on [ctx] the read waits at most 3 seconds.
+func (f *FTDI232H) Exchange(ctx context.Context, command []byte, expected int) ([]byte, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	_, err := f.write(command)
+	if err != nil {
+		return nil, err
+	}
+
+	if expected == 0 {
+		return nil, nil
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultPollTimeout)
+		defer cancel()
+	}
+
+	return f.pollReadContext(ctx, expected)
 }
 
 // PinsRead returns current state of pins (circumventing the read buffer).
 func (f *FTDI232H) PinsRead() (byte, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.device == nil {
+		return 0, f.notOpen()
+	}
+
 	pins, err := f.device.Pins()
 
 	if err != nil {
 		log.Printf("FTDI232H PinsRead err (%v)\n", err)
-		return byte(0), err
+		return byte(0), f.checkDisconnect(err)
 	}
 
 	return pins, nil
@@ -622,42 +851,54 @@ func (f *FTDI232H) PinsRead() (byte, error) {
 // ------------------------------------------------------------------------
 
 // EnableMPSSE enables MPSSE mode
-func (f *FTDI232H) EnableMPSSE() {
-	err := f.device.SetBitmode(0xff, ftdi.ModeMPSSE)
-	if err != nil {
-		log.Fatal(err)
-	}
+func (f *FTDI232H) EnableMPSSE() error {
+	return f.SetBitmode(0xff, ModeMPSSE)
 }
 
-func (f *FTDI232H) mpsseGpio() {
+func (f *FTDI232H) mpsseGpio() []byte {
 	// Update command to change the MPSSE GPIO state to the current directions
 	// and levels.
+	f.updatePins[0] = 0x80
+	f.updatePins[3] = 0x82
 
 	// lower 8 bits
-	commandUpdatePins[1] = byte(f.level & 0xff)     // levelLow
-	commandUpdatePins[2] = byte(f.direction & 0xff) // dirLow
+	f.updatePins[1] = byte(f.level & 0xff)     // levelLow
+	f.updatePins[2] = byte(f.direction & 0xff) // dirLow
 
 	// upper 8 bits
-	commandUpdatePins[4] = byte((f.level >> 8) & 0xff)     // levelHigh
-	commandUpdatePins[5] = byte((f.direction >> 8) & 0xff) // dirHigh
+	f.updatePins[4] = byte((f.level >> 8) & 0xff)     // levelHigh
+	f.updatePins[5] = byte((f.direction >> 8) & 0xff) // dirHigh
+
+	return f.updatePins[:]
+}
+
+// GPIOCommand returns a copy of the MPSSE command that sets the current
+// directions and levels. It does NOT write to the device, which allows
+// callers to batch several pin changes with other commands.
+func (f *FTDI232H) GPIOCommand() []byte {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	command := make([]byte, len(f.updatePins))
+	copy(command, f.mpsseGpio())
+	return command
 }
 
 // Write the current MPSSE GPIO state to the FT232H chip.
 func (f *FTDI232H) mpsseWriteGpio() error {
-	f.mpsseGpio()
-	_, err := f.Write(commandUpdatePins)
+	_, err := f.write(f.mpsseGpio())
 	return err
 }
 
-// if [maxRetries] < 0 then default to 10.
-func (f *FTDI232H) mpsseSync(maxRetries int) error {
+// if [maxRetries] < 0 then default to 10. Each read waits at most 3 seconds,
+// or less if [ctx] ends first.
+func (f *FTDI232H) mpsseSync(ctx context.Context, maxRetries int) error {
 	// Synchronize buffers with MPSSE by sending bad opcode and reading expected
 	// error response.  Should be called once after enabling MPSSE.
 
 	// Send a bad/unknown command (0xab), then read buffer until bad command
 	// response is found.
 	// log.Println("FTDI232H mpsseSync writing bad command")
-	_, err := f.Write(commandBad)
+	_, err := f.write(commandBad)
 
 	if err != nil {
 		return err
@@ -673,10 +914,12 @@ func (f *FTDI232H) mpsseSync(maxRetries int) error {
 	sync := false
 
 	for !sync {
-		data, err := f.PollRead(2, -1)
+		readCtx, cancel := context.WithTimeout(ctx, defaultPollTimeout)
+		data, err := f.pollReadContext(readCtx, 2)
+		cancel()
 		if err != nil {
 			log.Println("FTDI232H mpsseSync pollRead failed.")
-			return err
+			return &SyncError{Tries: tries, Err: err}
 		}
 
 		if data[0] == 0xfa && data[1] == 0xab {
@@ -687,7 +930,7 @@ func (f *FTDI232H) mpsseSync(maxRetries int) error {
 			log.Printf("FTDI232H mpsseSync trying again: %d", tries)
 
 			if tries >= maxRetries {
-				return errors.New("could not synchronize with FT232H")
+				return &SyncError{Tries: tries, Response: []byte{data[0], data[1]}}
 			}
 		}
 	}
@@ -700,7 +943,13 @@ func (f *FTDI232H) mpsseSync(maxRetries int) error {
 // [clock] is specified in Hertzs (Hz)
 // Set the clock speed of the MPSSE engine.  Can be any value from 450hz
 // to 30mhz and will pick that speed or the closest speed below it.
-func (f *FTDI232H) SetClock(clock int, adaptive, threePhase bool) {
+func (f *FTDI232H) SetClock(clock int, adaptive, threePhase bool) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.setClock(clock, adaptive, threePhase)
+}
+
+func (f *FTDI232H) setClock(clock int, adaptive, threePhase bool) error {
 
 	// ----------------------------------------------------------
 	// Could issue each command on a separate "write"
@@ -726,7 +975,15 @@ func (f *FTDI232H) SetClock(clock int, adaptive, threePhase bool) {
 	// ----------------------------------------------------------
 	// Or
 	// We issue all commands with one write as done below:
-	f.Write(commandBasicSPIConfig)
+	command := []byte{disableClockDivisor, disableAdaptiveClocking, disable3PhaseClk, 0x86, 0, 0}
+
+	if adaptive {
+		command[1] = enableAdaptiveClocking
+	}
+
+	if threePhase {
+		command[2] = enable3PhaseClk
+	}
 
 	// Compute divisor for requested clock.
 	// Use equation from section 3.8.1 of:
@@ -739,23 +996,44 @@ func (f *FTDI232H) SetClock(clock int, adaptive, threePhase bool) {
 	}
 
 	// Send command to set divisor from low and high byte values.
-	commandSetDivisor[1] = byte(divisor & 0xff)        // low byte
-	commandSetDivisor[2] = byte((divisor >> 8) & 0xff) // high byte
+	command[4] = byte(divisor & 0xff)        // low byte
+	command[5] = byte((divisor >> 8) & 0xff) // high byte
 
-	f.Write(commandSetDivisor)
+	_, err := f.write(command)
+	if err != nil {
+		return err
+	}
+
+	// Three-phase clocking makes each TCK period three half periods long.
+	f.clock = 30000000 / (divisor + 1)
+	if threePhase {
+		f.clock = f.clock * 2 / 3
+	}
+
+	f.state.clock = clock
+	f.state.adaptive = adaptive
+	f.state.threePhase = threePhase
+
+	return nil
 }
 
-func (f *FTDI232H) mpsseReadGpio() gpio.Pins {
+func (f *FTDI232H) mpsseReadGpio() (gpio.Pins, error) {
 	// Read both GPIO bus states and return a 16 bit value with their state.
 	// D0-D7 are the lower 8 bits and C0-C7 are the upper 8 bits.
 
 	// Send command to read low byte and high byte.
-	f.Write(commandReadHighLowBytes)
+	_, err := f.write(commandReadHighLowBytes)
+	if err != nil {
+		return 0, err
+	}
 
 	// Wait for 2 byte response.
-	data, err := f.PollRead(2, -1)
+	ctx, cancel := context.WithTimeout(context.Background(), defaultPollTimeout)
+	defer cancel()
+
+	data, err := f.pollReadContext(ctx, 2)
 	if err != nil {
-		log.Fatal(err)
+		return 0, err
 	}
 
 	// Assemble response into 16 bit value.
@@ -764,10 +1042,13 @@ func (f *FTDI232H) mpsseReadGpio() gpio.Pins {
 
 	// logger.debug('Read MPSSE GPIO low byte = {0:02X} and high byte = {1:02X}'.format(low_byte, high_byte))
 
-	return gpio.Pins(highByte | lowByte)
+	return gpio.Pins(highByte | lowByte), nil
 }
 
-func (f FTDI232H) String() string {
+func (f *FTDI232H) String() string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
 	s := "\n"
 	s += "          111111\n"
 	s += "0123456789012345\n"
@@ -777,6 +1058,9 @@ func (f FTDI232H) String() string {
 
 // ToStringFullBinary returns a full report of the component
 func (f *FTDI232H) ToStringFullBinary() string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
 	s := "\n"
 	s += "          111111\n"
 	s += "0123456789012345\n"
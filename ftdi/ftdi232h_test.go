@@ -0,0 +1,153 @@
+package ftdi_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/wdevore/hardware/ftdi"
+	"github.com/wdevore/hardware/ftdi/sim"
+	"github.com/wdevore/hardware/gpio"
+)
+
+func configured(t *testing.T) (*ftdi.FTDI232H, *sim.FT232H) {
+	t.Helper()
+	f, s := sim.NewFTDI232H()
+	err := f.Configure(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, s
+}
+
+func TestConfigure(t *testing.T) {
+	_, s := configured(t)
+
+	if s.Mode != ftdi.ModeMPSSE {
+		t.Errorf("mode %v, want MPSSE", s.Mode)
+	}
+
+	// 60MHz / ((1 + 1) * 2), the closest to the default 20MHz.
+	if hz := s.Clock(); hz != 15000000 {
+		t.Errorf("clock %d, want 15000000", hz)
+	}
+	if s.DivideBy5 || s.Adaptive || s.ThreePhase {
+		t.Errorf("divide by 5 %v, adaptive %v, three phase %v, want all off", s.DivideBy5, s.Adaptive, s.ThreePhase)
+	}
+
+	// The clock setup, then the bad command mpsseSync waits for.
+	want := []byte{0x8a, 0x97, 0x8d, 0x86, 0xab}
+	if len(s.Ops) != len(want) {
+		t.Fatalf("ops %v, want opcodes % x", s.Ops, want)
+	}
+	for i, op := range s.Ops {
+		if op.Opcode != want[i] {
+			t.Errorf("op %d is %v, want opcode %#02x", i, op, want[i])
+		}
+	}
+	if in := s.Ops[4].In; !bytes.Equal(in, []byte{0xfa, 0xab}) {
+		t.Errorf("bad command answered % x, want fa ab", in)
+	}
+}
+
+func TestOutput(t *testing.T) {
+	f, s := configured(t)
+
+	err := f.ConfigPins([]gpio.PinConfiguration{
+		{Pin: ftdi.D4, Direction: gpio.Output, Value: gpio.High},
+		{Pin: ftdi.C2, Direction: gpio.Output, Value: gpio.Low},
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = f.OutputHigh(ftdi.C2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Direction != 1<<ftdi.D4|1<<ftdi.C2 {
+		t.Errorf("direction %#04x", s.Direction)
+	}
+	if pins := s.Pins16(); pins&(1<<ftdi.D4|1<<ftdi.C2) != 1<<ftdi.D4|1<<ftdi.C2 {
+		t.Errorf("pins %#04x, want D4 and C2 high", pins)
+	}
+
+	// Each write sets both banks.
+	last := s.Ops[len(s.Ops)-2:]
+	if last[0].Opcode != 0x80 || last[1].Opcode != 0x82 {
+		t.Fatalf("ops %v, want 0x80 then 0x82", last)
+	}
+	if !bytes.Equal(last[1].Args, []byte{1 << (ftdi.C2 - 8), 1 << (ftdi.C2 - 8)}) {
+		t.Errorf("high bank args % x", last[1].Args)
+	}
+}
+
+func TestReadInputs(t *testing.T) {
+	f, s := configured(t)
+
+	s.SetInput(ftdi.D5, true)
+	s.SetInput(ftdi.C7, true)
+
+	pins, err := f.ReadInputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pins != 1<<ftdi.D5|1<<ftdi.C7 {
+		t.Errorf("pins %#04x, want D5 and C7", pins)
+	}
+
+	level, err := f.ReadInput(ftdi.D5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if level != gpio.High {
+		t.Errorf("D5 %v, want High", level)
+	}
+
+	s.SetInput(ftdi.D5, false)
+	level, err = f.ReadInput(ftdi.D5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if level != gpio.Low {
+		t.Errorf("D5 %v, want Low", level)
+	}
+
+	// Both banks are read back every time.
+	reads := 0
+	for _, op := range s.Ops {
+		if op.Opcode == 0x81 || op.Opcode == 0x83 {
+			reads++
+		}
+	}
+	if reads != 6 {
+		t.Errorf("%d bank reads, want 6", reads)
+	}
+}
+
+// failingClose is a simulator whose Close fails.
+type failingClose struct {
+	*sim.FT232H
+}
+
+var errClose = errors.New("close failed")
+
+func (failingClose) Close() error {
+	return errClose
+}
+
+func TestCloseError(t *testing.T) {
+	f := ftdi.NewFTDI232H(0x0403, 0x6014)
+	f.SetTransport(failingClose{sim.New()})
+
+	err := f.Close()
+	if !errors.Is(err, errClose) {
+		t.Errorf("Close: %v, want the transport's error", err)
+	}
+
+	// The device is let go of anyway.
+	err = f.Close()
+	if !errors.Is(err, ftdi.ErrNotOpen) {
+		t.Errorf("second Close: %v, want ErrNotOpen", err)
+	}
+}
@@ -0,0 +1,125 @@
+package ftdi
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/wdevore/hardware/gpio"
+)
+
+// FTDI232H is a gpio.Provider and a gpio.Port over D0-D7 and C0-C7 in MPSSE
+// mode, so drivers written against the gpio interfaces can use its pins.
+
+// Line is one FT232H pin, see gpio.Line.
+type Line struct {
+	f   *FTDI232H
+	pin gpio.Pin
+}
+
+// Line returns [pin] as a gpio.Line.
+func (f *FTDI232H) Line(pin gpio.Pin) (gpio.Line, error) {
+	if pin > C7 {
+		return nil, fmt.Errorf("%w: %s", ErrNotGPIO, PinName(pin))
+	}
+	return &Line{f: f, pin: pin}, nil
+}
+
+// Pin returns the pin number.
+func (l *Line) Pin() gpio.Pin {
+	return l.pin
+}
+
+// Routed is implemented by lines driven through a FTDI232H, such as its own
+// pins or SoftSPI's. A Queue can't drive such a line of its own device from
+// Flush, which holds the device.
+type Routed interface {
+	FTDI() *FTDI232H
+}
+
+// FTDI returns the device the pin belongs to.
+func (l *Line) FTDI() *FTDI232H {
+	return l.f
+}
+
+func (l *Line) String() string {
+	return PinName(l.pin)
+}
+
+// Claim claims the pin for [owner], see ClaimPins.
+func (l *Line) Claim(owner string, direction gpio.IODirection) error {
+	return l.f.ClaimPin(owner, l.pin, direction)
+}
+
+// Out makes the pin an output at [level].
+func (l *Line) Out(level gpio.PinState) error {
+	return l.f.WritePins(levelBit(l.pin, level), 1<<l.pin)
+}
+
+// In makes the pin an input and reads it.
+func (l *Line) In() (gpio.PinState, error) {
+	f := l.f
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.setPin(l.pin, gpio.Input)
+	err := f.mpsseWriteGpio()
+	if err != nil {
+		return gpio.Low, err
+	}
+
+	pins, err := f.mpsseReadGpio()
+	if err != nil {
+		return gpio.Low, err
+	}
+
+	if pins&(1<<l.pin) != 0 {
+		return gpio.High, nil
+	}
+	return gpio.Low, nil
+}
+
+// Toggle drives the pin to the opposite of its last written level.
+func (l *Line) Toggle() error {
+	f := l.f
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.setPin(l.pin, gpio.Output)
+	f.level ^= 1 << l.pin
+	return f.mpsseWriteGpio()
+}
+
+// Pulse drives the pin to [level] for [duration] then back. Both edges
+// are one queued sequence so no other traffic delays the second one.
+func (l *Line) Pulse(level gpio.PinState, duration time.Duration) error {
+	q := l.f.NewQueue()
+	q.OutputLine(l, level)
+	q.Delay(duration)
+	q.OutputLine(l, level.Invert())
+	_, err := q.Flush()
+	return err
+}
+
+// WritePins makes the pins in [mask] outputs at their bit of [levels] with
+// a single GPIO write, see gpio.Port.
+func (f *FTDI232H) WritePins(levels, mask gpio.Pins) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.direction |= uint16(mask)
+	f.level = f.level&^uint16(mask) | uint16(levels&mask)
+	return f.mpsseWriteGpio()
+}
+
+// ReadPins returns the levels of the pins in [mask], see gpio.Port.
+func (f *FTDI232H) ReadPins(mask gpio.Pins) (gpio.Pins, error) {
+	pins, err := f.ReadInputs()
+	return pins & mask, err
+}
+
+func levelBit(pin gpio.Pin, level gpio.PinState) gpio.Pins {
+	if level == gpio.High {
+		return 1 << pin
+	}
+	return 0
+}
@@ -0,0 +1,417 @@
+package ftdi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/wdevore/hardware/gpio"
+)
+
+// A Generator repeats a pattern of D0-D7 levels, for example PWM to dim a
+// backlight or drive a buzzer, without Go toggling each edge over USB.
+//
+// In MPSSE mode each level change is a GPIO write followed by idle clocks
+// (0x8E/0x8F) that time it, so the device can keep doing SPI between
+// repetitions. The idle clocks run TCK, therefore D0 is released (made an
+// input) while they run and a device on a shared SPI bus doesn't see them.
+// Add a pull-down on SCK (a pull-up for SPI mode 2 and 3) if the bus is
+// shared. Timing is exact to a TCK period plus a few cycles per GPIO write,
+// but other traffic on the device delays the next repetition.
+//
+// In synchronous bitbang mode the samples are clocked out at the rate set
+// by the baud rate. The whole D bus belongs to the generator, pins outside
+// the pattern are inputs.
+
+// Opcodes that clock TCK without shifting data.
+const (
+	idleClockBits  = 0x8e
+	idleClockBytes = 0x8f
+)
+
+// Each write to the device holds about this much of the pattern, other
+// users of the device wait at most this long.
+const patternChunkRate = 100 // chunks per second
+
+// pwmSteps is the duty cycle resolution of PWM patterns.
+const pwmSteps = 100
+
+// Errors returned by Generator.
+var (
+	// ErrPattern is returned for a pattern the generator can't play.
+	ErrPattern = errors.New("ftdi: invalid pattern")
+	// ErrGeneratorRunning is returned by Start while a pattern is playing.
+	ErrGeneratorRunning = errors.New("ftdi: generator already running")
+	// ErrGeneratorStopped is returned by Set when no pattern is playing.
+	ErrGeneratorStopped = errors.New("ftdi: generator not running")
+)
+
+// GeneratorMode selects how a Generator clocks out its samples.
+type GeneratorMode int
+
+const (
+	// GenerateMPSSE times GPIO writes with idle clocks. The device stays in
+	// MPSSE mode so SPI, I2C etc. can share it.
+	GenerateMPSSE GeneratorMode = iota
+	// GenerateSyncBitbang switches the device to synchronous bitbang and
+	// streams one byte per sample.
+	GenerateSyncBitbang
+)
+
+func (m GeneratorMode) String() string {
+	switch m {
+	case GenerateMPSSE:
+		return "MPSSE"
+	case GenerateSyncBitbang:
+		return "sync bitbang"
+	}
+	return fmt.Sprintf("GeneratorMode(%d)", int(m))
+}
+
+// Pattern is a sequence of D0-D7 levels played at a fixed rate.
+type Pattern struct {
+	// Pins is the mask of the D0-D7 pins driven, bit 0 is D0.
+	Pins byte
+	// Samples are D0-D7 levels, only the Pins bits are used.
+	Samples []byte
+	// Rate is in samples per second.
+	Rate int
+}
+
+// PWM returns a pattern driving [pins] at [frequency] Hz, High for [duty]
+// (0 to 1) of each period. The duty cycle is rounded to 1%.
+func PWM(pins byte, frequency int, duty float64) (Pattern, error) {
+	if frequency <= 0 {
+		return Pattern{}, fmt.Errorf("%w: PWM frequency %dHz", ErrPattern, frequency)
+	}
+	if duty < 0 || duty > 1 {
+		return Pattern{}, fmt.Errorf("%w: PWM duty %v is outside 0 to 1", ErrPattern, duty)
+	}
+
+	p := Pattern{Pins: pins, Samples: make([]byte, pwmSteps), Rate: frequency * pwmSteps}
+
+	high := int(math.Round(duty * pwmSteps))
+	for i := 0; i < high; i++ {
+		p.Samples[i] = pins
+	}
+
+	return p, nil
+}
+
+// Generator plays a Pattern from a background goroutine until stopped.
+type Generator struct {
+	f     *FTDI232H
+	owner string
+	mode  GeneratorMode
+
+	mutex   sync.Mutex
+	pattern Pattern
+	cancel  context.CancelFunc
+	done    chan struct{}
+	err     error
+}
+
+// NewGenerator creates a pattern generator for this device. The pattern's
+// pins are claimed for [owner], see ClaimPins.
+func (f *FTDI232H) NewGenerator(owner string, mode GeneratorMode) *Generator {
+	g := new(Generator)
+	g.f = f
+	g.owner = owner
+	g.mode = mode
+	return g
+}
+
+// Start configures the pattern's pins as outputs and plays [p] repeatedly
+// until Stop is called or [ctx] is done.
+func (g *Generator) Start(ctx context.Context, p Pattern) error {
+	p, err := g.check(p)
+	if err != nil {
+		return err
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.running() {
+		return ErrGeneratorRunning
+	}
+
+	pins := []gpio.PinConfiguration{}
+	for i := 0; i < 8; i++ {
+		if p.Pins&(1<<i) != 0 {
+			pins = append(pins, gpio.PinConfiguration{Pin: D0 + gpio.Pin(i), Direction: gpio.Output, Value: gpio.Z})
+		}
+	}
+
+	// The pins claimed here are given back if they can't be set up, the
+	// ones a previous pattern left claimed are kept.
+	held := g.f.PinClaims()
+	claimed := []gpio.PinConfiguration{}
+	for _, pin := range pins {
+		if claim, ok := held[pin.Pin]; !ok || claim.Owner != g.owner {
+			claimed = append(claimed, pin)
+		}
+	}
+
+	err = g.f.ClaimPins(g.owner, pins)
+	if err != nil {
+		return err
+	}
+
+	switch g.mode {
+	case GenerateMPSSE:
+		err = g.f.ConfigPins(pins, true)
+	case GenerateSyncBitbang:
+		err = g.f.SyncConfigure(p.Pins, p.Rate)
+	}
+	if err != nil {
+		g.f.releasePins(g.owner, claimed)
+		return err
+	}
+
+	parent := ctx
+	ctx, cancel := context.WithCancel(parent)
+	done := make(chan struct{})
+
+	g.pattern = p
+	g.cancel = cancel
+	g.done = done
+	g.err = nil
+
+	go func() {
+		defer close(done)
+		err := g.run(ctx, p.Rate)
+		if ctx.Err() != nil {
+			// Stopped, by Stop (not an error) or by the caller's context.
+			err = parent.Err()
+		}
+		g.setErr(err)
+	}()
+
+	return nil
+}
+
+// Set replaces the pattern of a running generator from its next repetition.
+// The pins must be the same, only the samples and rate can change.
+func (g *Generator) Set(p Pattern) error {
+	p, err := g.check(p)
+	if err != nil {
+		return err
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if !g.running() {
+		return ErrGeneratorStopped
+	}
+
+	if p.Pins != g.pattern.Pins {
+		return fmt.Errorf("%w: pins 0x%02x differ from the running pattern's 0x%02x", ErrPattern, p.Pins, g.pattern.Pins)
+	}
+
+	g.pattern = p
+
+	return nil
+}
+
+// Stop ends the pattern and waits for the goroutine to finish. The pins
+// are left at the level of the pattern's last sample and stay claimed.
+// It returns the error that stopped the generator earlier, if any.
+func (g *Generator) Stop() error {
+	g.mutex.Lock()
+	cancel, done := g.cancel, g.done
+	g.mutex.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+
+	cancel()
+	<-done
+
+	return g.Err()
+}
+
+// Running reports whether a pattern is playing.
+func (g *Generator) Running() bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.running()
+}
+
+// Err returns the error that stopped the generator, nil while it is
+// running or if Stop ended it.
+func (g *Generator) Err() error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.err
+}
+
+func (g *Generator) running() bool {
+	if g.done == nil {
+		return false
+	}
+	select {
+	case <-g.done:
+		return false
+	default:
+		return true
+	}
+}
+
+func (g *Generator) setErr(err error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.err = err
+}
+
+func (g *Generator) current() Pattern {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.pattern
+}
+
+// check validates [p] and returns a copy the caller can't modify.
+func (g *Generator) check(p Pattern) (Pattern, error) {
+	if p.Pins == 0 {
+		return p, fmt.Errorf("%w: no pins", ErrPattern)
+	}
+	if len(p.Samples) == 0 {
+		return p, fmt.Errorf("%w: no samples", ErrPattern)
+	}
+	if p.Rate <= 0 {
+		return p, fmt.Errorf("%w: rate %d", ErrPattern, p.Rate)
+	}
+	if g.mode == GenerateMPSSE && p.Pins&0x01 != 0 {
+		return p, fmt.Errorf("%w: D0 is the MPSSE clock", ErrPattern)
+	}
+
+	p.Samples = append([]byte(nil), p.Samples...)
+
+	return p, nil
+}
+
+// repeats returns how many repetitions of [p] make up one write.
+func repeats(p Pattern) int {
+	n := (p.Rate/patternChunkRate + len(p.Samples) - 1) / len(p.Samples)
+	if n < 1 {
+		n = 1
+	}
+	if n*len(p.Samples) > chunkSize {
+		n = chunkSize / len(p.Samples)
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+func (g *Generator) run(ctx context.Context, rate int) error {
+	var buffer []byte
+
+	for ctx.Err() == nil {
+		p := g.current()
+
+		switch g.mode {
+		case GenerateMPSSE:
+			var err error
+			buffer, err = g.f.playMPSSE(p, repeats(p), buffer[:0])
+			if err != nil {
+				return err
+			}
+		case GenerateSyncBitbang:
+			if p.Rate != rate {
+				err := g.f.SetBaudrate(p.Rate)
+				if err != nil {
+					return err
+				}
+				rate = p.Rate
+			}
+
+			buffer = buffer[:0]
+			for r := repeats(p); r > 0; r-- {
+				for _, sample := range p.Samples {
+					buffer = append(buffer, sample&p.Pins)
+				}
+			}
+
+			// Every byte clocked out returns a sample, the chip stalls if
+			// they aren't read.
+			_, err := g.f.Exchange(ctx, buffer, len(buffer))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return ctx.Err()
+}
+
+// playMPSSE writes [repeat] repetitions of [p] as GPIO writes timed by
+// idle clocks, using [buffer] for the commands.
+func (f *FTDI232H) playMPSSE(p Pattern, repeat int, buffer []byte) ([]byte, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.clock < p.Rate {
+		return buffer, fmt.Errorf("%w: rate %d is above the %dHz MPSSE clock", ErrPattern, p.Rate, f.clock)
+	}
+
+	// Pins outside the pattern keep whatever other users set.
+	level := byte(f.level) &^ p.Pins
+	direction := byte(f.direction) | p.Pins
+
+	// The clock at sample [i], rounded so the errors don't add up.
+	clocks := func(i int) int {
+		return int((int64(i)*int64(f.clock) + int64(p.Rate)/2) / int64(p.Rate))
+	}
+
+	n := len(p.Samples)
+	for r := 0; r < repeat; r++ {
+		for start := 0; start < n; {
+			// Samples with the same level are a single write.
+			end := start + 1
+			for end < n && (p.Samples[end]^p.Samples[start])&p.Pins == 0 {
+				end++
+			}
+
+			buffer = append(buffer, 0x80, level|p.Samples[start]&p.Pins, direction&^0x01)
+			buffer = appendIdleClocks(buffer, clocks(end)-clocks(start))
+
+			start = end
+		}
+	}
+
+	// Other users write the level the pattern ended at, not a stale one.
+	f.level = f.level&^uint16(p.Pins) | uint16(p.Samples[n-1]&p.Pins)
+
+	// Drive D0 again.
+	buffer = append(buffer, f.mpsseGpio()...)
+
+	_, err := f.write(buffer)
+
+	return buffer, err
+}
+
+// appendIdleClocks appends commands clocking TCK [n] times.
+func appendIdleClocks(buffer []byte, n int) []byte {
+	for n >= 8 {
+		bytes := n / 8
+		if bytes > MaxShiftLength {
+			bytes = MaxShiftLength
+		}
+
+		length := bytes - 1
+		buffer = append(buffer, idleClockBytes, byte(length&0xff), byte((length>>8)&0xff))
+		n -= bytes * 8
+	}
+
+	if n > 0 {
+		buffer = append(buffer, idleClockBits, byte(n-1))
+	}
+
+	return buffer
+}
@@ -0,0 +1,63 @@
+package ftdi_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/wdevore/hardware/ftdi"
+)
+
+func TestGeneratorThreePhase(t *testing.T) {
+	f, s := configured(t)
+
+	err := f.SetClock(1000000, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Reset()
+
+	g := f.NewGenerator("pwm", ftdi.GenerateMPSSE)
+	p := ftdi.Pattern{Pins: 0x02, Samples: []byte{0x02, 0x00}, Rate: 1000}
+	err = g.Start(context.Background(), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	err = g.Stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The idle clocks after the first level are one sample at the real
+	// TCK, two thirds of the divided clock.
+	clocks := 0
+	started := false
+	for _, op := range s.Ops {
+		if op.Opcode == 0x8e || op.Opcode == 0x8f {
+			started = true
+			clocks += op.Bits
+		} else if started {
+			break
+		}
+	}
+	if want := s.Clock() / p.Rate; clocks != want {
+		t.Errorf("%d clocks per sample, want %d", clocks, want)
+	}
+}
+
+func TestGeneratorStartError(t *testing.T) {
+	f, s := configured(t)
+
+	g := f.NewGenerator("pwm", ftdi.GenerateMPSSE)
+	p := ftdi.Pattern{Pins: 0x02, Samples: []byte{0x02, 0x00}, Rate: 1000}
+
+	s.Unplug()
+	err := g.Start(context.Background(), p)
+	if err == nil {
+		t.Fatal("started on an unplugged device")
+	}
+	if claims := f.PinClaims(); len(claims) != 0 {
+		t.Errorf("claims %v left after a failed Start", claims)
+	}
+}
@@ -0,0 +1,165 @@
+package ftdi
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/wdevore/hardware/gpio"
+)
+
+// Subsystems (SPI, I2C, JTAG, display drivers...) claim the pins they drive
+// when they are configured, so two of them can't silently fight over a pin.
+
+// ErrPinClaimed is returned when a pin is already claimed by another owner.
+var ErrPinClaimed = errors.New("ftdi: pin already claimed")
+
+// ErrNotGPIO is returned for a pin the MPSSE can't drive, for example C8 or
+// gpio.NoPin.
+var ErrNotGPIO = errors.New("ftdi: not a MPSSE GPIO pin")
+
+// PinClaim records who claimed a pin and in which direction.
+type PinClaim struct {
+	Owner     string
+	Direction gpio.IODirection
+}
+
+// PinConflictError reports a claim on a pin that another owner holds.
+type PinConflictError struct {
+	Pin      gpio.Pin
+	Owner    string
+	Claimant string
+}
+
+func (e *PinConflictError) Error() string {
+	return fmt.Sprintf("ftdi: pin %s is claimed by %q, it can't also be used by %q", PinName(e.Pin), e.Owner, e.Claimant)
+}
+
+// Is matches ErrPinClaimed.
+func (e *PinConflictError) Is(target error) bool {
+	return target == ErrPinClaimed
+}
+
+// PinName returns the board label of a pin, for example "D4" or "C0".
+func PinName(pin gpio.Pin) string {
+	switch {
+	case pin <= D7:
+		return fmt.Sprintf("D%d", pin-D0)
+	case pin <= C9:
+		return fmt.Sprintf("C%d", pin-C0)
+	}
+	return fmt.Sprintf("pin(%d)", pin)
+}
+
+// isRealPin filters out the gpio.NoPin style placeholders.
+func isRealPin(pin gpio.Pin) bool {
+	return pin <= C9
+}
+
+// ClaimPin claims a single pin for [owner], see ClaimPins.
+func (f *FTDI232H) ClaimPin(owner string, pin gpio.Pin, direction gpio.IODirection) error {
+	return f.ClaimPins(owner, []gpio.PinConfiguration{{Pin: pin, Direction: direction}})
+}
+
+// ClaimPins records [owner] as the user of [pins]. A pin held by another
+// owner fails the whole claim with a *PinConflictError, unless both only
+// read it (gpio.Input). Claiming again as the same owner updates the
+// direction. Placeholders such as gpio.NoPin are ignored.
+func (f *FTDI232H) ClaimPins(owner string, pins []gpio.PinConfiguration) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for _, p := range pins {
+		if !isRealPin(p.Pin) {
+			continue
+		}
+		claim, ok := f.claims[p.Pin]
+		if !ok || claim.Owner == owner {
+			continue
+		}
+		if claim.Direction == gpio.Input && p.Direction == gpio.Input {
+			continue
+		}
+		return &PinConflictError{Pin: p.Pin, Owner: claim.Owner, Claimant: owner}
+	}
+
+	if f.claims == nil {
+		f.claims = map[gpio.Pin]PinClaim{}
+	}
+
+	for _, p := range pins {
+		if !isRealPin(p.Pin) {
+			continue
+		}
+		if claim, ok := f.claims[p.Pin]; ok && claim.Owner != owner {
+			// A shared input stays with its first owner.
+			continue
+		}
+		f.claims[p.Pin] = PinClaim{Owner: owner, Direction: p.Direction}
+	}
+
+	return nil
+}
+
+// ReleasePins drops every claim held by [owner].
+func (f *FTDI232H) ReleasePins(owner string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for pin, claim := range f.claims {
+		if claim.Owner == owner {
+			delete(f.claims, pin)
+		}
+	}
+}
+
+// releasePins drops [owner]'s claims on [pins].
+func (f *FTDI232H) releasePins(owner string, pins []gpio.PinConfiguration) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for _, p := range pins {
+		if claim, ok := f.claims[p.Pin]; ok && claim.Owner == owner {
+			delete(f.claims, p.Pin)
+		}
+	}
+}
+
+// PinClaims returns a copy of the current claims.
+func (f *FTDI232H) PinClaims() map[gpio.Pin]PinClaim {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	claims := make(map[gpio.Pin]PinClaim, len(f.claims))
+	for pin, claim := range f.claims {
+		claims[pin] = claim
+	}
+	return claims
+}
+
+// PinMap formats the claims as a table, one pin per line, for example:
+//
+//	D0  out  SPI
+//	D4  out  RA8875 reset
+func (f *FTDI232H) PinMap() string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	pins := make([]gpio.Pin, 0, len(f.claims))
+	for pin := range f.claims {
+		pins = append(pins, pin)
+	}
+	sort.Slice(pins, func(i, j int) bool { return pins[i] < pins[j] })
+
+	var b strings.Builder
+	for _, pin := range pins {
+		claim := f.claims[pin]
+		direction := "out"
+		if claim.Direction == gpio.Input {
+			direction = "in"
+		}
+		fmt.Fprintf(&b, "%-3s %-4s %s\n", PinName(pin), direction, claim.Owner)
+	}
+	return b.String()
+}
@@ -0,0 +1,449 @@
+package ftdi
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/wdevore/hardware/gpio"
+)
+
+// MaxShiftLength is the most bytes a single shift command carries. MPSSE
+// lengths are 16 bits where 0 means 1, so it is 65536.
+const MaxShiftLength = 65536
+
+// rxFIFOSize is the FT232H's receive buffer. Once it is full the MPSSE stalls
+// until the host reads, and so does the rest of a write, see FlushContext.
+// Reading shift commands are split to fit it.
+const rxFIFOSize = 1024
+
+const (
+	shiftWriteBit = 0x10
+	shiftReadBit  = 0x20
+	shiftTMSBit   = 0x40
+	sendImmediate = 0x87
+)
+
+// queueStep runs after the bytes before [offset] are written: a pause, or
+// an action that isn't a MPSSE command such as driving another chip's pin.
+type queueStep struct {
+	offset   int
+	duration time.Duration
+	action   func() error
+}
+
+// queueRead is a command at [offset] that adds [n] bytes to the response.
+type queueRead struct {
+	offset int
+	n      int
+}
+
+// gpioUpdate is a GPIO command in the buffer at [offset]. Flush fills it in
+// from the device's directions and levels with the queued changes applied,
+// so they only reach the device's state once they are sent.
+type gpioUpdate struct {
+	offset int
+	// The pins in levelMask are set to level, the ones in directionMask to
+	// direction.
+	levelMask, level         uint16
+	directionMask, direction uint16
+}
+
+// Queue batches MPSSE commands so they reach the chip with a single USB write
+// instead of one write per pin change or data block. GPIO updates, data
+// shifts, reads and delays are appended in order and sent by Flush.
+//
+// Each FTDI232H.OutputHigh/OutputLow is a USB round trip of ~1ms, which
+// dominates small transfers like display commands. For example, a ST7735
+// SetAddrWindow is 12 writes unbatched and 1 write batched.
+//
+// A Queue is NOT safe for concurrent use, but several queues, each owned by
+// one goroutine, may share a device: Flush holds the device until it is
+// done, including while it drives lines of other chips, so their commands
+// never interleave.
+type Queue struct {
+	f *FTDI232H
+
+	buffer   []byte
+	steps    []queueStep
+	updates  []gpioUpdate
+	reads    []queueRead
+	expected int
+	// last is where the last Append started.
+	last int
+	// err is the first step that can't be queued, Flush returns it.
+	err error
+}
+
+// NewQueue creates an empty command queue for this device.
+func (f *FTDI232H) NewQueue() *Queue {
+	q := new(Queue)
+	q.f = f
+	q.buffer = make([]byte, 0, 256)
+	return q
+}
+
+// Reset empties the queue without sending anything.
+func (q *Queue) Reset() {
+	q.buffer = q.buffer[:0]
+	q.steps = q.steps[:0]
+	q.updates = q.updates[:0]
+	q.reads = q.reads[:0]
+	q.expected = 0
+	q.last = 0
+	q.err = nil
+}
+
+// Len returns the number of bytes queued.
+func (q *Queue) Len() int {
+	return len(q.buffer)
+}
+
+// Expected returns the number of response bytes the queued reads will return.
+func (q *Queue) Expected() int {
+	return q.expected
+}
+
+// Append adds raw MPSSE command bytes.
+func (q *Queue) Append(command ...byte) {
+	q.last = len(q.buffer)
+	q.buffer = append(q.buffer, command...)
+}
+
+// Expect adds [n] bytes to the response, for the reads added by the last
+// Append.
+func (q *Queue) Expect(n int) {
+	q.expect(q.last, n)
+}
+
+// expect records that the command at [offset] adds [n] bytes to the
+// response.
+func (q *Queue) expect(offset, n int) {
+	q.reads = append(q.reads, queueRead{offset: offset, n: n})
+	q.expected += n
+}
+
+// ------------------------------------------------------------------------
+// GPIO
+// ------------------------------------------------------------------------
+
+// WriteGPIO appends the device's directions and levels, as they are when
+// the queue is flushed.
+func (q *Queue) WriteGPIO() {
+	q.update(gpioUpdate{})
+}
+
+// Output sets the pin and appends the GPIO update. The device's level of
+// the pin changes when the queue is flushed.
+func (q *Queue) Output(pin gpio.Pin, value gpio.PinState) {
+	q.OutputPins(gpio.Pins(levelBits(pin, value)), 1<<pin)
+}
+
+// OutputHigh sets the pin High and appends the GPIO update.
+func (q *Queue) OutputHigh(pin gpio.Pin) {
+	q.Output(pin, gpio.High)
+}
+
+// OutputLow sets the pin Low and appends the GPIO update.
+func (q *Queue) OutputLow(pin gpio.Pin) {
+	q.Output(pin, gpio.Low)
+}
+
+// OutputPins sets the pins in [mask] to [levels] and appends one GPIO update
+// for all of them.
+func (q *Queue) OutputPins(levels, mask gpio.Pins) {
+	q.update(gpioUpdate{levelMask: uint16(mask), level: uint16(levels & mask)})
+}
+
+// ConfigPin sets the direction of the pin and appends the GPIO update. Like
+// FTDI232H.ConfigPin an input's level is cleared.
+func (q *Queue) ConfigPin(pin gpio.Pin, mode gpio.IODirection) {
+	u := gpioUpdate{directionMask: 1 << pin}
+	if mode == gpio.Input {
+		u.levelMask = 1 << pin
+	} else {
+		u.direction = 1 << pin
+	}
+	q.update(u)
+}
+
+// OutputLine makes [line] an output driven to [value] at this point of the
+// queue. A pin of this device is batched like Output, any other line is
+// driven by Flush between the commands before and after it, with the device
+// still held. A line driven through this device some other way (see Routed)
+// would need it too, Flush fails with ErrRoutedLine instead.
+func (q *Queue) OutputLine(line gpio.Line, value gpio.PinState) {
+	if l, ok := line.(*Line); ok && l.f == q.f {
+		q.update(gpioUpdate{
+			levelMask:     1 << l.pin,
+			level:         levelBits(l.pin, value),
+			directionMask: 1 << l.pin,
+			direction:     1 << l.pin,
+		})
+		return
+	}
+
+	if routed, ok := line.(Routed); ok && routed.FTDI() == q.f {
+		if q.err == nil {
+			q.err = fmt.Errorf("%w: %v", ErrRoutedLine, line)
+		}
+		return
+	}
+
+	q.steps = append(q.steps, queueStep{
+		offset: len(q.buffer),
+		action: func() error { return line.Out(value) },
+	})
+}
+
+// update appends a GPIO command Flush fills in, see gpioUpdate.
+func (q *Queue) update(u gpioUpdate) {
+	u.offset = len(q.buffer)
+	q.buffer = append(q.buffer, 0x80, 0, 0, 0x82, 0, 0)
+	q.updates = append(q.updates, u)
+}
+
+// levelBits returns the level bit of [pin] for [value].
+func levelBits(pin gpio.Pin, value gpio.PinState) uint16 {
+	if value == gpio.High {
+		return 1 << pin
+	}
+	return 0
+}
+
+// ReadGPIO appends a read of both GPIO banks. Two bytes (D0-D7 then C0-C7)
+// are added to the response.
+func (q *Queue) ReadGPIO() {
+	q.expect(len(q.buffer), 2)
+	q.buffer = append(q.buffer, commandReadHighLowBytes...)
+}
+
+// ------------------------------------------------------------------------
+// Data
+// ------------------------------------------------------------------------
+
+// Shift appends a byte oriented shift command (0x10-0x3F) followed by [data].
+// If the command reads (bit 5 set) len(data) bytes are added to the response.
+// Data longer than 64K is split into multiple commands, when the command
+// reads it is split into commands that fit the chip's receive buffer.
+func (q *Queue) Shift(opcode byte, data []byte) {
+	limit := MaxShiftLength
+	if opcode&shiftReadBit != 0 {
+		limit = rxFIFOSize
+	}
+
+	for len(data) > 0 {
+		n := len(data)
+		if n > limit {
+			n = limit
+		}
+
+		if opcode&shiftReadBit != 0 {
+			q.expect(len(q.buffer), n)
+		}
+
+		length := n - 1
+		q.buffer = append(q.buffer, opcode, byte(length&0xff), byte((length>>8)&0xff))
+		q.buffer = append(q.buffer, data[:n]...)
+
+		data = data[n:]
+	}
+}
+
+// ShiftIn appends a read-only shift command (0x20-0x2F) for [length] bytes,
+// split into commands that fit the chip's receive buffer.
+func (q *Queue) ShiftIn(opcode byte, length int) {
+	for length > 0 {
+		n := length
+		if n > rxFIFOSize {
+			n = rxFIFOSize
+		}
+
+		q.expect(len(q.buffer), n)
+		l := n - 1
+		q.buffer = append(q.buffer, opcode, byte(l&0xff), byte((l>>8)&0xff))
+
+		length -= n
+	}
+}
+
+// ShiftBits appends a bit oriented shift command (bit 1 set, including the
+// TMS commands 0x4A-0x6F) clocking 1 to 8 [bits] of [data]. If the command
+// reads one byte is added to the response holding the bits read.
+func (q *Queue) ShiftBits(opcode byte, bits int, data byte) {
+	if opcode&shiftReadBit != 0 {
+		q.expect(len(q.buffer), 1)
+	}
+
+	q.buffer = append(q.buffer, opcode, byte(bits-1))
+	if opcode&(shiftWriteBit|shiftTMSBit) != 0 {
+		q.buffer = append(q.buffer, data)
+	}
+}
+
+// ------------------------------------------------------------------------
+// Timing
+// ------------------------------------------------------------------------
+
+// Delay inserts a pause. The MPSSE has no timed wait so the queue is split
+// at this point: Flush writes everything before it, sleeps, then continues.
+func (q *Queue) Delay(duration time.Duration) {
+	q.steps = append(q.steps, queueStep{offset: len(q.buffer), duration: duration})
+}
+
+// Flush writes the queue to the device and, if any reads were queued,
+// polls for the response. The queue is empty afterwards.
+func (q *Queue) Flush() ([]byte, error) {
+	return q.FlushContext(context.Background())
+}
+
+// FlushContext is Flush but queued delays and the response read end early
+// when [ctx] is canceled or its deadline passes. Commands already written
+// are not undone. Without a deadline each read waits at most 3 seconds.
+//
+// The chip's receive buffer holds 1 KiB, so a queue whose reads return more
+// is sent in parts: ahead of a read that wouldn't fit the response so far
+// is read back, then the rest is written.
+func (q *Queue) FlushContext(ctx context.Context) ([]byte, error) {
+	defer q.Reset()
+
+	if q.err != nil {
+		return nil, q.err
+	}
+
+	// Hold the device through the delays and the read, another goroutine's
+	// commands must not land in the middle of the sequence.
+	q.f.mutex.Lock()
+	defer q.f.mutex.Unlock()
+
+	if q.expected > 0 {
+		// Ask the MPSSE to return the response immediately.
+		q.buffer = append(q.buffer, sendImmediate)
+	}
+
+	// The GPIO updates start from the device's state now, pins changed
+	// since they were queued keep their new levels.
+	level, direction := q.f.level, q.f.direction
+	for _, u := range q.updates {
+		level = level&^u.levelMask | u.level
+		direction = direction&^u.directionMask | u.direction
+		command := q.buffer[u.offset : u.offset+6]
+		command[1] = byte(level)
+		command[2] = byte(direction)
+		command[4] = byte(level >> 8)
+		command[5] = byte(direction >> 8)
+	}
+
+	s := sender{q: q, ctx: ctx, response: make([]byte, 0, q.expected)}
+
+	start := 0
+	for _, step := range q.steps {
+		err := s.write(start, step.offset)
+		if err != nil {
+			return nil, err
+		}
+		start = step.offset
+
+		if step.action != nil {
+			// The line is on another chip, the device stays held.
+			err := step.action()
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(step.duration):
+		}
+	}
+
+	err := s.write(start, len(q.buffer))
+	if err != nil {
+		return nil, err
+	}
+
+	// Everything is written, the device's state is what was sent.
+	q.f.level, q.f.direction = level, direction
+
+	if q.expected == 0 {
+		return nil, nil
+	}
+
+	err = s.read()
+	if err != nil {
+		return nil, err
+	}
+	return s.response, nil
+}
+
+// sender writes a queue's buffer for FlushContext, reading the response
+// back before it overflows the chip's receive buffer.
+type sender struct {
+	q   *Queue
+	ctx context.Context
+	// next is the first read not written yet, pending the response bytes
+	// the chip holds.
+	next     int
+	pending  int
+	response []byte
+}
+
+// write writes buffer[start:end]. A full receive buffer stalls the MPSSE,
+// and with it the rest of the write, so ahead of a read that doesn't fit
+// the response so far is read first.
+func (s *sender) write(start, end int) error {
+	q := s.q
+
+	for s.next < len(q.reads) && q.reads[s.next].offset < end {
+		r := q.reads[s.next]
+		if s.pending > 0 && s.pending+r.n > rxFIFOSize {
+			if r.offset > start {
+				_, err := q.f.write(q.buffer[start:r.offset])
+				if err != nil {
+					return err
+				}
+				start = r.offset
+			}
+
+			_, err := q.f.write([]byte{sendImmediate})
+			if err != nil {
+				return err
+			}
+			err = s.read()
+			if err != nil {
+				return err
+			}
+		}
+
+		s.pending += r.n
+		s.next++
+	}
+
+	if end > start {
+		_, err := q.f.write(q.buffer[start:end])
+		return err
+	}
+	return nil
+}
+
+// read reads the response bytes the chip holds.
+func (s *sender) read() error {
+	ctx := s.ctx
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultPollTimeout)
+		defer cancel()
+	}
+
+	response, err := s.q.f.pollReadContext(ctx, s.pending)
+	if err != nil {
+		return err
+	}
+
+	s.response = append(s.response, response...)
+	s.pending = 0
+	return nil
+}
@@ -0,0 +1,66 @@
+package ftdi_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/wdevore/hardware/ftdi"
+	"github.com/wdevore/hardware/gpio"
+)
+
+func TestQueueOutput(t *testing.T) {
+	f, s := configured(t)
+
+	q := f.NewQueue()
+	q.ConfigPin(ftdi.D4, gpio.Output)
+	q.OutputHigh(ftdi.D4)
+
+	// Nothing changes until the queue is flushed.
+	if f.GetLevels() != 0 {
+		t.Errorf("levels %#04x before Flush", f.GetLevels())
+	}
+
+	// A pin changed meanwhile is sent as is, without the queued one.
+	err := f.ConfigPin(ftdi.C0, gpio.Output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = f.OutputHigh(ftdi.C0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Level&(1<<ftdi.D4) != 0 {
+		t.Error("the queued level was sent by OutputHigh")
+	}
+
+	s.Reset()
+	_, err = q.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte{0x80, 0x00, 0x10, 0x82, 0x01, 0x01, 0x80, 0x10, 0x10, 0x82, 0x01, 0x01}
+	if !bytes.Equal(s.Raw, want) {
+		t.Errorf("wrote % x, want % x", s.Raw, want)
+	}
+	if levels := f.GetLevels(); levels != 1<<ftdi.D4|1<<ftdi.C0 {
+		t.Errorf("levels %#04x after Flush", levels)
+	}
+}
+
+func TestQueueReset(t *testing.T) {
+	f, s := configured(t)
+
+	q := f.NewQueue()
+	q.ConfigPin(ftdi.D4, gpio.Output)
+	q.OutputHigh(ftdi.D4)
+	q.Reset()
+
+	err := f.WriteGPIO()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.GetLevels() != 0 || s.Direction != 0 {
+		t.Errorf("levels %#04x, direction %#04x after Reset", f.GetLevels(), s.Direction)
+	}
+}
@@ -0,0 +1,322 @@
+package ftdi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"syscall"
+	"time"
+)
+
+// When reconnecting is enabled (see WithReconnect) the first operation that
+// finds the device gone, for example unplugged, closes it and returns an
+// error matching ErrDisconnected. A goroutine then reopens the same device,
+// by serial number, following the ReconnectPolicy. Until it succeeds every
+// operation fails fast with ErrDisconnected.
+//
+// Once reopened the chip gets its mode, clock, MPSSE sync, pin directions
+// and levels (or UART setup) back, then the OnReconnect hooks run so
+// drivers can redo what lives in their own chips, for example a display
+// that lost power with the cable.
+
+// ReconnectPolicy controls how a lost device is reopened.
+type ReconnectPolicy struct {
+	// Attempts is how many times reopening is tried, 0 keeps trying until
+	// Close.
+	Attempts int
+	// Delay is the wait before the first attempt. It doubles after each
+	// failed attempt up to MaxDelay.
+	Delay    time.Duration
+	MaxDelay time.Duration
+}
+
+// DefaultReconnectPolicy keeps trying, at least every 5 seconds.
+var DefaultReconnectPolicy = ReconnectPolicy{Delay: 250 * time.Millisecond, MaxDelay: 5 * time.Second}
+
+// WithReconnect reopens the device according to [policy] when it goes away.
+func WithReconnect(policy ReconnectPolicy) Option {
+	return func(f *FTDI232H) {
+		f.policy = &policy
+	}
+}
+
+// deviceState is what was set up on the chip, replayed after a reconnect.
+type deviceState struct {
+	channel Channel
+
+	// Set by SetBitmode, nothing is restored before the first one.
+	modeSet bool
+	iomask  byte
+	mode    BitMode
+
+	baudrate int
+
+	// The last SetClock arguments.
+	clock      int
+	adaptive   bool
+	threePhase bool
+}
+
+// OnReconnect adds [hook] to the functions run, in the order added, after
+// the device is reopened and its state restored. Hooks run on their own
+// goroutine and may use the FTDI232H, [ctx] ends when it is closed.
+func (f *FTDI232H) OnReconnect(hook func(ctx context.Context) error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.hooks = append(f.hooks, hook)
+}
+
+// Connected reports whether the device is open and not reconnecting.
+func (f *FTDI232H) Connected() bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.device != nil && f.reconnected == nil
+}
+
+// WaitConnected waits for a running reconnect, including its hooks, to
+// finish. It returns nil if the device is usable, else why not.
+func (f *FTDI232H) WaitConnected(ctx context.Context) error {
+	f.mutex.Lock()
+	done := f.reconnected
+	f.mutex.Unlock()
+
+	if done != nil {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-done:
+		}
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.device == nil {
+		return f.notOpen()
+	}
+
+	return f.reconnectErr
+}
+
+// isDisconnect reports whether [err] means the device went away.
+func isDisconnect(err error) bool {
+	return errors.Is(err, ErrDisconnected) || errors.Is(err, syscall.ENODEV)
+}
+
+// notOpen is the error for operations without a device.
+func (f *FTDI232H) notOpen() error {
+	if f.lost != nil {
+		return f.lost
+	}
+	return ErrNotOpen
+}
+
+// checkDisconnect starts reconnecting if [err] means the device went away
+// and reconnecting is enabled. It returns the error to report.
+func (f *FTDI232H) checkDisconnect(err error) error {
+	if err == nil || f.policy == nil || !isDisconnect(err) {
+		return err
+	}
+
+	if !errors.Is(err, ErrDisconnected) {
+		err = fmt.Errorf("%w: %v", ErrDisconnected, err)
+	}
+
+	if f.device != nil {
+		log.Printf("FTDI232H device lost: %v\n", err)
+		f.device.Close()
+		f.device = nil
+	}
+
+	if f.lost == nil {
+		f.lost = err
+	}
+
+	if f.reconnected == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		f.reconnected = make(chan struct{})
+		f.cancelReconnect = cancel
+		f.reconnectErr = nil
+		go f.reconnect(ctx, *f.policy, f.reconnected)
+	}
+
+	return err
+}
+
+// reconnect reopens the device until it works, the policy gives up or the
+// FTDI232H is closed.
+func (f *FTDI232H) reconnect(ctx context.Context, policy ReconnectPolicy, done chan struct{}) {
+	err := f.reconnectAttempts(ctx, policy)
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err != nil && ctx.Err() == nil {
+		log.Printf("FTDI232H reconnect failed: %v\n", err)
+		if f.device == nil {
+			f.lost = fmt.Errorf("%w: gave up reconnecting: %v", ErrDisconnected, err)
+		}
+	}
+
+	f.reconnectErr = err
+	f.reconnected = nil
+	f.cancelReconnect()
+	f.cancelReconnect = nil
+	close(done)
+}
+
+func (f *FTDI232H) reconnectAttempts(ctx context.Context, policy ReconnectPolicy) error {
+	delay := policy.Delay
+	var err error
+
+	for attempt := 1; policy.Attempts == 0 || attempt <= policy.Attempts; attempt++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+
+		err = f.reopen(ctx)
+		if err == nil {
+			log.Println("FTDI232H reconnected")
+
+			err = f.runHooks(ctx)
+			if err == nil || f.Transport() != nil {
+				// A hook failing on a present device isn't fixed by
+				// reopening it.
+				return err
+			}
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		log.Printf("FTDI232H reconnect attempt %d failed: %v\n", attempt, err)
+
+		delay *= 2
+		if policy.MaxDelay > 0 && delay > policy.MaxDelay {
+			delay = policy.MaxDelay
+		}
+	}
+
+	return err
+}
+
+// reopen opens the lost device again and restores its state.
+func (f *FTDI232H) reopen(ctx context.Context) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	// Closed while waiting.
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if f.device == nil {
+		err := f.reopenDevice()
+		if err != nil {
+			return err
+		}
+	}
+
+	err := f.restore(ctx)
+	if err != nil {
+		if f.device != nil {
+			f.device.Close()
+			f.device = nil
+		}
+		return err
+	}
+
+	f.lost = nil
+
+	return nil
+}
+
+// reopenDevice opens the device that was lost, even if others were
+// plugged in meanwhile.
+func (f *FTDI232H) reopenDevice() error {
+	if f.injected != nil {
+		transport, ok := f.injected.(ReopenTransport)
+		if !ok {
+			return fmt.Errorf("%w: the injected transport can't be reopened", ErrDisconnected)
+		}
+
+		err := transport.Reopen()
+		if err != nil {
+			return err
+		}
+
+		f.device = f.traced(transport)
+		return nil
+	}
+
+	selector := f.selector
+	if f.opened.Serial != "" {
+		selector = Selector{Serial: f.opened.Serial}
+	}
+
+	return f.openSelected(selector, f.state.channel)
+}
+
+// restore sets the chip up the way it was before it was lost.
+func (f *FTDI232H) restore(ctx context.Context) error {
+	err := f.configureBuffers()
+	if err != nil {
+		return err
+	}
+
+	state := f.state
+	if !state.modeSet {
+		return nil
+	}
+
+	err = f.setBitmode(state.iomask, state.mode)
+	if err != nil {
+		return err
+	}
+
+	switch state.mode {
+	case ModeMPSSE:
+		if state.clock != 0 {
+			err = f.setClock(state.clock, state.adaptive, state.threePhase)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = f.mpsseSync(ctx, -1)
+		if err != nil {
+			return err
+		}
+
+		return f.mpsseWriteGpio()
+	case ModeReset:
+		if f.serial.Baudrate != 0 {
+			return f.serialConfigure(f.serial)
+		}
+	}
+
+	if state.baudrate != 0 {
+		return f.setBaudrate(state.baudrate)
+	}
+
+	return nil
+}
+
+func (f *FTDI232H) runHooks(ctx context.Context) error {
+	f.mutex.Lock()
+	hooks := append([]func(context.Context) error(nil), f.hooks...)
+	f.mutex.Unlock()
+
+	for _, hook := range hooks {
+		err := hook(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
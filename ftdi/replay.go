@@ -0,0 +1,173 @@
+package ftdi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ErrReplayMismatch is returned when a replayed device answers differently
+// than the recording.
+var ErrReplayMismatch = errors.New("ftdi: replay differs from the trace")
+
+// ReplayMismatchError reports the first record the device answered
+// differently.
+type ReplayMismatchError struct {
+	// Record is the index of the record in the trace, from 0.
+	Record int
+	TraceRecord
+	Got []byte
+}
+
+func (e *ReplayMismatchError) Error() string {
+	return fmt.Sprintf("ftdi: replay differs at record %d (%s), expected % x, got % x", e.Record, e.Tag, e.Data, e.Got)
+}
+
+// Is matches ErrReplayMismatch.
+func (e *ReplayMismatchError) Is(target error) bool {
+	return target == ErrReplayMismatch
+}
+
+// ReplayOptions controls Replay.
+type ReplayOptions struct {
+	// Realtime keeps the recorded gaps between records, otherwise records
+	// are sent back to back.
+	Realtime bool
+	// Verify fails with a *ReplayMismatchError when the device doesn't
+	// return the bytes recorded.
+	Verify bool
+	// Record, if not nil, is called with each record before it is sent.
+	Record func(index int, record TraceRecord)
+}
+
+// Replay sends the trace read from [trace] to [device], a real chip (see
+// OpenUSBDevice) or a simulator. Reads wait for as many bytes as were
+// recorded, at most 3 seconds each unless [ctx] ends earlier.
+func Replay(ctx context.Context, trace io.Reader, device Transport, options ReplayOptions) error {
+	reader, err := NewTraceReader(trace)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+
+	for index := 0; ; index++ {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if options.Realtime {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Until(start.Add(record.Time))):
+			}
+		} else if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if options.Record != nil {
+			options.Record(index, record)
+		}
+
+		got, err := replayRecord(ctx, device, record)
+		if err != nil {
+			return fmt.Errorf("ftdi: replay record %d (%s): %w", index, record.Tag, err)
+		}
+
+		if options.Verify && got != nil && !bytes.Equal(got, record.Data) {
+			return &ReplayMismatchError{Record: index, TraceRecord: record, Got: got}
+		}
+	}
+}
+
+// replayRecord performs [record] on [device] and returns what was read, if
+// the record is a read.
+func replayRecord(ctx context.Context, device Transport, record TraceRecord) ([]byte, error) {
+	switch record.Kind {
+	case TraceWrite:
+		_, err := device.Write(record.Data)
+		return nil, err
+	case TraceRead:
+		return replayRead(ctx, device, len(record.Data))
+	case TracePins:
+		pins, err := device.Pins()
+		return []byte{pins}, err
+	case TraceBitmode:
+		if len(record.Data) != 2 {
+			return nil, ErrTraceFormat
+		}
+		return nil, device.SetBitmode(record.Data[0], BitMode(record.Data[1]))
+	case TraceBaudrate:
+		return nil, device.SetBaudrate(record.Value)
+	case TraceReadChunkSize:
+		return nil, device.SetReadChunkSize(record.Value)
+	case TraceWriteChunkSize:
+		return nil, device.SetWriteChunkSize(record.Value)
+	}
+
+	serial, ok := device.(SerialTransport)
+	if !ok {
+		return nil, ErrNotSerial
+	}
+
+	switch record.Kind {
+	case TraceLineProperties:
+		if len(record.Data) != 4 {
+			return nil, ErrTraceFormat
+		}
+		d := record.Data
+		return nil, serial.SetLineProperties(DataBits(d[0]), StopBits(d[1]), Parity(d[2]), d[3] != 0)
+	case TraceFlowControl:
+		return nil, serial.SetFlowControl(FlowControl(record.Value))
+	case TraceModemLines:
+		if len(record.Data) != 2 {
+			return nil, ErrTraceFormat
+		}
+		return nil, serial.SetDTRRTS(record.Data[0] != 0, record.Data[1] != 0)
+	}
+
+	return nil, fmt.Errorf("%w: unknown record kind %v", ErrTraceFormat, record.Kind)
+}
+
+// replayRead polls [device] until [expected] bytes arrive, for at most 3
+// seconds or until [ctx] is done.
+func replayRead(ctx context.Context, device Transport, expected int) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultPollTimeout)
+	defer cancel()
+
+	start := time.Now()
+	response := make([]byte, expected)
+	received := 0
+
+	for {
+		n, err := device.Read(response[received:])
+		if err != nil {
+			return nil, err
+		}
+		received += n
+
+		if received >= expected {
+			return response, nil
+		}
+
+		if n == 0 {
+			// Nothing yet, give the device a moment.
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Millisecond):
+			}
+		}
+
+		if ctx.Err() != nil {
+			return nil, &TimeoutError{Expected: expected, Received: received, Timeout: time.Since(start), Err: ctx.Err()}
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package ftdi
+
+import (
+	"errors"
+
+	libftdi "github.com/ziutek/ftdi"
+)
+
+// When the chip is in UART mode (ModeReset) the following pins will have a special meaning:
+// D0 - TXD / Transmit data output.
+// D1 - RXD / Receive data input.
+// D2 - RTS# / Request to send output (active low).
+// D3 - CTS# / Clear to send input (active low).
+// D4 - DTR# / Data terminal ready output (active low).
+
+// DataBits is the number of data bits per character.
+type DataBits int
+
+const (
+	// DataBits7 is 7 data bits
+	DataBits7 DataBits = 7
+	// DataBits8 is 8 data bits
+	DataBits8 DataBits = 8
+)
+
+// StopBits is the number of stop bits per character.
+type StopBits int
+
+const (
+	// StopBits1 is 1 stop bit
+	StopBits1 StopBits = iota
+	// StopBits15 is 1.5 stop bits
+	StopBits15
+	// StopBits2 is 2 stop bits
+	StopBits2
+)
+
+// Parity selects the parity bit.
+type Parity int
+
+const (
+	// ParityNone sends no parity bit
+	ParityNone Parity = iota
+	// ParityOdd sends odd parity
+	ParityOdd
+	// ParityEven sends even parity
+	ParityEven
+	// ParityMark always sends a 1
+	ParityMark
+	// ParitySpace always sends a 0
+	ParitySpace
+)
+
+// FlowControl selects the handshaking the chip does in hardware.
+// The values match libftdi's SIO_xxx_HS constants.
+type FlowControl int
+
+const (
+	// FlowControlNone disables handshaking
+	FlowControlNone FlowControl = 0
+	// FlowControlRTSCTS uses the RTS#/CTS# lines
+	FlowControlRTSCTS FlowControl = 1 << 8
+	// FlowControlDTRDSR uses the DTR#/DSR# lines
+	FlowControlDTRDSR FlowControl = 2 << 8
+	// FlowControlXONXOFF uses XON/XOFF characters
+	FlowControlXONXOFF FlowControl = 4 << 8
+)
+
+// ErrNotSerial is returned when the Transport can't drive the UART.
+var ErrNotSerial = errors.New("ftdi: transport doesn't support UART mode")
+
+// SerialTransport is implemented by Transports that can drive the chip's
+// UART. It is separate from Transport so MPSSE only transports don't need it.
+type SerialTransport interface {
+	// SetLineProperties sets the character format and the break condition.
+	SetLineProperties(bits DataBits, stop StopBits, parity Parity, breakOn bool) error
+	// SetFlowControl selects hardware handshaking.
+	SetFlowControl(flow FlowControl) error
+	// SetDTRRTS drives the DTR# and RTS# lines. True asserts the line (low).
+	SetDTRRTS(dtr, rts bool) error
+}
+
+// SerialConfig is the UART line setup.
+type SerialConfig struct {
+	Baudrate    int
+	DataBits    DataBits
+	Parity      Parity
+	StopBits    StopBits
+	FlowControl FlowControl
+}
+
+// DefaultSerialConfig is 115200 8N1 without flow control.
+var DefaultSerialConfig = SerialConfig{
+	Baudrate: 115200,
+	DataBits: DataBits8,
+	Parity:   ParityNone,
+	StopBits: StopBits1,
+}
+
+// SerialConfigure switches the chip to its UART function. Configure or
+// SoftConfigure switch it back, so a device can share the adapter with SPI.
+func (f *FTDI232H) SerialConfigure(config SerialConfig) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	err := f.openIfNeeded()
+	if err != nil {
+		return err
+	}
+
+	return f.serialConfigure(config)
+}
+
+func (f *FTDI232H) serialConfigure(config SerialConfig) error {
+	serial, ok := f.device.(SerialTransport)
+	if !ok {
+		return ErrNotSerial
+	}
+
+	if config.DataBits == 0 {
+		config.DataBits = DataBits8
+	}
+
+	err := f.setBitmode(0x00, ModeReset)
+	if err != nil {
+		return err
+	}
+
+	err = f.setBaudrate(config.Baudrate)
+	if err != nil {
+		return err
+	}
+
+	err = serial.SetLineProperties(config.DataBits, config.StopBits, config.Parity, false)
+	if err != nil {
+		return f.checkDisconnect(err)
+	}
+
+	err = serial.SetFlowControl(config.FlowControl)
+	if err != nil {
+		return f.checkDisconnect(err)
+	}
+
+	f.serial = config
+
+	return nil
+}
+
+// SetBreak holds TXD low (a break condition) while [on] is true.
+func (f *FTDI232H) SetBreak(on bool) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.device == nil {
+		return f.notOpen()
+	}
+
+	serial, ok := f.device.(SerialTransport)
+	if !ok {
+		return ErrNotSerial
+	}
+
+	return f.checkDisconnect(serial.SetLineProperties(f.serial.DataBits, f.serial.StopBits, f.serial.Parity, on))
+}
+
+// SetDTRRTS drives the DTR# and RTS# lines. True asserts the line (low).
+func (f *FTDI232H) SetDTRRTS(dtr, rts bool) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.device == nil {
+		return f.notOpen()
+	}
+
+	serial, ok := f.device.(SerialTransport)
+	if !ok {
+		return ErrNotSerial
+	}
+
+	return f.checkDisconnect(serial.SetDTRRTS(dtr, rts))
+}
+
+// Read returns whatever bytes the chip has received, possibly none. Unlike
+// PollRead it doesn't wait for an expected count.
+func (f *FTDI232H) Read(data []byte) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.device == nil {
+		return 0, f.notOpen()
+	}
+
+	n, err := f.device.Read(data)
+	return n, f.checkDisconnect(err)
+}
+
+// ------------------------------------------------------------------------
+// libftdi
+// ------------------------------------------------------------------------
+
+func (u *usbTransport) SetLineProperties(bits DataBits, stop StopBits, parity Parity, breakOn bool) error {
+	brk := libftdi.BreakOff
+	if breakOn {
+		brk = libftdi.BreakOn
+	}
+	return u.check(u.device.SetLineProperties2(libftdi.DataBits(bits), libftdi.StopBits(stop), libftdi.Parity(parity), brk))
+}
+
+func (u *usbTransport) SetFlowControl(flow FlowControl) error {
+	return u.check(u.device.SetFlowControl(libftdi.FlowCtrl(flow)))
+}
+
+func (u *usbTransport) SetDTRRTS(dtr, rts bool) error {
+	d, r := 0, 0
+	if dtr {
+		d = 1
+	}
+	if rts {
+		r = 1
+	}
+	return u.check(u.device.SetDTRRTS(d, r))
+}
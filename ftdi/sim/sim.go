@@ -0,0 +1,731 @@
+// Package sim is a software model of the FT232H. It implements ftdi.Transport
+// so FTDI232H, FtdiSPI and the device drivers can be exercised without any
+// hardware attached.
+//
+// The MPSSE engine interprets the opcodes this project emits:
+// GPIO set/read (0x80-0x83), clock configuration (0x86, 0x8A/0x8B,
+// 0x8C/0x8D, 0x96/0x97), data shifting (0x10-0x3F and the 0x4x/0x6x TMS
+// variants), loopback (0x84/0x85), send-immediate (0x87), wait on I/O
+// (0x88/0x89, 0x94/0x95) and the bad-command response (0xFA, opcode) that
+// mpsseSync relies on.
+// In reset mode the chip is a UART, see QueueRX and TX. The EEPROM
+// field backs ftdi.EEPROMTransport. Unplug and Plug mimic a loose cable
+// for ftdi.WithReconnect.
+package sim
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/wdevore/hardware/ftdi"
+	"github.com/wdevore/hardware/gpio"
+)
+
+// Shift command bits. See section 3.2 of AN_108.
+const (
+	shiftWriteNegEdge = 0x01
+	shiftBitMode      = 0x02
+	shiftReadNegEdge  = 0x04
+	shiftLSBFirst     = 0x08
+	shiftWriteTDI     = 0x10
+	shiftReadTDO      = 0x20
+	shiftWriteTMS     = 0x40
+)
+
+// Other opcodes
+const (
+	setLowBits          = 0x80
+	readLowBits         = 0x81
+	setHighBits         = 0x82
+	readHighBits        = 0x83
+	loopbackOn          = 0x84
+	loopbackOff         = 0x85
+	setDivisor          = 0x86
+	sendImmediate       = 0x87
+	waitOnIOHigh        = 0x88
+	waitOnIOLow         = 0x89
+	disableClockDivisor = 0x8a
+	enableClockDivisor  = 0x8b
+	enable3PhaseClk     = 0x8c
+	disable3PhaseClk    = 0x8d
+	clockBits           = 0x8e
+	clockBytes          = 0x8f
+	clockWaitOnIOHigh   = 0x94
+	clockWaitOnIOLow    = 0x95
+	enableAdaptive      = 0x96
+	disableAdaptive     = 0x97
+	clockUntilHigh      = 0x9c
+	clockUntilLow       = 0x9d
+	driveZeroOnly       = 0x9e
+
+	badCommandResponse = 0xfa
+)
+
+// waitPin is GPIOL1, the pin the wait on I/O commands watch.
+const waitPin = gpio.Pin(5)
+
+// ErrClosed is returned when the simulator is used after Close.
+var ErrClosed = errors.New("sim: device closed")
+
+// Op is a decoded command as seen by the engine. Ops are appended to
+// FT232H.Ops in the order they were executed.
+type Op struct {
+	// Opcode is the first byte of the command.
+	Opcode byte
+	// Args holds the parameter bytes that follow the opcode (values, lengths).
+	Args []byte
+	// Out is the payload clocked out (MOSI/TDI or TMS) for shift commands.
+	Out []byte
+	// In is the data returned to the host, if any.
+	In []byte
+	// Bits is the number of clocks of a shift command.
+	Bits int
+}
+
+// FT232H simulates the chip behind a Transport.
+type FT232H struct {
+	mutex sync.Mutex
+
+	// Mode is the current bit mode as set by SetBitmode.
+	Mode ftdi.BitMode
+	// IOMask is the mask passed to SetBitmode.
+	IOMask byte
+	// Baudrate is the last value passed to SetBaudrate.
+	Baudrate int
+
+	// Direction is the 16 bit direction register (1 = output).
+	// D0-D7 are the lower 8 bits and C0-C7 are the upper 8 bits.
+	Direction uint16
+	// Level is the 16 bit output level register.
+	Level uint16
+	// Inputs is what the outside world drives onto pins configured as inputs.
+	Inputs uint16
+	// DriveZero is the open-drain mask set by 0x9E.
+	DriveZero uint16
+
+	// Divisor is the clock divisor set by 0x86.
+	Divisor uint16
+	// DivideBy5 is true when the 60MHz master clock is divided by 5.
+	DivideBy5 bool
+	// Adaptive is true when adaptive clocking is enabled.
+	Adaptive bool
+	// ThreePhase is true when three phase clocking is enabled.
+	ThreePhase bool
+	// Loopback connects TDI/DO to TDO/DI internally. In UART mode it
+	// connects TXD to RXD.
+	Loopback bool
+
+	// UART line setup as set through ftdi.SerialTransport.
+	DataBits    ftdi.DataBits
+	StopBits    ftdi.StopBits
+	Parity      ftdi.Parity
+	Break       bool
+	FlowControl ftdi.FlowControl
+	DTR, RTS    bool
+	// TX holds every byte transmitted in UART mode.
+	TX []byte
+
+	// EEPROM is the configuration EEPROM as 16 bit words. New fills it
+	// with the factory image, see ftdi.NewEEPROM.
+	EEPROM []uint16
+
+	// MISO supplies the bytes a slave clocks back during reads. If nil the
+	// bytes queued with QueueMISO are used, then 0xFF (an idle, pulled-up line).
+	MISO func() byte
+	miso []byte
+
+	// Ops is the transcript of every decoded command.
+	Ops []Op
+	// Raw holds every byte written, including GPIO and clock commands.
+	Raw []byte
+	// Flushes counts the send-immediate commands.
+	Flushes int
+	// MaxResponse is the most response bytes that waited for the host at
+	// once. The real chip's receive buffer holds 1024, past that the MPSSE
+	// stalls until the host reads.
+	MaxResponse int
+
+	readChunkSize  int
+	writeChunkSize int
+
+	pending  []byte // Partial command waiting for more bytes
+	response []byte // Bytes waiting to be read by the host
+
+	closed    bool
+	unplugged bool
+}
+
+// New creates a simulator in reset mode with all pins as inputs.
+func New() *FT232H {
+	s := new(FT232H)
+	s.DivideBy5 = true
+
+	image, _ := ftdi.NewEEPROM().Encode()
+	s.EEPROM = make([]uint16, len(image)/2)
+	for i := range s.EEPROM {
+		s.EEPROM[i] = uint16(image[2*i]) | uint16(image[2*i+1])<<8
+	}
+
+	return s
+}
+
+// NewFTDI232H returns an FTDI232H wired to a new simulator. Configure
+// can be called on the result as if it were real hardware.
+func NewFTDI232H() (*ftdi.FTDI232H, *FT232H) {
+	s := New()
+	f := ftdi.NewFTDI232H(0x0403, 0x6014)
+	f.SetTransport(s)
+	return f, s
+}
+
+// ------------------------------------------------------------------------
+// Scripting
+// ------------------------------------------------------------------------
+
+// QueueMISO appends bytes to be returned by subsequent reads.
+func (s *FT232H) QueueMISO(data ...byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.miso = append(s.miso, data...)
+}
+
+// QueueRX appends bytes as if they were received on RXD in UART mode.
+func (s *FT232H) QueueRX(data ...byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.response = append(s.response, data...)
+}
+
+// SetInput sets the externally driven level of a pin. A wait on I/O command
+// waiting for that level completes and the commands behind it run.
+func (s *FT232H) SetInput(pin gpio.Pin, high bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if high {
+		s.Inputs |= 1 << pin
+	} else {
+		s.Inputs &^= 1 << pin
+	}
+	if s.Mode == ftdi.ModeMPSSE {
+		s.execute()
+	}
+}
+
+// Pins16 returns the 16 bit value a read of both GPIO banks would return.
+func (s *FT232H) Pins16() uint16 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.pins()
+}
+
+// MOSI returns every byte clocked out on DO/MOSI by byte-mode shift commands.
+func (s *FT232H) MOSI() []byte {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var out []byte
+	for _, op := range s.Ops {
+		if isShift(op.Opcode) && op.Opcode&shiftWriteTDI != 0 && op.Opcode&shiftBitMode == 0 {
+			out = append(out, op.Out...)
+		}
+	}
+	return out
+}
+
+// Clock returns the effective SCK frequency in Hz.
+func (s *FT232H) Clock() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	base := 60000000
+	if s.DivideBy5 {
+		base = 12000000
+	}
+	hz := base / ((1 + int(s.Divisor)) * 2)
+	if s.ThreePhase {
+		hz = hz * 2 / 3
+	}
+	return hz
+}
+
+// Reset clears the transcripts but leaves the registers alone.
+func (s *FT232H) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Ops = nil
+	s.Raw = nil
+	s.Flushes = 0
+	s.MaxResponse = 0
+}
+
+// ------------------------------------------------------------------------
+// ftdi.Transport
+// ------------------------------------------------------------------------
+
+// Write feeds bytes to the engine. Commands may be split across writes.
+func (s *FT232H) Write(data []byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.usable(); err != nil {
+		return 0, err
+	}
+
+	s.Raw = append(s.Raw, data...)
+
+	switch s.Mode {
+	case ftdi.ModeReset:
+		// UART
+		s.TX = append(s.TX, data...)
+		if s.Loopback {
+			s.response = append(s.response, data...)
+		}
+	case ftdi.ModeMPSSE:
+		s.pending = append(s.pending, data...)
+		s.execute()
+	case ftdi.ModeBitbang:
+		for _, b := range data {
+			s.Level = (s.Level & 0xff00) | uint16(b&s.IOMask)
+		}
+	case ftdi.ModeSyncBB:
+		// Each byte written produces one byte of sampled pins.
+		for _, b := range data {
+			s.response = append(s.response, byte(s.pins()))
+			s.Level = (s.Level & 0xff00) | uint16(b&s.IOMask)
+		}
+	}
+
+	return len(data), nil
+}
+
+// Read returns queued response bytes. It never blocks.
+func (s *FT232H) Read(data []byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.usable(); err != nil {
+		return 0, err
+	}
+
+	n := copy(data, s.response)
+	s.response = s.response[n:]
+	return n, nil
+}
+
+// SetBitmode switches modes. Entering MPSSE resets the command parser.
+func (s *FT232H) SetBitmode(iomask byte, mode ftdi.BitMode) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.usable(); err != nil {
+		return err
+	}
+
+	s.IOMask = iomask
+	s.Mode = mode
+	s.pending = nil
+
+	if mode == ftdi.ModeBitbang || mode == ftdi.ModeSyncBB {
+		s.Direction = (s.Direction & 0xff00) | uint16(iomask)
+	}
+
+	return nil
+}
+
+// SetBaudrate records the rate.
+func (s *FT232H) SetBaudrate(baudRate int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Baudrate = baudRate
+	return nil
+}
+
+// Pins returns D0-D7.
+func (s *FT232H) Pins() (byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.usable(); err != nil {
+		return 0, err
+	}
+
+	return byte(s.pins()), nil
+}
+
+// SetReadChunkSize records the size.
+func (s *FT232H) SetReadChunkSize(size int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.readChunkSize = size
+	return nil
+}
+
+// SetWriteChunkSize records the size.
+func (s *FT232H) SetWriteChunkSize(size int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.writeChunkSize = size
+	return nil
+}
+
+// SetLineProperties records the UART character format and break.
+func (s *FT232H) SetLineProperties(bits ftdi.DataBits, stop ftdi.StopBits, parity ftdi.Parity, breakOn bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.DataBits = bits
+	s.StopBits = stop
+	s.Parity = parity
+	s.Break = breakOn
+	return nil
+}
+
+// SetFlowControl records the UART handshaking.
+func (s *FT232H) SetFlowControl(flow ftdi.FlowControl) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.FlowControl = flow
+	return nil
+}
+
+// SetDTRRTS records the modem control lines.
+func (s *FT232H) SetDTRRTS(dtr, rts bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.DTR = dtr
+	s.RTS = rts
+	return nil
+}
+
+// ReadEEPROMWord implements ftdi.EEPROMTransport.
+func (s *FT232H) ReadEEPROMWord(addr int) (uint16, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if addr < 0 || addr >= len(s.EEPROM) {
+		return 0, fmt.Errorf("sim: EEPROM address %d out of range", addr)
+	}
+	return s.EEPROM[addr], nil
+}
+
+// WriteEEPROMWord implements ftdi.EEPROMTransport.
+func (s *FT232H) WriteEEPROMWord(addr int, value uint16) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if addr < 0 || addr >= len(s.EEPROM) {
+		return fmt.Errorf("sim: EEPROM address %d out of range", addr)
+	}
+	s.EEPROM[addr] = value
+	return nil
+}
+
+// Close marks the simulator closed. Further I/O returns ErrClosed.
+func (s *FT232H) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.closed = true
+	return nil
+}
+
+// Reopen clears the closed flag, mimicking a device being opened again.
+// It fails with ftdi.ErrDisconnected while unplugged.
+func (s *FT232H) Reopen() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.unplugged {
+		return ftdi.ErrDisconnected
+	}
+
+	s.closed = false
+	s.pending = nil
+	s.response = nil
+	return nil
+}
+
+// Unplug mimics pulling the USB cable: I/O fails with ftdi.ErrDisconnected
+// and the chip loses its setup, as it does without power.
+func (s *FT232H) Unplug() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.unplugged = true
+
+	s.Mode = ftdi.ModeReset
+	s.IOMask = 0
+	s.Baudrate = 0
+	s.Direction = 0
+	s.Level = 0
+	s.DriveZero = 0
+	s.Divisor = 0
+	s.DivideBy5 = true
+	s.Adaptive = false
+	s.ThreePhase = false
+	s.Loopback = false
+	s.pending = nil
+	s.response = nil
+}
+
+// Plug mimics plugging the chip back in, Reopen works again.
+func (s *FT232H) Plug() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.unplugged = false
+}
+
+func (s *FT232H) usable() error {
+	if s.unplugged {
+		return ftdi.ErrDisconnected
+	}
+	if s.closed {
+		return ErrClosed
+	}
+	return nil
+}
+
+// ------------------------------------------------------------------------
+// Engine
+// ------------------------------------------------------------------------
+
+func (s *FT232H) pins() uint16 {
+	return (s.Level & s.Direction) | (s.Inputs & ^s.Direction)
+}
+
+func (s *FT232H) nextMISO() byte {
+	if s.MISO != nil {
+		return s.MISO()
+	}
+
+	if len(s.miso) > 0 {
+		b := s.miso[0]
+		s.miso = s.miso[1:]
+		return b
+	}
+
+	return 0xff
+}
+
+func isShift(opcode byte) bool {
+	return opcode&0x80 == 0 && opcode&(shiftWriteTDI|shiftReadTDO|shiftWriteTMS) != 0
+}
+
+// execute runs every complete command in pending.
+func (s *FT232H) execute() {
+	defer func() {
+		if len(s.response) > s.MaxResponse {
+			s.MaxResponse = len(s.response)
+		}
+	}()
+
+	for len(s.pending) > 0 {
+		n := s.step(s.pending)
+		if n == 0 {
+			// Incomplete, wait for more bytes.
+			return
+		}
+		s.pending = s.pending[n:]
+	}
+}
+
+// step decodes and runs one command. It returns the number of bytes consumed
+// or 0 if the command isn't complete yet or is waiting on I/O.
+func (s *FT232H) step(cmd []byte) int {
+	opcode := cmd[0]
+
+	if isShift(opcode) {
+		return s.shift(cmd)
+	}
+
+	need := func(n int) bool { return len(cmd) >= n }
+
+	switch opcode {
+	case setLowBits:
+		if !need(3) {
+			return 0
+		}
+		s.Level = (s.Level & 0xff00) | uint16(cmd[1])
+		s.Direction = (s.Direction & 0xff00) | uint16(cmd[2])
+		s.record(Op{Opcode: opcode, Args: clone(cmd[1:3])})
+		return 3
+	case setHighBits:
+		if !need(3) {
+			return 0
+		}
+		s.Level = (s.Level & 0x00ff) | uint16(cmd[1])<<8
+		s.Direction = (s.Direction & 0x00ff) | uint16(cmd[2])<<8
+		s.record(Op{Opcode: opcode, Args: clone(cmd[1:3])})
+		return 3
+	case readLowBits:
+		in := []byte{byte(s.pins())}
+		s.response = append(s.response, in...)
+		s.record(Op{Opcode: opcode, In: in})
+		return 1
+	case readHighBits:
+		in := []byte{byte(s.pins() >> 8)}
+		s.response = append(s.response, in...)
+		s.record(Op{Opcode: opcode, In: in})
+		return 1
+	case setDivisor:
+		if !need(3) {
+			return 0
+		}
+		s.Divisor = uint16(cmd[1]) | uint16(cmd[2])<<8
+		s.record(Op{Opcode: opcode, Args: clone(cmd[1:3])})
+		return 3
+	case clockBits:
+		if !need(2) {
+			return 0
+		}
+		s.record(Op{Opcode: opcode, Args: clone(cmd[1:2]), Bits: int(cmd[1]) + 1})
+		return 2
+	case clockBytes, clockUntilHigh, clockUntilLow, driveZeroOnly:
+		if !need(3) {
+			return 0
+		}
+		op := Op{Opcode: opcode, Args: clone(cmd[1:3])}
+		value := uint16(cmd[1]) | uint16(cmd[2])<<8
+		if opcode == clockBytes {
+			op.Bits = (int(value) + 1) * 8
+		}
+		if opcode == driveZeroOnly {
+			s.DriveZero = value
+		}
+		s.record(op)
+		return 3
+	case waitOnIOHigh, waitOnIOLow, clockWaitOnIOHigh, clockWaitOnIOLow:
+		high := s.pins()&(1<<waitPin) != 0
+		if high != (opcode == waitOnIOHigh || opcode == clockWaitOnIOHigh) {
+			// The engine stalls until GPIOL1 changes, see SetInput.
+			return 0
+		}
+		s.record(Op{Opcode: opcode})
+		return 1
+	case loopbackOn, loopbackOff, sendImmediate,
+		disableClockDivisor, enableClockDivisor,
+		enable3PhaseClk, disable3PhaseClk,
+		enableAdaptive, disableAdaptive:
+		switch opcode {
+		case loopbackOn:
+			s.Loopback = true
+		case loopbackOff:
+			s.Loopback = false
+		case sendImmediate:
+			s.Flushes++
+		case disableClockDivisor:
+			s.DivideBy5 = false
+		case enableClockDivisor:
+			s.DivideBy5 = true
+		case enable3PhaseClk:
+			s.ThreePhase = true
+		case disable3PhaseClk:
+			s.ThreePhase = false
+		case enableAdaptive:
+			s.Adaptive = true
+		case disableAdaptive:
+			s.Adaptive = false
+		}
+		s.record(Op{Opcode: opcode})
+		return 1
+	}
+
+	// Unknown opcode. The engine answers with 0xFA followed by the opcode.
+	in := []byte{badCommandResponse, opcode}
+	s.response = append(s.response, in...)
+	s.record(Op{Opcode: opcode, In: in})
+	return 1
+}
+
+// shift handles the data shifting commands.
+func (s *FT232H) shift(cmd []byte) int {
+	opcode := cmd[0]
+	write := opcode&(shiftWriteTDI|shiftWriteTMS) != 0
+	read := opcode&shiftReadTDO != 0
+	lsbFirst := opcode&shiftLSBFirst != 0
+
+	// TMS commands are always bit mode with a single data byte.
+	if opcode&(shiftBitMode|shiftWriteTMS) != 0 {
+		need := 2
+		if write {
+			need = 3
+		}
+		if len(cmd) < need {
+			return 0
+		}
+		bits := int(cmd[1]&0x07) + 1
+		op := Op{Opcode: opcode, Args: clone(cmd[1:2]), Bits: bits}
+		n := 2
+		var out byte
+		if write {
+			out = cmd[2]
+			op.Out = []byte{out}
+			n = 3
+		}
+		if read {
+			var b byte
+			if s.Loopback {
+				b = out
+			} else {
+				b = s.nextMISO()
+			}
+			op.In = []byte{shiftBitsIn(b, bits, lsbFirst)}
+			s.response = append(s.response, op.In...)
+		}
+		s.record(op)
+		return n
+	}
+
+	if len(cmd) < 3 {
+		return 0
+	}
+	length := int(cmd[1]) | int(cmd[2])<<8 + 1
+	n := 3
+	op := Op{Opcode: opcode, Args: clone(cmd[1:3]), Bits: length * 8}
+
+	if write {
+		if len(cmd) < 3+length {
+			return 0
+		}
+		op.Out = clone(cmd[3 : 3+length])
+		n += length
+	}
+
+	if read {
+		op.In = make([]byte, length)
+		for i := range op.In {
+			if s.Loopback && write {
+				op.In[i] = op.Out[i]
+			} else {
+				op.In[i] = s.nextMISO()
+			}
+		}
+		s.response = append(s.response, op.In...)
+	}
+
+	s.record(op)
+	return n
+}
+
+// shiftBitsIn models how the engine packs a partial byte. MSB first data is
+// shifted in at bit 0, LSB first data at bit 7.
+func shiftBitsIn(b byte, bits int, lsbFirst bool) byte {
+	if bits >= 8 {
+		return b
+	}
+	if lsbFirst {
+		return b << uint(8-bits)
+	}
+	return b >> uint(8-bits)
+}
+
+func (s *FT232H) record(op Op) {
+	s.Ops = append(s.Ops, op)
+}
+
+func clone(b []byte) []byte {
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
+
+func (op Op) String() string {
+	return fmt.Sprintf("%#02x args:%v out:%v in:%v bits:%d", op.Opcode, op.Args, op.Out, op.In, op.Bits)
+}
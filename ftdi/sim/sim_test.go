@@ -0,0 +1,85 @@
+package sim
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/wdevore/hardware/ftdi"
+)
+
+func mpsse(t *testing.T) *FT232H {
+	t.Helper()
+	s := New()
+	err := s.SetBitmode(0, ftdi.ModeMPSSE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func response(t *testing.T, s *FT232H) []byte {
+	t.Helper()
+	buf := make([]byte, 16)
+	n, err := s.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf[:n]
+}
+
+func TestWaitOnIO(t *testing.T) {
+	tests := []struct {
+		opcode byte
+		level  bool
+	}{
+		{waitOnIOHigh, true},
+		{waitOnIOLow, false},
+		{clockWaitOnIOHigh, true},
+		{clockWaitOnIOLow, false},
+	}
+
+	for _, test := range tests {
+		s := mpsse(t)
+		s.SetInput(waitPin, !test.level)
+
+		// The read behind the wait runs once GPIOL1 gets to the level.
+		s.Write([]byte{test.opcode, readLowBits})
+		if in := response(t, s); len(in) != 0 {
+			t.Errorf("%#02x: answered % x before GPIOL1 changed", test.opcode, in)
+		}
+
+		s.SetInput(waitPin, test.level)
+		want := byte(0)
+		if test.level {
+			want = 1 << waitPin
+		}
+		if in := response(t, s); !bytes.Equal(in, []byte{want}) {
+			t.Errorf("%#02x: answered % x, want %02x", test.opcode, in, want)
+		}
+	}
+}
+
+func TestBadCommand(t *testing.T) {
+	s := mpsse(t)
+	s.Write([]byte{0xab})
+	if in := response(t, s); !bytes.Equal(in, []byte{badCommandResponse, 0xab}) {
+		t.Errorf("answered % x, want fa ab", in)
+	}
+}
+
+func TestShift(t *testing.T) {
+	s := mpsse(t)
+	s.QueueMISO(0x5a)
+
+	// Full duplex, one byte, then the sample of a 3 bit read.
+	s.Write([]byte{0x31, 0x00, 0x00, 0xa5, 0x22, 0x02})
+	if in := response(t, s); !bytes.Equal(in, []byte{0x5a, 0x07}) {
+		t.Errorf("answered % x, want 5a 07", in)
+	}
+	if mosi := s.MOSI(); !bytes.Equal(mosi, []byte{0xa5}) {
+		t.Errorf("MOSI % x, want a5", mosi)
+	}
+	if len(s.Ops) != 2 || s.Ops[0].Bits != 8 || s.Ops[1].Bits != 3 {
+		t.Errorf("ops %v", s.Ops)
+	}
+}
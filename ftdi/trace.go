@@ -0,0 +1,525 @@
+package ftdi
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A trace records every buffer written to and read from the chip, with a
+// timestamp and the driver call that caused it, for example:
+//
+//	f.StartTrace(file)
+//	... misbehaving display initialization ...
+//	f.StopTrace()
+//
+// Traces are read back with NewTraceReader and pushed to a real or
+// simulated device with Replay.
+//
+// The file starts with traceMagic followed by records:
+//
+//	kind byte, time since the previous record in µs (uvarint), tag index
+//	(uvarint), data length (uvarint), data, value (uvarint)
+//
+// Tags are only written once, a record of kind 'T' with the tag string
+// (length and bytes) defines the next tag index.
+
+const traceMagic = "FTDITRC1"
+
+// A full HX8357 frame is 300K, anything much bigger isn't a record.
+const maxTraceRecord = 1 << 24
+
+// traceTag is the record kind defining a tag.
+const traceTag = 'T'
+
+// ErrTraceFormat is returned when a trace is damaged or not a trace.
+var ErrTraceFormat = errors.New("ftdi: bad trace format")
+
+// ErrTracing is returned by StartTrace when a trace is already running.
+var ErrTracing = errors.New("ftdi: already tracing")
+
+// TraceKind is the operation a TraceRecord describes.
+type TraceKind byte
+
+const (
+	// TraceWrite is a buffer written to the chip
+	TraceWrite TraceKind = 'W'
+	// TraceRead is a buffer read from the chip
+	TraceRead TraceKind = 'R'
+	// TracePins is a read of D0-D7 bypassing the read buffer
+	TracePins TraceKind = 'P'
+	// TraceBitmode is a bit mode change, Data holds the mask and mode
+	TraceBitmode TraceKind = 'M'
+	// TraceBaudrate is a baud rate change, Value holds the rate
+	TraceBaudrate TraceKind = 'B'
+	// TraceReadChunkSize is a USB read size change, Value holds the size
+	TraceReadChunkSize TraceKind = 'r'
+	// TraceWriteChunkSize is a USB write size change, Value holds the size
+	TraceWriteChunkSize TraceKind = 'w'
+	// TraceLineProperties is a UART format change, Data holds the data
+	// bits, stop bits, parity and break (0 or 1)
+	TraceLineProperties TraceKind = 'L'
+	// TraceFlowControl is a UART handshaking change, Value holds the flow control
+	TraceFlowControl TraceKind = 'F'
+	// TraceModemLines is a DTR#/RTS# change, Data holds dtr and rts (0 or 1)
+	TraceModemLines TraceKind = 'D'
+)
+
+func (k TraceKind) String() string {
+	switch k {
+	case TraceWrite:
+		return "write"
+	case TraceRead:
+		return "read"
+	case TracePins:
+		return "pins"
+	case TraceBitmode:
+		return "bitmode"
+	case TraceBaudrate:
+		return "baudrate"
+	case TraceReadChunkSize:
+		return "read-chunk"
+	case TraceWriteChunkSize:
+		return "write-chunk"
+	case TraceLineProperties:
+		return "line"
+	case TraceFlowControl:
+		return "flow"
+	case TraceModemLines:
+		return "modem"
+	}
+	return fmt.Sprintf("TraceKind(%d)", byte(k))
+}
+
+// TraceRecord is one traced operation.
+type TraceRecord struct {
+	// Time is since the trace started.
+	Time time.Duration
+	Kind TraceKind
+	// Tag is the driver call that caused the operation, for example
+	// "ST7735.SetAddrWindow".
+	Tag   string
+	Data  []byte
+	Value int
+}
+
+func (r TraceRecord) String() string {
+	s := fmt.Sprintf("%12.3fms %-11s %-28s", float64(r.Time)/float64(time.Millisecond), r.Kind, r.Tag)
+	switch r.Kind {
+	case TraceBaudrate, TraceReadChunkSize, TraceWriteChunkSize, TraceFlowControl:
+		return s + fmt.Sprintf(" %d", r.Value)
+	}
+	return s + fmt.Sprintf(" (%d) % x", len(r.Data), r.Data)
+}
+
+// ------------------------------------------------------------------------
+// Recording
+// ------------------------------------------------------------------------
+
+// traceWriter encodes records. It outlives the transports it wraps, so a
+// trace continues when the device is re-opened.
+type traceWriter struct {
+	mutex sync.Mutex
+	w     *bufio.Writer
+	start time.Time
+	last  time.Duration
+	tags  map[string]int
+	err   error
+}
+
+func newTraceWriter(w io.Writer) *traceWriter {
+	t := new(traceWriter)
+	t.w = bufio.NewWriter(w)
+	t.start = time.Now()
+	t.tags = map[string]int{}
+	_, t.err = t.w.WriteString(traceMagic)
+	return t
+}
+
+// record appends a record. The first error stops the trace, it is
+// reported by StopTrace.
+func (t *traceWriter) record(kind TraceKind, tag string, data []byte, value int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.err != nil {
+		return
+	}
+
+	index, ok := t.tags[tag]
+	if !ok {
+		index = len(t.tags)
+		t.tags[tag] = index
+		t.w.WriteByte(traceTag)
+		t.uvarint(uint64(len(tag)))
+		t.w.WriteString(tag)
+	}
+
+	now := time.Since(t.start)
+	delta := (now - t.last) / time.Microsecond
+	t.last += delta * time.Microsecond
+
+	t.w.WriteByte(byte(kind))
+	t.uvarint(uint64(delta))
+	t.uvarint(uint64(index))
+	t.uvarint(uint64(len(data)))
+	t.w.Write(data)
+	_, t.err = t.uvarint(uint64(value))
+}
+
+func (t *traceWriter) uvarint(v uint64) (int, error) {
+	var buffer [binary.MaxVarintLen64]byte
+	return t.w.Write(buffer[:binary.PutUvarint(buffer[:], v)])
+}
+
+func (t *traceWriter) flush() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.err != nil {
+		return t.err
+	}
+	return t.w.Flush()
+}
+
+// traceSkip are the packages below the drivers. A tag names the first
+// caller outside of them.
+var traceSkip = []string{
+	"github.com/wdevore/hardware/ftdi.",
+	"github.com/wdevore/hardware/spi.",
+	"github.com/wdevore/hardware/i2c.",
+	"github.com/wdevore/hardware/gpio.",
+	"runtime.",
+	"sync.",
+}
+
+// traceCaller returns the tag of the code calling into the transport
+// layers, for example "ST7735.SetAddrWindow".
+func traceCaller() string {
+	var pcs [32]uintptr
+	n := runtime.Callers(3, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+
+	for {
+		frame, more := frames.Next()
+		skip := false
+		for _, prefix := range traceSkip {
+			if strings.HasPrefix(frame.Function, prefix) {
+				skip = true
+				break
+			}
+		}
+		if !skip {
+			return traceName(frame.Function)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// traceName shortens "github.com/x/st7735.(*ST7735).SetAddrWindow" to
+// "ST7735.SetAddrWindow". Plain functions keep their package, "main.main".
+func traceName(function string) string {
+	function = function[strings.LastIndex(function, "/")+1:]
+
+	pkg, name, ok := strings.Cut(function, ".")
+	if !ok {
+		return function
+	}
+
+	if strings.HasPrefix(name, "(") {
+		name = strings.NewReplacer("(", "", ")", "", "*", "").Replace(name)
+		return name
+	}
+
+	return pkg + "." + name
+}
+
+// traceTransport records the traffic of the Transport it wraps.
+type traceTransport struct {
+	Transport
+	trace *traceWriter
+}
+
+func (t *traceTransport) Write(data []byte) (int, error) {
+	n, err := t.Transport.Write(data)
+	if n > 0 {
+		t.trace.record(TraceWrite, traceCaller(), data[:n], 0)
+	}
+	return n, err
+}
+
+func (t *traceTransport) Read(data []byte) (int, error) {
+	n, err := t.Transport.Read(data)
+	// Polls return nothing most of the time, only keep the data.
+	if n > 0 {
+		t.trace.record(TraceRead, traceCaller(), data[:n], 0)
+	}
+	return n, err
+}
+
+func (t *traceTransport) Pins() (byte, error) {
+	pins, err := t.Transport.Pins()
+	if err == nil {
+		t.trace.record(TracePins, traceCaller(), []byte{pins}, 0)
+	}
+	return pins, err
+}
+
+func (t *traceTransport) SetBitmode(iomask byte, mode BitMode) error {
+	err := t.Transport.SetBitmode(iomask, mode)
+	if err == nil {
+		t.trace.record(TraceBitmode, traceCaller(), []byte{iomask, byte(mode)}, 0)
+	}
+	return err
+}
+
+func (t *traceTransport) SetBaudrate(baudRate int) error {
+	err := t.Transport.SetBaudrate(baudRate)
+	if err == nil {
+		t.trace.record(TraceBaudrate, traceCaller(), nil, baudRate)
+	}
+	return err
+}
+
+func (t *traceTransport) SetReadChunkSize(size int) error {
+	err := t.Transport.SetReadChunkSize(size)
+	if err == nil {
+		t.trace.record(TraceReadChunkSize, traceCaller(), nil, size)
+	}
+	return err
+}
+
+func (t *traceTransport) SetWriteChunkSize(size int) error {
+	err := t.Transport.SetWriteChunkSize(size)
+	if err == nil {
+		t.trace.record(TraceWriteChunkSize, traceCaller(), nil, size)
+	}
+	return err
+}
+
+// The optional interfaces are passed through, so wrapping doesn't hide them.
+
+func (t *traceTransport) SetLineProperties(bits DataBits, stop StopBits, parity Parity, breakOn bool) error {
+	serial, ok := t.Transport.(SerialTransport)
+	if !ok {
+		return ErrNotSerial
+	}
+	err := serial.SetLineProperties(bits, stop, parity, breakOn)
+	if err == nil {
+		t.trace.record(TraceLineProperties, traceCaller(), []byte{byte(bits), byte(stop), byte(parity), traceBool(breakOn)}, 0)
+	}
+	return err
+}
+
+func (t *traceTransport) SetFlowControl(flow FlowControl) error {
+	serial, ok := t.Transport.(SerialTransport)
+	if !ok {
+		return ErrNotSerial
+	}
+	err := serial.SetFlowControl(flow)
+	if err == nil {
+		t.trace.record(TraceFlowControl, traceCaller(), nil, int(flow))
+	}
+	return err
+}
+
+func (t *traceTransport) SetDTRRTS(dtr, rts bool) error {
+	serial, ok := t.Transport.(SerialTransport)
+	if !ok {
+		return ErrNotSerial
+	}
+	err := serial.SetDTRRTS(dtr, rts)
+	if err == nil {
+		t.trace.record(TraceModemLines, traceCaller(), []byte{traceBool(dtr), traceBool(rts)}, 0)
+	}
+	return err
+}
+
+// EEPROM access isn't recorded, replaying it would rewrite the EEPROM.
+
+func (t *traceTransport) ReadEEPROMWord(addr int) (uint16, error) {
+	eeprom, ok := t.Transport.(EEPROMTransport)
+	if !ok {
+		return 0, ErrNoEEPROM
+	}
+	return eeprom.ReadEEPROMWord(addr)
+}
+
+func (t *traceTransport) WriteEEPROMWord(addr int, value uint16) error {
+	eeprom, ok := t.Transport.(EEPROMTransport)
+	if !ok {
+		return ErrNoEEPROM
+	}
+	return eeprom.WriteEEPROMWord(addr, value)
+}
+
+func traceBool(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// WithTrace records all traffic to [w] from the moment the device is
+// opened, see StartTrace.
+func WithTrace(w io.Writer) Option {
+	return func(f *FTDI232H) {
+		f.trace = newTraceWriter(w)
+	}
+}
+
+// StartTrace records all traffic to the chip to [w], including through a
+// device opened later, until StopTrace. SubmitRead doesn't work while
+// tracing.
+func (f *FTDI232H) StartTrace(w io.Writer) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.trace != nil {
+		return ErrTracing
+	}
+
+	f.trace = newTraceWriter(w)
+	f.device = f.traced(f.device)
+
+	return nil
+}
+
+// StopTrace ends the trace and flushes it. It returns the first error
+// writing the trace, the trace is cut short at that point.
+func (f *FTDI232H) StopTrace() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.trace == nil {
+		return nil
+	}
+
+	if t, ok := f.device.(*traceTransport); ok {
+		f.device = t.Transport
+	}
+
+	err := f.trace.flush()
+	f.trace = nil
+
+	return err
+}
+
+// traced wraps [device] while a trace is running.
+func (f *FTDI232H) traced(device Transport) Transport {
+	if f.trace == nil || device == nil {
+		return device
+	}
+	if _, ok := device.(*traceTransport); ok {
+		return device
+	}
+	return &traceTransport{Transport: device, trace: f.trace}
+}
+
+// ------------------------------------------------------------------------
+// Reading
+// ------------------------------------------------------------------------
+
+// TraceReader decodes a trace.
+type TraceReader struct {
+	r    *bufio.Reader
+	tags []string
+	time time.Duration
+}
+
+// NewTraceReader checks the trace header and returns a reader positioned
+// at the first record.
+func NewTraceReader(r io.Reader) (*TraceReader, error) {
+	t := new(TraceReader)
+	t.r = bufio.NewReader(r)
+
+	magic := make([]byte, len(traceMagic))
+	_, err := io.ReadFull(t.r, magic)
+	if err != nil || string(magic) != traceMagic {
+		return nil, fmt.Errorf("%w: missing header", ErrTraceFormat)
+	}
+
+	return t, nil
+}
+
+// Next returns the next record, or io.EOF after the last.
+func (t *TraceReader) Next() (TraceRecord, error) {
+	for {
+		kind, err := t.r.ReadByte()
+		if err != nil {
+			return TraceRecord{}, err
+		}
+
+		if kind != traceTag {
+			return t.record(TraceKind(kind))
+		}
+
+		tag, err := t.bytes()
+		if err != nil {
+			return TraceRecord{}, err
+		}
+		t.tags = append(t.tags, string(tag))
+	}
+}
+
+func (t *TraceReader) record(kind TraceKind) (TraceRecord, error) {
+	delta, err := t.uvarint()
+	if err != nil {
+		return TraceRecord{}, err
+	}
+
+	index, err := t.uvarint()
+	if err != nil {
+		return TraceRecord{}, err
+	}
+	if index >= uint64(len(t.tags)) {
+		return TraceRecord{}, fmt.Errorf("%w: undefined tag %d", ErrTraceFormat, index)
+	}
+
+	data, err := t.bytes()
+	if err != nil {
+		return TraceRecord{}, err
+	}
+
+	value, err := t.uvarint()
+	if err != nil {
+		return TraceRecord{}, err
+	}
+
+	t.time += time.Duration(delta) * time.Microsecond
+
+	return TraceRecord{Time: t.time, Kind: kind, Tag: t.tags[index], Data: data, Value: int(value)}, nil
+}
+
+func (t *TraceReader) uvarint() (uint64, error) {
+	v, err := binary.ReadUvarint(t.r)
+	if err != nil {
+		return 0, fmt.Errorf("%w: truncated record", ErrTraceFormat)
+	}
+	return v, nil
+}
+
+func (t *TraceReader) bytes() ([]byte, error) {
+	n, err := t.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if n > maxTraceRecord {
+		return nil, fmt.Errorf("%w: record of %d bytes", ErrTraceFormat, n)
+	}
+
+	data := make([]byte, n)
+	_, err = io.ReadFull(t.r, data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: truncated record", ErrTraceFormat)
+	}
+	return data, nil
+}
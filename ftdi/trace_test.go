@@ -0,0 +1,208 @@
+package ftdi_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/wdevore/hardware/ftdi"
+	"github.com/wdevore/hardware/ftdi/sim"
+)
+
+// traced configures a simulated chip and reads its inputs while tracing.
+func traced(t *testing.T) ([]byte, *sim.FT232H) {
+	t.Helper()
+	f, s := sim.NewFTDI232H()
+
+	var trace bytes.Buffer
+	err := f.StartTrace(&trace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = f.Configure(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetInput(ftdi.D5, true)
+	_, err = f.ReadInputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = f.StopTrace()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return trace.Bytes(), s
+}
+
+func TestTraceRoundTrip(t *testing.T) {
+	trace, s := traced(t)
+
+	r, err := ftdi.NewTraceReader(bytes.NewReader(trace))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var written, read []byte
+	var last time.Duration
+	bitmode := false
+	for {
+		record, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if record.Tag == "" {
+			t.Errorf("record %v has no tag", record)
+		}
+		if record.Time < last {
+			t.Errorf("record %v goes back in time", record)
+		}
+		last = record.Time
+
+		switch record.Kind {
+		case ftdi.TraceWrite:
+			written = append(written, record.Data...)
+		case ftdi.TraceRead:
+			read = append(read, record.Data...)
+		case ftdi.TraceBitmode:
+			if ftdi.BitMode(record.Data[1]) == ftdi.ModeMPSSE {
+				bitmode = true
+			}
+		}
+	}
+
+	if !bitmode {
+		t.Error("no switch to MPSSE in the trace")
+	}
+	if !bytes.Equal(written, s.Raw) {
+		t.Errorf("traced writes % x, the chip got % x", written, s.Raw)
+	}
+	// The sync answer, then both banks with D5 high.
+	if want := []byte{0xfa, 0xab, 1 << ftdi.D5, 0x00}; !bytes.Equal(read, want) {
+		t.Errorf("traced reads % x, want % x", read, want)
+	}
+}
+
+func TestTraceDamaged(t *testing.T) {
+	trace, _ := traced(t)
+
+	_, err := ftdi.NewTraceReader(bytes.NewReader(trace[:2]))
+	if !errors.Is(err, ftdi.ErrTraceFormat) {
+		t.Errorf("short header: %v, want ErrTraceFormat", err)
+	}
+
+	r, err := ftdi.NewTraceReader(bytes.NewReader(trace[:len(trace)-1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for err == nil {
+		_, err = r.Next()
+	}
+	if !errors.Is(err, ftdi.ErrTraceFormat) {
+		t.Errorf("truncated record: %v, want ErrTraceFormat", err)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	trace, s := traced(t)
+
+	replayed := sim.New()
+	replayed.SetInput(ftdi.D5, true)
+	records := 0
+	err := ftdi.Replay(context.Background(), bytes.NewReader(trace), replayed, ftdi.ReplayOptions{
+		Verify: true,
+		Record: func(index int, record ftdi.TraceRecord) { records++ },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records == 0 {
+		t.Error("no records replayed")
+	}
+	if !bytes.Equal(replayed.Raw, s.Raw) {
+		t.Errorf("replay wrote % x, want % x", replayed.Raw, s.Raw)
+	}
+	if replayed.Mode != ftdi.ModeMPSSE {
+		t.Errorf("replay left mode %v, want MPSSE", replayed.Mode)
+	}
+}
+
+func TestReplayMismatch(t *testing.T) {
+	trace, _ := traced(t)
+
+	// D5 is low this time.
+	err := ftdi.Replay(context.Background(), bytes.NewReader(trace), sim.New(), ftdi.ReplayOptions{Verify: true})
+
+	var mismatch *ftdi.ReplayMismatchError
+	if !errors.As(err, &mismatch) || !errors.Is(err, ftdi.ErrReplayMismatch) {
+		t.Fatalf("replay: %v, want a mismatch", err)
+	}
+	if mismatch.Kind != ftdi.TraceRead || !bytes.Equal(mismatch.Got, []byte{0x00, 0x00}) {
+		t.Errorf("mismatch %v got % x, want the pin read returning 00 00", mismatch.TraceRecord, mismatch.Got)
+	}
+}
+
+// uartTrace records a read of [data] received on a UART.
+func uartTrace(t *testing.T, data ...byte) []byte {
+	t.Helper()
+	f, s := sim.NewFTDI232H()
+	s.QueueRX(data...)
+
+	var trace bytes.Buffer
+	err := f.StartTrace(&trace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.Transport().Read(make([]byte, len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = f.StopTrace()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return trace.Bytes()
+}
+
+func TestReplayTimeout(t *testing.T) {
+	trace := uartTrace(t, 0x55)
+
+	// Nothing arrives, the read gives up at the context deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := ftdi.Replay(ctx, bytes.NewReader(trace), sim.New(), ftdi.ReplayOptions{})
+	if !errors.Is(err, ftdi.ErrReadTimeout) {
+		t.Fatalf("replay: %v, want ErrReadTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("gave up after %v, want about 50ms", elapsed)
+	}
+}
+
+func TestReplayReadLimit(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits for the 3 second read limit")
+	}
+	trace := uartTrace(t, 0x55)
+
+	// A distant deadline doesn't lift the per-read limit.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	start := time.Now()
+	err := ftdi.Replay(ctx, bytes.NewReader(trace), sim.New(), ftdi.ReplayOptions{})
+	if !errors.Is(err, ftdi.ErrReadTimeout) {
+		t.Fatalf("replay: %v, want ErrReadTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("gave up after %v, want about 3s", elapsed)
+	}
+}
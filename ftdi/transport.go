@@ -0,0 +1,82 @@
+package ftdi
+
+// -----------------------------------------------------------------------------
+// Transport
+// -----------------------------------------------------------------------------
+
+// BitMode selects how the FTDI chip interprets the bytes written to it.
+// The values match libftdi's BITMODE_xxx constants.
+type BitMode byte
+
+const (
+	// ModeReset switches the chip back to its EEPROM defined mode (typically UART)
+	ModeReset BitMode = 0x00
+	// ModeBitbang is asynchronous bit-bang
+	ModeBitbang BitMode = 0x01
+	// ModeMPSSE enables the Multi-Protocol Synchronous Serial Engine
+	ModeMPSSE BitMode = 0x02
+	// ModeSyncBB is synchronous bit-bang
+	ModeSyncBB BitMode = 0x04
+	// ModeMCU is MCU host bus emulation
+	ModeMCU BitMode = 0x08
+	// ModeOpto is fast opto-isolated serial
+	ModeOpto BitMode = 0x10
+	// ModeCBUS is bit-bang on the CBUS pins
+	ModeCBUS BitMode = 0x20
+	// ModeSyncFF is synchronous FIFO (FT2232H/FT232H only)
+	ModeSyncFF BitMode = 0x40
+	// ModeFT1284 is FT1284 mode (FT232H only)
+	ModeFT1284 BitMode = 0x80
+)
+
+// Channel selects an interface on multi-channel chips. The FT232H only
+// has one, so ChannelAny is almost always what you want.
+type Channel int
+
+const (
+	// ChannelAny uses the first available interface
+	ChannelAny Channel = iota
+	// ChannelA is interface A
+	ChannelA
+	// ChannelB is interface B
+	ChannelB
+	// ChannelC is interface C
+	ChannelC
+	// ChannelD is interface D
+	ChannelD
+)
+
+// Transport is the link FTDI232H uses to reach the chip. The default
+// implementation is a libftdi device on the USB bus (see OpenUSB), however,
+// anything that satisfies this interface can be injected with
+// FTDI232H.SetTransport, for example a fake, a recorder or a different USB stack.
+type Transport interface {
+	// Write sends raw bytes to the chip.
+	Write(data []byte) (int, error)
+	// Read fills data with whatever bytes the chip has queued. It may return
+	// fewer bytes than requested, including zero.
+	Read(data []byte) (int, error)
+	// SetBitmode configures the pin mask and operating mode.
+	SetBitmode(iomask byte, mode BitMode) error
+	// SetBaudrate sets the bit-bang/UART rate.
+	SetBaudrate(baudRate int) error
+	// Pins returns the current state of the low byte pins (D0-D7),
+	// circumventing the read buffer.
+	Pins() (byte, error)
+	// SetReadChunkSize sets the size of the USB read transfers.
+	SetReadChunkSize(size int) error
+	// SetWriteChunkSize sets the size of the USB write transfers.
+	SetWriteChunkSize(size int) error
+	// Close releases the link.
+	Close() error
+}
+
+// ReopenTransport is implemented by injected Transports that can get their
+// link back after it was lost, so FTDI232H can reconnect them (see
+// WithReconnect) like the USB devices it opens itself.
+type ReopenTransport interface {
+	Transport
+	// Reopen is called after Close, until it succeeds or reconnecting
+	// gives up.
+	Reopen() error
+}
@@ -0,0 +1,123 @@
+package ftdi
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	libftdi "github.com/ziutek/ftdi"
+)
+
+// usbTransport is the default Transport. It wraps a libftdi device.
+type usbTransport struct {
+	device *libftdi.Device
+
+	// What was opened, to find the usbfs node for EEPROM access.
+	vender, product int
+	selector        Selector
+	usbfs           *os.File
+
+	// The opened device as found in sysfs, empty fields if it couldn't be
+	// found. Its Path tells whether it is still attached.
+	info DeviceInfo
+}
+
+// newUSBTransport wraps an opened libftdi device, [info] is the device as
+// found in sysfs.
+func newUSBTransport(d *libftdi.Device, vender, product int, selector Selector, info DeviceInfo) *usbTransport {
+	return &usbTransport{device: d, vender: vender, product: product, selector: selector, info: info}
+}
+
+// OpenUSB opens the first device matching vender/product on the USB bus
+// using libftdi.
+func OpenUSB(vender, product int, channel Channel) (Transport, error) {
+	d, err := libftdi.OpenFirst(vender, product, libftdi.Channel(channel))
+	if err != nil {
+		if accessErr := usbAccessError(vender, product); accessErr != nil {
+			return nil, accessErr
+		}
+		return nil, err
+	}
+
+	// Which device libftdi took first is only known if there is just one.
+	var info DeviceInfo
+	if devices, _ := ListDevices(vender, product); len(devices) == 1 {
+		info = devices[0]
+	}
+
+	return newUSBTransport(d, vender, product, Selector{}, info), nil
+}
+
+// libusb and libftdi messages of a device that went away.
+var disconnectMessages = []string{"no such device", "device unavailable", "no_device"}
+
+// check marks [err] as ErrDisconnected if the device is no longer
+// attached, libftdi only reports which transfer failed.
+func (u *usbTransport) check(err error) error {
+	if err == nil || !u.gone(err) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", ErrDisconnected, err)
+}
+
+func (u *usbTransport) gone(err error) bool {
+	message := strings.ToLower(err.Error())
+	for _, m := range disconnectMessages {
+		if strings.Contains(message, m) {
+			return true
+		}
+	}
+
+	if u.info.Path == "" {
+		return false
+	}
+
+	_, statErr := os.Stat(filepath.Join(usbDevicesPath, u.info.Path))
+	return os.IsNotExist(statErr)
+}
+
+func (u *usbTransport) Write(data []byte) (int, error) {
+	n, err := u.device.Write(data)
+	return n, u.check(err)
+}
+
+func (u *usbTransport) Read(data []byte) (int, error) {
+	n, err := u.device.Read(data)
+	return n, u.check(err)
+}
+
+func (u *usbTransport) SetBitmode(iomask byte, mode BitMode) error {
+	return u.check(u.device.SetBitmode(iomask, libftdi.Mode(mode)))
+}
+
+func (u *usbTransport) SetBaudrate(baudRate int) error {
+	return u.check(u.device.SetBaudrate(baudRate))
+}
+
+func (u *usbTransport) Pins() (byte, error) {
+	pins, err := u.device.Pins()
+	return pins, u.check(err)
+}
+
+func (u *usbTransport) SetReadChunkSize(size int) error {
+	return u.device.SetReadChunkSize(size)
+}
+
+func (u *usbTransport) SetWriteChunkSize(size int) error {
+	return u.device.SetWriteChunkSize(size)
+}
+
+func (u *usbTransport) Close() error {
+	if u.usbfs != nil {
+		u.usbfs.Close()
+		u.usbfs = nil
+	}
+	return u.device.Close()
+}
+
+// SubmitRead starts an asynchronous read. This is libftdi specific and is
+// not part of Transport.
+func (u *usbTransport) SubmitRead(data []byte) (*libftdi.Transfer, error) {
+	return u.device.SubmitRead(data)
+}
@@ -0,0 +1,120 @@
+package ftdi
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// libftdi doesn't expose its raw EEPROM access, so the usbTransport sends the
+// vendor requests itself through the Linux usbfs device node. The kernel
+// allows vendor control requests on a node even while libftdi (or ftdi_sio)
+// has claimed the interface.
+
+// FTDI vendor requests, see libftdi's SIO_xxx_EEPROM_REQUEST.
+const (
+	requestTypeOut   = 0x40
+	requestTypeIn    = 0xc0
+	readEEPROMReq    = 0x90
+	writeEEPROMReq   = 0x91
+	controlTimeoutMs = 5000
+)
+
+// usbdevfsCtrlTransfer mirrors struct usbdevfs_ctrltransfer.
+type usbdevfsCtrlTransfer struct {
+	RequestType byte
+	Request     byte
+	Value       uint16
+	Index       uint16
+	Length      uint16
+	Timeout     uint32
+	Data        unsafe.Pointer
+}
+
+// USBDEVFS_CONTROL is _IOWR('U', 0, struct usbdevfs_ctrltransfer).
+var usbdevfsControl = uintptr(3<<30 | unsafe.Sizeof(usbdevfsCtrlTransfer{})<<16 | 'U'<<8)
+
+// usbfsNode returns the device node of the opened device. Only a selector
+// that names a single attached device can be mapped to its node.
+func (u *usbTransport) usbfsNode() (string, error) {
+	devices, err := ListDevices(u.vender, u.product)
+	if err != nil {
+		return "", err
+	}
+
+	matches := []DeviceInfo{}
+	for _, d := range devices {
+		if u.selector.Serial != "" && d.Serial != u.selector.Serial {
+			continue
+		}
+		if u.selector.Description != "" && d.Description != u.selector.Description {
+			continue
+		}
+		matches = append(matches, d)
+	}
+
+	if len(matches) == 0 {
+		return "", ErrDeviceNotFound
+	}
+	if len(matches) > 1 {
+		return "", errors.New("ftdi: several devices match, select one by serial or USB path")
+	}
+
+	d := matches[0]
+	return filepath.Join("/dev/bus/usb", fmt.Sprintf("%03d", d.Bus), fmt.Sprintf("%03d", d.Address)), nil
+}
+
+// control sends a control request on endpoint 0.
+func (u *usbTransport) control(requestType, request byte, value, index uint16, data []byte) error {
+	if u.usbfs == nil {
+		node, err := u.usbfsNode()
+		if err != nil {
+			return err
+		}
+
+		u.usbfs, err = os.OpenFile(node, os.O_RDWR, 0)
+		if errors.Is(err, os.ErrPermission) {
+			return fmt.Errorf("%w: %s", ErrPermission, node)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	ctrl := usbdevfsCtrlTransfer{
+		RequestType: requestType,
+		Request:     request,
+		Value:       value,
+		Index:       index,
+		Length:      uint16(len(data)),
+		Timeout:     controlTimeoutMs,
+	}
+	if len(data) > 0 {
+		ctrl.Data = unsafe.Pointer(&data[0])
+	}
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, u.usbfs.Fd(), usbdevfsControl, uintptr(unsafe.Pointer(&ctrl)))
+	runtime.KeepAlive(data)
+	if errno != 0 {
+		return fmt.Errorf("ftdi: control request 0x%02x failed: %w", request, errno)
+	}
+
+	return nil
+}
+
+func (u *usbTransport) ReadEEPROMWord(addr int) (uint16, error) {
+	data := make([]byte, 2)
+	err := u.control(requestTypeIn, readEEPROMReq, 0, uint16(addr), data)
+	if err != nil {
+		return 0, err
+	}
+	return uint16(data[0]) | uint16(data[1])<<8, nil
+}
+
+func (u *usbTransport) WriteEEPROMWord(addr int, value uint16) error {
+	return u.control(requestTypeOut, writeEEPROMReq, value, uint16(addr), nil)
+}
@@ -0,0 +1,328 @@
+// Package gpiochip drives native GPIOs, such as a single board computer's,
+// through the Linux character device /dev/gpiochipN and its v2 uAPI. Chip
+// is a gpio.Provider and its Lines and Ports implement gpio.Line and
+// gpio.Port, so drivers take them in place of FT232H pins. For example:
+//
+//	chip, err := gpiochip.Open("gpiochip0")
+//	dc, err := chip.Line(25)
+//	reset, err := chip.Line(24)
+//	display := st7735.NewST7735RLines(dc, reset, devices.BlackTab, dimensions)
+//
+// Lines are requested from the kernel the first time they are driven or
+// read, and released by Close. The kernel calls go through an Ioctl which
+// can be replaced, see WithIoctl.
+package gpiochip
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/wdevore/hardware/gpio"
+)
+
+var (
+	// ErrClosed is returned when the chip or line was closed.
+	ErrClosed = errors.New("gpiochip: closed")
+	// ErrInvalidLine is returned for an offset the chip doesn't have.
+	ErrInvalidLine = errors.New("gpiochip: no such line")
+	// ErrBusy is returned when another consumer, for example a kernel
+	// driver or another process, holds the line.
+	ErrBusy = errors.New("gpiochip: line busy")
+	// ErrPermission is returned when the process isn't allowed to open the
+	// chip. Add the user to the chip's group (often gpio) or use a udev rule.
+	ErrPermission = errors.New("gpiochip: permission denied")
+)
+
+// DefaultConsumer labels the lines this package requests, as shown by
+// gpioinfo.
+const DefaultConsumer = "wdevore-hardware"
+
+// Option configures a Chip when it is opened.
+type Option func(c *Chip)
+
+// WithIoctl makes the chip use [ioctl] instead of the kernel.
+func WithIoctl(ioctl Ioctl) Option {
+	return func(c *Chip) {
+		c.ioctl = ioctl
+	}
+}
+
+// WithConsumer labels the requested lines [consumer] instead of
+// DefaultConsumer.
+func WithConsumer(consumer string) Option {
+	return func(c *Chip) {
+		c.consumer = consumer
+	}
+}
+
+// Chip is an open GPIO chip. It is safe for concurrent use.
+type Chip struct {
+	ioctl    Ioctl
+	consumer string
+
+	// Path is the device node, for example /dev/gpiochip0.
+	Path string
+	// Name is the kernel's name, Label the driver's, for example
+	// "pinctrl-bcm2711".
+	Name  string
+	Label string
+	// Lines is the number of lines, offsets go from 0 to Lines-1.
+	Lines int
+
+	mutex    sync.Mutex
+	fd       int
+	closed   bool
+	requests []*request
+}
+
+// Open opens the chip at [path], either a device node or a name such as
+// "gpiochip0".
+func Open(path string, options ...Option) (*Chip, error) {
+	c := new(Chip)
+	c.consumer = DefaultConsumer
+	for _, option := range options {
+		option(c)
+	}
+
+	if c.ioctl == nil {
+		c.ioctl = SystemIoctl()
+	}
+
+	if !strings.ContainsRune(path, '/') {
+		path = filepath.Join("/dev", path)
+	}
+	c.Path = path
+
+	fd, err := c.ioctl.Open(path)
+	if errors.Is(err, os.ErrPermission) {
+		return nil, fmt.Errorf("%w: %s", ErrPermission, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("gpiochip: open %s: %w", path, err)
+	}
+
+	var info ChipInfo
+	err = c.ioctl.GetChipInfo(fd, &info)
+	if err != nil {
+		c.ioctl.Close(fd)
+		return nil, fmt.Errorf("gpiochip: %s isn't a GPIO chip: %w", path, err)
+	}
+
+	c.fd = fd
+	c.Name = cString(info.Name[:])
+	c.Label = cString(info.Label[:])
+	c.Lines = int(info.Lines)
+
+	return c, nil
+}
+
+// Chips returns the device nodes of the GPIO chips on this system.
+func Chips() ([]string, error) {
+	return filepath.Glob("/dev/gpiochip*")
+}
+
+// Close releases every line requested through the chip, then the chip.
+func (c *Chip) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+
+	var first error
+	for _, r := range c.requests {
+		err := r.close()
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	c.requests = nil
+
+	err := c.ioctl.Close(c.fd)
+	if err != nil && first == nil {
+		first = err
+	}
+
+	return first
+}
+
+// LineStatus describes a line as the kernel reports it.
+type LineStatus struct {
+	Offset   gpio.Pin
+	Name     string
+	Consumer string
+	// Used is true when a driver, process or this package holds the line.
+	Used      bool
+	Direction gpio.IODirection
+	ActiveLow bool
+}
+
+func (s LineStatus) String() string {
+	direction := "output"
+	if s.Direction == gpio.Input {
+		direction = "input"
+	}
+
+	status := fmt.Sprintf("line %3d: %-16q %s", s.Offset, s.Name, direction)
+	if s.Used {
+		status += fmt.Sprintf(" used by %q", s.Consumer)
+	}
+	return status
+}
+
+// LineStatus returns the kernel's view of the line at [offset].
+func (c *Chip) LineStatus(offset gpio.Pin) (LineStatus, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return LineStatus{}, ErrClosed
+	}
+
+	return c.lineStatus(offset)
+}
+
+func (c *Chip) lineStatus(offset gpio.Pin) (LineStatus, error) {
+	if int(offset) >= c.Lines {
+		return LineStatus{}, fmt.Errorf("%w: %d on %s", ErrInvalidLine, offset, c.Name)
+	}
+
+	info := LineInfo{Offset: uint32(offset)}
+	err := c.ioctl.GetLineInfo(c.fd, &info)
+	if err != nil {
+		return LineStatus{}, fmt.Errorf("gpiochip: line %d info: %w", offset, err)
+	}
+
+	status := LineStatus{
+		Offset:    offset,
+		Name:      cString(info.Name[:]),
+		Consumer:  cString(info.Consumer[:]),
+		Used:      info.Flags&FlagUsed != 0,
+		Direction: gpio.Input,
+		ActiveLow: info.Flags&FlagActiveLow != 0,
+	}
+	if info.Flags&FlagOutput != 0 {
+		status.Direction = gpio.Output
+	}
+
+	return status, nil
+}
+
+// FindLine returns the offset of the line named [name], for example
+// "GPIO25" on a Raspberry Pi.
+func (c *Chip) FindLine(name string) (gpio.Pin, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return 0, ErrClosed
+	}
+
+	for offset := 0; offset < c.Lines; offset++ {
+		status, err := c.lineStatus(gpio.Pin(offset))
+		if err != nil {
+			return 0, err
+		}
+		if status.Name == name {
+			return status.Offset, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w: %q on %s", ErrInvalidLine, name, c.Name)
+}
+
+// Line returns the line at [offset] with the default Config. It is
+// requested from the kernel when first used.
+func (c *Chip) Line(offset gpio.Pin) (gpio.Line, error) {
+	return c.ConfiguredLine(offset, Config{})
+}
+
+// ConfiguredLine is Line with bias, drive, polarity and debounce set by
+// [config].
+func (c *Chip) ConfiguredLine(offset gpio.Pin, config Config) (*Line, error) {
+	r, err := c.newRequest([]gpio.Pin{offset}, config)
+	if err != nil {
+		return nil, err
+	}
+	return &Line{r: r}, nil
+}
+
+// Port returns the lines at [offsets] as one gpio.Port, bit n of its pins
+// is offsets[n]. They are requested together so a write changes them at
+// the same time.
+func (c *Chip) Port(config Config, offsets ...gpio.Pin) (*Port, error) {
+	if len(offsets) > 16 {
+		return nil, fmt.Errorf("gpiochip: a port has at most 16 lines, not %d", len(offsets))
+	}
+
+	r, err := c.newRequest(offsets, config)
+	if err != nil {
+		return nil, err
+	}
+	return &Port{r: r}, nil
+}
+
+func (c *Chip) newRequest(offsets []gpio.Pin, config Config) (*request, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return nil, ErrClosed
+	}
+
+	for _, offset := range offsets {
+		if int(offset) >= c.Lines {
+			return nil, fmt.Errorf("%w: %d on %s", ErrInvalidLine, offset, c.Name)
+		}
+	}
+
+	r := &request{chip: c, offsets: offsets, config: config, fd: -1}
+	c.requests = append(c.requests, r)
+	return r, nil
+}
+
+// forget drops a request closed on its own.
+func (c *Chip) forget(r *request) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for i, other := range c.requests {
+		if other == r {
+			c.requests = append(c.requests[:i], c.requests[i+1:]...)
+			return
+		}
+	}
+}
+
+// busy turns EBUSY from a line request into an ErrBusy naming the holder.
+// The caller holds the request, so the chip can't be closed meanwhile.
+func (c *Chip) busy(offsets []gpio.Pin, err error) error {
+	if !errors.Is(err, syscall.EBUSY) {
+		return fmt.Errorf("gpiochip: requesting lines %v: %w", offsets, err)
+	}
+
+	for _, offset := range offsets {
+		status, statusErr := c.lineStatus(offset)
+		if statusErr == nil && status.Used {
+			return fmt.Errorf("%w: line %d is used by %q", ErrBusy, offset, status.Consumer)
+		}
+	}
+
+	return fmt.Errorf("%w: lines %v", ErrBusy, offsets)
+}
+
+// cString converts a NUL terminated uAPI string.
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
@@ -0,0 +1,316 @@
+package gpiochip_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wdevore/hardware/gpio"
+	"github.com/wdevore/hardware/gpio/gpiochip"
+	"github.com/wdevore/hardware/gpio/gpiochip/sim"
+)
+
+func newChip(t *testing.T) (*gpiochip.Chip, *sim.Chip) {
+	t.Helper()
+	chip, s, err := sim.NewChip("gpiochip0", 8, gpiochip.WithConsumer("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { chip.Close() })
+	return chip, s
+}
+
+func TestOpen(t *testing.T) {
+	chip, _ := newChip(t)
+
+	if chip.Name != "gpiochip0" || chip.Label != "sim" || chip.Lines != 8 {
+		t.Errorf("chip %q %q with %d lines", chip.Name, chip.Label, chip.Lines)
+	}
+
+	_, err := gpiochip.Open("gpiochip1", gpiochip.WithIoctl(sim.New("gpiochip0", 8)))
+	if err == nil {
+		t.Error("opened a chip that doesn't exist")
+	}
+}
+
+func TestLineRequest(t *testing.T) {
+	chip, s := newChip(t)
+
+	line, err := chip.ConfiguredLine(3, gpiochip.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Lines are requested when first used.
+	if consumer := s.Consumer(3); consumer != "" {
+		t.Errorf("line held by %q before use", consumer)
+	}
+
+	err = line.Out(gpio.High)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if consumer := s.Consumer(3); consumer != "test" {
+		t.Errorf("line held by %q, want test", consumer)
+	}
+	if flags := s.Flags(3); flags&gpiochip.FlagOutput == 0 {
+		t.Errorf("flags %#x, want an output", flags)
+	}
+
+	status, err := chip.LineStatus(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Used || status.Consumer != "test" || status.Direction != gpio.Output {
+		t.Errorf("status %v", status)
+	}
+
+	// A second request for the line is refused.
+	other, err := chip.Line(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = other.Out(gpio.Low)
+	if !errors.Is(err, gpiochip.ErrBusy) {
+		t.Errorf("second request: %v, want ErrBusy", err)
+	}
+
+	// Closing releases it.
+	err = line.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if consumer := s.Consumer(3); consumer != "" {
+		t.Errorf("line held by %q after Close", consumer)
+	}
+	err = other.Out(gpio.Low)
+	if err != nil {
+		t.Errorf("request after Close: %v", err)
+	}
+
+	err = line.Out(gpio.High)
+	if !errors.Is(err, gpiochip.ErrClosed) {
+		t.Errorf("closed line: %v, want ErrClosed", err)
+	}
+}
+
+func TestLineRequestErrors(t *testing.T) {
+	chip, s := newChip(t)
+
+	_, err := chip.Line(8)
+	if !errors.Is(err, gpiochip.ErrInvalidLine) {
+		t.Errorf("line 8: %v, want ErrInvalidLine", err)
+	}
+
+	s.Claim(5, "spi0 CS1")
+	line, err := chip.Line(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = line.In()
+	if !errors.Is(err, gpiochip.ErrBusy) || !strings.Contains(err.Error(), "spi0 CS1") {
+		t.Errorf("claimed line: %v, want ErrBusy naming the consumer", err)
+	}
+
+	offset, err := chip.FindLine("GPIO6")
+	if err != nil || offset != 6 {
+		t.Errorf("FindLine: %d, %v", offset, err)
+	}
+	_, err = chip.FindLine("LED")
+	if !errors.Is(err, gpiochip.ErrInvalidLine) {
+		t.Errorf("FindLine: %v, want ErrInvalidLine", err)
+	}
+}
+
+func TestOutputValues(t *testing.T) {
+	chip, s := newChip(t)
+
+	port, err := chip.Port(gpiochip.Config{}, 1, 2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The levels are part of the request, the lines never glitch low.
+	err = port.WritePins(0b101, 0b111)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Level(1) || s.Level(2) || !s.Level(4) {
+		t.Errorf("levels %v %v %v, want high, low, high", s.Level(1), s.Level(2), s.Level(4))
+	}
+
+	line, err := chip.ConfiguredLine(6, gpiochip.Config{ActiveLow: true, Drive: gpiochip.OpenDrain})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = line.Out(gpio.High)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Level(6) {
+		t.Error("active low line is physically high")
+	}
+	if flags := s.Flags(6); flags&(gpiochip.FlagActiveLow|gpiochip.FlagOpenDrain) != gpiochip.FlagActiveLow|gpiochip.FlagOpenDrain {
+		t.Errorf("flags %#x, want active low and open drain", flags)
+	}
+
+	err = line.Toggle()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Level(6) {
+		t.Error("active low line is physically low after Toggle")
+	}
+}
+
+func TestBias(t *testing.T) {
+	chip, s := newChip(t)
+
+	tests := []struct {
+		bias  gpiochip.Bias
+		flag  uint64
+		level gpio.PinState
+	}{
+		{gpiochip.BiasPullUp, gpiochip.FlagBiasPullUp, gpio.High},
+		{gpiochip.BiasPullDown, gpiochip.FlagBiasPullDown, gpio.Low},
+	}
+
+	for i, test := range tests {
+		offset := gpio.Pin(i)
+		line, err := chip.ConfiguredLine(offset, gpiochip.Config{Bias: test.bias})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		level, err := line.In()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if level != test.level {
+			t.Errorf("bias %d: %v, want %v", test.bias, level, test.level)
+		}
+		if flags := s.Flags(int(offset)); flags&test.flag == 0 || flags&gpiochip.FlagInput == 0 {
+			t.Errorf("bias %d: flags %#x", test.bias, flags)
+		}
+
+		// Something driving the line wins over the pull.
+		s.SetInput(int(offset), test.level == gpio.Low)
+		level, _ = line.In()
+		if level == test.level {
+			t.Errorf("bias %d: driven line still at %v", test.bias, level)
+		}
+		s.Release(int(offset))
+		level, _ = line.In()
+		if level != test.level {
+			t.Errorf("bias %d: released line at %v, want %v", test.bias, level, test.level)
+		}
+	}
+}
+
+func TestValuesMask(t *testing.T) {
+	chip, s := newChip(t)
+
+	port, err := chip.Port(gpiochip.Config{}, 0, 1, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the masked lines become outputs.
+	err = port.WritePins(0b1111, 0b0011)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for offset, output := range []bool{true, true, false, false} {
+		if got := s.Flags(offset)&gpiochip.FlagOutput != 0; got != output {
+			t.Errorf("line %d output %v, want %v", offset, got, output)
+		}
+	}
+
+	// Lines outside the mask keep their level.
+	err = port.WritePins(0, 0b0001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Level(0) || !s.Level(1) {
+		t.Errorf("levels %v %v, want low, high", s.Level(0), s.Level(1))
+	}
+
+	s.SetInput(2, true)
+	s.SetInput(3, true)
+
+	pins, err := port.ReadPins(0b0110)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pins != 0b0110 {
+		t.Errorf("read %04b, want 0110", pins)
+	}
+
+	pins, err = port.ReadPins(0b1001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pins != 0b1000 {
+		t.Errorf("read %04b, want 1000", pins)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	chip, s := newChip(t)
+
+	line, err := chip.ConfiguredLine(2, gpiochip.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := line.Watch(ctx, gpio.RisingEdge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flags := s.Flags(2); flags&gpiochip.FlagEdgeRising == 0 || flags&gpiochip.FlagEdgeFalling != 0 {
+		t.Errorf("flags %#x, want the rising edge only", flags)
+	}
+
+	_, err = line.Watch(ctx, gpio.FallingEdge)
+	if err == nil {
+		t.Error("watched the line twice")
+	}
+
+	// The falling edge isn't watched, the second rising edge is the next
+	// event.
+	s.SetInput(2, true)
+	s.SetInput(2, false)
+	s.SetInput(2, true)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case event := <-events:
+			if event.Pin != 2 || event.Edge != gpio.RisingEdge || event.Level != gpio.High {
+				t.Errorf("event %d: %v", i, event)
+			}
+			if event.Time.IsZero() {
+				t.Errorf("event %d has no time", i)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event %d never came", i)
+		}
+	}
+
+	select {
+	case event := <-events:
+		t.Errorf("unexpected event %v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancel()
+	for range events {
+	}
+	if err := line.Err(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Err %v, want context.Canceled", err)
+	}
+}
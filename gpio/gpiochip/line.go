@@ -0,0 +1,448 @@
+package gpiochip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/wdevore/hardware/gpio"
+)
+
+// Bias selects the line's internal pull resistor.
+type Bias int
+
+const (
+	// BiasAsIs leaves the bias the way it is
+	BiasAsIs Bias = iota
+	// BiasDisabled floats the line
+	BiasDisabled
+	// BiasPullUp pulls the line up
+	BiasPullUp
+	// BiasPullDown pulls the line down
+	BiasPullDown
+)
+
+// Drive selects how an output drives the line.
+type Drive int
+
+const (
+	// PushPull drives both levels
+	PushPull Drive = iota
+	// OpenDrain only drives Low, High floats
+	OpenDrain
+	// OpenSource only drives High, Low floats
+	OpenSource
+)
+
+// Config is how lines are requested from the kernel.
+type Config struct {
+	Bias  Bias
+	Drive Drive
+	// ActiveLow inverts the levels, High drives the line low.
+	ActiveLow bool
+	// Debounce filters input changes shorter than this, in the kernel.
+	Debounce time.Duration
+}
+
+// eventPoll bounds how long a watcher waits for an event before checking
+// its context.
+const eventPoll = 100 * time.Millisecond
+
+// request is a set of lines requested together. Until it is first used the
+// kernel isn't asked for them, fd is -1.
+type request struct {
+	chip    *Chip
+	offsets []gpio.Pin
+	config  Config
+
+	mutex  sync.Mutex
+	fd     int
+	closed bool
+
+	// Bit n is offsets[n].
+	outputs uint64
+	values  uint64
+
+	// Edges reported on the inputs, set while a Watch runs.
+	edge     gpio.Edge
+	watching bool
+	err      error
+}
+
+func (r *request) all() uint64 {
+	return 1<<uint(len(r.offsets)) - 1
+}
+
+// lineConfig builds the uAPI configuration for the lines in [outputs]
+// being outputs at [values] and the others inputs reporting [edge].
+func (r *request) lineConfig(outputs, values uint64, edge gpio.Edge) LineConfig {
+	var base uint64
+	switch r.config.Bias {
+	case BiasDisabled:
+		base |= FlagBiasDisabled
+	case BiasPullUp:
+		base |= FlagBiasPullUp
+	case BiasPullDown:
+		base |= FlagBiasPullDown
+	}
+	if r.config.ActiveLow {
+		base |= FlagActiveLow
+	}
+
+	inputFlags := base | FlagInput
+	if edge&gpio.RisingEdge != 0 {
+		inputFlags |= FlagEdgeRising
+	}
+	if edge&gpio.FallingEdge != 0 {
+		inputFlags |= FlagEdgeFalling
+	}
+	if edge != 0 {
+		inputFlags |= FlagEventClockRealtime
+	}
+
+	outputFlags := base | FlagOutput
+	switch r.config.Drive {
+	case OpenDrain:
+		outputFlags |= FlagOpenDrain
+	case OpenSource:
+		outputFlags |= FlagOpenSource
+	}
+
+	var config LineConfig
+	add := func(id uint32, value, mask uint64) {
+		config.Attrs[config.NumAttrs] = LineConfigAttribute{Attr: LineAttribute{ID: id, Value: value}, Mask: mask}
+		config.NumAttrs++
+	}
+
+	inputs := r.all() &^ outputs
+	if inputs == 0 {
+		config.Flags = outputFlags
+	} else {
+		config.Flags = inputFlags
+		if outputs != 0 {
+			add(AttrFlags, outputFlags, outputs)
+		}
+	}
+
+	if outputs != 0 {
+		add(AttrOutputValues, values&outputs, outputs)
+	}
+
+	if r.config.Debounce > 0 && inputs != 0 {
+		add(AttrDebounce, uint64(r.config.Debounce/time.Microsecond), inputs)
+	}
+
+	return config
+}
+
+// configure requests the lines, or changes their configuration if they
+// are already requested.
+func (r *request) configure(outputs, values uint64, edge gpio.Edge) error {
+	config := r.lineConfig(outputs, values, edge)
+
+	if r.fd < 0 {
+		lr := LineRequest{Config: config, NumLines: uint32(len(r.offsets))}
+		for i, offset := range r.offsets {
+			lr.Offsets[i] = uint32(offset)
+		}
+		copy(lr.Consumer[:MaxNameSize-1], r.chip.consumer)
+
+		err := r.chip.ioctl.GetLine(r.chip.fd, &lr)
+		if err != nil {
+			return r.chip.busy(r.offsets, err)
+		}
+		r.fd = int(lr.Fd)
+	} else {
+		err := r.chip.ioctl.SetConfig(r.fd, &config)
+		if err != nil {
+			return fmt.Errorf("gpiochip: configuring lines %v: %w", r.offsets, err)
+		}
+	}
+
+	r.outputs = outputs
+	r.values = values & outputs
+	r.edge = edge
+
+	return nil
+}
+
+// write drives the lines in [mask] to their bit of [bits], making them
+// outputs.
+func (r *request) write(bits, mask uint64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return ErrClosed
+	}
+
+	mask &= r.all()
+	values := r.values&^mask | bits&mask
+	outputs := r.outputs | mask
+
+	if r.fd < 0 || outputs != r.outputs {
+		return r.configure(outputs, values, r.edge)
+	}
+
+	err := r.chip.ioctl.SetValues(r.fd, &LineValues{Bits: values, Mask: mask})
+	if err != nil {
+		return fmt.Errorf("gpiochip: setting lines %v: %w", r.offsets, err)
+	}
+	r.values = values
+
+	return nil
+}
+
+// toggle inverts the outputs in [mask].
+func (r *request) toggle(mask uint64) error {
+	r.mutex.Lock()
+	values := r.values
+	r.mutex.Unlock()
+
+	return r.write(^values, mask)
+}
+
+// read returns the levels of the lines in [mask]. With [input] the lines
+// are made inputs first.
+func (r *request) read(mask uint64, input bool) (uint64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return 0, ErrClosed
+	}
+
+	mask &= r.all()
+	outputs := r.outputs
+	if input {
+		outputs &^= mask
+	}
+
+	if r.fd < 0 || outputs != r.outputs {
+		err := r.configure(outputs, r.values, r.edge)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	values := LineValues{Mask: mask}
+	err := r.chip.ioctl.GetValues(r.fd, &values)
+	if err != nil {
+		return 0, fmt.Errorf("gpiochip: reading lines %v: %w", r.offsets, err)
+	}
+
+	return values.Bits & mask, nil
+}
+
+func (r *request) close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+
+	if r.fd < 0 {
+		return nil
+	}
+
+	err := r.chip.ioctl.Close(r.fd)
+	r.fd = -1
+	return err
+}
+
+// -----------------------------------------------------------------------------
+// Line
+// -----------------------------------------------------------------------------
+
+// Line is one line of a Chip, see gpio.Line. It is safe for concurrent use.
+type Line struct {
+	r *request
+}
+
+// Offset returns the line's offset on its chip.
+func (l *Line) Offset() gpio.Pin {
+	return l.r.offsets[0]
+}
+
+func (l *Line) String() string {
+	return fmt.Sprintf("%s:%d", l.r.chip.Name, l.Offset())
+}
+
+// Out makes the line an output at [level].
+func (l *Line) Out(level gpio.PinState) error {
+	var bits uint64
+	if level == gpio.High {
+		bits = 1
+	}
+	return l.r.write(bits, 1)
+}
+
+// In makes the line an input and reads it.
+func (l *Line) In() (gpio.PinState, error) {
+	bits, err := l.r.read(1, true)
+	if err != nil || bits == 0 {
+		return gpio.Low, err
+	}
+	return gpio.High, nil
+}
+
+// Toggle drives the line to the opposite of its last written level.
+func (l *Line) Toggle() error {
+	return l.r.toggle(1)
+}
+
+// Pulse drives the line to [level] for [duration] then back.
+func (l *Line) Pulse(level gpio.PinState, duration time.Duration) error {
+	err := l.Out(level)
+	if err != nil {
+		return err
+	}
+
+	time.Sleep(duration)
+
+	return l.Out(level.Invert())
+}
+
+// Watch makes the line an input and reports its [edge] changes on the
+// returned channel until [ctx] is done or reading fails, then closes it.
+// Err tells which. Config.Debounce filters the changes in the kernel.
+func (l *Line) Watch(ctx context.Context, edge gpio.Edge) (<-chan gpio.Event, error) {
+	r := l.r
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return nil, ErrClosed
+	}
+	if r.watching {
+		return nil, errors.New("gpiochip: line already watched")
+	}
+
+	err := r.configure(0, r.values, edge)
+	if err != nil {
+		return nil, err
+	}
+
+	r.watching = true
+	r.err = nil
+
+	events := make(chan gpio.Event, 16)
+
+	go func() {
+		err := l.watch(ctx, events)
+
+		r.mutex.Lock()
+		r.watching = false
+		r.err = err
+		r.mutex.Unlock()
+
+		close(events)
+	}()
+
+	return events, nil
+}
+
+// Err returns the error that stopped the last Watch, the context's error if
+// it was canceled, or nil while it is running.
+func (l *Line) Err() error {
+	l.r.mutex.Lock()
+	defer l.r.mutex.Unlock()
+	return l.r.err
+}
+
+func (l *Line) watch(ctx context.Context, events chan<- gpio.Event) error {
+	r := l.r
+
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		r.mutex.Lock()
+		fd, closed := r.fd, r.closed
+		r.mutex.Unlock()
+
+		if closed {
+			return ErrClosed
+		}
+
+		var event LineEvent
+		ok, err := r.chip.ioctl.ReadEvent(fd, &event, eventPoll)
+		if err != nil {
+			r.mutex.Lock()
+			closed = r.closed
+			r.mutex.Unlock()
+			if closed {
+				return ErrClosed
+			}
+			return fmt.Errorf("gpiochip: reading events of %s: %w", l, err)
+		}
+		if !ok {
+			continue
+		}
+
+		e := gpio.Event{
+			Pin:   gpio.Pin(event.Offset),
+			Edge:  gpio.FallingEdge,
+			Level: gpio.Low,
+			Time:  time.Unix(0, int64(event.Timestamp)),
+		}
+		if event.ID == EventRisingEdge {
+			e.Edge = gpio.RisingEdge
+			e.Level = gpio.High
+		}
+
+		select {
+		case events <- e:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Close releases the line. It is also released by closing its Chip.
+func (l *Line) Close() error {
+	err := l.r.close()
+	l.r.chip.forget(l.r)
+	return err
+}
+
+// -----------------------------------------------------------------------------
+// Port
+// -----------------------------------------------------------------------------
+
+// Port is several lines of a Chip requested together, see gpio.Port. Bit n
+// of its pins is the nth offset given to Chip.Port. It is safe for
+// concurrent use.
+type Port struct {
+	r *request
+}
+
+// Offsets returns the chip offsets of the port's lines.
+func (p *Port) Offsets() []gpio.Pin {
+	return append([]gpio.Pin(nil), p.r.offsets...)
+}
+
+// WritePins makes the lines in [mask] outputs at their bit of [levels],
+// all at once.
+func (p *Port) WritePins(levels, mask gpio.Pins) error {
+	return p.r.write(uint64(levels), uint64(mask))
+}
+
+// ReadPins returns the levels of the lines in [mask]. Lines not yet driven
+// are read as inputs.
+func (p *Port) ReadPins(mask gpio.Pins) (gpio.Pins, error) {
+	bits, err := p.r.read(uint64(mask), false)
+	return gpio.Pins(bits), err
+}
+
+// Close releases the lines. They are also released by closing their Chip.
+func (p *Port) Close() error {
+	err := p.r.close()
+	p.r.chip.forget(p.r)
+	return err
+}
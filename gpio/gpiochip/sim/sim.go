@@ -0,0 +1,567 @@
+// Package sim is a software model of a GPIO chip behind the Linux character
+// device. It implements gpiochip.Ioctl so gpiochip, and the drivers given
+// its lines, can be exercised without the hardware or the kernel.
+//
+// Requests are validated the way the kernel does: a line is held by one
+// request at a time (EBUSY), and conflicting flags, such as input with
+// output or edges on an output, fail with EINVAL. SetInput plays the
+// outside world, driving an input and queueing the edge events its
+// request asked for. Undriven inputs follow their pull resistor.
+// Debouncing isn't modelled.
+package sim
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/wdevore/hardware/gpio/gpiochip"
+)
+
+// eventBuffer is the kernel's default of 16 events per line.
+const eventBuffer = 16
+
+// line is the state of one line of the chip.
+type line struct {
+	name string
+	// Held by a request of this package, or claimed by Claim.
+	consumer string
+	request  *request
+	flags    uint64
+
+	// The physical level, and whether SetInput drives it.
+	level  bool
+	driven bool
+}
+
+// request is an open line request.
+type request struct {
+	offsets []int
+	events  chan gpiochip.LineEvent
+	closed  chan struct{}
+	seqno   uint32
+}
+
+// Chip models one GPIO chip. It is safe for concurrent use.
+type Chip struct {
+	// Path is the node Open accepts, Name and Label are reported by
+	// GetChipInfo.
+	Path  string
+	Name  string
+	Label string
+
+	mutex sync.Mutex
+	lines []line
+	// Open file descriptors, nil for the chip itself.
+	fds    map[int]*request
+	nextFd int
+}
+
+// New creates a chip called [name], at /dev/[name], with [lines] lines.
+func New(name string, lines int) *Chip {
+	s := new(Chip)
+	s.Path = "/dev/" + name
+	s.Name = name
+	s.Label = "sim"
+	s.lines = make([]line, lines)
+	for i := range s.lines {
+		s.lines[i].name = fmt.Sprintf("GPIO%d", i)
+	}
+	s.fds = map[int]*request{}
+	s.nextFd = 3
+	return s
+}
+
+// NewChip returns an open gpiochip.Chip backed by a new simulated chip.
+func NewChip(name string, lines int, options ...gpiochip.Option) (*gpiochip.Chip, *Chip, error) {
+	s := New(name, lines)
+	chip, err := gpiochip.Open(s.Path, append(options, gpiochip.WithIoctl(s))...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return chip, s, nil
+}
+
+// -----------------------------------------------------------------------------
+// The outside world
+// -----------------------------------------------------------------------------
+
+// SetName names the line at [offset].
+func (s *Chip) SetName(offset int, name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.lines[offset].name = name
+}
+
+// Claim marks the line at [offset] as used by [consumer], for example a
+// kernel driver, so requests for it fail with EBUSY.
+func (s *Chip) Claim(offset int, consumer string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.lines[offset].consumer = consumer
+	s.lines[offset].flags = gpiochip.FlagUsed
+}
+
+// SetInput drives the line at [offset] from outside. If it is a requested
+// input watching the edge, an event is queued.
+func (s *Chip) SetInput(offset int, high bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	l := &s.lines[offset]
+	l.driven = true
+	s.setLevel(offset, high)
+}
+
+// Release stops driving the line at [offset], it follows its bias again.
+func (s *Chip) Release(offset int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	l := &s.lines[offset]
+	l.driven = false
+	s.applyBias(offset, true)
+}
+
+// Level returns the physical level of the line at [offset].
+func (s *Chip) Level(offset int) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.lines[offset].level
+}
+
+// Flags returns the flags the line at [offset] is requested with.
+func (s *Chip) Flags(offset int) uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.lines[offset].flags
+}
+
+// Consumer returns who holds the line at [offset], "" if it is free.
+func (s *Chip) Consumer(offset int) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.lines[offset].consumer
+}
+
+// setLevel changes a physical level and reports the edge.
+func (s *Chip) setLevel(offset int, high bool) {
+	l := &s.lines[offset]
+	if l.level == high {
+		return
+	}
+	l.level = high
+
+	r := l.request
+	if r == nil || l.flags&gpiochip.FlagInput == 0 {
+		return
+	}
+
+	logical := high != (l.flags&gpiochip.FlagActiveLow != 0)
+
+	event := gpiochip.LineEvent{ID: gpiochip.EventFallingEdge, Offset: uint32(offset)}
+	if logical {
+		event.ID = gpiochip.EventRisingEdge
+	}
+	if event.ID == gpiochip.EventRisingEdge && l.flags&gpiochip.FlagEdgeRising == 0 ||
+		event.ID == gpiochip.EventFallingEdge && l.flags&gpiochip.FlagEdgeFalling == 0 {
+		return
+	}
+
+	if l.flags&gpiochip.FlagEventClockRealtime != 0 {
+		event.Timestamp = uint64(time.Now().UnixNano())
+	} else {
+		event.Timestamp = uint64(monotonic())
+	}
+	r.seqno++
+	event.Seqno = r.seqno
+	event.LineSeqno = r.seqno
+
+	select {
+	case r.events <- event:
+	default:
+		// The kernel drops events when the buffer is full too.
+	}
+}
+
+// applyBias moves an undriven input to its pull. Only with [report] is the
+// change an edge, the kernel doesn't report what a request configures.
+func (s *Chip) applyBias(offset int, report bool) {
+	l := &s.lines[offset]
+	if l.driven || l.flags&gpiochip.FlagInput == 0 {
+		return
+	}
+
+	level := l.level
+	switch {
+	case l.flags&gpiochip.FlagBiasPullUp != 0:
+		level = true
+	case l.flags&gpiochip.FlagBiasPullDown != 0:
+		level = false
+	}
+
+	if report {
+		s.setLevel(offset, level)
+	} else {
+		l.level = level
+	}
+}
+
+var start = time.Now()
+
+func monotonic() time.Duration {
+	return time.Since(start)
+}
+
+// -----------------------------------------------------------------------------
+// gpiochip.Ioctl
+// -----------------------------------------------------------------------------
+
+// Open opens the chip if [path] is its Path.
+func (s *Chip) Open(path string) (int, error) {
+	if path != s.Path {
+		return -1, syscall.ENOENT
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.newFd(nil), nil
+}
+
+func (s *Chip) newFd(r *request) int {
+	fd := s.nextFd
+	s.nextFd++
+	s.fds[fd] = r
+	return fd
+}
+
+// Close closes the chip or releases a line request.
+func (s *Chip) Close(fd int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	r, ok := s.fds[fd]
+	if !ok {
+		return syscall.EBADF
+	}
+	delete(s.fds, fd)
+
+	if r == nil {
+		return nil
+	}
+
+	for _, offset := range r.offsets {
+		l := &s.lines[offset]
+		l.request = nil
+		l.consumer = ""
+		l.flags = 0
+	}
+	close(r.closed)
+
+	return nil
+}
+
+// chipFd checks that [fd] is an open chip.
+func (s *Chip) chipFd(fd int) error {
+	r, ok := s.fds[fd]
+	if !ok {
+		return syscall.EBADF
+	}
+	if r != nil {
+		return syscall.ENOTTY
+	}
+	return nil
+}
+
+// requestFd returns the line request open as [fd].
+func (s *Chip) requestFd(fd int) (*request, error) {
+	r, ok := s.fds[fd]
+	if !ok {
+		return nil, syscall.EBADF
+	}
+	if r == nil {
+		return nil, syscall.ENOTTY
+	}
+	return r, nil
+}
+
+// GetChipInfo reports the chip's name, label and number of lines.
+func (s *Chip) GetChipInfo(fd int, info *gpiochip.ChipInfo) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	err := s.chipFd(fd)
+	if err != nil {
+		return err
+	}
+
+	copy(info.Name[:gpiochip.MaxNameSize-1], s.Name)
+	copy(info.Label[:gpiochip.MaxNameSize-1], s.Label)
+	info.Lines = uint32(len(s.lines))
+
+	return nil
+}
+
+// GetLineInfo reports the line at info.Offset.
+func (s *Chip) GetLineInfo(fd int, info *gpiochip.LineInfo) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	err := s.chipFd(fd)
+	if err != nil {
+		return err
+	}
+	if int(info.Offset) >= len(s.lines) {
+		return syscall.EINVAL
+	}
+
+	l := &s.lines[info.Offset]
+	offset := info.Offset
+	*info = gpiochip.LineInfo{Offset: offset, Flags: l.flags}
+	if l.flags == 0 {
+		info.Flags = gpiochip.FlagInput
+	}
+	copy(info.Name[:gpiochip.MaxNameSize-1], l.name)
+	copy(info.Consumer[:gpiochip.MaxNameSize-1], l.consumer)
+
+	return nil
+}
+
+// GetLine requests lines, failing like the kernel for held lines and
+// invalid configurations.
+func (s *Chip) GetLine(fd int, lr *gpiochip.LineRequest) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	err := s.chipFd(fd)
+	if err != nil {
+		return err
+	}
+
+	if lr.NumLines == 0 || lr.NumLines > gpiochip.MaxLines {
+		return syscall.EINVAL
+	}
+
+	offsets := make([]int, lr.NumLines)
+	seen := map[int]bool{}
+	for i := range offsets {
+		offset := int(lr.Offsets[i])
+		if offset >= len(s.lines) || seen[offset] {
+			return syscall.EINVAL
+		}
+		if s.lines[offset].consumer != "" {
+			return syscall.EBUSY
+		}
+		seen[offset] = true
+		offsets[i] = offset
+	}
+
+	flags, err := lineFlags(&lr.Config, len(offsets))
+	if err != nil {
+		return err
+	}
+
+	size := lr.EventBufferSize
+	if size == 0 {
+		size = eventBuffer * lr.NumLines
+	}
+
+	r := &request{
+		offsets: offsets,
+		events:  make(chan gpiochip.LineEvent, size),
+		closed:  make(chan struct{}),
+	}
+
+	consumer := cString(lr.Consumer[:])
+	if consumer == "" {
+		consumer = "?"
+	}
+
+	for _, offset := range offsets {
+		l := &s.lines[offset]
+		l.request = r
+		l.consumer = consumer
+	}
+	s.apply(r, &lr.Config, flags)
+
+	lr.Fd = int32(s.newFd(r))
+
+	return nil
+}
+
+// SetConfig reconfigures the lines of a request.
+func (s *Chip) SetConfig(fd int, config *gpiochip.LineConfig) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	r, err := s.requestFd(fd)
+	if err != nil {
+		return err
+	}
+
+	flags, err := lineFlags(config, len(r.offsets))
+	if err != nil {
+		return err
+	}
+
+	s.apply(r, config, flags)
+
+	return nil
+}
+
+// lineFlags returns the validated flags of each of [n] lines.
+func lineFlags(config *gpiochip.LineConfig, n int) ([]uint64, error) {
+	if config.NumAttrs > gpiochip.MaxAttrs {
+		return nil, syscall.EINVAL
+	}
+
+	flags := make([]uint64, n)
+	for i := range flags {
+		flags[i] = config.Flags
+		for _, a := range config.Attrs[:config.NumAttrs] {
+			if a.Attr.ID == gpiochip.AttrFlags && a.Mask&(1<<uint(i)) != 0 {
+				flags[i] = a.Attr.Value
+			}
+		}
+
+		err := validate(flags[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return flags, nil
+}
+
+// validate rejects the flag combinations the kernel does.
+func validate(flags uint64) error {
+	input := flags&gpiochip.FlagInput != 0
+	output := flags&gpiochip.FlagOutput != 0
+	edges := flags & (gpiochip.FlagEdgeRising | gpiochip.FlagEdgeFalling)
+	drive := flags & (gpiochip.FlagOpenDrain | gpiochip.FlagOpenSource)
+	bias := flags & (gpiochip.FlagBiasPullUp | gpiochip.FlagBiasPullDown | gpiochip.FlagBiasDisabled)
+
+	switch {
+	case input && output:
+		return syscall.EINVAL
+	case edges != 0 && !input:
+		return syscall.EINVAL
+	case drive != 0 && !output:
+		return syscall.EINVAL
+	case drive == gpiochip.FlagOpenDrain|gpiochip.FlagOpenSource:
+		return syscall.EINVAL
+	case bias != 0 && !input && !output:
+		return syscall.EINVAL
+	case bias&(bias-1) != 0:
+		return syscall.EINVAL
+	}
+
+	return nil
+}
+
+// apply sets the flags and output values of the request's lines.
+func (s *Chip) apply(r *request, config *gpiochip.LineConfig, flags []uint64) {
+	for i, offset := range r.offsets {
+		l := &s.lines[offset]
+		l.flags = flags[i] | gpiochip.FlagUsed
+
+		if l.flags&gpiochip.FlagOutput != 0 {
+			l.driven = false
+			value := false
+			for _, a := range config.Attrs[:config.NumAttrs] {
+				if a.Attr.ID == gpiochip.AttrOutputValues && a.Mask&(1<<uint(i)) != 0 {
+					value = a.Attr.Value&(1<<uint(i)) != 0
+				}
+			}
+			s.setLevel(offset, value != (l.flags&gpiochip.FlagActiveLow != 0))
+			continue
+		}
+
+		s.applyBias(offset, false)
+	}
+}
+
+// GetValues reads the logical levels of the masked lines.
+func (s *Chip) GetValues(fd int, values *gpiochip.LineValues) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	r, err := s.requestFd(fd)
+	if err != nil {
+		return err
+	}
+
+	values.Bits = 0
+	for i, offset := range r.offsets {
+		l := &s.lines[offset]
+		if values.Mask&(1<<uint(i)) == 0 {
+			continue
+		}
+		if l.level != (l.flags&gpiochip.FlagActiveLow != 0) {
+			values.Bits |= 1 << uint(i)
+		}
+	}
+
+	return nil
+}
+
+// SetValues drives the masked lines, which must be outputs.
+func (s *Chip) SetValues(fd int, values *gpiochip.LineValues) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	r, err := s.requestFd(fd)
+	if err != nil {
+		return err
+	}
+
+	for i, offset := range r.offsets {
+		if values.Mask&(1<<uint(i)) != 0 && s.lines[offset].flags&gpiochip.FlagOutput == 0 {
+			return syscall.EPERM
+		}
+	}
+
+	for i, offset := range r.offsets {
+		if values.Mask&(1<<uint(i)) == 0 {
+			continue
+		}
+		l := &s.lines[offset]
+		logical := values.Bits&(1<<uint(i)) != 0
+		s.setLevel(offset, logical != (l.flags&gpiochip.FlagActiveLow != 0))
+	}
+
+	return nil
+}
+
+// ReadEvent waits for the next edge event of a request.
+func (s *Chip) ReadEvent(fd int, event *gpiochip.LineEvent, timeout time.Duration) (bool, error) {
+	s.mutex.Lock()
+	r, err := s.requestFd(fd)
+	s.mutex.Unlock()
+	if err != nil {
+		return false, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case *event = <-r.events:
+		return true, nil
+	case <-r.closed:
+		return false, os.ErrClosed
+	case <-timer.C:
+		return false, nil
+	}
+}
+
+func cString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,277 @@
+package gpiochip
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// -----------------------------------------------------------------------------
+// GPIO v2 character device uAPI, see include/uapi/linux/gpio.h
+// -----------------------------------------------------------------------------
+
+// Limits of the uAPI.
+const (
+	MaxNameSize = 32
+	MaxLines    = 64
+	MaxAttrs    = 10
+)
+
+// Line flags, GPIO_V2_LINE_FLAG_xxx.
+const (
+	FlagUsed               uint64 = 1 << 0
+	FlagActiveLow          uint64 = 1 << 1
+	FlagInput              uint64 = 1 << 2
+	FlagOutput             uint64 = 1 << 3
+	FlagEdgeRising         uint64 = 1 << 4
+	FlagEdgeFalling        uint64 = 1 << 5
+	FlagOpenDrain          uint64 = 1 << 6
+	FlagOpenSource         uint64 = 1 << 7
+	FlagBiasPullUp         uint64 = 1 << 8
+	FlagBiasPullDown       uint64 = 1 << 9
+	FlagBiasDisabled       uint64 = 1 << 10
+	FlagEventClockRealtime uint64 = 1 << 11
+)
+
+// Line attribute ids, GPIO_V2_LINE_ATTR_ID_xxx.
+const (
+	AttrFlags        uint32 = 1
+	AttrOutputValues uint32 = 2
+	AttrDebounce     uint32 = 3
+)
+
+// Line event ids, GPIO_V2_LINE_EVENT_xxx.
+const (
+	EventRisingEdge  uint32 = 1
+	EventFallingEdge uint32 = 2
+)
+
+// ChipInfo mirrors struct gpiochip_info.
+type ChipInfo struct {
+	Name  [MaxNameSize]byte
+	Label [MaxNameSize]byte
+	Lines uint32
+}
+
+// LineAttribute mirrors struct gpio_v2_line_attribute. Value holds the
+// flags, the output values or the debounce period in microseconds,
+// depending on ID.
+type LineAttribute struct {
+	ID      uint32
+	Padding uint32
+	Value   uint64
+}
+
+// LineConfigAttribute mirrors struct gpio_v2_line_config_attribute. The
+// attribute applies to the requested lines whose bit is set in Mask.
+type LineConfigAttribute struct {
+	Attr LineAttribute
+	Mask uint64
+}
+
+// LineConfig mirrors struct gpio_v2_line_config. Flags apply to the lines
+// no FLAGS attribute covers.
+type LineConfig struct {
+	Flags    uint64
+	NumAttrs uint32
+	Padding  [5]uint32
+	Attrs    [MaxAttrs]LineConfigAttribute
+}
+
+// LineRequest mirrors struct gpio_v2_line_request. The kernel returns the
+// request's file descriptor in Fd.
+type LineRequest struct {
+	Offsets         [MaxLines]uint32
+	Consumer        [MaxNameSize]byte
+	Config          LineConfig
+	NumLines        uint32
+	EventBufferSize uint32
+	Padding         [5]uint32
+	Fd              int32
+}
+
+// LineInfo mirrors struct gpio_v2_line_info.
+type LineInfo struct {
+	Name     [MaxNameSize]byte
+	Consumer [MaxNameSize]byte
+	Offset   uint32
+	NumAttrs uint32
+	Flags    uint64
+	Attrs    [MaxAttrs]LineAttribute
+	Padding  [4]uint32
+}
+
+// LineValues mirrors struct gpio_v2_line_values. Bit n is the nth line of
+// the request.
+type LineValues struct {
+	Bits uint64
+	Mask uint64
+}
+
+// LineEvent mirrors struct gpio_v2_line_event.
+type LineEvent struct {
+	Timestamp uint64
+	ID        uint32
+	Offset    uint32
+	Seqno     uint32
+	LineSeqno uint32
+	Padding   [6]uint32
+}
+
+// ioc encodes an ioctl request number like the kernel's _IOC.
+func ioc(dir, nr, size uintptr) uintptr {
+	return dir<<30 | size<<16 | 0xb4<<8 | nr
+}
+
+const (
+	iocRead      = 2
+	iocReadWrite = 3
+)
+
+var (
+	getChipInfoIoctl = ioc(iocRead, 0x01, unsafe.Sizeof(ChipInfo{}))
+	getLineInfoIoctl = ioc(iocReadWrite, 0x05, unsafe.Sizeof(LineInfo{}))
+	getLineIoctl     = ioc(iocReadWrite, 0x07, unsafe.Sizeof(LineRequest{}))
+	setConfigIoctl   = ioc(iocReadWrite, 0x0d, unsafe.Sizeof(LineConfig{}))
+	getValuesIoctl   = ioc(iocReadWrite, 0x0e, unsafe.Sizeof(LineValues{}))
+	setValuesIoctl   = ioc(iocReadWrite, 0x0f, unsafe.Sizeof(LineValues{}))
+)
+
+// -----------------------------------------------------------------------------
+// Ioctl
+// -----------------------------------------------------------------------------
+
+// Ioctl is the kernel interface a Chip is driven through, one method per
+// uAPI call. The default goes to the kernel, however, anything that
+// satisfies this interface can be injected with WithIoctl, for example a
+// fake (see the sim package) or a recorder.
+type Ioctl interface {
+	// Open opens a chip's device node.
+	Open(path string) (fd int, err error)
+	// Close closes a chip or line request file descriptor.
+	Close(fd int) error
+
+	// GetChipInfo is GPIO_GET_CHIPINFO_IOCTL on a chip.
+	GetChipInfo(fd int, info *ChipInfo) error
+	// GetLineInfo is GPIO_V2_GET_LINEINFO_IOCTL on a chip, info.Offset
+	// selects the line.
+	GetLineInfo(fd int, info *LineInfo) error
+	// GetLine is GPIO_V2_GET_LINE_IOCTL on a chip, it sets request.Fd.
+	GetLine(fd int, request *LineRequest) error
+
+	// SetConfig is GPIO_V2_LINE_SET_CONFIG_IOCTL on a line request.
+	SetConfig(fd int, config *LineConfig) error
+	// GetValues is GPIO_V2_LINE_GET_VALUES_IOCTL on a line request.
+	GetValues(fd int, values *LineValues) error
+	// SetValues is GPIO_V2_LINE_SET_VALUES_IOCTL on a line request.
+	SetValues(fd int, values *LineValues) error
+	// ReadEvent waits up to [timeout] for an edge event of a line request.
+	// It returns false if none came.
+	ReadEvent(fd int, event *LineEvent, timeout time.Duration) (bool, error)
+}
+
+// sysIoctl is the Ioctl of the running kernel.
+type sysIoctl struct {
+	mutex sync.Mutex
+	// Line requests being read, wrapped so reads can time out.
+	files map[int]*os.File
+}
+
+// SystemIoctl returns the Ioctl that calls the kernel.
+func SystemIoctl() Ioctl {
+	return &sysIoctl{files: map[int]*os.File{}}
+}
+
+func (s *sysIoctl) Open(path string) (int, error) {
+	return syscall.Open(path, syscall.O_RDWR|syscall.O_CLOEXEC, 0)
+}
+
+func (s *sysIoctl) Close(fd int) error {
+	s.mutex.Lock()
+	file, ok := s.files[fd]
+	delete(s.files, fd)
+	s.mutex.Unlock()
+
+	if ok {
+		return file.Close()
+	}
+	return syscall.Close(fd)
+}
+
+func (s *sysIoctl) ioctl(fd int, request uintptr, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), request, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func (s *sysIoctl) GetChipInfo(fd int, info *ChipInfo) error {
+	return s.ioctl(fd, getChipInfoIoctl, unsafe.Pointer(info))
+}
+
+func (s *sysIoctl) GetLineInfo(fd int, info *LineInfo) error {
+	return s.ioctl(fd, getLineInfoIoctl, unsafe.Pointer(info))
+}
+
+func (s *sysIoctl) GetLine(fd int, request *LineRequest) error {
+	return s.ioctl(fd, getLineIoctl, unsafe.Pointer(request))
+}
+
+func (s *sysIoctl) SetConfig(fd int, config *LineConfig) error {
+	return s.ioctl(fd, setConfigIoctl, unsafe.Pointer(config))
+}
+
+func (s *sysIoctl) GetValues(fd int, values *LineValues) error {
+	return s.ioctl(fd, getValuesIoctl, unsafe.Pointer(values))
+}
+
+func (s *sysIoctl) SetValues(fd int, values *LineValues) error {
+	return s.ioctl(fd, setValuesIoctl, unsafe.Pointer(values))
+}
+
+func (s *sysIoctl) ReadEvent(fd int, event *LineEvent, timeout time.Duration) (bool, error) {
+	file, err := s.file(fd)
+	if err != nil {
+		return false, err
+	}
+
+	err = file.SetReadDeadline(time.Now().Add(timeout))
+	if err != nil {
+		return false, err
+	}
+
+	buffer := (*[unsafe.Sizeof(LineEvent{})]byte)(unsafe.Pointer(event))
+	_, err = file.Read(buffer[:])
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// file wraps a line request in a non-blocking os.File, the runtime's poller
+// then implements the read deadline.
+func (s *sysIoctl) file(fd int) (*os.File, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if file, ok := s.files[fd]; ok {
+		return file, nil
+	}
+
+	err := syscall.SetNonblock(fd, true)
+	if err != nil {
+		return nil, err
+	}
+
+	file := os.NewFile(uintptr(fd), "gpio-line")
+	s.files[fd] = file
+	return file, nil
+}
@@ -0,0 +1,70 @@
+package gpio
+
+import "time"
+
+// The types above name pins by number. The interfaces below drive them, so
+// a driver can toggle its D/C or reset pin the same way whether it is a
+// FT232H pin or sits on an I/O expander.
+
+// Line is a single pin of some chip, handed out by a Provider.
+type Line interface {
+	// Out makes the pin an output driven to [level].
+	Out(level PinState) error
+	// In makes the pin an input and returns its level.
+	In() (PinState, error)
+	// Toggle drives the output to the opposite level.
+	Toggle() error
+	// Pulse drives the output to [level] for [duration], then back to the
+	// opposite level.
+	Pulse(level PinState, duration time.Duration) error
+}
+
+// Port is a bank of up to 16 pins written and read at once, bit n is pin n.
+type Port interface {
+	// WritePins makes the pins in [mask] outputs driven to their bit of
+	// [levels]. The other pins are left alone.
+	WritePins(levels, mask Pins) error
+	// ReadPins returns the levels of the pins in [mask], other bits are 0.
+	ReadPins(mask Pins) (Pins, error)
+}
+
+// Provider hands out the Lines of a chip by pin number.
+type Provider interface {
+	Line(pin Pin) (Line, error)
+}
+
+// Claimable is implemented by Lines whose chip records which driver uses
+// each pin, for example the FT232H's.
+type Claimable interface {
+	// Claim records [owner] as the user of the line.
+	Claim(owner string, direction IODirection) error
+}
+
+// Claim claims each line that is Claimable for [owner] as an output. Nil
+// lines and lines of chips that don't track owners are skipped.
+func Claim(owner string, lines ...Line) error {
+	for _, line := range lines {
+		claimable, ok := line.(Claimable)
+		if !ok {
+			continue
+		}
+
+		err := claimable.Claim(owner, Output)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Invert returns the opposite level, Z stays Z.
+func (s PinState) Invert() PinState {
+	switch s {
+	case Low:
+		return High
+	case High:
+		return Low
+	}
+	return s
+}
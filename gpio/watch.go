@@ -0,0 +1,224 @@
+package gpio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Edge selects which level changes a Watcher reports.
+type Edge int
+
+const (
+	// RisingEdge is a change from Low to High
+	RisingEdge Edge = 1 << iota
+	// FallingEdge is a change from High to Low
+	FallingEdge
+	// BothEdges is any change
+	BothEdges = RisingEdge | FallingEdge
+)
+
+func (e Edge) String() string {
+	switch e {
+	case RisingEdge:
+		return "rising"
+	case FallingEdge:
+		return "falling"
+	case BothEdges:
+		return "both"
+	}
+	return fmt.Sprintf("Edge(%d)", int(e))
+}
+
+// ErrWatcherStarted is returned by Watch and Start once the watcher is
+// started, its pins can't change while it polls.
+var ErrWatcherStarted = errors.New("gpio: watcher already started")
+
+// InputReader reads the level of every pin at once, for example
+// ftdi.FTDI232H.
+type InputReader interface {
+	ReadInputs() (Pins, error)
+}
+
+// Event is a debounced level change of a watched pin.
+type Event struct {
+	Pin   Pin
+	Edge  Edge
+	Level PinState
+	// Time is when the new level was first seen, before debouncing.
+	Time time.Time
+}
+
+func (e Event) String() string {
+	return fmt.Sprintf("pin %d %s edge at %s", e.Pin, e.Edge, e.Time.Format("15:04:05.000"))
+}
+
+// watched is the debounce state of one pin.
+type watched struct {
+	pin      Pin
+	edge     Edge
+	debounce time.Duration
+
+	stable    PinState
+	candidate PinState
+	since     time.Time
+}
+
+// Watcher polls input pins and reports their edges on a channel. The pins
+// must already be configured as inputs. For example:
+//
+//	w := gpio.NewWatcher(ft232h, 5*time.Millisecond)
+//	w.Watch(ftdi.C0, gpio.FallingEdge, 20*time.Millisecond)
+//	events, err := w.Start(ctx)
+//	for event := range events { ... }
+//	err = w.Err()
+type Watcher struct {
+	reader   InputReader
+	interval time.Duration
+
+	mutex   sync.Mutex
+	pins    []*watched
+	started bool
+	err     error
+}
+
+// NewWatcher creates a watcher that reads [reader] every [interval].
+// A read of the FT232H takes around 1ms, polling faster is pointless.
+func NewWatcher(reader InputReader, interval time.Duration) *Watcher {
+	w := new(Watcher)
+	w.reader = reader
+	w.interval = interval
+	return w
+}
+
+// Watch adds [pin]. A new level must hold for [debounce] before it is
+// reported, 0 reports every change seen. It must be called before Start,
+// afterwards it returns ErrWatcherStarted.
+func (w *Watcher) Watch(pin Pin, edge Edge, debounce time.Duration) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.started {
+		return ErrWatcherStarted
+	}
+
+	w.pins = append(w.pins, &watched{pin: pin, edge: edge, debounce: debounce})
+	return nil
+}
+
+// Start polls in a goroutine until [ctx] is done or a read fails, then
+// closes the returned channel. Err tells which. A watcher starts once, a
+// second Start returns ErrWatcherStarted.
+func (w *Watcher) Start(ctx context.Context) (<-chan Event, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.started {
+		return nil, ErrWatcherStarted
+	}
+	w.started = true
+
+	// Watch can't add to the pins from now on, run reads them unlocked.
+	events := make(chan Event, 16)
+
+	go func() {
+		defer close(events)
+		w.setErr(w.run(ctx, events))
+	}()
+
+	return events, nil
+}
+
+// Err returns the error that stopped the watcher, the context's error if
+// it was canceled, or nil while it is running.
+func (w *Watcher) Err() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.err
+}
+
+func (w *Watcher) setErr(err error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.err = err
+}
+
+func (w *Watcher) run(ctx context.Context, events chan<- Event) error {
+	pins, err := w.reader.ReadInputs()
+	if err != nil {
+		return err
+	}
+
+	for _, p := range w.pins {
+		p.stable = level(pins, p.pin)
+		p.candidate = p.stable
+	}
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		pins, err := w.reader.ReadInputs()
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+
+		for _, p := range w.pins {
+			event, ok := p.update(level(pins, p.pin), now)
+			if !ok {
+				continue
+			}
+
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// update debounces a new reading and returns an event once a change has held.
+func (p *watched) update(current PinState, now time.Time) (Event, bool) {
+	if current == p.stable {
+		p.candidate = current
+		return Event{}, false
+	}
+
+	if current != p.candidate {
+		p.candidate = current
+		p.since = now
+	}
+
+	if now.Sub(p.since) < p.debounce {
+		return Event{}, false
+	}
+
+	p.stable = current
+
+	edge := FallingEdge
+	if current == High {
+		edge = RisingEdge
+	}
+	if p.edge&edge == 0 {
+		return Event{}, false
+	}
+
+	return Event{Pin: p.pin, Edge: edge, Level: current, Time: p.since}, true
+}
+
+func level(pins Pins, pin Pin) PinState {
+	if (pins>>pin)&0x0001 == 1 {
+		return High
+	}
+	return Low
+}
@@ -0,0 +1,115 @@
+package gpio
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// script is an InputReader returning [levels] in turn, then the last one.
+type script struct {
+	mutex  sync.Mutex
+	levels []Pins
+	err    error
+}
+
+func (s *script) ReadInputs() (Pins, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.levels) == 0 {
+		return 0, s.err
+	}
+	pins := s.levels[0]
+	if len(s.levels) > 1 {
+		s.levels = s.levels[1:]
+	} else if s.err != nil {
+		s.levels = nil
+	}
+	return pins, nil
+}
+
+func TestDebounce(t *testing.T) {
+	start := time.Now()
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+
+	p := &watched{pin: 3, edge: BothEdges, debounce: 10 * time.Millisecond, stable: Low, candidate: Low}
+
+	// A bounce shorter than the debounce time isn't reported.
+	if _, ok := p.update(High, at(0)); ok {
+		t.Error("reported a change before it held")
+	}
+	if _, ok := p.update(Low, at(5)); ok {
+		t.Error("reported a bounce")
+	}
+
+	// The change is timed from when it was first seen.
+	p.update(High, at(10))
+	if _, ok := p.update(High, at(15)); ok {
+		t.Error("reported a change held for 5ms")
+	}
+	event, ok := p.update(High, at(20))
+	if !ok {
+		t.Fatal("a change held for 10ms isn't reported")
+	}
+	if event.Pin != 3 || event.Edge != RisingEdge || event.Level != High || !event.Time.Equal(at(10)) {
+		t.Errorf("event %v", event)
+	}
+
+	// Once stable it isn't reported again.
+	if _, ok := p.update(High, at(40)); ok {
+		t.Error("reported the same level twice")
+	}
+}
+
+func TestEdges(t *testing.T) {
+	now := time.Now()
+
+	p := &watched{pin: 0, edge: FallingEdge, stable: Low, candidate: Low}
+	if _, ok := p.update(High, now); ok {
+		t.Error("reported a rising edge while watching falling edges")
+	}
+	event, ok := p.update(Low, now)
+	if !ok || event.Edge != FallingEdge || event.Level != Low {
+		t.Errorf("falling edge: %v %v", event, ok)
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	reader := &script{levels: []Pins{0, 1 << 2, 0, 1 << 2}, err: errors.New("unplugged")}
+
+	w := NewWatcher(reader, time.Millisecond)
+	err := w.Watch(2, RisingEdge, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := w.Start(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rising := 0
+	for event := range events {
+		if event.Pin != 2 || event.Edge != RisingEdge {
+			t.Errorf("event %v", event)
+		}
+		rising++
+	}
+	if rising != 2 {
+		t.Errorf("%d rising edges, want 2", rising)
+	}
+	if err := w.Err(); err == nil || err.Error() != "unplugged" {
+		t.Errorf("Err %v, want the read error", err)
+	}
+
+	// The pins can't change once started.
+	if err := w.Watch(3, BothEdges, 0); !errors.Is(err, ErrWatcherStarted) {
+		t.Errorf("Watch after Start: %v, want ErrWatcherStarted", err)
+	}
+	if _, err := w.Start(context.Background()); !errors.Is(err, ErrWatcherStarted) {
+		t.Errorf("second Start: %v, want ErrWatcherStarted", err)
+	}
+}
@@ -0,0 +1,178 @@
+package i2c
+
+import "errors"
+
+// ErrAddress is returned for an address that doesn't fit the addressing mode.
+var ErrAddress = errors.New("i2c: address out of range")
+
+// Device is a slave at a specific address on the bus.
+type Device struct {
+	bus     *I2C
+	address uint16
+	tenBit  bool
+}
+
+// Device returns a handle for a slave with a 7 bit address.
+func (i2c *I2C) Device(address uint16) *Device {
+	return &Device{bus: i2c, address: address}
+}
+
+// Device10 returns a handle for a slave with a 10 bit address.
+func (i2c *I2C) Device10(address uint16) *Device {
+	return &Device{bus: i2c, address: address, tenBit: true}
+}
+
+// Address returns the slave address.
+func (d *Device) Address() uint16 {
+	return d.address
+}
+
+// addressBytes builds the address phase. A 10 bit address is sent as
+// 11110 A9 A8 R/W followed by A7-A0.
+func (d *Device) addressBytes(read bool) ([]byte, error) {
+	rw := byte(0)
+	if read {
+		rw = 1
+	}
+
+	if !d.tenBit {
+		if d.address > 0x7f {
+			return nil, ErrAddress
+		}
+		return []byte{byte(d.address<<1) | rw}, nil
+	}
+
+	if d.address > 0x3ff {
+		return nil, ErrAddress
+	}
+
+	return []byte{0xf0 | byte(d.address>>7)&0x06 | rw, byte(d.address)}, nil
+}
+
+// Ping returns true if the slave acknowledges its address.
+func (d *Device) Ping() (bool, error) {
+	address, err := d.addressBytes(false)
+	if err != nil {
+		return false, err
+	}
+
+	t := d.bus.begin()
+	t.start()
+	t.writeBytes(address)
+	t.stop()
+
+	_, err = t.end()
+	if errors.Is(err, ErrNack) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// Write sends data to the slave in a single transaction.
+func (d *Device) Write(data []byte) error {
+	address, err := d.addressBytes(false)
+	if err != nil {
+		return err
+	}
+
+	t := d.bus.begin()
+	t.start()
+	t.writeBytes(address)
+	t.writeBytes(data)
+	t.stop()
+
+	_, err = t.end()
+	return err
+}
+
+// Read reads [length] bytes from the slave.
+func (d *Device) Read(length int) ([]byte, error) {
+	t := d.bus.begin()
+	t.start()
+
+	if d.tenBit {
+		// A 10 bit read sends the full address as a write, then a repeated
+		// start with only the first address byte and the read bit.
+		address, err := d.addressBytes(false)
+		if err != nil {
+			return nil, err
+		}
+		t.writeBytes(address)
+		t.repeatedStart()
+	}
+
+	address, err := d.addressBytes(true)
+	if err != nil {
+		return nil, err
+	}
+	t.writeBytes(address[:1])
+	t.readBytes(length)
+	t.stop()
+
+	return t.end()
+}
+
+// WriteRead writes [data] then, after a repeated start, reads [length] bytes.
+// This is the usual way to read from a register.
+func (d *Device) WriteRead(data []byte, length int) ([]byte, error) {
+	address, err := d.addressBytes(false)
+	if err != nil {
+		return nil, err
+	}
+
+	t := d.bus.begin()
+	t.start()
+	t.writeBytes(address)
+	t.writeBytes(data)
+	t.repeatedStart()
+
+	address, _ = d.addressBytes(true)
+	t.writeBytes(address[:1])
+	t.readBytes(length)
+	t.stop()
+
+	return t.end()
+}
+
+// ------------------------------------------------------------------------
+// Register helpers
+// ------------------------------------------------------------------------
+
+// WriteReg8 writes an 8 bit value to a register.
+func (d *Device) WriteReg8(register, value byte) error {
+	return d.Write([]byte{register, value})
+}
+
+// WriteReg16 writes a 16 bit value, MSB first, to a register.
+func (d *Device) WriteReg16(register byte, value uint16) error {
+	return d.Write([]byte{register, byte(value >> 8), byte(value)})
+}
+
+// WriteRegs writes a block of bytes starting at a register.
+func (d *Device) WriteRegs(register byte, data []byte) error {
+	return d.Write(append([]byte{register}, data...))
+}
+
+// ReadReg8 reads an 8 bit register.
+func (d *Device) ReadReg8(register byte) (byte, error) {
+	data, err := d.WriteRead([]byte{register}, 1)
+	if err != nil {
+		return 0, err
+	}
+	return data[0], nil
+}
+
+// ReadReg16 reads a 16 bit register that is sent MSB first.
+func (d *Device) ReadReg16(register byte) (uint16, error) {
+	data, err := d.WriteRead([]byte{register}, 2)
+	if err != nil {
+		return 0, err
+	}
+	return uint16(data[0])<<8 | uint16(data[1]), nil
+}
+
+// ReadRegs reads [length] bytes starting at a register.
+func (d *Device) ReadRegs(register byte, length int) ([]byte, error) {
+	return d.WriteRead([]byte{register}, length)
+}
@@ -0,0 +1,285 @@
+package i2c
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/wdevore/hardware/ftdi"
+	"github.com/wdevore/hardware/gpio"
+)
+
+// When using I2C with the FT232H the following pins will have a special meaning:
+// D0 - SCL / Clock signal.
+// D1 - SDA out. Must be wired to D2.
+// D2 - SDA in. Must be wired to D1.
+// Both SCL and SDA need pull-up resistors (typically 4.7K) to 3.3V. The
+// MPSSE drive-zero mode emulates open-drain outputs: a 0 drives the line low
+// and a 1 tri-states it so the pull-up (or a slave) controls the level.
+// See AN_255 "USB to I2C Example using the FT232H and FT201X devices".
+
+const (
+	// SCL is the clock pin
+	SCL = ftdi.D0
+	// SDAOut is the data output pin
+	SDAOut = ftdi.D1
+	// SDAIn is the data input pin
+	SDAIn = ftdi.D2
+)
+
+const (
+	// StandardMode is 100KHz
+	StandardMode = 100000
+	// FastMode is 400KHz
+	FastMode = 400000
+)
+
+// Each GPIO state is repeated a few times to hold it long enough for the
+// bus to settle (start/stop setup and hold times).
+const repeatDelay = 4
+
+var (
+	// Drive D0, D1 and D2 as open-drain (drive zero only)
+	commandDriveZero = []byte{0x9e, 0x07, 0x00}
+
+	// Clock in 1 byte on +ve edge MSB first, then clock out a 1 bit ACK on -ve edge.
+	commandReadAck = []byte{0x20, 0x00, 0x00, 0x13, 0x00, 0x00}
+	// Clock in 1 byte on +ve edge MSB first, then clock out a 1 bit NACK on -ve edge.
+	commandReadNack = []byte{0x20, 0x00, 0x00, 0x13, 0x00, 0xff}
+	// Clock out 1 byte on -ve edge MSB first. The data byte follows.
+	commandWriteByte = []byte{0x11, 0x00, 0x00}
+	// Clock in the 1 bit ACK/NACK on +ve edge.
+	commandReadBit = []byte{0x22, 0x00}
+)
+
+var (
+	// ErrNack is returned when a slave doesn't acknowledge a byte.
+	ErrNack = errors.New("i2c: no ACK from device")
+	// ErrShortResponse is returned when the MPSSE returns fewer bytes than expected.
+	ErrShortResponse = errors.New("i2c: short response")
+)
+
+// I2C is an I2C master facilitated by the FTDI232H MPSSE engine.
+type I2C struct {
+	ftdi *ftdi.FTDI232H
+
+	clock int
+}
+
+// NewI2C creates an I2C FTDI component
+func NewI2C(vender, product int, disableDrivers bool, options ...ftdi.Option) (*I2C, error) {
+	i2c := new(I2C)
+
+	i2c.ftdi = ftdi.NewFTDI232H(vender, product, options...)
+
+	err := i2c.ftdi.Initialize(disableDrivers)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return i2c, nil
+}
+
+// NewI2CFromFTDI creates an I2C component on top of an existing FTDI232H,
+// for example one using an injected Transport.
+func NewI2CFromFTDI(fi *ftdi.FTDI232H) *I2C {
+	i2c := new(I2C)
+	i2c.ftdi = fi
+	return i2c
+}
+
+// Configure opens the device, enables three phase clocking and open-drain
+// emulation, then idles the bus. A clock of 0 defaults to StandardMode.
+func (i2c *I2C) Configure(clock int) error {
+	err := i2c.ftdi.Configure(true)
+	if err != nil {
+		log.Println("I2C failed to configure.")
+		return err
+	}
+
+	if clock == 0 {
+		clock = StandardMode
+	}
+
+	err = i2c.SetClock(clock)
+	if err != nil {
+		return err
+	}
+
+	_, err = i2c.ftdi.Write(commandDriveZero)
+	if err != nil {
+		return err
+	}
+
+	return i2c.idle()
+}
+
+// GetFTDI returns the FTDI component
+func (i2c *I2C) GetFTDI() *ftdi.FTDI232H {
+	return i2c.ftdi
+}
+
+// Close closes the FTDI232 device
+func (i2c *I2C) Close() error {
+	log.Println("I2C closing FTDI device")
+	return i2c.ftdi.Close()
+}
+
+// SetClock sets the speed of SCL in hertz. Three phase clocking is
+// always enabled so data is valid on both clock edges as I2C requires.
+func (i2c *I2C) SetClock(hz int) error {
+	i2c.clock = hz
+	return i2c.ftdi.SetClock(hz, false, true)
+}
+
+// ------------------------------------------------------------------------
+// Bus
+// ------------------------------------------------------------------------
+
+// Scan probes every non-reserved 7 bit address (0x08 to 0x77) and returns
+// the ones that acknowledged.
+func (i2c *I2C) Scan() ([]uint16, error) {
+	found := []uint16{}
+
+	for address := uint16(0x08); address <= 0x77; address++ {
+		ok, err := i2c.Device(address).Ping()
+		if err != nil {
+			return found, err
+		}
+		if ok {
+			found = append(found, address)
+		}
+	}
+
+	return found, nil
+}
+
+// idle puts both lines high with SCL/SDA-out as outputs and SDA-in as input.
+func (i2c *I2C) idle() error {
+	pins := []gpio.PinConfiguration{
+		{Pin: SCL, Direction: gpio.Output, Value: gpio.High},
+		{Pin: SDAOut, Direction: gpio.Output, Value: gpio.High},
+		{Pin: SDAIn, Direction: gpio.Input, Value: gpio.Z},
+	}
+	err := i2c.ftdi.ClaimPins("I2C", pins)
+	if err != nil {
+		return err
+	}
+
+	return i2c.ftdi.ConfigPins(pins, true)
+}
+
+// ------------------------------------------------------------------------
+// Transaction building
+// ------------------------------------------------------------------------
+
+// transaction is one I2C transaction being built. It is sent with a single
+// USB write and its response read with a single poll. Each call builds its
+// own, so several goroutines can share the bus.
+type transaction struct {
+	q *ftdi.Queue
+	// Index of each ACK bit within the response.
+	acks []int
+}
+
+func (i2c *I2C) begin() *transaction {
+	return &transaction{q: i2c.ftdi.NewQueue()}
+}
+
+// end sends the transaction and returns the response bytes with ACKs removed.
+func (t *transaction) end() ([]byte, error) {
+	expected := t.q.Expected()
+
+	// The queue holds the device from the write to the read, so another
+	// user of the device can't slip in between, and it fills the pin levels
+	// in from the device's state when it is sent.
+	response, err := t.q.Flush()
+	if err != nil {
+		return nil, err
+	}
+
+	if expected == 0 {
+		return nil, nil
+	}
+
+	if len(response) < expected {
+		return nil, ErrShortResponse
+	}
+
+	data := []byte{}
+	ack := 0
+	for idx, b := range response {
+		if ack < len(t.acks) && t.acks[ack] == idx {
+			if b&0x01 != 0 {
+				return nil, fmt.Errorf("%w (byte %d)", ErrNack, ack)
+			}
+			ack++
+			continue
+		}
+		data = append(data, b)
+	}
+
+	return data, nil
+}
+
+// pins appends the SCL and SDA levels [count] times.
+func (t *transaction) pins(scl, sda gpio.PinState, count int) {
+	var levels gpio.Pins
+	if scl == gpio.High {
+		levels |= 1 << SCL
+	}
+	if sda == gpio.High {
+		levels |= 1 << SDAOut
+	}
+	for c := 0; c < count; c++ {
+		t.q.OutputPins(levels, 1<<SCL|1<<SDAOut)
+	}
+}
+
+// start pulls SDA low while SCL is high, then drops SCL.
+func (t *transaction) start() {
+	t.pins(gpio.High, gpio.High, repeatDelay)
+	t.pins(gpio.High, gpio.Low, repeatDelay)
+	t.pins(gpio.Low, gpio.Low, repeatDelay)
+}
+
+// repeatedStart releases SDA while SCL is low and then issues a start.
+func (t *transaction) repeatedStart() {
+	t.pins(gpio.Low, gpio.High, repeatDelay)
+	t.start()
+}
+
+// stop raises SDA while SCL is high.
+func (t *transaction) stop() {
+	t.pins(gpio.Low, gpio.Low, repeatDelay)
+	t.pins(gpio.High, gpio.Low, repeatDelay)
+	t.pins(gpio.High, gpio.High, repeatDelay)
+}
+
+// writeBytes clocks out each byte and reads its ACK bit.
+func (t *transaction) writeBytes(data []byte) {
+	for _, b := range data {
+		t.q.Append(commandWriteByte...)
+		t.q.Append(b)
+		// Release SDA so the slave can drive the ACK.
+		t.pins(gpio.Low, gpio.High, repeatDelay)
+		t.q.Append(commandReadBit...)
+		t.acks = append(t.acks, t.q.Expected())
+		t.q.Expect(1)
+	}
+}
+
+// readBytes clocks in [length] bytes, ACKing all but the last which is NACKed.
+func (t *transaction) readBytes(length int) {
+	for r := 0; r < length; r++ {
+		if r < length-1 {
+			t.q.Append(commandReadAck...)
+		} else {
+			t.q.Append(commandReadNack...)
+		}
+		// Leave clock low and SDA released.
+		t.pins(gpio.Low, gpio.High, 1)
+		t.q.Expect(1)
+	}
+}
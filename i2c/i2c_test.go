@@ -0,0 +1,183 @@
+package i2c_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/wdevore/hardware/ftdi/sim"
+	"github.com/wdevore/hardware/i2c"
+)
+
+func configured(t *testing.T) (*i2c.I2C, *sim.FT232H) {
+	t.Helper()
+	f, s := sim.NewFTDI232H()
+	bus := i2c.NewI2CFromFTDI(f)
+	err := bus.Configure(i2c.StandardMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Reset()
+	return bus, s
+}
+
+// Bus line states as set by the GPIO writes.
+const (
+	scl = 1 << i2c.SCL
+	sda = 1 << i2c.SDAOut
+)
+
+// lines returns the SCL and SDA levels of each GPIO write, without repeats.
+func lines(ops []sim.Op) []byte {
+	states := []byte{}
+	for _, op := range ops {
+		if op.Opcode != 0x80 {
+			continue
+		}
+		state := op.Args[0] & (scl | sda)
+		if len(states) == 0 || states[len(states)-1] != state {
+			states = append(states, state)
+		}
+	}
+	return states
+}
+
+func TestWrite(t *testing.T) {
+	bus, s := configured(t)
+
+	// The address and both data bytes are acknowledged.
+	s.QueueMISO(0x00, 0x00, 0x00)
+	err := bus.Device(0x50).Write([]byte{0x12, 0x34})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mosi := s.MOSI(); !bytes.Equal(mosi, []byte{0xa0, 0x12, 0x34}) {
+		t.Errorf("MOSI % x, want a0 12 34", mosi)
+	}
+
+	// A start is SDA falling while SCL is high, a stop SDA rising.
+	states := lines(s.Ops)
+	if len(states) < 6 {
+		t.Fatalf("line states %v", states)
+	}
+	if start := states[:3]; !bytes.Equal(start, []byte{scl | sda, scl, 0}) {
+		t.Errorf("start %v, want SDA low then SCL low", start)
+	}
+	if stop := states[len(states)-3:]; !bytes.Equal(stop, []byte{0, scl, scl | sda}) {
+		t.Errorf("stop %v, want SCL high then SDA high", stop)
+	}
+
+	// Everything is sent with one USB write.
+	if s.Flushes != 1 {
+		t.Errorf("%d send immediates, want 1", s.Flushes)
+	}
+}
+
+func TestNack(t *testing.T) {
+	bus, s := configured(t)
+
+	// Nothing drives SDA, every ACK bit reads high.
+	err := bus.Device(0x50).Write([]byte{0x12})
+	if !errors.Is(err, i2c.ErrNack) {
+		t.Errorf("Write: %v, want ErrNack", err)
+	}
+
+	ok, err := bus.Device(0x50).Ping()
+	if ok || err != nil {
+		t.Errorf("Ping: %v, %v, want false", ok, err)
+	}
+
+	// The data byte isn't acknowledged.
+	s.QueueMISO(0x00, 0xff)
+	err = bus.Device(0x50).Write([]byte{0x12})
+	if !errors.Is(err, i2c.ErrNack) {
+		t.Errorf("Write: %v, want ErrNack", err)
+	}
+}
+
+func TestRead(t *testing.T) {
+	bus, s := configured(t)
+
+	s.QueueMISO(0x00, 0xde, 0xad)
+	data, err := bus.Device(0x50).Read(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, []byte{0xde, 0xad}) {
+		t.Errorf("read % x, want de ad", data)
+	}
+
+	// The first byte is ACKed by the master, the last NACKed.
+	acks := []byte{}
+	for _, op := range s.Ops {
+		if op.Opcode == 0x13 {
+			acks = append(acks, op.Out[0])
+		}
+	}
+	if !bytes.Equal(acks, []byte{0x00, 0xff}) {
+		t.Errorf("master ACK bits % x, want 00 ff", acks)
+	}
+}
+
+func TestTenBit(t *testing.T) {
+	bus, s := configured(t)
+
+	s.QueueMISO(0x00, 0x00, 0x00)
+	err := bus.Device10(0x2a5).Write([]byte{0x01})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 11110 A9 A8 0, then A7-A0.
+	if mosi := s.MOSI(); !bytes.Equal(mosi, []byte{0xf4, 0xa5, 0x01}) {
+		t.Errorf("MOSI % x, want f4 a5 01", mosi)
+	}
+
+	// A read repeats the first address byte with the read bit.
+	s.Reset()
+	s.QueueMISO(0x00, 0x00, 0x00, 0x42)
+	data, err := bus.Device10(0x2a5).Read(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, []byte{0x42}) {
+		t.Errorf("read % x, want 42", data)
+	}
+	if mosi := s.MOSI(); !bytes.Equal(mosi, []byte{0xf4, 0xa5, 0xf5}) {
+		t.Errorf("MOSI % x, want f4 a5 f5", mosi)
+	}
+
+	err = bus.Device10(0x400).Write([]byte{0x01})
+	if !errors.Is(err, i2c.ErrAddress) {
+		t.Errorf("address 0x400: %v, want ErrAddress", err)
+	}
+	err = bus.Device(0x80).Write([]byte{0x01})
+	if !errors.Is(err, i2c.ErrAddress) {
+		t.Errorf("7 bit address 0x80: %v, want ErrAddress", err)
+	}
+}
+
+func TestScan(t *testing.T) {
+	bus, s := configured(t)
+
+	// Only 0x3c acknowledges its address.
+	s.MISO = func() byte {
+		for i := len(s.Ops) - 1; i >= 0; i-- {
+			if s.Ops[i].Opcode == 0x11 {
+				if s.Ops[i].Out[0]>>1 == 0x3c {
+					return 0x00
+				}
+				break
+			}
+		}
+		return 0xff
+	}
+
+	found, err := bus.Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0] != 0x3c {
+		t.Errorf("found %#x, want 0x3c", found)
+	}
+}
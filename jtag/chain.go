@@ -0,0 +1,96 @@
+package jtag
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MaxChainLength bounds DetectChain.
+const MaxChainLength = 32
+
+// ErrNoDevices is returned when TDO never reads back the bits shifted into TDI.
+var ErrNoDevices = errors.New("jtag: no devices found, check TDI/TDO wiring")
+
+// IDCode is a 32 bit IEEE 1149.1 device identification register. A device
+// without one shows up in the chain as BypassID.
+type IDCode uint32
+
+// BypassID marks a device that only has a bypass register.
+const BypassID IDCode = 0
+
+// Version returns bits 28-31.
+func (id IDCode) Version() int {
+	return int(id>>28) & 0xf
+}
+
+// Part returns the part number in bits 12-27.
+func (id IDCode) Part() int {
+	return int(id>>12) & 0xffff
+}
+
+// Manufacturer returns the JEDEC JEP106 code in bits 1-11.
+func (id IDCode) Manufacturer() int {
+	return int(id>>1) & 0x7ff
+}
+
+func (id IDCode) String() string {
+	if id == BypassID {
+		return "bypass"
+	}
+	return fmt.Sprintf("%#08x (manufacturer %#03x, part %#04x, version %d)", uint32(id), id.Manufacturer(), id.Part(), id.Version())
+}
+
+// DetectChain resets the chain and reads every device's IDCODE, nearest to
+// TDO first. Test-Logic-Reset selects IDCODE, or BYPASS when a device has
+// none, so the data registers are read while shifting in ones until the
+// ones come back out. The TAP is left in Run-Test/Idle.
+func (jtag *JTAG) DetectChain() ([]IDCode, error) {
+	err := jtag.Reset()
+	if err != nil {
+		return nil, err
+	}
+
+	// Room for the longest chain of IDCODEs plus one word of ones.
+	bits := (MaxChainLength + 1) * 32
+	ones := make([]byte, bits/8)
+	for i := range ones {
+		ones[i] = 0xff
+	}
+
+	tdo, err := jtag.ScanDR(bits, ones, true, RunTestIdle)
+	if err != nil {
+		return nil, err
+	}
+
+	bit := func(pos int) uint32 {
+		return uint32(tdo[pos/8]>>uint(pos%8)) & 1
+	}
+
+	ids := []IDCode{}
+	for pos := 0; pos+32 <= bits; {
+		// An IDCODE always has bit 0 set, a bypass register captures 0.
+		if bit(pos) == 0 {
+			ids = append(ids, BypassID)
+			pos++
+			continue
+		}
+
+		id := uint32(0)
+		for b := 0; b < 32; b++ {
+			id |= bit(pos+b) << uint(b)
+		}
+
+		if id == 0xffffffff {
+			// The ones shifted into TDI made it through the chain.
+			if len(ids) == 0 {
+				return nil, ErrNoDevices
+			}
+			return ids, nil
+		}
+
+		ids = append(ids, IDCode(id))
+		pos += 32
+	}
+
+	return nil, ErrNoDevices
+}
@@ -0,0 +1,352 @@
+package jtag
+
+import (
+	"errors"
+	"log"
+
+	"github.com/wdevore/hardware/ftdi"
+	"github.com/wdevore/hardware/gpio"
+)
+
+// When using JTAG with the FT232H the following pins will have a special meaning:
+// D0 - TCK / Test clock.
+// D1 - TDI / Test data into the first device of the chain.
+// D2 - TDO / Test data out of the last device of the chain.
+// D3 - TMS / Test mode select.
+// D7 - RTCK / Returned test clock, only used with adaptive clocking.
+// See AN_129 "Interfacing FTDI USB Hi-Speed Devices to a JTAG TAP".
+
+const (
+	// TCK is the clock pin
+	TCK = ftdi.D0
+	// TDI is the data output pin
+	TDI = ftdi.D1
+	// TDO is the data input pin
+	TDO = ftdi.D2
+	// TMS is the mode select pin
+	TMS = ftdi.D3
+	// RTCK is the returned clock pin used by adaptive clocking
+	RTCK = ftdi.D7
+)
+
+// JTAG data is shifted LSB first, written on the falling edge and read on
+// the rising edge of TCK. See section 3.2 of AN_108.
+const (
+	shiftOut       = 0x19 // Bytes out on -ve edge, LSB first
+	shiftInOut     = 0x39 // Bytes out on -ve edge and in on +ve edge, LSB first
+	shiftBitsOut   = 0x1b // Bits out on -ve edge, LSB first
+	shiftBitsInOut = 0x3b // Bits out on -ve edge and in on +ve edge, LSB first
+	tmsOut         = 0x4b // TMS bits out on -ve edge, LSB first, bit 7 is TDI
+	tmsInOut       = 0x6b // TMS bits out on -ve edge and TDO in on +ve edge
+
+	clockBits  = 0x8e // Clock 1 to 8 bits without data
+	clockBytes = 0x8f // Clock 8 to 524288 bits without data
+
+	// A TMS command carries at most 7 bits because bit 7 is TDI.
+	maxTMSBits = 7
+)
+
+var (
+	// ErrNotIdle is returned when a state can't be used as an end state.
+	ErrNotIdle = errors.New("jtag: not a stable state")
+	// ErrLength is returned when a scan is given too few data bits.
+	ErrLength = errors.New("jtag: data shorter than scan length")
+)
+
+// JTAG is a JTAG master facilitated by the FTDI232H MPSSE engine. It
+// tracks the TAP state of the chain so scans can start from anywhere.
+type JTAG struct {
+	ftdi  *ftdi.FTDI232H
+	queue *ftdi.Queue
+
+	clock    int
+	adaptive bool
+
+	state State
+}
+
+// NewJTAG creates a JTAG FTDI component
+func NewJTAG(vender, product int, disableDrivers bool, options ...ftdi.Option) (*JTAG, error) {
+	jtag := new(JTAG)
+	jtag.ftdi = ftdi.NewFTDI232H(vender, product, options...)
+
+	err := jtag.ftdi.Initialize(disableDrivers)
+
+	if err != nil {
+		return nil, err
+	}
+
+	jtag.queue = jtag.ftdi.NewQueue()
+
+	return jtag, nil
+}
+
+// NewJTAGFromFTDI creates a JTAG component on top of an existing FTDI232H,
+// for example one using an injected Transport.
+func NewJTAGFromFTDI(fi *ftdi.FTDI232H) *JTAG {
+	jtag := new(JTAG)
+	jtag.ftdi = fi
+	jtag.queue = fi.NewQueue()
+	return jtag
+}
+
+// Configure opens the device, sets the TCK frequency and resets the chain
+// into Run-Test/Idle. A clock of 0 defaults to 1MHz.
+// [adaptive] makes TCK wait for RTCK on D7, as required by ARM cores whose
+// clock is slower than or unrelated to TCK.
+func (jtag *JTAG) Configure(clock int, adaptive bool) error {
+	err := jtag.ftdi.Configure(true)
+	if err != nil {
+		log.Println("JTAG failed to configure.")
+		return err
+	}
+
+	if clock == 0 {
+		clock = 1000000
+	}
+
+	err = jtag.SetClock(clock, adaptive)
+	if err != nil {
+		return err
+	}
+
+	pins := []gpio.PinConfiguration{
+		{Pin: TCK, Direction: gpio.Output, Value: gpio.Low},
+		{Pin: TDI, Direction: gpio.Output, Value: gpio.Low},
+		{Pin: TDO, Direction: gpio.Input, Value: gpio.Z},
+		{Pin: TMS, Direction: gpio.Output, Value: gpio.High},
+	}
+	if adaptive {
+		pins = append(pins, gpio.PinConfiguration{Pin: RTCK, Direction: gpio.Input, Value: gpio.Z})
+	}
+	err = jtag.ftdi.ClaimPins("JTAG", pins)
+	if err != nil {
+		return err
+	}
+
+	err = jtag.ftdi.ConfigPins(pins, true)
+	if err != nil {
+		return err
+	}
+
+	err = jtag.Reset()
+	if err != nil {
+		return err
+	}
+
+	return jtag.GotoState(RunTestIdle)
+}
+
+// GetFTDI returns the FTDI component
+func (jtag *JTAG) GetFTDI() *ftdi.FTDI232H {
+	return jtag.ftdi
+}
+
+// Close closes the FTDI232 device
+func (jtag *JTAG) Close() error {
+	log.Println("JTAG closing FTDI device")
+	return jtag.ftdi.Close()
+}
+
+// SetClock sets the frequency of TCK in hertz. [adaptive] enables adaptive
+// clocking (the enableAdaptiveClocking command) where each TCK edge waits
+// for RTCK.
+func (jtag *JTAG) SetClock(hz int, adaptive bool) error {
+	jtag.clock = hz
+	jtag.adaptive = adaptive
+	return jtag.ftdi.SetClock(hz, adaptive, false)
+}
+
+// Clock returns the TCK frequency in hertz.
+func (jtag *JTAG) Clock() int {
+	return jtag.clock
+}
+
+// State returns the current TAP state.
+func (jtag *JTAG) State() State {
+	return jtag.state
+}
+
+// ------------------------------------------------------------------------
+// TAP
+// ------------------------------------------------------------------------
+
+// Reset holds TMS high for 5 clocks which puts every TAP in the chain into
+// Test-Logic-Reset from any state.
+func (jtag *JTAG) Reset() error {
+	q := jtag.queue
+	q.Reset()
+
+	jtag.queueTMS(q, []bool{true, true, true, true, true}, false)
+	jtag.state = TestLogicReset
+
+	_, err := q.Flush()
+	return err
+}
+
+// GotoState moves the TAP to [state] along the shortest path.
+func (jtag *JTAG) GotoState(state State) error {
+	q := jtag.queue
+	q.Reset()
+
+	jtag.queueGoto(q, state)
+
+	_, err := q.Flush()
+	return err
+}
+
+// RunTest moves to [state], which must be stable, and clocks TCK [clocks]
+// times while staying there. Typically used in Run-Test/Idle to let a
+// device finish an operation such as a flash erase.
+func (jtag *JTAG) RunTest(state State, clocks int) error {
+	if !state.Stable() {
+		return ErrNotIdle
+	}
+
+	q := jtag.queue
+	q.Reset()
+
+	jtag.queueGoto(q, state)
+	jtag.queueClocks(q, clocks)
+
+	_, err := q.Flush()
+	return err
+}
+
+// queueTMS appends TMS commands for [tms] while holding TDI at [tdi].
+func (jtag *JTAG) queueTMS(q *ftdi.Queue, tms []bool, tdi bool) {
+	for len(tms) > 0 {
+		n := len(tms)
+		if n > maxTMSBits {
+			n = maxTMSBits
+		}
+
+		data := byte(0)
+		for i, b := range tms[:n] {
+			if b {
+				data |= 1 << uint(i)
+			}
+		}
+		if tdi {
+			data |= 0x80
+		}
+
+		q.ShiftBits(tmsOut, n, data)
+		tms = tms[n:]
+	}
+}
+
+// queueGoto appends the TMS sequence to reach [state] and tracks it.
+func (jtag *JTAG) queueGoto(q *ftdi.Queue, state State) {
+	if jtag.state == state {
+		return
+	}
+
+	jtag.queueTMS(q, Path(jtag.state, state), false)
+	jtag.state = state
+}
+
+// queueClocks appends TCK pulses that leave TMS and TDI unchanged. The TAP
+// stays in a stable state because TMS still holds the bit that entered it.
+func (jtag *JTAG) queueClocks(q *ftdi.Queue, clocks int) {
+	for clocks >= 8 {
+		n := clocks / 8
+		if n > 65536 {
+			n = 65536
+		}
+		q.Append(clockBytes, byte((n-1)&0xff), byte(((n-1)>>8)&0xff))
+		clocks -= n * 8
+	}
+
+	if clocks > 0 {
+		q.Append(clockBits, byte(clocks-1))
+	}
+}
+
+// ------------------------------------------------------------------------
+// Scans
+// ------------------------------------------------------------------------
+
+// ScanIR shifts [bits] of [tdi] into the instruction registers of the
+// chain and moves to [end]. The bits shifted out are returned if [read].
+// Data is LSB first: bit 0 of tdi[0] is the first bit clocked in.
+func (jtag *JTAG) ScanIR(bits int, tdi []byte, read bool, end State) ([]byte, error) {
+	return jtag.scan(ShiftIR, bits, tdi, read, end)
+}
+
+// ScanDR shifts [bits] of [tdi] into the selected data registers of the
+// chain and moves to [end]. The bits shifted out are returned if [read].
+// Data is LSB first: bit 0 of tdi[0] is the first bit clocked in.
+func (jtag *JTAG) ScanDR(bits int, tdi []byte, read bool, end State) ([]byte, error) {
+	return jtag.scan(ShiftDR, bits, tdi, read, end)
+}
+
+func (jtag *JTAG) scan(shift State, bits int, tdi []byte, read bool, end State) ([]byte, error) {
+	if bits <= 0 {
+		return nil, nil
+	}
+	if len(tdi)*8 < bits {
+		return nil, ErrLength
+	}
+
+	q := jtag.queue
+	q.Reset()
+
+	jtag.queueGoto(q, shift)
+
+	// Every bit but the last is shifted with TMS low. The last one is
+	// clocked with TMS high which leaves Shift-xR for Exit1-xR.
+	body := bits - 1
+	full := body / 8
+	partial := body % 8
+
+	if full > 0 {
+		if read {
+			q.Shift(shiftInOut, tdi[:full])
+		} else {
+			q.Shift(shiftOut, tdi[:full])
+		}
+	}
+
+	if partial > 0 {
+		if read {
+			q.ShiftBits(shiftBitsInOut, partial, tdi[full])
+		} else {
+			q.ShiftBits(shiftBitsOut, partial, tdi[full])
+		}
+	}
+
+	last := tdi[body/8]&(1<<uint(body%8)) != 0
+	tms := byte(0x01)
+	if last {
+		tms |= 0x80
+	}
+	if read {
+		q.ShiftBits(tmsInOut, 1, tms)
+	} else {
+		q.ShiftBits(tmsOut, 1, tms)
+	}
+	jtag.state = shift.Next(true)
+
+	jtag.queueGoto(q, end)
+
+	response, err := q.Flush()
+	if err != nil || !read {
+		return nil, err
+	}
+
+	// Bit commands shift LSB first data in at bit 7, so n bits read end up
+	// in the top n bits of their byte.
+	tdo := make([]byte, (bits+7)/8)
+	copy(tdo, response[:full])
+	idx := full
+	if partial > 0 {
+		tdo[full] = response[idx] >> uint(8-partial)
+		idx++
+	}
+	if response[idx]&0x80 != 0 {
+		tdo[body/8] |= 1 << uint(body%8)
+	}
+
+	return tdo, nil
+}
@@ -0,0 +1,527 @@
+package jtag
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrTDOMismatch is returned when a scan doesn't read back the TDO an SVF
+// file expects.
+var ErrTDOMismatch = errors.New("jtag: TDO mismatch")
+
+// SVFError reports the statement an SVF file failed on.
+type SVFError struct {
+	Line      int
+	Statement string
+	Err       error
+}
+
+func (e *SVFError) Error() string {
+	return fmt.Sprintf("jtag: svf line %d: %s: %v", e.Line, e.Statement, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *SVFError) Unwrap() error {
+	return e.Err
+}
+
+var svfStates = map[string]State{
+	"RESET":     TestLogicReset,
+	"IDLE":      RunTestIdle,
+	"DRSELECT":  SelectDRScan,
+	"DRCAPTURE": CaptureDR,
+	"DRSHIFT":   ShiftDR,
+	"DREXIT1":   Exit1DR,
+	"DRPAUSE":   PauseDR,
+	"DREXIT2":   Exit2DR,
+	"DRUPDATE":  UpdateDR,
+	"IRSELECT":  SelectIRScan,
+	"IRCAPTURE": CaptureIR,
+	"IRSHIFT":   ShiftIR,
+	"IREXIT1":   Exit1IR,
+	"IRPAUSE":   PauseIR,
+	"IREXIT2":   Exit2IR,
+	"IRUPDATE":  UpdateIR,
+}
+
+// svfPattern is the state of one of the SIR, SDR, HIR, TIR, HDR or TDR
+// commands. TDI, MASK and SMASK carry over to the next command of the same
+// kind while the length is unchanged. TDO is only checked when given.
+type svfPattern struct {
+	length int
+	tdi    []byte
+	tdo    []byte
+	mask   []byte
+}
+
+// SVFPlayer runs Serial Vector Format files, as exported by FPGA and CPLD
+// tools, against a chain. Only the data pattern of a scan is checked
+// against TDO, header and trailer bits are shifted but not compared.
+type SVFPlayer struct {
+	jtag *JTAG
+
+	endIR    State
+	endDR    State
+	runState State
+	runEnd   State
+
+	sir, sdr           svfPattern
+	hir, tir, hdr, tdr svfPattern
+}
+
+// NewSVFPlayer creates a player for the chain.
+func (jtag *JTAG) NewSVFPlayer() *SVFPlayer {
+	p := new(SVFPlayer)
+	p.jtag = jtag
+	p.endIR = RunTestIdle
+	p.endDR = RunTestIdle
+	p.runState = RunTestIdle
+	p.runEnd = RunTestIdle
+	return p
+}
+
+// PlaySVF runs every statement read from [r].
+func (jtag *JTAG) PlaySVF(r io.Reader) error {
+	return jtag.NewSVFPlayer().Play(r)
+}
+
+// Play runs every statement read from [r] and stops at the first failure
+// with a *SVFError.
+func (p *SVFPlayer) Play(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), math.MaxInt32)
+
+	statement := strings.Builder{}
+	line := 0
+	start := 0
+
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+
+		// Comments run to the end of the line.
+		if idx := strings.Index(text, "!"); idx >= 0 {
+			text = text[:idx]
+		}
+		if idx := strings.Index(text, "//"); idx >= 0 {
+			text = text[:idx]
+		}
+
+		for {
+			idx := strings.IndexByte(text, ';')
+			if idx < 0 {
+				break
+			}
+
+			if statement.Len() == 0 {
+				start = line
+			}
+			statement.WriteString(text[:idx])
+
+			stmt := strings.TrimSpace(statement.String())
+			statement.Reset()
+			text = text[idx+1:]
+
+			if stmt == "" {
+				continue
+			}
+
+			err := p.Execute(stmt)
+			if err != nil {
+				return &SVFError{Line: start, Statement: stmt, Err: err}
+			}
+		}
+
+		if strings.TrimSpace(text) != "" {
+			if statement.Len() == 0 {
+				start = line
+			}
+			statement.WriteString(text)
+			statement.WriteByte(' ')
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(statement.String()) != "" {
+		return &SVFError{Line: start, Statement: statement.String(), Err: errors.New("missing ';'")}
+	}
+
+	return nil
+}
+
+// Execute runs a single statement without its terminating ';'.
+func (p *SVFPlayer) Execute(statement string) error {
+	fields := svfFields(statement)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	command := strings.ToUpper(fields[0])
+	args := fields[1:]
+
+	switch command {
+	case "ENDIR":
+		return p.endState(args, &p.endIR)
+	case "ENDDR":
+		return p.endState(args, &p.endDR)
+	case "STATE":
+		return p.state(args)
+	case "SIR":
+		return p.scan(args, &p.sir, &p.hir, &p.tir, ShiftIR, p.endIR)
+	case "SDR":
+		return p.scan(args, &p.sdr, &p.hdr, &p.tdr, ShiftDR, p.endDR)
+	case "HIR":
+		return p.pattern(args, &p.hir)
+	case "TIR":
+		return p.pattern(args, &p.tir)
+	case "HDR":
+		return p.pattern(args, &p.hdr)
+	case "TDR":
+		return p.pattern(args, &p.tdr)
+	case "RUNTEST":
+		return p.runTest(args)
+	case "FREQUENCY":
+		return p.frequency(args)
+	case "TRST":
+		// There is no TRST pin, only ON (asserting it) would matter.
+		if len(args) == 1 && strings.ToUpper(args[0]) == "ON" {
+			return errors.New("TRST ON is not supported, TRST isn't wired")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported command %s", command)
+	}
+}
+
+// svfFields splits a statement into words keeping "(...)" values, which may
+// contain white space, as single fields without the parentheses.
+func svfFields(statement string) []string {
+	fields := []string{}
+	word := strings.Builder{}
+	inParen := false
+
+	flush := func() {
+		if word.Len() > 0 {
+			fields = append(fields, word.String())
+			word.Reset()
+		}
+	}
+
+	for _, c := range statement {
+		switch {
+		case c == '(':
+			flush()
+			inParen = true
+		case c == ')':
+			fields = append(fields, word.String())
+			word.Reset()
+			inParen = false
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			if !inParen {
+				flush()
+			}
+		default:
+			word.WriteRune(c)
+		}
+	}
+	flush()
+
+	return fields
+}
+
+func parseSVFState(name string) (State, error) {
+	state, ok := svfStates[strings.ToUpper(name)]
+	if !ok {
+		return 0, fmt.Errorf("unknown state %s", name)
+	}
+	return state, nil
+}
+
+func (p *SVFPlayer) endState(args []string, end *State) error {
+	if len(args) != 1 {
+		return errors.New("expected a single state")
+	}
+
+	state, err := parseSVFState(args[0])
+	if err != nil {
+		return err
+	}
+	if !state.Stable() {
+		return ErrNotIdle
+	}
+
+	*end = state
+	return nil
+}
+
+// state moves through the listed states. The last must be stable.
+func (p *SVFPlayer) state(args []string) error {
+	if len(args) == 0 {
+		return errors.New("expected at least one state")
+	}
+
+	for idx, name := range args {
+		state, err := parseSVFState(name)
+		if err != nil {
+			return err
+		}
+		if idx == len(args)-1 && !state.Stable() {
+			return ErrNotIdle
+		}
+
+		err = p.jtag.GotoState(state)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// pattern parses "length [TDI (hex)] [TDO (hex)] [MASK (hex)] [SMASK (hex)]".
+func (p *SVFPlayer) pattern(args []string, pat *svfPattern) error {
+	if len(args) == 0 {
+		return errors.New("missing length")
+	}
+
+	length, err := strconv.Atoi(args[0])
+	if err != nil || length < 0 {
+		return fmt.Errorf("bad length %s", args[0])
+	}
+
+	if length != pat.length {
+		// A new length forgets the previous TDI and MASK.
+		pat.tdi = nil
+		pat.mask = nil
+	}
+	pat.length = length
+	pat.tdo = nil
+
+	args = args[1:]
+	for len(args) >= 2 {
+		value, err := parseSVFHex(args[1], length)
+		if err != nil {
+			return err
+		}
+
+		switch strings.ToUpper(args[0]) {
+		case "TDI":
+			pat.tdi = value
+		case "TDO":
+			pat.tdo = value
+		case "MASK":
+			pat.mask = value
+		case "SMASK":
+			// TDI is always driven, SMASK doesn't change anything.
+		default:
+			return fmt.Errorf("unknown parameter %s", args[0])
+		}
+
+		args = args[2:]
+	}
+
+	if len(args) != 0 {
+		return fmt.Errorf("parameter %s without a value", args[0])
+	}
+
+	if pat.length > 0 && pat.tdi == nil {
+		return errors.New("TDI required for a new length")
+	}
+
+	return nil
+}
+
+// parseSVFHex converts a hex string, most significant digit first, into
+// LSB first bytes holding [length] bits.
+func parseSVFHex(hex string, length int) ([]byte, error) {
+	value := make([]byte, (length+7)/8)
+
+	bit := 0
+	for idx := len(hex) - 1; idx >= 0; idx-- {
+		digit, err := strconv.ParseUint(hex[idx:idx+1], 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("bad hex digit %q", hex[idx])
+		}
+
+		for b := 0; b < 4; b++ {
+			if digit&(1<<uint(b)) == 0 {
+				bit++
+				continue
+			}
+			if bit >= length {
+				return nil, fmt.Errorf("value %s longer than (%d) bits", hex, length)
+			}
+			value[bit/8] |= 1 << uint(bit%8)
+			bit++
+		}
+	}
+
+	return value, nil
+}
+
+func (p *SVFPlayer) scan(args []string, pat, header, trailer *svfPattern, shift State, end State) error {
+	err := p.pattern(args, pat)
+	if err != nil {
+		return err
+	}
+
+	if pat.length == 0 {
+		return p.jtag.GotoState(end)
+	}
+
+	// The header is clocked in first and the trailer last.
+	bits := header.length + pat.length + trailer.length
+	tdi := make([]byte, (bits+7)/8)
+	offset := 0
+	for _, part := range []*svfPattern{header, pat, trailer} {
+		copyBits(tdi, offset, part.tdi, part.length)
+		offset += part.length
+	}
+
+	check := pat.tdo != nil
+	tdo, err := p.jtag.scan(shift, bits, tdi, check, end)
+	if err != nil || !check {
+		return err
+	}
+
+	for b := 0; b < pat.length; b++ {
+		if pat.mask != nil && pat.mask[b/8]&(1<<uint(b%8)) == 0 {
+			continue
+		}
+
+		pos := header.length + b
+		got := tdo[pos/8]&(1<<uint(pos%8)) != 0
+		want := pat.tdo[b/8]&(1<<uint(b%8)) != 0
+		if got != want {
+			return fmt.Errorf("%w at bit %d", ErrTDOMismatch, b)
+		}
+	}
+
+	return nil
+}
+
+// copyBits copies [length] bits of [src] into [dst] starting at bit [offset].
+func copyBits(dst []byte, offset int, src []byte, length int) {
+	for b := 0; b < length && b/8 < len(src); b++ {
+		if src[b/8]&(1<<uint(b%8)) != 0 {
+			pos := offset + b
+			dst[pos/8] |= 1 << uint(pos%8)
+		}
+	}
+}
+
+// runTest parses
+// "[run_state] [run_count TCK|SCK] [min_time SEC [MAXIMUM max_time SEC]] [ENDSTATE end_state]".
+// The run and end states carry over to the next RUNTEST, giving a run
+// state also makes it the end state.
+func (p *SVFPlayer) runTest(args []string) error {
+	runState := p.runState
+	end := p.runEnd
+	clocks := 0
+	minTime := time.Duration(0)
+
+	if len(args) > 0 {
+		if state, err := parseSVFState(args[0]); err == nil {
+			if !state.Stable() {
+				return ErrNotIdle
+			}
+			runState = state
+			end = state
+			args = args[1:]
+		}
+	}
+
+	for len(args) > 0 {
+		switch strings.ToUpper(args[0]) {
+		case "MAXIMUM":
+			// Running longer than the minimum is always fine.
+			if len(args) < 3 {
+				return errors.New("MAXIMUM without a time")
+			}
+			args = args[3:]
+			continue
+		case "ENDSTATE":
+			if len(args) < 2 {
+				return errors.New("ENDSTATE without a state")
+			}
+			state, err := parseSVFState(args[1])
+			if err != nil {
+				return err
+			}
+			if !state.Stable() {
+				return ErrNotIdle
+			}
+			end = state
+			args = args[2:]
+			continue
+		}
+
+		if len(args) < 2 {
+			return fmt.Errorf("%s without a unit", args[0])
+		}
+
+		value, err := strconv.ParseFloat(args[0], 64)
+		if err != nil {
+			return fmt.Errorf("bad number %s", args[0])
+		}
+
+		switch strings.ToUpper(args[1]) {
+		case "TCK":
+			clocks = int(value)
+		case "SCK":
+			// The system clock isn't ours, treat it as TCK.
+			clocks = int(value)
+		case "SEC":
+			minTime = time.Duration(value * float64(time.Second))
+		default:
+			return fmt.Errorf("unknown unit %s", args[1])
+		}
+		args = args[2:]
+	}
+
+	err := p.jtag.RunTest(runState, clocks)
+	if err != nil {
+		return err
+	}
+
+	if minTime > 0 {
+		// The clocks above already took part of the time.
+		elapsed := time.Duration(0)
+		if p.jtag.clock > 0 {
+			elapsed = time.Duration(clocks) * time.Second / time.Duration(p.jtag.clock)
+		}
+		if minTime > elapsed {
+			time.Sleep(minTime - elapsed)
+		}
+	}
+
+	p.runState = runState
+	p.runEnd = end
+
+	return p.jtag.GotoState(end)
+}
+
+func (p *SVFPlayer) frequency(args []string) error {
+	if len(args) == 0 {
+		// Back to the default, leave the clock as it is.
+		return nil
+	}
+
+	hz, err := strconv.ParseFloat(args[0], 64)
+	if err != nil {
+		return fmt.Errorf("bad frequency %s", args[0])
+	}
+
+	log.Printf("JTAG svf setting TCK to (%d)Hz\n", int(hz))
+	return p.jtag.SetClock(int(hz), p.jtag.adaptive)
+}
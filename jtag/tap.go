@@ -0,0 +1,125 @@
+package jtag
+
+// State is a state of the IEEE 1149.1 TAP controller.
+type State int
+
+// TAP controller states
+const (
+	TestLogicReset State = iota
+	RunTestIdle
+	SelectDRScan
+	CaptureDR
+	ShiftDR
+	Exit1DR
+	PauseDR
+	Exit2DR
+	UpdateDR
+	SelectIRScan
+	CaptureIR
+	ShiftIR
+	Exit1IR
+	PauseIR
+	Exit2IR
+	UpdateIR
+)
+
+var stateNames = []string{
+	"Test-Logic-Reset",
+	"Run-Test/Idle",
+	"Select-DR-Scan",
+	"Capture-DR",
+	"Shift-DR",
+	"Exit1-DR",
+	"Pause-DR",
+	"Exit2-DR",
+	"Update-DR",
+	"Select-IR-Scan",
+	"Capture-IR",
+	"Shift-IR",
+	"Exit1-IR",
+	"Pause-IR",
+	"Exit2-IR",
+	"Update-IR",
+}
+
+func (s State) String() string {
+	if s < 0 || int(s) >= len(stateNames) {
+		return "Unknown"
+	}
+	return stateNames[s]
+}
+
+// transitions holds the next state for TMS = 0 and TMS = 1.
+var transitions = [][2]State{
+	TestLogicReset: {RunTestIdle, TestLogicReset},
+	RunTestIdle:    {RunTestIdle, SelectDRScan},
+	SelectDRScan:   {CaptureDR, SelectIRScan},
+	CaptureDR:      {ShiftDR, Exit1DR},
+	ShiftDR:        {ShiftDR, Exit1DR},
+	Exit1DR:        {PauseDR, UpdateDR},
+	PauseDR:        {PauseDR, Exit2DR},
+	Exit2DR:        {ShiftDR, UpdateDR},
+	UpdateDR:       {RunTestIdle, SelectDRScan},
+	SelectIRScan:   {CaptureIR, TestLogicReset},
+	CaptureIR:      {ShiftIR, Exit1IR},
+	ShiftIR:        {ShiftIR, Exit1IR},
+	Exit1IR:        {PauseIR, UpdateIR},
+	PauseIR:        {PauseIR, Exit2IR},
+	Exit2IR:        {ShiftIR, UpdateIR},
+	UpdateIR:       {RunTestIdle, SelectDRScan},
+}
+
+// Next returns the state entered after one TCK with [tms].
+func (s State) Next(tms bool) State {
+	if tms {
+		return transitions[s][1]
+	}
+	return transitions[s][0]
+}
+
+// Stable reports whether the TAP can stay in the state while TCK runs
+// without shifting, that is the states SVF allows as end states.
+func (s State) Stable() bool {
+	switch s {
+	case TestLogicReset, RunTestIdle, PauseDR, PauseIR:
+		return true
+	}
+	return false
+}
+
+// Path returns the shortest TMS sequence that moves the TAP from [from] to
+// [to]. The path from a state to itself is empty.
+func Path(from, to State) []bool {
+	// Breadth first search over the 16 states.
+	type step struct {
+		prev State
+		tms  bool
+	}
+
+	visited := make([]bool, len(transitions))
+	steps := make([]step, len(transitions))
+	visited[from] = true
+
+	frontier := []State{from}
+	for len(frontier) > 0 && !visited[to] {
+		next := []State{}
+		for _, s := range frontier {
+			for _, tms := range []bool{false, true} {
+				n := s.Next(tms)
+				if !visited[n] {
+					visited[n] = true
+					steps[n] = step{prev: s, tms: tms}
+					next = append(next, n)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	path := []bool{}
+	for s := to; s != from; s = steps[s].prev {
+		path = append([]bool{steps[s].tms}, path...)
+	}
+
+	return path
+}
@@ -0,0 +1,222 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/wdevore/hardware/ftdi"
+	"github.com/wdevore/hardware/gpio"
+)
+
+// The analyzer samples D0-D7 using synchronous bitbang. All 8 pins are
+// inputs, so nothing is driven onto the circuit under test. Keep probes to
+// 3.3V logic, the FT232H pins are 5V tolerant but not more.
+
+// MaxRate is the fastest sample rate worth asking for. The USB link can't
+// stream samples much faster than this without gaps between blocks.
+const MaxRate = 1000000
+
+// Samples are written and read in blocks this size. The chip samples
+// without gaps within a block only.
+const blockSize = 4096
+
+// How long a block may take to come back.
+const blockTimeout = 3 * time.Second
+
+// ErrNoSamples is returned when a capture of 0 samples is requested.
+var ErrNoSamples = errors.New("logic: no samples requested")
+
+// ErrRate is returned by Configure for a sample rate it can't deliver.
+var ErrRate = errors.New("logic: unsupported sample rate")
+
+// Analyzer is a simple 8 channel logic analyzer facilitated by the FTDI232H.
+type Analyzer struct {
+	ftdi *ftdi.FTDI232H
+
+	rate int
+
+	// Bytes written to clock out samples. Their value is irrelevant since
+	// every pin is an input.
+	clocks []byte
+}
+
+// NewAnalyzer creates a logic analyzer FTDI component
+func NewAnalyzer(vender, product int, disableDrivers bool, options ...ftdi.Option) (*Analyzer, error) {
+	an := new(Analyzer)
+	an.ftdi = ftdi.NewFTDI232H(vender, product, options...)
+
+	err := an.ftdi.Initialize(disableDrivers)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return an, nil
+}
+
+// NewAnalyzerFromFTDI creates an analyzer on top of an existing FTDI232H,
+// for example one using an injected Transport.
+func NewAnalyzerFromFTDI(fi *ftdi.FTDI232H) *Analyzer {
+	an := new(Analyzer)
+	an.ftdi = fi
+	return an
+}
+
+// Configure opens the device and switches to synchronous bitbang with every
+// pin an input. [rate] is in samples per second, 0 defaults to MaxRate and
+// more than MaxRate returns ErrRate.
+//
+// The rate is the baud rate given to libftdi, which multiplies it by 4 in
+// the bitbang modes so that it is the sample clock. The chip's baud
+// generator rounds it, libftdi refuses rates it can't get within 5% of.
+func (an *Analyzer) Configure(rate int) error {
+	if rate == 0 {
+		rate = MaxRate
+	}
+	if rate < 0 || rate > MaxRate {
+		return fmt.Errorf("%w: %d samples per second, the most is %d", ErrRate, rate, MaxRate)
+	}
+
+	err := an.ftdi.SyncConfigure(0x00, rate)
+	if err != nil {
+		log.Println("Analyzer failed to configure.")
+		return err
+	}
+
+	an.rate = rate
+	an.clocks = make([]byte, blockSize)
+
+	return nil
+}
+
+// GetFTDI returns the FTDI component
+func (an *Analyzer) GetFTDI() *ftdi.FTDI232H {
+	return an.ftdi
+}
+
+// Close closes the FTDI232 device
+func (an *Analyzer) Close() error {
+	log.Println("Analyzer closing FTDI device")
+	return an.ftdi.Close()
+}
+
+// Rate returns the sample rate in samples per second.
+func (an *Analyzer) Rate() int {
+	return an.rate
+}
+
+// sample reads the next block of samples.
+func (an *Analyzer) sample(ctx context.Context, count int) ([]byte, error) {
+	readCtx, cancel := context.WithTimeout(ctx, blockTimeout)
+	defer cancel()
+
+	response, err := an.ftdi.Exchange(readCtx, an.clocks[:count], count)
+	if err != nil {
+		return nil, err
+	}
+
+	block := make([]byte, count)
+	copy(block, response)
+	return block, nil
+}
+
+// Capture records [samples] samples of D0-D7. With a [trigger] it first
+// waits, forever if need be, for the trigger to match and [pretrigger] of
+// the samples are from before the match. A nil trigger starts immediately.
+func (an *Analyzer) Capture(samples, pretrigger int, trigger Trigger) (*Capture, error) {
+	return an.CaptureContext(context.Background(), samples, pretrigger, trigger)
+}
+
+// CaptureContext is Capture but stops waiting for the trigger, or for
+// samples, when [ctx] is canceled or its deadline passes.
+func (an *Analyzer) CaptureContext(ctx context.Context, samples, pretrigger int, trigger Trigger) (*Capture, error) {
+	if samples <= 0 {
+		return nil, ErrNoSamples
+	}
+	if pretrigger > samples {
+		pretrigger = samples
+	}
+	if trigger == nil {
+		pretrigger = 0
+	}
+
+	capture := &Capture{Rate: an.rate, Trigger: -1}
+	data := []byte{}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		count := blockSize
+		if capture.Trigger >= 0 || trigger == nil {
+			// Only what's missing after the trigger.
+			count = samples - len(data)
+			if count <= 0 {
+				break
+			}
+			if count > blockSize {
+				count = blockSize
+			}
+		}
+
+		block, err := an.sample(ctx, count)
+		if err != nil {
+			return nil, err
+		}
+
+		start := len(data)
+		data = append(data, block...)
+
+		if trigger != nil && capture.Trigger < 0 {
+			for idx := start; idx < len(data); idx++ {
+				if idx > 0 && trigger(data[idx-1], data[idx]) {
+					capture.Trigger = idx
+					break
+				}
+			}
+
+			if capture.Trigger < 0 {
+				// Keep only what pretrigger needs, and at least the last
+				// sample to find an edge across blocks.
+				keep := pretrigger
+				if keep < 1 {
+					keep = 1
+				}
+				if drop := len(data) - keep; drop > 0 {
+					data = data[drop:]
+				}
+				continue
+			}
+
+			// Drop samples older than the pretrigger window.
+			if drop := capture.Trigger - pretrigger; drop > 0 {
+				data = data[drop:]
+				capture.Trigger -= drop
+			}
+		}
+	}
+
+	if len(data) > samples {
+		data = data[:samples]
+	}
+	capture.Samples = data
+
+	return capture, nil
+}
+
+// Measure captures [duration] worth of samples and measures the frequency
+// and duty cycle of [pin]. The duration should cover several periods.
+func (an *Analyzer) Measure(pin gpio.Pin, duration time.Duration) (Measurement, error) {
+	samples := int(duration.Seconds() * float64(an.rate))
+
+	capture, err := an.Capture(samples, 0, nil)
+	if err != nil {
+		return Measurement{}, err
+	}
+
+	return capture.Measure(pin)
+}
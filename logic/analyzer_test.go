@@ -0,0 +1,171 @@
+package logic_test
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wdevore/hardware/ftdi"
+	"github.com/wdevore/hardware/ftdi/sim"
+	"github.com/wdevore/hardware/logic"
+)
+
+// signal is a simulator whose D0-D7 inputs follow [levels], called with
+// the index of each sample.
+type signal struct {
+	*sim.FT232H
+	levels func(sample int) byte
+	sample int
+}
+
+func (s *signal) Write(data []byte) (int, error) {
+	for _, b := range data {
+		level := s.levels(s.sample)
+		for pin := ftdi.D0; pin <= ftdi.D7; pin++ {
+			s.SetInput(pin, level&(1<<pin) != 0)
+		}
+		s.sample++
+
+		_, err := s.FT232H.Write([]byte{b})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+// square is high on D2 for [high] of every [period] samples, starting low.
+func square(period, high int) func(int) byte {
+	return func(sample int) byte {
+		if sample%period >= period-high {
+			return 1 << ftdi.D2
+		}
+		return 0
+	}
+}
+
+func analyzer(t *testing.T, rate int, levels func(int) byte) (*logic.Analyzer, *signal) {
+	t.Helper()
+	s := &signal{FT232H: sim.New(), levels: levels}
+	f := ftdi.NewFTDI232H(0x0403, 0x6014)
+	f.SetTransport(s)
+
+	an := logic.NewAnalyzerFromFTDI(f)
+	err := an.Configure(rate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return an, s
+}
+
+func TestConfigure(t *testing.T) {
+	an, s := analyzer(t, 0, square(2, 1))
+
+	if s.Mode != ftdi.ModeSyncBB || s.IOMask != 0 {
+		t.Errorf("mode %v mask %#02x, want sync bitbang with every pin an input", s.Mode, s.IOMask)
+	}
+	if an.Rate() != logic.MaxRate || s.Baudrate != logic.MaxRate {
+		t.Errorf("rate %d baud rate %d, want %d", an.Rate(), s.Baudrate, logic.MaxRate)
+	}
+
+	err := an.Configure(logic.MaxRate + 1)
+	if !errors.Is(err, logic.ErrRate) {
+		t.Errorf("rate above MaxRate: %v, want ErrRate", err)
+	}
+}
+
+func TestCaptureTrigger(t *testing.T) {
+	// The first rising edge is at sample 70.
+	an, _ := analyzer(t, 100000, square(100, 30))
+
+	capture, err := an.Capture(200, 50, logic.RisingEdge(ftdi.D2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(capture.Samples) != 200 || capture.Trigger != 50 {
+		t.Fatalf("%d samples triggered at %d, want 200 at 50", len(capture.Samples), capture.Trigger)
+	}
+	if capture.Level(ftdi.D2, 49) != 0 || capture.Level(ftdi.D2, 50) == 0 {
+		t.Error("the trigger isn't at the rising edge")
+	}
+}
+
+func TestTriggers(t *testing.T) {
+	d2 := byte(1 << ftdi.D2)
+	tests := []struct {
+		name              string
+		trigger           logic.Trigger
+		previous, current byte
+		match             bool
+	}{
+		{"rising", logic.RisingEdge(ftdi.D2), 0, d2, true},
+		{"rising on falling", logic.RisingEdge(ftdi.D2), d2, 0, false},
+		{"falling", logic.FallingEdge(ftdi.D2), d2, 0, true},
+		{"falling on high", logic.FallingEdge(ftdi.D2), d2, d2, false},
+		{"any edge", logic.AnyEdge(ftdi.D2), d2, 0, true},
+		{"any edge on other pin", logic.AnyEdge(ftdi.D2), 0x01, 0x00, false},
+		{"pattern", logic.Pattern(0x03, 0x02), 0x03, 0x02, true},
+		{"pattern held", logic.Pattern(0x03, 0x02), 0x02, 0x06, false},
+	}
+
+	for _, test := range tests {
+		if got := test.trigger(test.previous, test.current); got != test.match {
+			t.Errorf("%s: %v, want %v", test.name, got, test.match)
+		}
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	// 10kHz at 30% duty cycle.
+	an, _ := analyzer(t, logic.MaxRate, square(100, 30))
+
+	m, err := an.Measure(ftdi.D2, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(m.Frequency-10000) > 1 || math.Abs(m.DutyCycle-0.3) > 0.001 {
+		t.Errorf("measured %v, want 10kHz at 30%%", m)
+	}
+	if m.Period != 100*time.Microsecond || m.Edges != 9 {
+		t.Errorf("period %v over %d edges, want 100µs over 9", m.Period, m.Edges)
+	}
+
+	_, err = an.Measure(ftdi.D5, time.Millisecond)
+	if !errors.Is(err, logic.ErrNoSignal) {
+		t.Errorf("idle pin: %v, want ErrNoSignal", err)
+	}
+}
+
+func TestWriteVCD(t *testing.T) {
+	capture := &logic.Capture{Rate: 1000000, Samples: []byte{0x00, 0x01, 0x01, 0x03}, Trigger: -1}
+	capture.Names[1] = "CLK"
+
+	var out bytes.Buffer
+	err := capture.WriteVCD(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vcd := out.String()
+
+	for _, want := range []string{
+		"$timescale 1ns $end\n",
+		"$var wire 1 ! D0 $end\n",
+		"$var wire 1 \" CLK $end\n",
+		"#0\n$dumpvars\n0!\n0\"\n",
+		// D0 rises at 1µs, only what changed is dumped.
+		"#1000\n1!\n#3000\n1\"\n#4000\n",
+	} {
+		if !strings.Contains(vcd, want) {
+			t.Errorf("VCD is missing %q:\n%s", want, vcd)
+		}
+	}
+
+	err = (&logic.Capture{Rate: 1000}).WriteVCD(&out)
+	if !errors.Is(err, logic.ErrNoSamples) {
+		t.Errorf("empty capture: %v, want ErrNoSamples", err)
+	}
+}
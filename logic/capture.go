@@ -0,0 +1,221 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/wdevore/hardware/gpio"
+)
+
+// ErrNoSignal is returned when a pin doesn't toggle enough to be measured.
+var ErrNoSignal = errors.New("logic: not enough edges to measure")
+
+// Trigger decides, from two consecutive samples of D0-D7, where a capture
+// starts.
+type Trigger func(previous, current byte) bool
+
+// RisingEdge triggers when [pin] goes from Low to High.
+func RisingEdge(pin gpio.Pin) Trigger {
+	mask := byte(1) << pin
+	return func(previous, current byte) bool {
+		return previous&mask == 0 && current&mask != 0
+	}
+}
+
+// FallingEdge triggers when [pin] goes from High to Low.
+func FallingEdge(pin gpio.Pin) Trigger {
+	mask := byte(1) << pin
+	return func(previous, current byte) bool {
+		return previous&mask != 0 && current&mask == 0
+	}
+}
+
+// AnyEdge triggers when [pin] changes.
+func AnyEdge(pin gpio.Pin) Trigger {
+	mask := byte(1) << pin
+	return func(previous, current byte) bool {
+		return (previous^current)&mask != 0
+	}
+}
+
+// Pattern triggers when the pins selected by [mask] become [value].
+func Pattern(mask, value byte) Trigger {
+	return func(previous, current byte) bool {
+		return current&mask == value&mask && previous&mask != value&mask
+	}
+}
+
+// Capture is a record of D0-D7, one byte per sample.
+type Capture struct {
+	// Rate is in samples per second.
+	Rate int
+	// Samples has bit 0 = D0 ... bit 7 = D7.
+	Samples []byte
+	// Trigger is the index of the first sample after the trigger matched,
+	// or -1 when the capture wasn't triggered.
+	Trigger int
+	// Names optionally labels the channels in the VCD, D0-D7 by default.
+	Names [8]string
+}
+
+// Period returns the time between samples.
+func (c *Capture) Period() time.Duration {
+	return time.Second / time.Duration(c.Rate)
+}
+
+// Duration returns the time span of the capture.
+func (c *Capture) Duration() time.Duration {
+	return time.Duration(len(c.Samples)) * c.Period()
+}
+
+// Level returns the state of [pin] at sample [index].
+func (c *Capture) Level(pin gpio.Pin, index int) gpio.PinState {
+	if c.Samples[index]&(1<<pin) != 0 {
+		return gpio.High
+	}
+	return gpio.Low
+}
+
+// ------------------------------------------------------------------------
+// Measurement
+// ------------------------------------------------------------------------
+
+// Measurement describes a periodic signal on one pin.
+type Measurement struct {
+	// Frequency in Hz.
+	Frequency float64
+	// DutyCycle is the fraction of each period the pin is High, 0 to 1.
+	DutyCycle float64
+	// Period is the average time between rising edges.
+	Period time.Duration
+	// Edges is the number of rising edges the averages are made of.
+	Edges int
+}
+
+func (m Measurement) String() string {
+	return fmt.Sprintf("%.3fHz, duty %.1f%%, period %v", m.Frequency, m.DutyCycle*100, m.Period)
+}
+
+// Measure averages the frequency and duty cycle of [pin] over the whole
+// periods in the capture. Resolution is one sample, so signals faster than
+// about a tenth of the sample rate are measured poorly.
+func (c *Capture) Measure(pin gpio.Pin) (Measurement, error) {
+	mask := byte(1) << pin
+
+	first, last := -1, -1
+	edges := 0
+	high := 0
+
+	for idx := 1; idx < len(c.Samples); idx++ {
+		rising := c.Samples[idx-1]&mask == 0 && c.Samples[idx]&mask != 0
+
+		if rising {
+			if first < 0 {
+				first = idx
+			} else {
+				edges++
+			}
+			last = idx
+		}
+
+		// Count High samples inside whole periods only.
+		if first >= 0 && c.Samples[idx]&mask != 0 {
+			high++
+		}
+	}
+
+	if edges < 1 {
+		return Measurement{}, ErrNoSignal
+	}
+
+	// Samples after the last rising edge belong to an incomplete period.
+	for idx := last; idx < len(c.Samples); idx++ {
+		if c.Samples[idx]&mask != 0 {
+			high--
+		}
+	}
+
+	span := last - first
+	period := float64(span) / float64(edges) / float64(c.Rate)
+
+	return Measurement{
+		Frequency: 1 / period,
+		DutyCycle: float64(high) / float64(span),
+		Period:    time.Duration(period * float64(time.Second)),
+		Edges:     edges,
+	}, nil
+}
+
+// ------------------------------------------------------------------------
+// VCD
+// ------------------------------------------------------------------------
+
+// WriteVCD writes the capture as a Value Change Dump that GTKWave, PulseView
+// and most simulators open. Time is in nanoseconds from the first sample.
+func (c *Capture) WriteVCD(w io.Writer) error {
+	if len(c.Samples) == 0 {
+		return ErrNoSamples
+	}
+
+	header := fmt.Sprintf("$date %s $end\n", time.Now().Format(time.RFC1123))
+	header += "$version github.com/wdevore/hardware logic $end\n"
+	header += "$timescale 1ns $end\n"
+	header += "$scope module ft232h $end\n"
+	for pin := 0; pin < 8; pin++ {
+		name := c.Names[pin]
+		if name == "" {
+			name = fmt.Sprintf("D%d", pin)
+		}
+		header += fmt.Sprintf("$var wire 1 %c %s $end\n", vcdID(pin), name)
+	}
+	header += "$upscope $end\n"
+	header += "$enddefinitions $end\n"
+
+	_, err := io.WriteString(w, header)
+	if err != nil {
+		return err
+	}
+
+	values := func(previous, current byte, all bool) string {
+		out := ""
+		for pin := 0; pin < 8; pin++ {
+			mask := byte(1) << uint(pin)
+			if all || (previous^current)&mask != 0 {
+				level := '0'
+				if current&mask != 0 {
+					level = '1'
+				}
+				out += fmt.Sprintf("%c%c\n", level, vcdID(pin))
+			}
+		}
+		return out
+	}
+
+	_, err = io.WriteString(w, "#0\n$dumpvars\n"+values(0, c.Samples[0], true)+"$end\n")
+	if err != nil {
+		return err
+	}
+
+	for idx := 1; idx < len(c.Samples); idx++ {
+		if c.Samples[idx] == c.Samples[idx-1] {
+			continue
+		}
+
+		ns := int64(idx) * int64(time.Second) / int64(c.Rate)
+		_, err = fmt.Fprintf(w, "#%d\n%s", ns, values(c.Samples[idx-1], c.Samples[idx], false))
+		if err != nil {
+			return err
+		}
+	}
+
+	// Mark the end so viewers show the last level for its full duration.
+	_, err = fmt.Fprintf(w, "#%d\n", int64(len(c.Samples))*int64(time.Second)/int64(c.Rate))
+	return err
+}
+
+// vcdID returns the VCD identifier of a channel, '!' for D0 and so on.
+func vcdID(pin int) rune {
+	return rune('!' + pin)
+}
@@ -0,0 +1,519 @@
+package spi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/wdevore/hardware/ftdi"
+	"github.com/wdevore/hardware/gpio"
+)
+
+// ErrDeviceClosed is returned by a bus Device used after Close.
+var ErrDeviceClosed = errors.New("spi: bus device closed")
+
+// Bus shares one FT232H between several SPI devices, for example a display,
+// its breakout's microSD slot and a flash chip. Each device gets a Device
+// handle with its own chip select, capture mode, bit order and clock:
+//
+//	bus, err := spi.NewBus(0x0403, 0x06014, false)
+//	display, err := bus.Device(ftdi.D3, 30000000, spi.Mode0, spi.MSBFirst)
+//	card, err := bus.Device(ftdi.D5, 400000, spi.Mode0, spi.MSBFirst)
+//
+// The devices take turns: each exchange locks the bus and, when the device
+// changed, de-asserts the previous device's chip select then sets the mode
+// and clock for the new one. It is safe for concurrent use.
+type Bus struct {
+	spi *FtdiSPI
+
+	// Guards everything below, and the FtdiSPI.
+	mutex sync.Mutex
+	// free is signaled when holder releases the bus.
+	free *sync.Cond
+	// holder is the device that locked the bus with Device.Lock.
+	holder *Device
+	// active is the device the FtdiSPI is set up for.
+	active *Device
+	// devices counts the devices added, it names their pin claims.
+	devices int
+	// clock is the clock the FtdiSPI is set to.
+	clock int
+}
+
+// NewBus creates a bus on a FTDI232H and configures its MPSSE for SPI.
+// [options] choose a specific device, for example ftdi.WithSerial("FT0RN5XA").
+func NewBus(vender, product int, disableDrivers bool, options ...ftdi.Option) (*Bus, error) {
+	spi, err := NewSPI(vender, product, disableDrivers, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := newBus(spi)
+	if err != nil {
+		spi.Close()
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// NewBusFromFTDI creates a bus on top of an existing FTDI232H, for example
+// one using an injected Transport.
+func NewBusFromFTDI(fi *ftdi.FTDI232H) (*Bus, error) {
+	return newBus(NewSPIFromFTDI(fi))
+}
+
+// busClock is the clock until a device sets its own.
+const busClock = 1000000
+
+func newBus(spi *FtdiSPI) (*Bus, error) {
+	// The devices drive their own chip selects.
+	err := spi.Configure(gpio.NoPin, busClock, Mode0, MSBFirst)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Bus{spi: spi, clock: busClock}
+	b.free = sync.NewCond(&b.mutex)
+
+	return b, nil
+}
+
+// Close closes the FTDI232H, the devices can't be used afterwards.
+func (b *Bus) Close() error {
+	return b.spi.Close()
+}
+
+// DeviceOption configures a Device when it is added to the bus.
+type DeviceOption func(d *Device)
+
+// WithCSActiveHigh makes the device's chip select active high instead of low.
+func WithCSActiveHigh() DeviceOption {
+	return func(d *Device) {
+		d.csActiveLow = false
+	}
+}
+
+// Device adds a device selected by [chipSelect] to the bus. [maxSpeed] is
+// the most the device's clock is set to, ConfigureContext can only lower
+// it. gpio.DefaultPin is D3, the chip select of FtdiSPI.
+func (b *Bus) Device(chipSelect gpio.Pin, maxSpeed int, mode CaptureMode, bitOrder BitOrder, options ...DeviceOption) (*Device, error) {
+	d := &Device{
+		bus:         b,
+		csActiveLow: true,
+		chipSelect:  gpio.NoPin,
+		maxSpeed:    maxSpeed,
+		clock:       maxSpeed,
+		mode:        mode,
+		bitOrder:    bitOrder,
+	}
+	for _, option := range options {
+		option(d)
+	}
+
+	if chipSelect == gpio.DefaultPin || chipSelect == gpio.HardwarePin {
+		chipSelect = ftdi.D3
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.devices++
+	d.owner = fmt.Sprintf("SPI device %d", b.devices)
+
+	err := b.setChipSelect(d, chipSelect)
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// setChipSelect moves [d] to [chipSelect], claiming the pin for it and
+// leaving it de-asserted.
+func (b *Bus) setChipSelect(d *Device, chipSelect gpio.Pin) error {
+	fi := b.spi.ftdi
+
+	if b.active == d && d.selected {
+		b.spi.DeAssertChipSelect()
+	}
+	d.selected = false
+
+	// The owner only holds the chip select.
+	fi.ReleasePins(d.owner)
+
+	if chipSelect != gpio.NoPin {
+		err := fi.ClaimPin(d.owner, chipSelect, gpio.Output)
+		if err != nil {
+			fi.ClaimPin(d.owner, d.chipSelect, gpio.Output)
+			return err
+		}
+
+		fi.SetConfigPin(chipSelect, gpio.Output)
+		if d.csActiveLow {
+			fi.OutputHigh(chipSelect)
+		} else {
+			fi.OutputLow(chipSelect)
+		}
+	}
+
+	d.chipSelect = chipSelect
+	d.changed = true
+
+	return nil
+}
+
+// lock waits until the bus isn't held by another device, then locks it.
+func (b *Bus) lock(d *Device) {
+	b.mutex.Lock()
+	for b.holder != nil && b.holder != d {
+		b.free.Wait()
+	}
+}
+
+// use sets the FtdiSPI up for [d]. The bus is locked.
+func (b *Bus) use(d *Device) error {
+	if d.closed {
+		return ErrDeviceClosed
+	}
+
+	spi := b.spi
+
+	if b.active != d && b.active != nil && b.active.selected {
+		// The FtdiSPI is still set up for the previous device.
+		spi.DeAssertChipSelect()
+	}
+
+	if b.active != d || d.changed {
+		b.active = nil
+
+		spi.chipSelect = d.chipSelect
+		spi.CSActiveLow = d.csActiveLow
+		spi.SetBitOrder(d.bitOrder)
+
+		// Each is a USB write, skipped when the devices agree.
+		if d.mode != spi.mode {
+			err := spi.SetMode(d.mode)
+			if err != nil {
+				return err
+			}
+		}
+		if d.clock != b.clock {
+			err := spi.SetClock(d.clock)
+			if err != nil {
+				return err
+			}
+			b.clock = d.clock
+		}
+
+		if d.selected {
+			spi.AssertChipSelect()
+		}
+		b.active = d
+		d.changed = false
+	}
+
+	spi.manualChipSelect = d.manualChipSelect
+	spi.ConstantCSAssert = d.constantCSAssert
+
+	return nil
+}
+
+// Device is one device on a Bus, it implements Conn, see Bus.Device. Its
+// exchanges are safe for concurrent use with the other devices'.
+//
+// Chip select is asserted around each Tx by default. A device kept
+// selected, by SetConstantCSAssert or AssertChipSelect, is de-asserted while
+// another device uses the bus and asserted again when it gets it back.
+// Sequences that must not be interleaved, such as a SD card command and its
+// response, go between Lock and Unlock.
+type Device struct {
+	bus *Bus
+	// owner claims the chip select pin.
+	owner string
+
+	// Guarded by the bus.
+	chipSelect       gpio.Pin
+	csActiveLow      bool
+	maxSpeed         int
+	clock            int
+	mode             CaptureMode
+	bitOrder         BitOrder
+	manualChipSelect bool
+	constantCSAssert bool
+	// selected is true while chip select is left asserted.
+	selected bool
+	// changed is true when the settings must be applied again.
+	changed bool
+	closed  bool
+}
+
+// ConfigureContext sets the device's chip select, clock, capture mode and
+// bit order. gpio.DefaultPin keeps the chip select given to Bus.Device and
+// [maxSpeed] is lowered to its maximum. The FT232H itself was configured by
+// the Bus.
+func (d *Device) ConfigureContext(ctx context.Context, chipSelect gpio.Pin, maxSpeed int, mode CaptureMode, bitOrder BitOrder) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b := d.bus
+	b.lock(d)
+	defer b.mutex.Unlock()
+
+	if d.closed {
+		return ErrDeviceClosed
+	}
+
+	if chipSelect != gpio.DefaultPin && chipSelect != gpio.HardwarePin && chipSelect != d.chipSelect {
+		err := b.setChipSelect(d, chipSelect)
+		if err != nil {
+			return err
+		}
+	}
+
+	d.clock = d.maxSpeed
+	if maxSpeed > 0 && maxSpeed < d.maxSpeed {
+		d.clock = maxSpeed
+	}
+	d.mode = mode
+	d.bitOrder = bitOrder
+
+	// Applied on the next exchange.
+	d.changed = true
+
+	return nil
+}
+
+// Tx is a write ([r] nil) or a full-duplex transfer of [w], see Conn.
+func (d *Device) Tx(w, r []byte) error {
+	b := d.bus
+	b.lock(d)
+	defer b.mutex.Unlock()
+
+	err := b.use(d)
+	if err != nil {
+		return err
+	}
+
+	return b.spi.Tx(w, r)
+}
+
+// TxBits shifts the first [bits] bits of [w] out, see BitConn.
+func (d *Device) TxBits(w []byte, bits int, r []byte) error {
+	b := d.bus
+	b.lock(d)
+	defer b.mutex.Unlock()
+
+	err := b.use(d)
+	if err != nil {
+		return err
+	}
+
+	return b.spi.TxBits(w, bits, r)
+}
+
+// Lock keeps the bus for this device until Unlock. Meanwhile the other
+// devices wait and this one's chip select stays as it is left.
+func (d *Device) Lock() {
+	b := d.bus
+	b.lock(d)
+	b.holder = d
+	b.mutex.Unlock()
+}
+
+// Unlock lets the other devices use the bus again.
+func (d *Device) Unlock() {
+	b := d.bus
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.holder == d {
+		b.holder = nil
+		b.free.Broadcast()
+	}
+}
+
+// AssertChipSelect selects the device until DeAssertChipSelect.
+func (d *Device) AssertChipSelect() {
+	b := d.bus
+	b.lock(d)
+	defer b.mutex.Unlock()
+
+	if b.use(d) != nil {
+		return
+	}
+
+	d.selected = true
+	b.spi.AssertChipSelect()
+}
+
+// DeAssertChipSelect de-selects the device.
+func (d *Device) DeAssertChipSelect() {
+	b := d.bus
+	b.lock(d)
+	defer b.mutex.Unlock()
+
+	if b.use(d) != nil {
+		return
+	}
+
+	d.selected = false
+	b.spi.DeAssertChipSelect()
+}
+
+// TakeControlOfCS stops Tx from driving chip select, the caller does.
+func (d *Device) TakeControlOfCS() {
+	d.bus.mutex.Lock()
+	defer d.bus.mutex.Unlock()
+	d.manualChipSelect = true
+}
+
+// ReleaseControlOfCS gives chip select back to Tx.
+func (d *Device) ReleaseControlOfCS() {
+	d.bus.mutex.Lock()
+	defer d.bus.mutex.Unlock()
+	d.manualChipSelect = false
+}
+
+// SetConstantCSAssert keeps chip select as it is between the device's
+// exchanges (true) or asserts it for every Tx (false, the default).
+func (d *Device) SetConstantCSAssert(constant bool) {
+	d.bus.mutex.Lock()
+	defer d.bus.mutex.Unlock()
+	d.constantCSAssert = constant
+}
+
+// SetClock sets the device's clock in hertz, at most its maximum. It is
+// applied on the next exchange, as are SetMode and SetBitOrder.
+func (d *Device) SetClock(hz int) error {
+	d.bus.mutex.Lock()
+	defer d.bus.mutex.Unlock()
+
+	if d.closed {
+		return ErrDeviceClosed
+	}
+
+	if hz > d.maxSpeed {
+		hz = d.maxSpeed
+	}
+	d.clock = hz
+	d.changed = true
+
+	return nil
+}
+
+// SetMode sets the device's clock polarity and phase.
+func (d *Device) SetMode(mode CaptureMode) error {
+	d.bus.mutex.Lock()
+	defer d.bus.mutex.Unlock()
+
+	if d.closed {
+		return ErrDeviceClosed
+	}
+
+	d.mode = mode
+	d.changed = true
+
+	return nil
+}
+
+// SetBitOrder sets which bit of a byte the device gets first. It does
+// nothing once the device is closed.
+func (d *Device) SetBitOrder(order BitOrder) {
+	d.bus.mutex.Lock()
+	defer d.bus.mutex.Unlock()
+
+	if d.closed {
+		return
+	}
+
+	d.bitOrder = order
+	d.changed = true
+}
+
+// Line returns a pin of the FTDI232H as a gpio.Line, for example the
+// device's D/C pin.
+func (d *Device) Line(pin gpio.Pin) (gpio.Line, error) {
+	return d.bus.spi.Line(pin)
+}
+
+// OnReconnect runs [hook] after the FTDI232H got its device back, see
+// FtdiSPI.OnReconnect.
+func (d *Device) OnReconnect(hook func(ctx context.Context) error) {
+	d.bus.spi.OnReconnect(hook)
+}
+
+// Close de-selects the device and releases its chip select. The bus and
+// the other devices are left open, see Bus.Close.
+func (d *Device) Close() error {
+	b := d.bus
+	b.lock(d)
+	defer b.mutex.Unlock()
+
+	if d.closed {
+		return nil
+	}
+
+	if b.active == d && d.selected {
+		b.spi.DeAssertChipSelect()
+	}
+	if b.active == d {
+		b.active = nil
+	}
+	if b.holder == d {
+		b.holder = nil
+		b.free.Broadcast()
+	}
+
+	b.spi.ftdi.ReleasePins(d.owner)
+	d.closed = true
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Batch support
+// -----------------------------------------------------------------------------
+
+// NewQueue returns a command queue, see FtdiSPI.NewQueue.
+func (d *Device) NewQueue() *ftdi.Queue {
+	return d.bus.spi.NewQueue()
+}
+
+// FlushQueue locks the bus and sets it up for the device, then runs [fill],
+// which appends to [q], and sends [q]. The Queue methods below are meant
+// for [fill].
+func (d *Device) FlushQueue(ctx context.Context, q *ftdi.Queue, fill func(q *ftdi.Queue)) ([]byte, error) {
+	b := d.bus
+	b.lock(d)
+	defer b.mutex.Unlock()
+
+	err := b.use(d)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.spi.FlushQueue(ctx, q, fill)
+}
+
+// QueueWrite appends a half-duplex write to [q], see FtdiSPI.QueueWrite.
+// The bus must be set up for the device, see FlushQueue.
+func (d *Device) QueueWrite(q *ftdi.Queue, data []byte) {
+	d.bus.spi.QueueWrite(q, data)
+}
+
+// QueueAssertChipSelect appends the device's chip select assertion to [q].
+// The bus must be set up for the device, see FlushQueue.
+func (d *Device) QueueAssertChipSelect(q *ftdi.Queue) {
+	d.selected = true
+	d.bus.spi.QueueAssertChipSelect(q)
+}
+
+// QueueDeAssertChipSelect appends the device's chip select de-assertion to
+// [q]. The bus must be set up for the device, see FlushQueue.
+func (d *Device) QueueDeAssertChipSelect(q *ftdi.Queue) {
+	d.selected = false
+	d.bus.spi.QueueDeAssertChipSelect(q)
+}
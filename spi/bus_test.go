@@ -0,0 +1,215 @@
+package spi_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/wdevore/hardware/ftdi"
+	"github.com/wdevore/hardware/ftdi/sim"
+	"github.com/wdevore/hardware/spi"
+)
+
+// bus returns a bus with a display on D3 and a card on D5 that differ in
+// clock, mode and bit order.
+func bus(t *testing.T) (*spi.Device, *spi.Device, *sim.FT232H) {
+	t.Helper()
+	f, s := sim.NewFTDI232H()
+	b, err := spi.NewBusFromFTDI(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	display, err := b.Device(ftdi.D3, 10000000, spi.Mode0, spi.MSBFirst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	card, err := b.Device(ftdi.D5, 400000, spi.Mode1, spi.LSBFirst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Reset()
+	return display, card, s
+}
+
+// lastShift returns the last shift in [ops] and the low bank level set
+// before it.
+func lastShift(t *testing.T, ops []sim.Op) (sim.Op, byte) {
+	t.Helper()
+	for i := len(ops) - 1; i >= 0; i-- {
+		if ops[i].Opcode >= 0x80 {
+			continue
+		}
+		for j := i - 1; j >= 0; j-- {
+			if ops[j].Opcode == 0x80 {
+				return ops[i], ops[j].Args[0]
+			}
+		}
+		t.Fatalf("no level set before %v", ops[i])
+	}
+	t.Fatalf("no shift in %v", ops)
+	return sim.Op{}, 0
+}
+
+func TestBusSwitch(t *testing.T) {
+	display, card, s := bus(t)
+
+	err := display.Tx([]byte{0x01}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := lastShift(t, s.Ops)
+	if hz := s.Clock(); hz != 10000000 {
+		t.Errorf("display clock %d, want 10000000", hz)
+	}
+
+	err = card.Tx([]byte{0x02}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shift, _ := lastShift(t, s.Ops)
+	if hz := s.Clock(); hz != 400000 {
+		t.Errorf("card clock %d, want 400000", hz)
+	}
+	// Mode 1 writes on the rising edge, LSB first.
+	if shift.Opcode != 0x18 {
+		t.Errorf("card shifted with %v, want opcode 0x18", shift)
+	}
+
+	err = display.Tx([]byte{0x03}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shift, _ = lastShift(t, s.Ops)
+	if hz := s.Clock(); hz != 10000000 {
+		t.Errorf("display clock %d after the card, want 10000000", hz)
+	}
+	if shift.Opcode != first.Opcode {
+		t.Errorf("display shifted with %v after the card, want opcode %#02x", shift, first.Opcode)
+	}
+}
+
+func TestBusChipSelect(t *testing.T) {
+	display, card, s := bus(t)
+
+	display.SetConstantCSAssert(true)
+	display.AssertChipSelect()
+
+	err := card.Tx([]byte{0x01}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, level := lastShift(t, s.Ops)
+	if level&(1<<ftdi.D3) == 0 {
+		t.Error("display still selected while the card shifts")
+	}
+	if level&(1<<ftdi.D5) != 0 {
+		t.Error("card not selected while it shifts")
+	}
+	if s.Level&(1<<ftdi.D5) == 0 {
+		t.Error("card still selected after its Tx")
+	}
+
+	// The display gets its chip select back with the bus.
+	err = display.Tx([]byte{0x02}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, level = lastShift(t, s.Ops)
+	if level&(1<<ftdi.D3) != 0 {
+		t.Error("display not selected again")
+	}
+	if s.Level&(1<<ftdi.D3) != 0 {
+		t.Error("display de-selected after a constant chip select Tx")
+	}
+}
+
+func TestBusLock(t *testing.T) {
+	display, card, s := bus(t)
+
+	display.Lock()
+
+	done := make(chan error)
+	go func() {
+		done <- card.Tx([]byte{0x01}, nil)
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("card used the locked bus: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// The holder keeps using the bus.
+	err := display.Tx([]byte{0x02}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	display.Unlock()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("card still waiting after Unlock")
+	}
+
+	if mosi := s.MOSI(); len(mosi) != 2 || mosi[0] != 0x02 {
+		t.Errorf("MOSI % x, want the display's byte first", mosi)
+	}
+}
+
+func TestBusCloseHolder(t *testing.T) {
+	display, card, _ := bus(t)
+
+	display.Lock()
+	done := make(chan error)
+	go func() {
+		done <- card.Tx([]byte{0x01}, nil)
+	}()
+
+	err := display.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("card still waiting after the holder closed")
+	}
+}
+
+func TestDeviceClosed(t *testing.T) {
+	display, card, s := bus(t)
+
+	err := card.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := card.Tx([]byte{0x01}, nil); !errors.Is(err, spi.ErrDeviceClosed) {
+		t.Errorf("Tx: %v, want ErrDeviceClosed", err)
+	}
+	if err := card.SetClock(100000); !errors.Is(err, spi.ErrDeviceClosed) {
+		t.Errorf("SetClock: %v, want ErrDeviceClosed", err)
+	}
+	if err := card.SetMode(spi.Mode2); !errors.Is(err, spi.ErrDeviceClosed) {
+		t.Errorf("SetMode: %v, want ErrDeviceClosed", err)
+	}
+	card.SetBitOrder(spi.MSBFirst)
+
+	// The other device is unaffected.
+	err = display.Tx([]byte{0x02}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mosi := s.MOSI(); len(mosi) != 1 || mosi[0] != 0x02 {
+		t.Errorf("MOSI % x, want 02", mosi)
+	}
+}
@@ -0,0 +1,251 @@
+package spi
+
+import (
+	"context"
+	"time"
+
+	"github.com/wdevore/hardware/ftdi"
+	"github.com/wdevore/hardware/gpio"
+)
+
+// Conn is a SPI connection as the device drivers see it. FtdiSPI (the
+// FT232H's MPSSE engine) and SoftSPI (bitbang) implement it, so a driver
+// taking a Conn works over either, or over any other implementation.
+//
+// Optional capabilities are separate interfaces checked with a type
+// assertion: gpio.Provider for the driver's extra pins (D/C, reset...),
+// Trigger and Reconnector.
+type Conn interface {
+	// ConfigureContext claims the pins and sets the clock, capture mode and
+	// bit order. gpio.DefaultPin selects the implementation's usual chip
+	// select.
+	ConfigureContext(ctx context.Context, chipSelect gpio.Pin, maxSpeed int, mode CaptureMode, bitOrder BitOrder) error
+
+	// Tx shifts [w] out. If [r] isn't nil, it must be as long as [w] and
+	// receives the bytes shifted in at the same time (full-duplex). Chip
+	// select is asserted around the exchange unless it is held constant or
+	// the caller took control of it.
+	Tx(w, r []byte) error
+
+	// AssertChipSelect drives chip select to its active level.
+	AssertChipSelect()
+	// DeAssertChipSelect drives chip select to its inactive level.
+	DeAssertChipSelect()
+	// TakeControlOfCS stops Tx from driving chip select, the caller does.
+	TakeControlOfCS()
+	// ReleaseControlOfCS gives chip select back to Tx.
+	ReleaseControlOfCS()
+	// SetConstantCSAssert keeps chip select as it is between exchanges
+	// (true) or asserts it for every Tx (false).
+	SetConstantCSAssert(constant bool)
+
+	// SetClock sets the SPI clock in hertz.
+	SetClock(hz int) error
+	// SetMode sets the clock polarity and phase.
+	SetMode(mode CaptureMode) error
+	// SetBitOrder sets which bit of a byte is shifted first.
+	SetBitOrder(order BitOrder)
+
+	// Close releases the connection and its device.
+	Close() error
+}
+
+// BitConn is implemented by connections that can shift a number of bits
+// that isn't a multiple of 8, for example 9-bit display words or a sensor's
+// 12-bit frames. FtdiSPI, SoftSPI and bus Devices implement it.
+type BitConn interface {
+	Conn
+
+	// TxBits shifts the first [bits] bits of [w] out, in a single chip
+	// select frame as Tx. MSB first they are taken from the high end of
+	// each byte, LSB first from the low end, so a partial last byte is left
+	// aligned or right aligned respectively. If [r] isn't nil it receives
+	// the bits shifted in, packed the same way.
+	TxBits(w []byte, bits int, r []byte) error
+}
+
+// Trigger is implemented by connections with a pin dedicated to triggering
+// tools such as a logic analyzer.
+type Trigger interface {
+	// EnableTrigger reserves the trigger pin, call it before configuring.
+	EnableTrigger()
+	// TriggerPulse pulses the trigger pin.
+	TriggerPulse()
+}
+
+// Reconnector is implemented by connections that can get their device back
+// after it was unplugged, see FtdiSPI.OnReconnect.
+type Reconnector interface {
+	OnReconnect(hook func(ctx context.Context) error)
+}
+
+// queuer is implemented by connections that batch their traffic into a
+// ftdi.Queue, Batch uses it instead of running each step in turn.
+type queuer interface {
+	NewQueue() *ftdi.Queue
+	QueueWrite(q *ftdi.Queue, data []byte)
+	QueueAssertChipSelect(q *ftdi.Queue)
+	QueueDeAssertChipSelect(q *ftdi.Queue)
+	// FlushQueue runs [fill], which appends to [q], then sends [q].
+	FlushQueue(ctx context.Context, q *ftdi.Queue, fill func(q *ftdi.Queue)) ([]byte, error)
+}
+
+// Batch collects the line changes, chip select changes, writes and delays of
+// one device update, for example a display's D/C low, command, D/C high and
+// data. Over a FtdiSPI it is a ftdi.Queue, so it reaches the chip in as few
+// USB writes as possible. Over any other Conn Flush runs the steps in turn.
+//
+// A Batch is NOT safe for concurrent use.
+type Batch struct {
+	conn Conn
+
+	// Set when conn batches through a ftdi.Queue.
+	queuer queuer
+	queue  *ftdi.Queue
+
+	steps []batchStep
+	// data holds the bytes of the queued writes, the steps index it.
+	data []byte
+}
+
+type batchOp int
+
+const (
+	batchOutput batchOp = iota
+	batchWrite
+	batchAssert
+	batchDeAssert
+	batchDelay
+)
+
+type batchStep struct {
+	op batchOp
+
+	line  gpio.Line
+	level gpio.PinState
+
+	start, end int
+
+	duration time.Duration
+}
+
+// NewBatch creates an empty batch for [conn].
+func NewBatch(conn Conn) *Batch {
+	b := &Batch{conn: conn}
+	if q, ok := conn.(queuer); ok {
+		b.queuer = q
+		b.queue = q.NewQueue()
+	}
+	return b
+}
+
+// Reset empties the batch without sending anything.
+func (b *Batch) Reset() {
+	b.steps = b.steps[:0]
+	b.data = b.data[:0]
+}
+
+// OutputLine drives [line] to [level] at this point of the batch.
+func (b *Batch) OutputLine(line gpio.Line, level gpio.PinState) {
+	b.steps = append(b.steps, batchStep{op: batchOutput, line: line, level: level})
+}
+
+// Write appends a half-duplex write of [data], including the chip select
+// handling of Tx. [data] is copied so the caller may reuse it.
+func (b *Batch) Write(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	start := len(b.data)
+	b.data = append(b.data, data...)
+	b.steps = append(b.steps, batchStep{op: batchWrite, start: start, end: len(b.data)})
+}
+
+// AssertChipSelect appends a chip select assertion.
+func (b *Batch) AssertChipSelect() {
+	b.steps = append(b.steps, batchStep{op: batchAssert})
+}
+
+// DeAssertChipSelect appends a chip select de-assertion.
+func (b *Batch) DeAssertChipSelect() {
+	b.steps = append(b.steps, batchStep{op: batchDeAssert})
+}
+
+// Delay inserts a pause.
+func (b *Batch) Delay(duration time.Duration) {
+	b.steps = append(b.steps, batchStep{op: batchDelay, duration: duration})
+}
+
+// Flush sends the batch. The batch is empty afterwards.
+func (b *Batch) Flush() error {
+	return b.FlushContext(context.Background())
+}
+
+// FlushContext is Flush but the delays end early when [ctx] is canceled or
+// its deadline passes. Steps already sent are not undone.
+func (b *Batch) FlushContext(ctx context.Context) error {
+	defer b.Reset()
+
+	if b.queuer != nil {
+		// The queue is filled when the connection is ready to send it, a
+		// bus Device only then has the bus set up for its device.
+		_, err := b.queuer.FlushQueue(ctx, b.queue, b.fill)
+		return err
+	}
+
+	for _, step := range b.steps {
+		var err error
+
+		switch step.op {
+		case batchOutput:
+			err = step.line.Out(step.level)
+		case batchWrite:
+			err = b.conn.Tx(b.data[step.start:step.end], nil)
+		case batchAssert:
+			b.conn.AssertChipSelect()
+		case batchDeAssert:
+			b.conn.DeAssertChipSelect()
+		case batchDelay:
+			err = sleepContext(ctx, step.duration)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fill appends the steps to [q].
+func (b *Batch) fill(q *ftdi.Queue) {
+	q.Reset()
+
+	for _, step := range b.steps {
+		switch step.op {
+		case batchOutput:
+			q.OutputLine(step.line, step.level)
+		case batchWrite:
+			b.queuer.QueueWrite(q, b.data[step.start:step.end])
+		case batchAssert:
+			b.queuer.QueueAssertChipSelect(q)
+		case batchDeAssert:
+			b.queuer.QueueDeAssertChipSelect(q)
+		case batchDelay:
+			q.Delay(step.duration)
+		}
+	}
+}
+
+// sleepContext sleeps for [duration] or until [ctx] is done.
+func sleepContext(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,152 @@
+package spi
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/wdevore/hardware/gpio"
+)
+
+// NineBit is a 3-wire display transport: each byte goes out as a 9-bit word
+// whose first bit selects data (1) or command (0), which frees the D/C pin.
+// It implements Conn over a BitConn and the D/C "pin" is the gpio.Line
+// returned by DC, so the TFT drivers use it unchanged:
+//
+//	nb := spi.NewNineBit(ftdiSPI)
+//	st := st7735.NewST7735RLines(nb.DC(), reset, devices.GreenTab, dimensions)
+//	err = st.InitializeConn(ctx, nb, 16000000, gpio.DefaultPin, devices.Orientation0, devices.RGBOrder)
+//
+// Words are sent MSB first and packed back to back, 8 words in 9 bytes. It
+// is safe for concurrent use.
+type NineBit struct {
+	BitConn
+
+	mutex sync.Mutex
+	// data is the D/C level, true for data.
+	data bool
+	// buffer holds the packed words.
+	buffer []byte
+}
+
+// NewNineBit creates a 9-bit transport over [conn].
+func NewNineBit(conn BitConn) *NineBit {
+	return &NineBit{BitConn: conn}
+}
+
+// DC returns the line selecting command (Low) or data (High) for the next
+// words. Driving it sends nothing, it only sets their 9th bit.
+func (nb *NineBit) DC() gpio.Line {
+	return nineBitDC{nb}
+}
+
+// ConfigureContext configures the connection, see Conn. [bitOrder] is
+// ignored, the words are MSB first.
+func (nb *NineBit) ConfigureContext(ctx context.Context, chipSelect gpio.Pin, maxSpeed int, mode CaptureMode, bitOrder BitOrder) error {
+	return nb.BitConn.ConfigureContext(ctx, chipSelect, maxSpeed, mode, MSBFirst)
+}
+
+// SetBitOrder does nothing, the words are MSB first.
+func (nb *NineBit) SetBitOrder(order BitOrder) {
+}
+
+// Tx sends each byte of [w] as a 9-bit word with the D/C bit in front, in
+// one chip select frame. 3-wire displays share one line for both
+// directions, so [r] must be nil.
+func (nb *NineBit) Tx(w, r []byte) error {
+	if r != nil {
+		return errors.New("spi: 9-bit transport can't read")
+	}
+	if len(w) == 0 {
+		return nil
+	}
+
+	nb.mutex.Lock()
+	defer nb.mutex.Unlock()
+
+	var dc uint32
+	if nb.data {
+		dc = 1 << 8
+	}
+
+	nb.buffer = nb.buffer[:0]
+
+	// Words go in at the bottom of acc, bytes come out at its top.
+	var acc uint32
+	bits := uint(0)
+	for _, b := range w {
+		acc = acc<<9 | dc | uint32(b)
+		bits += 9
+		for bits >= 8 {
+			bits -= 8
+			nb.buffer = append(nb.buffer, byte(acc>>bits))
+		}
+	}
+	if bits > 0 {
+		// The last byte is partial, left aligned.
+		nb.buffer = append(nb.buffer, byte(acc<<(8-bits)))
+	}
+
+	return nb.BitConn.TxBits(nb.buffer, 9*len(w), nil)
+}
+
+// Line returns a pin of the connection, for example the display's reset,
+// if it is a gpio.Provider.
+func (nb *NineBit) Line(pin gpio.Pin) (gpio.Line, error) {
+	provider, ok := nb.BitConn.(gpio.Provider)
+	if !ok {
+		return nil, errors.New("spi: the 9-bit transport's connection has no pins")
+	}
+	return provider.Line(pin)
+}
+
+// OnReconnect runs [hook] after the connection got its device back, if it
+// is a Reconnector.
+func (nb *NineBit) OnReconnect(hook func(ctx context.Context) error) {
+	if reconnector, ok := nb.BitConn.(Reconnector); ok {
+		reconnector.OnReconnect(hook)
+	}
+}
+
+// nineBitDC is the D/C line of a NineBit.
+type nineBitDC struct {
+	nb *NineBit
+}
+
+func (l nineBitDC) String() string {
+	return "9-bit D/C"
+}
+
+// Out selects command (Low) or data (High).
+func (l nineBitDC) Out(level gpio.PinState) error {
+	l.nb.mutex.Lock()
+	defer l.nb.mutex.Unlock()
+	l.nb.data = level == gpio.High
+	return nil
+}
+
+// In returns the D/C level.
+func (l nineBitDC) In() (gpio.PinState, error) {
+	l.nb.mutex.Lock()
+	defer l.nb.mutex.Unlock()
+	if l.nb.data {
+		return gpio.High, nil
+	}
+	return gpio.Low, nil
+}
+
+// Toggle switches between command and data.
+func (l nineBitDC) Toggle() error {
+	l.nb.mutex.Lock()
+	defer l.nb.mutex.Unlock()
+	l.nb.data = !l.nb.data
+	return nil
+}
+
+// Pulse selects [level], waits [duration] then selects the other one.
+func (l nineBitDC) Pulse(level gpio.PinState, duration time.Duration) error {
+	l.Out(level)
+	time.Sleep(duration)
+	return l.Out(level.Invert())
+}
@@ -0,0 +1,104 @@
+package spi_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/wdevore/hardware/ftdi/sim"
+	"github.com/wdevore/hardware/gpio"
+	"github.com/wdevore/hardware/spi"
+)
+
+// nineBits packs [words] as 9-bit words behind [dc], MSB first, the
+// last byte left aligned.
+func nineBits(dc bool, words ...byte) []byte {
+	var bits []bool
+	for _, w := range words {
+		bits = append(bits, dc)
+		for i := 7; i >= 0; i-- {
+			bits = append(bits, w&(1<<uint(i)) != 0)
+		}
+	}
+
+	packed := make([]byte, (len(bits)+7)/8)
+	for i, bit := range bits {
+		if bit {
+			packed[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+	return packed
+}
+
+// shifted returns the bytes and bits clocked out by [ops].
+func shifted(ops []sim.Op) ([]byte, int) {
+	var out []byte
+	bits := 0
+	for _, op := range ops {
+		if op.Opcode < 0x80 {
+			out = append(out, op.Out...)
+			bits += op.Bits
+		}
+	}
+	return out, bits
+}
+
+func TestNineBit(t *testing.T) {
+	tests := []struct {
+		data  bool
+		words []byte
+	}{
+		{false, []byte{0x2a}},
+		{true, []byte{0x12, 0x34}},
+		{true, []byte{0x00, 0xff, 0x00, 0xff, 0x00, 0xff, 0x00, 0xff}},
+		{false, []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09}},
+	}
+
+	for _, test := range tests {
+		conn, s := configured(t, spi.Mode0, spi.MSBFirst)
+		nb := spi.NewNineBit(conn)
+
+		level := gpio.Low
+		if test.data {
+			level = gpio.High
+		}
+		err := nb.DC().Out(level)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = nb.Tx(test.words, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out, bits := shifted(s.Ops)
+		if want := nineBits(test.data, test.words...); !bytes.Equal(out, want) {
+			t.Errorf("words % x: sent % x, want % x", test.words, out, want)
+		}
+		if bits != 9*len(test.words) {
+			t.Errorf("words % x: %d bits, want %d", test.words, bits, 9*len(test.words))
+		}
+		// All in one chip select frame.
+		if !selected(s.Ops[0]) || selected(s.Ops[len(s.Ops)-2]) {
+			t.Errorf("words % x: chip select isn't asserted around the words", test.words)
+		}
+	}
+}
+
+func TestNineBitDC(t *testing.T) {
+	conn, _ := configured(t, spi.Mode0, spi.MSBFirst)
+	nb := spi.NewNineBit(conn)
+	dc := nb.DC()
+
+	if level, _ := dc.In(); level != gpio.Low {
+		t.Errorf("D/C starts %v, want Low", level)
+	}
+	dc.Toggle()
+	if level, _ := dc.In(); level != gpio.High {
+		t.Errorf("D/C %v after Toggle, want High", level)
+	}
+
+	if err := nb.Tx([]byte{0x01}, make([]byte, 1)); err == nil {
+		t.Error("9-bit transport read")
+	}
+}
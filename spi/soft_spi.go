@@ -5,8 +5,10 @@ package spi
 // toggle is 12KHz on the FTDI232H!
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/wdevore/hardware/ftdi"
@@ -28,7 +30,15 @@ const (
 	SoftLSBFirst
 )
 
-// SoftSPI is an emulation
+// softFrameSize bounds the pin states buffered before they are written.
+const softFrameSize = 4096
+
+// softStatesPerBit is the number of pin states shift writes for each bit.
+const softStatesPerBit = 3
+
+// SoftSPI is an emulation, it implements Conn by bit banging D0-D7.
+//
+// Tx and the Lines are safe for concurrent use.
 type SoftSPI struct {
 	// SPI is-a protocol facilitated by FTDI232 device
 	ftdi *ftdi.FTDI232H
@@ -48,7 +58,14 @@ type SoftSPI struct {
 	rst  gpio.Pin // Output = D4
 	trig gpio.Pin // Output = D7
 
+	// Guards pins, outputs and frame.
+	mutex sync.Mutex
+
 	pins byte
+	// outputs is the bitbang direction mask, 1 = output.
+	outputs byte
+	// frame buffers pin states so a write reaches the chip in one USB write.
+	frame []byte
 
 	// CSActiveLow is chip select active high(false) or low(true)
 	CSActiveLow bool
@@ -58,75 +75,160 @@ type SoftSPI struct {
 	// slaves which means you want to assert on every call to make sure you are
 	// targeting the tft. The default = true.
 	ConstantCSAssert bool
+	manualChipSelect bool
 
+	mode     CaptureMode
 	bitOrder BitOrder
 }
 
 // NewSoftSPI creates an SPI FTDI component
-func NewSoftSPI(vender, product int, disableDrivers bool) *SoftSPI {
+func NewSoftSPI(vender, product int, disableDrivers bool, options ...ftdi.Option) (*SoftSPI, error) {
 	spi := new(SoftSPI)
 
 	spi.ConstantCSAssert = false
+	spi.defaultPins()
 
-	spi.ftdi = new(ftdi.FTDI232H)
-
-	spi.ftdi.SetTarget(vender, product)
+	spi.ftdi = ftdi.NewFTDI232H(vender, product, options...)
 
 	err := spi.ftdi.Initialize(disableDrivers)
 
 	if err != nil {
-		log.Fatal(err)
-		return nil
+		return nil, err
 	}
 
+	return spi, nil
+}
+
+// NewSoftSPIFromFTDI creates a SoftSPI component on top of an existing FTDI232H,
+// for example one using an injected Transport.
+func NewSoftSPIFromFTDI(fi *ftdi.FTDI232H) *SoftSPI {
+	spi := new(SoftSPI)
+
+	spi.ConstantCSAssert = false
+	spi.defaultPins()
+
+	spi.ftdi = fi
+
 	return spi
 }
 
+func (sopi *SoftSPI) defaultPins() {
+	sopi.clk = ftdi.D0
+	sopi.miso = ftdi.D1
+	sopi.mosi = ftdi.D2
+	sopi.cs = ftdi.D3
+	sopi.rst = ftdi.D4
+	sopi.trig = ftdi.D7
+}
+
+// SetPins moves the SPI lines, call it before Configure. The defaults are
+// D0-D4 and D7. [rst] and [trig] may be gpio.NoPin. Every pin must be one
+// of D0-D7 since bitbang mode only drives the low byte.
+func (sopi *SoftSPI) SetPins(clk, miso, mosi, cs, rst, trig gpio.Pin) error {
+	for _, pin := range []gpio.Pin{clk, miso, mosi, cs} {
+		if pin > ftdi.D7 {
+			return fmt.Errorf("soft SPI: pin %s isn't one of D0-D7", ftdi.PinName(pin))
+		}
+	}
+	for _, pin := range []gpio.Pin{rst, trig} {
+		if pin != gpio.NoPin && pin > ftdi.D7 {
+			return fmt.Errorf("soft SPI: pin %s isn't one of D0-D7", ftdi.PinName(pin))
+		}
+	}
+
+	sopi.clk = clk
+	sopi.miso = miso
+	sopi.mosi = mosi
+	sopi.cs = cs
+	sopi.rst = rst
+	sopi.trig = trig
+
+	return nil
+}
+
 // Configure sets up pins and various stuff
 func (sopi *SoftSPI) Configure(maxSpeed int, bitOrder BitOrder) error {
+	return sopi.ConfigureContext(context.Background(), gpio.DefaultPin, maxSpeed, sopi.mode, bitOrder)
+}
+
+// ConfigureContext is Configure with the chip select and capture mode of
+// Conn. gpio.DefaultPin keeps the chip select given to SetPins, D3 unless
+// moved, gpio.NoPin leaves it to the caller.
+func (sopi *SoftSPI) ConfigureContext(ctx context.Context, chipSelect gpio.Pin, maxSpeed int, mode CaptureMode, bitOrder BitOrder) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	switch {
+	case chipSelect == gpio.DefaultPin || chipSelect == gpio.HardwarePin:
+	case chipSelect == gpio.NoPin || chipSelect <= ftdi.D7:
+		sopi.cs = chipSelect
+	default:
+		return fmt.Errorf("soft SPI: pin %s isn't one of D0-D7", ftdi.PinName(chipSelect))
+	}
+
 	err := sopi.ftdi.SoftConfigure(false)
 	if err != nil {
 		log.Println("SPI failed to configure.")
 		return err
 	}
 
-	sopi.CSActiveLow = true // Default for SPI protocol
+	sopi.mutex.Lock()
+	defer sopi.mutex.Unlock()
 
-	sopi.clk = ftdi.D0
-	sopi.miso = ftdi.D1
-	sopi.mosi = ftdi.D2
-	sopi.cs = ftdi.D3
-	sopi.rst = ftdi.D4
-	sopi.trig = ftdi.D7
+	// Only MISO is an input, until a Line is read.
+	sopi.outputs = 0xff &^ (1 << sopi.miso)
+	err = sopi.ftdi.SetBitmode(sopi.outputs, ftdi.ModeBitbang)
+	if err != nil {
+		return err
+	}
 
+	sopi.CSActiveLow = true // Default for SPI protocol
+
+	sopi.mode = mode
 	sopi.bitOrder = bitOrder
 
 	pins := []gpio.PinConfiguration{
-		{Pin: sopi.clk, Direction: gpio.Output, Value: gpio.Low},
+		{Pin: sopi.clk, Direction: gpio.Output, Value: sopi.clockIdle()},
 		{Pin: sopi.miso, Direction: gpio.Input, Value: gpio.Z},
 		{Pin: sopi.mosi, Direction: gpio.Output, Value: gpio.Low},
 		{Pin: sopi.cs, Direction: gpio.Output, Value: gpio.High},
 		{Pin: sopi.rst, Direction: gpio.Output, Value: gpio.High},
 		{Pin: sopi.trig, Direction: gpio.Output, Value: gpio.Low},
 	}
+
+	// A previous Configure may have used another chip select.
+	sopi.ftdi.ReleasePins("soft SPI")
+	err = sopi.ftdi.ClaimPins("soft SPI", pins)
+	if err != nil {
+		return err
+	}
 	// In Bitbang mode the directions are ignored. You can read all the
 	// pins at once regardless of direction.
 	sopi.ConfigPins(pins)
 
 	// Initialize clock, mode, and bit order.
 	// log.Printf("SPI Setting clock speed to (%d)MHz\n", maxSpeed/1000000)
-	sopi.SetClock(maxSpeed)
-
-	// log.Println("SPI Setting bit order")
-	sopi.SetBitOrder(bitOrder)
+	err = sopi.SetClock(maxSpeed)
+	if err != nil {
+		return err
+	}
 
-	fmt.Printf("Default pin values: %08b\n", sopi.pins)
-	sopi.ftdi.WriteByte(sopi.pins)
+	err = sopi.ftdi.WriteByte(sopi.pins)
+	if err != nil {
+		return err
+	}
 
 	// Give time for the GPIO pins to stablize.
-	time.Sleep(time.Millisecond)
+	return sleepContext(ctx, time.Millisecond)
+}
 
-	return nil
+// clockIdle is the clock level between bits, the polarity of the mode.
+func (sopi *SoftSPI) clockIdle() gpio.PinState {
+	if sopi.mode == Mode2 || sopi.mode == Mode3 {
+		return gpio.High
+	}
+	return gpio.Low
 }
 
 // Close closes the FTDI232 device
@@ -144,20 +246,199 @@ func (sopi *SoftSPI) Close() error {
 
 // SetClock sets the speed of the SPI clock in hertz.  Note that not all speeds
 // are supported and a lower speed might be chosen by the hardware.
-func (sopi *SoftSPI) SetClock(hz int) {
-	sopi.ftdi.SetClock(hz, false, false)
+// In bitbang mode the MPSSE divisor has no effect, the clock is the rate the
+// pin states are written at, softStatesPerBit per bit.
+func (sopi *SoftSPI) SetClock(hz int) error {
+	return sopi.ftdi.SetBaudrate(hz * softStatesPerBit)
+}
+
+// SetMode sets the clock polarity and phase, see CaptureMode. The clock
+// moves to its new idle level with the next exchange.
+func (sopi *SoftSPI) SetMode(mode CaptureMode) error {
+	sopi.mutex.Lock()
+	defer sopi.mutex.Unlock()
+	sopi.mode = mode
+	return nil
 }
 
 // SetBitOrder sets the order of bits to be read/written over serial lines.  Should be
 // either MSBFIRST for most-significant first, or LSBFIRST for
 // least-signifcant first.
 func (sopi *SoftSPI) SetBitOrder(order BitOrder) {
+	sopi.mutex.Lock()
+	defer sopi.mutex.Unlock()
 	sopi.bitOrder = order
 }
 
+// SetConstantCSAssert sets ConstantCSAssert.
+func (sopi *SoftSPI) SetConstantCSAssert(constant bool) {
+	sopi.ConstantCSAssert = constant
+}
+
+// TakeControlOfCS allows user to take control of CS
+func (sopi *SoftSPI) TakeControlOfCS() {
+	sopi.manualChipSelect = true
+}
+
+// ReleaseControlOfCS allows user to return control back to SPI
+func (sopi *SoftSPI) ReleaseControlOfCS() {
+	sopi.manualChipSelect = false
+}
+
+// Tx is a write ([r] nil) or a full-duplex transfer of [w], see Conn. Each
+// bit honors the capture mode and bit order. A write is sent as one USB
+// write, a transfer reads the pins after every sampling edge.
+func (sopi *SoftSPI) Tx(w, r []byte) error {
+	if r != nil && len(r) != len(w) {
+		return fmt.Errorf("soft SPI: read buffer of %d bytes for a %d bytes transfer", len(r), len(w))
+	}
+
+	sopi.mutex.Lock()
+	defer sopi.mutex.Unlock()
+
+	chipSelect := !sopi.manualChipSelect && !sopi.ConstantCSAssert
+	if chipSelect {
+		sopi.assertChipSelect()
+		sopi.push()
+	}
+
+	for i, data := range w {
+		in, err := sopi.shift(data, 8, r != nil)
+		if err != nil {
+			sopi.frame = sopi.frame[:0]
+			return err
+		}
+		if r != nil {
+			r[i] = in
+		}
+	}
+
+	if chipSelect {
+		sopi.deAssertChipSelect()
+		sopi.push()
+	}
+
+	return sopi.flush()
+}
+
+// TxBits shifts the first [bits] bits of [w] out, see BitConn.
+func (sopi *SoftSPI) TxBits(w []byte, bits int, r []byte) error {
+	n, err := checkBits(w, bits, r)
+	if err != nil || n == 0 {
+		return err
+	}
+
+	sopi.mutex.Lock()
+	defer sopi.mutex.Unlock()
+
+	chipSelect := !sopi.manualChipSelect && !sopi.ConstantCSAssert
+	if chipSelect {
+		sopi.assertChipSelect()
+		sopi.push()
+	}
+
+	for i := 0; i < n; i++ {
+		count := 8
+		if i == n-1 && bits%8 != 0 {
+			count = bits % 8
+		}
+
+		in, err := sopi.shift(w[i], count, r != nil)
+		if err != nil {
+			sopi.frame = sopi.frame[:0]
+			return err
+		}
+		if r != nil {
+			r[i] = in
+		}
+	}
+
+	if chipSelect {
+		sopi.deAssertChipSelect()
+		sopi.push()
+	}
+
+	return sopi.flush()
+}
+
+// shift clocks [bits] bits of [data] out MOSI, and in from MISO when [read]
+// is set. MSB first they are the high bits of [data], LSB first the low
+// ones.
+func (sopi *SoftSPI) shift(data byte, bits int, read bool) (byte, error) {
+	idle := sopi.clockIdle()
+	active := idle.Invert()
+	phase := sopi.mode == Mode1 || sopi.mode == Mode3
+
+	var in byte
+	for i := 0; i < bits; i++ {
+		n := uint(7 - i)
+		if sopi.bitOrder == LSBFirst {
+			n = uint(i)
+		}
+
+		if phase {
+			// MOSI changes on the leading edge, the trailing one samples.
+			sopi.setPin(sopi.clk, active)
+			sopi.push()
+			sopi.setPin(sopi.mosi, gpio.PinState(data>>n&1))
+			sopi.push()
+			sopi.setPin(sopi.clk, idle)
+			sopi.push()
+		} else {
+			// MOSI is set up before the leading edge, which samples.
+			sopi.setPin(sopi.mosi, gpio.PinState(data>>n&1))
+			sopi.push()
+			sopi.setPin(sopi.clk, active)
+			sopi.push()
+		}
+
+		if read {
+			err := sopi.flush()
+			if err != nil {
+				return 0, err
+			}
+
+			pins, err := sopi.ftdi.PinsRead()
+			if err != nil {
+				return 0, err
+			}
+			in |= (pins >> sopi.miso & 1) << n
+		}
+
+		if !phase {
+			sopi.setPin(sopi.clk, idle)
+			sopi.push()
+		}
+
+		if len(sopi.frame) >= softFrameSize {
+			err := sopi.flush()
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return in, nil
+}
+
+// push buffers the current pin states.
+func (sopi *SoftSPI) push() {
+	sopi.frame = append(sopi.frame, sopi.pins)
+}
+
+// flush writes the buffered pin states.
+func (sopi *SoftSPI) flush() error {
+	if len(sopi.frame) == 0 {
+		return nil
+	}
+	_, err := sopi.ftdi.Write(sopi.frame)
+	sopi.frame = sopi.frame[:0]
+	return err
+}
+
 func (sopi *SoftSPI) ConfigPins(pins []gpio.PinConfiguration) {
 	for _, o := range pins {
-		if o.Value != gpio.Z {
+		if o.Value != gpio.Z && o.Pin != gpio.NoPin {
 			sopi.setPin(o.Pin, o.Value)
 		}
 	}
@@ -165,38 +446,41 @@ func (sopi *SoftSPI) ConfigPins(pins []gpio.PinConfiguration) {
 
 // Write sends a byte-bit sequence out the MOSI pin.
 // This is a Half-duplex SPI write.
+//
+// Deprecated: Tx honors the capture mode, bit order and chip select.
 func (sopi *SoftSPI) Write(data byte) error {
-	// fmt.Printf("Writing: %x, %08b\n", data, data)
+	sopi.mutex.Lock()
+	defer sopi.mutex.Unlock()
 
 	// Send bits 7..0 on MOSI pin
 	for i := 0; i < 8; i++ {
-		bit := data & 0x80
-		if bit > 0 {
-			// fmt.Printf("High-")
+		if data&0x80 != 0 {
 			sopi.setHigh(sopi.mosi)
-			sopi.ftdi.WriteByte(sopi.pins)
 		} else {
-			// fmt.Printf("Low-")
 			sopi.setLow(sopi.mosi)
-			sopi.ftdi.WriteByte(sopi.pins)
+		}
+		err := sopi.ftdi.WriteByte(sopi.pins)
+		if err != nil {
+			return err
 		}
 
 		// Pulse clk-pin to indicate bit value should be sampled/read.
 		sopi.setLow(sopi.clk)
-		sopi.ftdi.WriteByte(sopi.pins)
+		err = sopi.ftdi.WriteByte(sopi.pins)
+		if err != nil {
+			return err
+		}
 		sopi.setHigh(sopi.clk)
-		sopi.ftdi.WriteByte(sopi.pins)
+		err = sopi.ftdi.WriteByte(sopi.pins)
+		if err != nil {
+			return err
+		}
 
 		data = data << 1
-		// fmt.Printf("data: %x, %08b, %d\n", data, data, bit)
 	}
-	// if err != nil {
-	// 	return err
-	// }
-	sopi.setLow(sopi.clk)
-	sopi.ftdi.WriteByte(sopi.pins)
 
-	return nil
+	sopi.setLow(sopi.clk)
+	return sopi.ftdi.WriteByte(sopi.pins)
 }
 
 // Half-duplex SPI read. The specified length of bytes will be clocked
@@ -205,18 +489,23 @@ func (sopi *SoftSPI) Read() (byte, error) {
 
 	// Read response bytes.
 	response, err := sopi.ftdi.PollRead(1, -1)
-	fmt.Printf("Soft read: %v\n", response)
+	if err != nil {
+		return 0, err
+	}
 
-	return response[0], err
+	return response[0], nil
 }
 
 // IsPinHigh returns true is pin is High and false for Low.
 func (sopi *SoftSPI) IsPinHigh(pin gpio.Pin) bool {
-	return sopi.pins&(1<<pin) == 1
+	return sopi.pins&(1<<pin) != 0
 }
 
 // TogglePin toggles pin to the opposite state.
 func (sopi *SoftSPI) TogglePin(pin gpio.Pin) {
+	sopi.mutex.Lock()
+	defer sopi.mutex.Unlock()
+
 	// Capture original state
 	if sopi.IsPinHigh(pin) {
 		sopi.setLow(pin)
@@ -235,6 +524,9 @@ func (sopi *SoftSPI) PulsePin(pin gpio.Pin) {
 }
 
 func (sopi *SoftSPI) SetReset(state bool) {
+	sopi.mutex.Lock()
+	defer sopi.mutex.Unlock()
+
 	if state {
 		sopi.setHigh(sopi.rst)
 	} else {
@@ -247,26 +539,31 @@ func (sopi *SoftSPI) SetReset(state bool) {
 // clocked out the MOSI line, while simultaneously bytes will be read from
 // the MISO line.  Read bytes will be returned as a bytearray object.
 // transferCommand could be a value of 0x30 for most devices.
+//
+// Deprecated: Tx honors the capture mode, bit order and chip select.
 func (sopi *SoftSPI) Transfer(data byte) (byte, error) {
-	var err error
+	sopi.mutex.Lock()
+	defer sopi.mutex.Unlock()
+
 	var response byte
 
-	// sopi.Write(data)
 	for i := 0; i < 8; i++ {
-		bit := data & 0x80
-		if bit > 0 {
-			// fmt.Printf("High-")
+		if data&0x80 != 0 {
 			sopi.setHigh(sopi.mosi)
-			sopi.ftdi.WriteByte(sopi.pins)
 		} else {
-			// fmt.Printf("Low-")
 			sopi.setLow(sopi.mosi)
-			sopi.ftdi.WriteByte(sopi.pins)
+		}
+		err := sopi.ftdi.WriteByte(sopi.pins)
+		if err != nil {
+			return 0, err
 		}
 
 		// Pulse clk-pin to indicate bit value should be sampled/read.
 		sopi.setHigh(sopi.clk)
-		sopi.ftdi.WriteByte(sopi.pins)
+		err = sopi.ftdi.WriteByte(sopi.pins)
+		if err != nil {
+			return 0, err
+		}
 
 		response, err = sopi.ftdi.PinsRead()
 		if err != nil {
@@ -274,43 +571,51 @@ func (sopi *SoftSPI) Transfer(data byte) (byte, error) {
 		}
 
 		sopi.setLow(sopi.clk)
-		sopi.ftdi.WriteByte(sopi.pins)
+		err = sopi.ftdi.WriteByte(sopi.pins)
+		if err != nil {
+			return 0, err
+		}
 
 		data = data << 1
-		// fmt.Printf("data: %x, %08b, %d\n", data, data, bit)
 	}
 
-	// Read response bytes.
-	// response, err := sopi.ftdi.PollRead(1, -1)
-
-	// if err != nil {
-	// 	log.Printf("SPI: Transfer pollread failed on data (%v)\n", data)
-	// 	log.Println(err)
-	// }
-
 	return response, nil
 }
 
 // AssertChipSelect will toggle chip select low or high depending on Active configuration
 func (sopi *SoftSPI) AssertChipSelect() {
+	sopi.mutex.Lock()
+	defer sopi.mutex.Unlock()
+
 	// log.Println("SPI asserting chip select")
+	sopi.assertChipSelect()
+	sopi.ftdi.WriteByte(sopi.pins)
+}
+
+// DeAssertChipSelect will toggle chip select low or high depending on Active configuration
+func (sopi *SoftSPI) DeAssertChipSelect() {
+	sopi.mutex.Lock()
+	defer sopi.mutex.Unlock()
+
+	// log.Println("SPI DE-asserting chip select")
+	sopi.deAssertChipSelect()
+	sopi.ftdi.WriteByte(sopi.pins)
+}
+
+func (sopi *SoftSPI) assertChipSelect() {
 	if sopi.CSActiveLow {
 		sopi.setLow(sopi.cs)
 	} else {
 		sopi.setHigh(sopi.cs)
 	}
-	sopi.ftdi.WriteByte(sopi.pins)
 }
 
-// DeAssertChipSelect will toggle chip select low or high depending on Active configuration
-func (sopi *SoftSPI) DeAssertChipSelect() {
-	// log.Println("SPI DE-asserting chip select")
+func (sopi *SoftSPI) deAssertChipSelect() {
 	if sopi.CSActiveLow {
 		sopi.setHigh(sopi.cs)
 	} else {
 		sopi.setLow(sopi.cs)
 	}
-	sopi.ftdi.WriteByte(sopi.pins)
 }
 
 func (sopi *SoftSPI) setPin(pin gpio.Pin, state gpio.PinState) {
@@ -329,10 +634,127 @@ func (sopi *SoftSPI) setHigh(pin gpio.Pin) {
 	sopi.pins |= (1 << pin) & 0xff
 }
 
+// ----------------------------------------------------------------------------------
+// Lines
+// ----------------------------------------------------------------------------------
+
+// Line returns one of D0-D7 as a gpio.Line driven in bitbang mode, for
+// example a display's D/C pin, which makes SoftSPI a gpio.Provider. The
+// lines aren't claimed, in bitbang mode SoftSPI owns the whole low byte.
+func (sopi *SoftSPI) Line(pin gpio.Pin) (gpio.Line, error) {
+	if pin > ftdi.D7 {
+		return nil, fmt.Errorf("soft SPI: %w: %s isn't one of D0-D7", ftdi.ErrNotGPIO, ftdi.PinName(pin))
+	}
+	return &softLine{sopi: sopi, pin: pin}, nil
+}
+
+// direction makes [pin] an input or an output, changing the bitbang
+// direction mask when needed.
+func (sopi *SoftSPI) direction(pin gpio.Pin, direction gpio.IODirection) error {
+	outputs := sopi.outputs | 1<<pin
+	if direction == gpio.Input {
+		outputs = sopi.outputs &^ (1 << pin)
+	}
+	if outputs == sopi.outputs {
+		return nil
+	}
+
+	err := sopi.ftdi.SetBitmode(outputs, ftdi.ModeBitbang)
+	if err != nil {
+		return err
+	}
+	sopi.outputs = outputs
+	return nil
+}
+
+// softLine is a pin of a SoftSPI.
+type softLine struct {
+	sopi *SoftSPI
+	pin  gpio.Pin
+}
+
+func (l *softLine) String() string {
+	return ftdi.PinName(l.pin)
+}
+
+// Out makes the pin an output at [level].
+func (l *softLine) Out(level gpio.PinState) error {
+	sopi := l.sopi
+	sopi.mutex.Lock()
+	defer sopi.mutex.Unlock()
+
+	err := sopi.direction(l.pin, gpio.Output)
+	if err != nil {
+		return err
+	}
+
+	sopi.setPin(l.pin, level)
+	return sopi.ftdi.WriteByte(sopi.pins)
+}
+
+// In makes the pin an input and reads it.
+func (l *softLine) In() (gpio.PinState, error) {
+	sopi := l.sopi
+	sopi.mutex.Lock()
+	defer sopi.mutex.Unlock()
+
+	err := sopi.direction(l.pin, gpio.Input)
+	if err != nil {
+		return gpio.Low, err
+	}
+
+	pins, err := sopi.ftdi.PinsRead()
+	if err != nil || pins&(1<<l.pin) == 0 {
+		return gpio.Low, err
+	}
+	return gpio.High, nil
+}
+
+// Toggle drives the pin to the opposite of its last written level.
+func (l *softLine) Toggle() error {
+	sopi := l.sopi
+	sopi.mutex.Lock()
+	defer sopi.mutex.Unlock()
+
+	err := sopi.direction(l.pin, gpio.Output)
+	if err != nil {
+		return err
+	}
+
+	if sopi.IsPinHigh(l.pin) {
+		sopi.setLow(l.pin)
+	} else {
+		sopi.setHigh(l.pin)
+	}
+	return sopi.ftdi.WriteByte(sopi.pins)
+}
+
+// FTDI returns the device the line is driven through, see ftdi.Routed.
+func (l *softLine) FTDI() *ftdi.FTDI232H {
+	return l.sopi.ftdi
+}
+
+// Pulse drives the pin to [level] for [duration] then back.
+func (l *softLine) Pulse(level gpio.PinState, duration time.Duration) error {
+	err := l.Out(level)
+	if err != nil {
+		return err
+	}
+
+	time.Sleep(duration)
+
+	return l.Out(level.Invert())
+}
+
 // ----------------------------------------------------------------------------------
 // Debug stuff
 // ----------------------------------------------------------------------------------
 
+// EnableTrigger is a no-op, the trigger pin is always configured unless
+// SetPins gave gpio.NoPin. It makes SoftSPI a Trigger like FtdiSPI.
+func (sopi *SoftSPI) EnableTrigger() {
+}
+
 // TriggerPulse generate a timed pulse for various tools, ex Logic analyser.
 func (sopi *SoftSPI) TriggerPulse() {
 	// log.Println("SPI: Triggering pulse")
@@ -0,0 +1,52 @@
+package spi_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/wdevore/hardware/ftdi"
+	"github.com/wdevore/hardware/ftdi/sim"
+	"github.com/wdevore/hardware/spi"
+)
+
+func TestSoftConfigure(t *testing.T) {
+	f, s := sim.NewFTDI232H()
+	soft := spi.NewSoftSPIFromFTDI(f)
+
+	err := soft.Configure(100000, spi.MSBFirst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Mode != ftdi.ModeBitbang {
+		t.Errorf("mode %v, want bitbang", s.Mode)
+	}
+	// Three pin states per bit.
+	if s.Baudrate != 300000 {
+		t.Errorf("rate %d, want 300000", s.Baudrate)
+	}
+	// Only the default pin states are written, no MPSSE clock commands.
+	if len(s.Raw) != 1 {
+		t.Errorf("wrote % x, want the pin states only", s.Raw)
+	}
+}
+
+func TestSoftWriteError(t *testing.T) {
+	f, s := sim.NewFTDI232H()
+	soft := spi.NewSoftSPIFromFTDI(f)
+
+	err := soft.Configure(100000, spi.MSBFirst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Unplug()
+	err = soft.Write(0xa5)
+	if !errors.Is(err, ftdi.ErrDisconnected) {
+		t.Errorf("Write: %v, want ErrDisconnected", err)
+	}
+	_, err = soft.Transfer(0xa5)
+	if !errors.Is(err, ftdi.ErrDisconnected) {
+		t.Errorf("Transfer: %v, want ErrDisconnected", err)
+	}
+}
@@ -1,8 +1,12 @@
 package spi
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"log"
+	"sync"
+	"time"
 
 	"github.com/wdevore/hardware/ftdi"
 	"github.com/wdevore/hardware/gpio"
@@ -26,9 +30,9 @@ const (
 	Mode0 CaptureMode = iota // Typical
 	// Mode1 captures of falling edge, propagate on rising clock, clock base = low
 	Mode1
-	// Mode2 captures on rising clock, propagate on falling clock, clock base = high
+	// Mode2 captures on falling clock (the first edge), propagate on rising clock, clock base = high
 	Mode2
-	// Mode3 captures on falling edge, propagage on rising clock, clock base = high
+	// Mode3 captures on rising clock (the second edge), propagate on falling clock, clock base = high
 	Mode3
 )
 
@@ -45,15 +49,20 @@ const (
 // NoChipSelectAssignment means a chip select pin isn't assigned
 const NoChipSelectAssignment = 9999
 
-var writeCommand = []byte{0, 0, 0}
-var writeCommand2 = []byte{0, 0, 0, 0}
-
 const (
 	ReadCommand     = 0x20
 	TransferCommand = 0x30
 )
 
-// FtdiSPI is perspective of FTDI232H
+// bitMode turns a shift command into its bit oriented form, which clocks 1
+// to 8 bits instead of whole bytes.
+const bitMode = 0x02
+
+// FtdiSPI is perspective of FTDI232H, it implements Conn over the MPSSE
+// engine.
+//
+// Tx, Write, Read and Transfer are safe for concurrent use, each one reaches
+// the chip as a single exchange.
 type FtdiSPI struct {
 	// SPI is-a protocol facilitated by FTDI232 device
 	ftdi *ftdi.FTDI232H
@@ -77,32 +86,59 @@ type FtdiSPI struct {
 
 	writeClockVE int
 	readClockVE  int
+
+	// Guards queue and stream.
+	mutex sync.Mutex
+	// queue batches chip select, header and data into one USB write.
+	queue *ftdi.Queue
+	// stream buffers ReadFrom's chunks.
+	stream []byte
 }
 
 // NewSPI creates an SPI FTDI component
 // A chipSelect of `NoPin` means no assignment.
-func NewSPI(vender, product int, disableDrivers bool) *FtdiSPI {
+// [options] choose a specific device, for example ftdi.WithSerial("FT0RN5XA").
+func NewSPI(vender, product int, disableDrivers bool, options ...ftdi.Option) (*FtdiSPI, error) {
 	spi := new(FtdiSPI)
 
 	spi.ConstantCSAssert = true
 
-	spi.ftdi = new(ftdi.FTDI232H)
+	spi.ftdi = ftdi.NewFTDI232H(vender, product, options...)
 
-	spi.ftdi.SetTarget(vender, product)
+	spi.queue = spi.ftdi.NewQueue()
 
 	err := spi.ftdi.Initialize(disableDrivers)
 
 	if err != nil {
-		log.Fatal(err)
-		return nil
+		return nil, err
 	}
 
+	return spi, nil
+}
+
+// NewSPIFromFTDI creates an SPI component on top of an existing FTDI232H,
+// for example one using an injected Transport.
+func NewSPIFromFTDI(fi *ftdi.FTDI232H) *FtdiSPI {
+	spi := new(FtdiSPI)
+
+	spi.ConstantCSAssert = true
+
+	spi.ftdi = fi
+
+	spi.queue = spi.ftdi.NewQueue()
+
 	return spi
 }
 
 // Configure arranges default values for SPI.
 func (spi *FtdiSPI) Configure(chipSelect gpio.Pin, maxSpeed int, mode CaptureMode, bitOrder BitOrder) error {
-	err := spi.ftdi.Configure(true)
+	return spi.ConfigureContext(context.Background(), chipSelect, maxSpeed, mode, bitOrder)
+}
+
+// ConfigureContext is Configure but gives up synchronizing with the
+// device when [ctx] is canceled or its deadline passes.
+func (spi *FtdiSPI) ConfigureContext(ctx context.Context, chipSelect gpio.Pin, maxSpeed int, mode CaptureMode, bitOrder BitOrder) error {
+	err := spi.ftdi.ConfigureContext(ctx, true)
 	if err != nil {
 		log.Println("SPI failed to configure.")
 		return err
@@ -123,6 +159,27 @@ func (spi *FtdiSPI) Configure(chipSelect gpio.Pin, maxSpeed int, mode CaptureMod
 		chipSelect = ftdi.D3
 	}
 
+	pins := []gpio.PinConfiguration{
+		{Pin: ftdi.D0, Direction: gpio.Output}, // clk
+		{Pin: ftdi.D1, Direction: gpio.Output}, // MOSI
+		{Pin: ftdi.D2, Direction: gpio.Input},  // MISO
+	}
+	if spi.hardwareControlled {
+		pins = append(pins, gpio.PinConfiguration{Pin: ftdi.D3, Direction: gpio.Output})
+	} else {
+		pins = append(pins, gpio.PinConfiguration{Pin: chipSelect, Direction: gpio.Output})
+	}
+	if spi.enableTrigger {
+		pins = append(pins, gpio.PinConfiguration{Pin: ftdi.D7, Direction: gpio.Output})
+	}
+
+	// A previous Configure may have used another chip select.
+	spi.ftdi.ReleasePins("SPI")
+	err = spi.ftdi.ClaimPins("SPI", pins)
+	if err != nil {
+		return err
+	}
+
 	spi.chipSelect = chipSelect
 	spi.maxSpeed = maxSpeed
 	spi.mode = mode
@@ -147,10 +204,16 @@ func (spi *FtdiSPI) Configure(chipSelect gpio.Pin, maxSpeed int, mode CaptureMod
 
 	// Initialize clock, mode, and bit order.
 	// log.Printf("SPI Setting clock speed to (%d)MHz\n", maxSpeed/1000000)
-	spi.SetClock(maxSpeed)
+	err = spi.SetClock(maxSpeed)
+	if err != nil {
+		return err
+	}
 
 	// log.Println("SPI Setting mode")
-	spi.SetMode(mode)
+	err = spi.SetMode(mode)
+	if err != nil {
+		return err
+	}
 
 	// log.Println("SPI Setting bit order")
 	spi.SetBitOrder(bitOrder)
@@ -158,6 +221,13 @@ func (spi *FtdiSPI) Configure(chipSelect gpio.Pin, maxSpeed int, mode CaptureMod
 	return nil
 }
 
+// OnReconnect runs [hook] after the FTDI232H got its device back, see
+// ftdi.WithReconnect. The FTDI232H restores the clock and the pins itself,
+// the hook is for the devices on the bus.
+func (spi *FtdiSPI) OnReconnect(hook func(ctx context.Context) error) {
+	spi.ftdi.OnReconnect(hook)
+}
+
 // GetFTDI returns the FTDI component
 func (spi *FtdiSPI) GetFTDI() *ftdi.FTDI232H {
 	return spi.ftdi
@@ -177,269 +247,496 @@ func (spi *FtdiSPI) Close() error {
 }
 
 // NewSPIDefaults creates an SPI component with default settings.
-func NewSPIDefaults(vender, product int, disableDrivers bool) *FtdiSPI {
-	spi := NewSPI(vender, product, disableDrivers)
-	spi.Configure(NoChipSelectAssignment, 1000000, Mode0, MSBFirst)
-	return spi
+func NewSPIDefaults(vender, product int, disableDrivers bool, options ...ftdi.Option) (*FtdiSPI, error) {
+	spi, err := NewSPI(vender, product, disableDrivers, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = spi.Configure(NoChipSelectAssignment, 1000000, Mode0, MSBFirst)
+	if err != nil {
+		return nil, err
+	}
+
+	return spi, nil
 }
 
 // SetClock sets the speed of the SPI clock in hertz.  Note that not all speeds
 // are supported and a lower speed might be chosen by the hardware.
-func (spi *FtdiSPI) SetClock(hz int) {
-	spi.ftdi.SetClock(hz, false, false)
+func (spi *FtdiSPI) SetClock(hz int) error {
+	return spi.ftdi.SetClock(hz, false, false)
 }
 
 // SetMode sets SPI mode which controls clock polarity and phase.  Should be a
 // numeric value 0, 1, 2, or 3.  See wikipedia page for details on meaning:
 // http://en.wikipedia.org/wiki/Serial_Peripheral_Interface_Bus
-func (spi *FtdiSPI) SetMode(mode CaptureMode) {
-	var clockBase gpio.PinState
+func (spi *FtdiSPI) SetMode(mode CaptureMode) error {
+	spi.mutex.Lock()
+	defer spi.mutex.Unlock()
 
-	switch mode {
-	case Mode0:
-		spi.writeClockVE = 1
-		spi.readClockVE = 0
-		// Clock base is low.
-		clockBase = gpio.Low
-		break
-	case Mode1:
-		spi.writeClockVE = 0
-		spi.readClockVE = 1
-		clockBase = gpio.Low
-		break
-	case Mode2:
-		spi.writeClockVE = 1
-		spi.readClockVE = 0
-		clockBase = gpio.High
-		break
-	case Mode3:
-		spi.writeClockVE = 0
-		spi.readClockVE = 1
-		clockBase = gpio.High
-		break
-	}
+	var clockBase gpio.PinState
+	spi.writeClockVE, spi.readClockVE, clockBase = clockEdges(mode)
+	spi.mode = mode
 
 	pins := []gpio.PinConfiguration{
 		{Pin: 0, Direction: gpio.Output, Value: clockBase}, // Set clock as output and start at it base value
 		{Pin: 1, Direction: gpio.Output, Value: gpio.Z},
 		{Pin: 2, Direction: gpio.Input, Value: gpio.Z},
 	}
-	spi.ftdi.ConfigPins(pins, true)
+	return spi.ftdi.ConfigPins(pins, true)
+}
+
+// clockEdges returns the MPSSE edge bits and the clock's idle level for
+// [mode]. writeClockVE set means data changes on the falling edge,
+// readClockVE set means it is sampled on the falling edge. With CPHA=0 the
+// data is sampled on the clock's first edge, with CPHA=1 on its second.
+func clockEdges(mode CaptureMode) (writeClockVE, readClockVE int, clockBase gpio.PinState) {
+	switch mode {
+	case Mode1:
+		return 0, 1, gpio.Low
+	case Mode2:
+		// Idles high so the first edge is falling.
+		return 0, 1, gpio.High
+	case Mode3:
+		return 1, 0, gpio.High
+	default:
+		return 1, 0, gpio.Low
+	}
 }
 
 // SetBitOrder sets the order of bits to be read/written over serial lines.  Should be
 // either MSBFIRST for most-significant first, or LSBFIRST for
 // least-signifcant first.
 func (spi *FtdiSPI) SetBitOrder(order BitOrder) {
+	spi.mutex.Lock()
+	defer spi.mutex.Unlock()
 	spi.bitOrder = order
 }
 
-// Write writes the specified array of bytes out on the MOSI line.
-// This is a Half-duplex SPI write.
-func (spi *FtdiSPI) Write(data []byte) error {
-	// Build command to write SPI data.
-	// writeCommand is the FTDI outer wrapper protocol. It won't appear
-	// on the output pins, it simply tells the ftdi chip about the
-	// data to be streamed out the MOSI pin.
-	writeCommand[0] = 0x10 | (byte(spi.bitOrder) << 3) | byte(spi.writeClockVE)
-	// logger.debug('SPI write with command {0:2X}.'.format(command))
+// Write writes the specified array of bytes out on the MOSI line, it
+// implements io.Writer. This is a Half-duplex SPI write of any length.
+// Chip select is asserted once around the whole write. The data is split in
+// chunks a single MPSSE command can carry, each sent with its header as one
+// USB write. Nothing is read back so the chunks follow each other without
+// waiting.
+func (spi *FtdiSPI) Write(data []byte) (int, error) {
+	spi.mutex.Lock()
+	defer spi.mutex.Unlock()
+
+	n := 0
+	for n < len(data) {
+		chunk := data[n:]
+		if len(chunk) > ftdi.MaxShiftLength {
+			chunk = chunk[:ftdi.MaxShiftLength]
+		}
 
-	// Compute length low and high bytes.
-	// NOTE: Must actually send length minus one because the MPSSE engine
-	// considers `0` a length of 1 and 0xffff a length of 65536
-	length := uint16(len(data) - 1)
-	writeCommand[1] = byte(length & 0xff)
-	writeCommand[2] = byte((length >> 8) & 0xff)
+		err := spi.writeChunk(chunk, n == 0, n+len(chunk) == len(data))
+		if err != nil {
+			return n, err
+		}
+		n += len(chunk)
+	}
 
-	// fmt.Printf("writing.....(%d)\n", writeCommand)
-	if !spi.manualChipSelect {
-		if !spi.ConstantCSAssert {
-			spi.AssertChipSelect() // typically low
+	return n, nil
+}
+
+// ReadFrom writes what [r] returns until io.EOF out on the MOSI line, it
+// implements io.ReaderFrom so io.Copy streams, for example, a raw frame
+// file to a display without loading it. Chip select is asserted once
+// around the whole stream.
+func (spi *FtdiSPI) ReadFrom(r io.Reader) (int64, error) {
+	spi.mutex.Lock()
+	defer spi.mutex.Unlock()
+
+	if spi.stream == nil {
+		spi.stream = make([]byte, ftdi.MaxShiftLength)
+	}
+
+	var total int64
+	for {
+		n, err := io.ReadFull(r, spi.stream)
+
+		if n > 0 {
+			werr := spi.writeChunk(spi.stream[:n], total == 0, false)
+			if werr != nil {
+				return total, werr
+			}
+			total += int64(n)
+		}
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			if total > 0 {
+				spi.endWrite()
+			}
+			return total, err
 		}
 	}
 
-	// Send command and length.
-	// fmt.Printf("SPI: Send command: %0x\n", writeCommand)
-	_, err := spi.ftdi.Write(writeCommand)
+	if total == 0 {
+		return 0, nil
+	}
 
-	if err != nil {
+	return total, spi.endWrite()
+}
+
+// writeChunk sends one MPSSE write of [data], asserting chip select before
+// the [first] chunk and de-asserting it after the [last].
+func (spi *FtdiSPI) writeChunk(data []byte, first, last bool) error {
+	automatic := !spi.manualChipSelect && !spi.ConstantCSAssert
+
+	q := spi.queue
+	q.Reset()
+
+	if first && automatic {
+		spi.QueueAssertChipSelect(q)
+	}
+
+	q.Shift(spi.writeOpcode(), data)
+
+	if last && automatic {
+		spi.QueueDeAssertChipSelect(q)
+	}
+
+	_, err := q.Flush()
+	if err != nil && !last {
+		// Don't leave the device selected.
+		spi.endWrite()
+	}
+	return err
+}
+
+// endWrite de-asserts chip select after a streamed write.
+func (spi *FtdiSPI) endWrite() error {
+	if spi.manualChipSelect || spi.ConstantCSAssert {
+		return nil
+	}
+
+	q := spi.queue
+	q.Reset()
+	spi.QueueDeAssertChipSelect(q)
+	_, err := q.Flush()
+	return err
+}
+
+// Tx is a write ([r] nil) or a full-duplex transfer of [w], see Conn.
+// Chip select, the command header and the data are sent as a single USB
+// write.
+func (spi *FtdiSPI) Tx(w, r []byte) error {
+	if r == nil {
+		_, err := spi.Write(w)
 		return err
 	}
+	return spi.txContext(context.Background(), w, r)
+}
 
-	// if !spi.ConstantCSAssert {
-	// 	spi.DeAssertChipSelect()
-	// }
+// txContext is a full-duplex transfer of [w] into [r], waiting for the
+// response until [ctx] is done.
+func (spi *FtdiSPI) txContext(ctx context.Context, w, r []byte) error {
+	if len(r) != len(w) {
+		return fmt.Errorf("spi: read buffer of %d bytes for a %d bytes transfer", len(r), len(w))
+	}
+	if len(w) == 0 {
+		return nil
+	}
 
-	// if !spi.ConstantCSAssert {
-	// 	spi.AssertChipSelect()
-	// }
-	// Send data.
-	// fmt.Printf("SPI: Send data: %0x\n", data)
-	// This is the data destine for the target device and will appear on
-	// the designated MOSI pin.
-	_, err = spi.ftdi.Write(data)
+	spi.mutex.Lock()
+	defer spi.mutex.Unlock()
+
+	q := spi.queue
+	q.Reset()
+
+	if !spi.manualChipSelect && !spi.ConstantCSAssert {
+		spi.QueueAssertChipSelect(q)
+	}
+
+	// One transfer command followed by the payload, Shift expects a
+	// response byte per byte shifted.
+	q.Shift(spi.transferOpcode(), w)
+
+	if !spi.manualChipSelect && !spi.ConstantCSAssert {
+		spi.QueueDeAssertChipSelect(q)
+	}
+
+	response, err := q.FlushContext(ctx)
 	if err != nil {
 		return err
 	}
 
-	if !spi.manualChipSelect {
-		if !spi.ConstantCSAssert {
-			spi.DeAssertChipSelect() // typically high
-		}
+	if len(response) != len(r) {
+		return fmt.Errorf("spi: transfer read %d bytes instead of %d", len(response), len(r))
 	}
-	// fmt.Printf("wrote. (%d)\n", data)
 
+	copy(r, response)
 	return nil
 }
 
-// WriteByte writes a single plain byte
-func (spi *FtdiSPI) WriteByte(data byte) error {
-	if !spi.ConstantCSAssert {
-		spi.AssertChipSelect() // typically low
+// readOpcode builds the MPSSE command to read SPI data.
+func (spi *FtdiSPI) readOpcode() byte {
+	return ReadCommand | (byte(spi.bitOrder) << 3) | byte(spi.readClockVE<<2)
+}
+
+// transferOpcode builds the MPSSE command to write and read SPI data.
+func (spi *FtdiSPI) transferOpcode() byte {
+	return TransferCommand | (byte(spi.bitOrder) << 3) | byte(spi.readClockVE<<2) | byte(spi.writeClockVE)
+}
+
+// TxBits shifts the first [bits] bits of [w] out, see BitConn. Whole bytes
+// use the byte commands and the remaining bits one bit mode command, all in
+// a single USB write.
+func (spi *FtdiSPI) TxBits(w []byte, bits int, r []byte) error {
+	n, err := checkBits(w, bits, r)
+	if err != nil || n == 0 {
+		return err
 	}
 
-	fmt.Printf("writing byte.....(%0x)\n", data)
-	// Send command and length.
-	// fmt.Printf("SPI: Send command: %0x\n", writeCommand)
-	_, err := spi.ftdi.WriteByte(data)
+	spi.mutex.Lock()
+	defer spi.mutex.Unlock()
 
-	if err != nil {
-		fmt.Printf("SPI: Error writing byte: %v\n", err)
+	q := spi.queue
+	q.Reset()
+
+	if !spi.manualChipSelect && !spi.ConstantCSAssert {
+		spi.QueueAssertChipSelect(q)
+	}
+
+	opcode := spi.writeOpcode()
+	if r != nil {
+		opcode = spi.transferOpcode()
+	}
+
+	whole, rest := bits/8, bits%8
+	if whole > 0 {
+		q.Shift(opcode, w[:whole])
+	}
+	if rest > 0 {
+		q.ShiftBits(opcode|bitMode, rest, w[whole])
+	}
+
+	if !spi.manualChipSelect && !spi.ConstantCSAssert {
+		spi.QueueDeAssertChipSelect(q)
+	}
+
+	response, err := q.Flush()
+	if err != nil || r == nil {
 		return err
 	}
 
-	if !spi.ConstantCSAssert {
-		spi.DeAssertChipSelect() // typically high
+	if len(response) != n {
+		return fmt.Errorf("spi: read %d bytes instead of %d", len(response), n)
+	}
+
+	copy(r, response)
+	if rest > 0 {
+		// The engine packs the bits read at the opposite end of the byte.
+		if spi.bitOrder == LSBFirst {
+			r[whole] >>= uint(8 - rest)
+		} else {
+			r[whole] <<= uint(8 - rest)
+		}
 	}
-	fmt.Printf("wrote byte. (%0x)\n", data)
 
 	return nil
 }
 
-// WriteLen writes the specified array of bytes out on the MOSI line.
-// Allows writing of variable length arrays of fixed size
-// This is a Half-duplex SPI write.
-func (spi *FtdiSPI) WriteLen(data []byte, length int) error {
-	// Build command to write SPI data.
-	writeCommand[0] = 0x10 | (byte(spi.bitOrder) << 3) | byte(spi.writeClockVE)
-	// logger.debug('SPI write with command {0:2X}.'.format(command))
+// checkBits validates the buffers of a TxBits and returns how many bytes
+// the [bits] span.
+func checkBits(w []byte, bits int, r []byte) (int, error) {
+	n := (bits + 7) / 8
+	if bits < 0 || len(w) < n {
+		return 0, fmt.Errorf("spi: %d bits don't fit a %d bytes buffer", bits, len(w))
+	}
+	if r != nil && len(r) < n {
+		return 0, fmt.Errorf("spi: read buffer of %d bytes for %d bits", len(r), bits)
+	}
+	return n, nil
+}
+
+// SetConstantCSAssert sets ConstantCSAssert.
+func (spi *FtdiSPI) SetConstantCSAssert(constant bool) {
+	spi.mutex.Lock()
+	defer spi.mutex.Unlock()
+	spi.ConstantCSAssert = constant
+}
+
+// Line returns a pin of the FTDI232H as a gpio.Line, for example a
+// display's D/C pin, which makes FtdiSPI a gpio.Provider.
+func (spi *FtdiSPI) Line(pin gpio.Pin) (gpio.Line, error) {
+	return spi.ftdi.Line(pin)
+}
+
+// NewQueue returns a command queue for batching SPI and GPIO traffic, for
+// example a D/C pin change followed by a command byte.
+func (spi *FtdiSPI) NewQueue() *ftdi.Queue {
+	return spi.ftdi.NewQueue()
+}
 
-	// Compute length low and high bytes.
-	// NOTE: Must actually send length minus one because the MPSSE engine
-	// considers `0` a length of 1 and 0xffff a length of 65536
-	dlength := uint16(length - 1)
-	writeCommand[1] = byte(dlength & 0xff)
-	writeCommand[2] = byte((dlength >> 8) & 0xff)
+// FlushQueue runs [fill], which appends to [q], then sends [q], see
+// ftdi.Queue.FlushContext. [fill] runs with the FtdiSPI locked, so it must
+// only use the Queue methods.
+func (spi *FtdiSPI) FlushQueue(ctx context.Context, q *ftdi.Queue, fill func(q *ftdi.Queue)) ([]byte, error) {
+	spi.mutex.Lock()
+	defer spi.mutex.Unlock()
 
-	if !spi.ConstantCSAssert {
-		spi.AssertChipSelect()
+	fill(q)
+	return q.FlushContext(ctx)
+}
+
+// QueueWrite appends a half-duplex write, including any chip select
+// handling, to [q]. Nothing is sent until q.Flush() is called.
+func (spi *FtdiSPI) QueueWrite(q *ftdi.Queue, data []byte) {
+	if len(data) == 0 {
+		return
 	}
 
-	// Send command and length.
-	_, err := spi.ftdi.Write(writeCommand)
+	if !spi.manualChipSelect {
+		if !spi.ConstantCSAssert {
+			spi.QueueAssertChipSelect(q) // typically low
+		}
+	}
 
-	if err != nil {
-		return err
+	// The command header is the FTDI outer wrapper protocol. It won't appear
+	// on the output pins, it simply tells the ftdi chip about the
+	// data to be streamed out the MOSI pin.
+	// This is the data destine for the target device and will appear on
+	// the designated MOSI pin.
+	q.Shift(spi.writeOpcode(), data)
+
+	if !spi.manualChipSelect {
+		if !spi.ConstantCSAssert {
+			spi.QueueDeAssertChipSelect(q) // typically high
+		}
 	}
+}
 
-	// Send data.
-	_, err = spi.ftdi.WriteLen(data, length)
-	if err != nil {
-		return err
+// writeOpcode builds the MPSSE command to write SPI data.
+func (spi *FtdiSPI) writeOpcode() byte {
+	return 0x10 | (byte(spi.bitOrder) << 3) | byte(spi.writeClockVE)
+}
+
+// WriteByte writes a single plain byte
+func (spi *FtdiSPI) WriteByte(data byte) error {
+	spi.mutex.Lock()
+	defer spi.mutex.Unlock()
+
+	q := spi.queue
+	q.Reset()
+
+	if !spi.manualChipSelect && !spi.ConstantCSAssert {
+		spi.QueueAssertChipSelect(q) // typically low
 	}
 
-	if !spi.ConstantCSAssert {
-		spi.DeAssertChipSelect()
+	q.Shift(spi.writeOpcode(), []byte{data})
+
+	if !spi.manualChipSelect && !spi.ConstantCSAssert {
+		spi.QueueDeAssertChipSelect(q) // typically high
 	}
 
-	return nil
+	_, err := q.Flush()
+	return err
+}
+
+// WriteLen writes the specified array of bytes out on the MOSI line.
+// Allows writing of variable length arrays of fixed size
+// This is a Half-duplex SPI write, of any length as Write.
+func (spi *FtdiSPI) WriteLen(data []byte, length int) error {
+	_, err := spi.Write(data[:length])
+	return err
 }
 
 // Half-duplex SPI read.  The specified length of bytes will be clocked
 // in the MISO line and returned as a bytearray object.
+// [readCommand] is kept for compatibility, the MPSSE command is built from
+// the capture mode and bit order.
 func (spi *FtdiSPI) Read(length int, readCommand byte) ([]byte, error) {
-	// Build command to read SPI data.
-	writeCommand2[0] = ReadCommand | (byte(spi.bitOrder) << 3) | (byte(spi.readClockVE) << 2)
-	// logger.debug('SPI read with command {0:2X}.'.format(command))
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	return spi.ReadContext(ctx, length, readCommand)
+}
+
+// ReadContext is Read but waits for the response until [ctx] is done
+// instead of a fixed 3 seconds.
+func (spi *FtdiSPI) ReadContext(ctx context.Context, length int, readCommand byte) ([]byte, error) {
+	if length <= 0 {
+		return []byte{}, nil
+	}
+
+	spi.mutex.Lock()
+	defer spi.mutex.Unlock()
 
-	// Compute length low and high bytes.
-	// NOTE: Must actually send length minus one because the MPSSE engine
-	// considers 0 a length of 1 and 0xffff a length of 65536
-	writeCommand2[1] = byte((length - 1) & 0xff)
-	writeCommand2[2] = byte(((length - 1) >> 8) & 0xff)
-	writeCommand2[3] = 0x87
+	q := spi.queue
+	q.Reset()
 
-	if !spi.ConstantCSAssert {
-		spi.AssertChipSelect()
+	if !spi.manualChipSelect && !spi.ConstantCSAssert {
+		spi.QueueAssertChipSelect(q)
 	}
 
-	// Send command and length.
-	spi.Write(writeCommand2)
+	// One read command, the engine clocks [length] bytes in while MOSI
+	// idles.
+	q.ShiftIn(spi.readOpcode(), length)
+
+	if !spi.manualChipSelect && !spi.ConstantCSAssert {
+		spi.QueueDeAssertChipSelect(q)
+	}
 
-	if !spi.ConstantCSAssert {
-		spi.DeAssertChipSelect()
+	// The chip select runs after the shift, so it can be queued ahead of
+	// the response. The queue ends with send-immediate.
+	response, err := q.FlushContext(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	// Read response bytes.
-	response, err := spi.ftdi.PollRead(length, -1)
+	if len(response) != length {
+		return nil, fmt.Errorf("spi: read %d bytes instead of %d", len(response), length)
+	}
 
-	return response, err
+	return response, nil
 }
 
 func (spi *FtdiSPI) SubmitTransfer(data []byte, transferCommand byte) ([]byte, error) {
-	transfer, err := spi.ftdi.SubmitRead(1)
-	println("transfer: ", transfer)
+	_, err := spi.ftdi.SubmitRead(1)
 	return nil, err
 }
 
 // Transfer is a Full-duplex SPI read and write.  The specified array of bytes will be
 // clocked out the MOSI line, while simultaneously bytes will be read from
 // the MISO line.  Read bytes will be returned as a bytearray object.
-// transferCommand could be a value of 0x30 for most devices.
+// [transferCommand] is kept for compatibility, the MPSSE command is built
+// from the capture mode and bit order.
 func (spi *FtdiSPI) Transfer(data []byte, transferCommand byte) ([]byte, error) {
-	// Build command to read and write SPI data.
-	writeCommand[0] = TransferCommand | (byte(spi.bitOrder) << 3) | byte(spi.readClockVE<<2) | byte(spi.writeClockVE)
-	// logger.debug('SPI transfer with command {0:2X}.'.format(command))
-	// Compute length low and high bytes.
-	// NOTE: Must actually send length minus one because the MPSSE engine
-	// considers 0 a length of 1 and 0xffff a length of 65536
-
-	length := len(data)
-	writeCommand[1] = byte((length - 1) & 0xff)
-	writeCommand[2] = byte(((length - 1) >> 8) & 0xff)
-	// writeCommand[1] = byte((length) & 0xff)
-	// writeCommand[2] = byte(((length) >> 8) & 0xff)
-
-	// Send command and length.
-	if !spi.ConstantCSAssert {
-		spi.AssertChipSelect()
-	}
-
-	spi.Write(writeCommand)
-	spi.Write(data)
-	spi.ftdi.WriteByte(0x87)
-
-	// Read response bytes.
-	response, err := spi.ftdi.PollRead(length, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	return spi.TransferContext(ctx, data, transferCommand)
+}
 
-	if !spi.ConstantCSAssert {
-		spi.DeAssertChipSelect()
-	}
+// TransferContext is Transfer but waits for the response until [ctx] is done
+// instead of a fixed 1 second.
+func (spi *FtdiSPI) TransferContext(ctx context.Context, data []byte, transferCommand byte) ([]byte, error) {
+	response := make([]byte, len(data))
 
+	err := spi.txContext(ctx, data, response)
 	if err != nil {
 		log.Printf("SPI: Transfer pollread failed on data (%v)\n", data)
 		log.Println(err)
+		return nil, err
 	}
-	return response, err
+
+	return response, nil
 }
 
 // TakeControlOfCS allows user to take control of CS
 func (spi *FtdiSPI) TakeControlOfCS() {
+	spi.mutex.Lock()
+	defer spi.mutex.Unlock()
 	spi.manualChipSelect = true
 }
 
 // ReleaseControlOfCS allows user to return control back to SPI
 func (spi *FtdiSPI) ReleaseControlOfCS() {
+	spi.mutex.Lock()
+	defer spi.mutex.Unlock()
 	spi.manualChipSelect = false
 }
 
@@ -467,9 +764,31 @@ func (spi *FtdiSPI) DeAssertChipSelect() {
 	}
 }
 
+// QueueAssertChipSelect appends a chip select assertion to [q]
+func (spi *FtdiSPI) QueueAssertChipSelect(q *ftdi.Queue) {
+	if spi.chipSelect != gpio.NoPin && spi.chipSelect != gpio.HardwarePin {
+		if spi.CSActiveLow {
+			q.OutputLow(spi.chipSelect)
+		} else {
+			q.OutputHigh(spi.chipSelect)
+		}
+	}
+}
+
+// QueueDeAssertChipSelect appends a chip select de-assertion to [q]
+func (spi *FtdiSPI) QueueDeAssertChipSelect(q *ftdi.Queue) {
+	if spi.chipSelect != gpio.NoPin && spi.chipSelect != gpio.HardwarePin {
+		if spi.CSActiveLow {
+			q.OutputHigh(spi.chipSelect)
+		} else {
+			q.OutputLow(spi.chipSelect)
+		}
+	}
+}
+
 // ConfigurePins allows direct pins configurations
-func (spi *FtdiSPI) ConfigurePins(pins []gpio.PinConfiguration) {
-	spi.ftdi.ConfigPins(pins, true)
+func (spi *FtdiSPI) ConfigurePins(pins []gpio.PinConfiguration) error {
+	return spi.ftdi.ConfigPins(pins, true)
 }
 
 // ----------------------------------------------------------------------------------
@@ -0,0 +1,333 @@
+package spi_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/wdevore/hardware/ftdi"
+	"github.com/wdevore/hardware/ftdi/sim"
+	"github.com/wdevore/hardware/spi"
+)
+
+func configured(t *testing.T, mode spi.CaptureMode, order spi.BitOrder) (*spi.FtdiSPI, *sim.FT232H) {
+	t.Helper()
+	f, s := sim.NewFTDI232H()
+	conn := spi.NewSPIFromFTDI(f)
+	conn.ConstantCSAssert = false
+	err := conn.Configure(ftdi.D3, 1000000, mode, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Reset()
+	return conn, s
+}
+
+// opcodes returns the opcodes of [ops].
+func opcodes(ops []sim.Op) []byte {
+	codes := make([]byte, len(ops))
+	for i, op := range ops {
+		codes[i] = op.Opcode
+	}
+	return codes
+}
+
+// selected returns whether D3, the chip select, is low after a 0x80 [op].
+func selected(op sim.Op) bool {
+	return op.Args[0]&(1<<ftdi.D3) == 0
+}
+
+func TestConfigure(t *testing.T) {
+	f, s := sim.NewFTDI232H()
+	err := spi.NewSPIFromFTDI(f).Configure(ftdi.D3, 1000000, spi.Mode2, spi.MSBFirst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Mode != ftdi.ModeMPSSE {
+		t.Errorf("mode %v, want MPSSE", s.Mode)
+	}
+	if hz := s.Clock(); hz != 1000000 {
+		t.Errorf("clock %d, want 1000000", hz)
+	}
+	// SCK idles high in mode 2, MOSI and chip select are outputs, MISO is
+	// an input.
+	if s.Direction&0x0f != 0x0b {
+		t.Errorf("direction %#02x, want 0x0b", s.Direction&0x0f)
+	}
+	if s.Level&0x01 == 0 {
+		t.Error("SCK idles low in mode 2")
+	}
+	if s.Level&(1<<ftdi.D3) == 0 {
+		t.Error("chip select is asserted")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	conn, s := configured(t, spi.Mode0, spi.MSBFirst)
+
+	data := []byte{0x01, 0x02, 0x03}
+	n, err := conn.Write(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(data) {
+		t.Errorf("wrote %d bytes, want %d", n, len(data))
+	}
+
+	want := []byte{0x80, 0x82, 0x11, 0x80, 0x82}
+	if got := opcodes(s.Ops); !bytes.Equal(got, want) {
+		t.Fatalf("ops % x, want % x", got, want)
+	}
+	if !selected(s.Ops[0]) || selected(s.Ops[3]) {
+		t.Error("chip select isn't asserted around the write")
+	}
+	if bits := s.Ops[2].Bits; bits != 24 {
+		t.Errorf("shifted %d bits, want 24", bits)
+	}
+	if mosi := s.MOSI(); !bytes.Equal(mosi, data) {
+		t.Errorf("MOSI % x, want % x", mosi, data)
+	}
+}
+
+func TestWriteChunks(t *testing.T) {
+	conn, s := configured(t, spi.Mode0, spi.MSBFirst)
+
+	data := make([]byte, ftdi.MaxShiftLength+10)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	_, err := conn.Write(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Chip select stays asserted across the chunks.
+	want := []byte{0x80, 0x82, 0x11, 0x11, 0x80, 0x82}
+	if got := opcodes(s.Ops); !bytes.Equal(got, want) {
+		t.Fatalf("ops % x, want % x", got, want)
+	}
+	if bits := s.Ops[2].Bits; bits != ftdi.MaxShiftLength*8 {
+		t.Errorf("first chunk is %d bits", bits)
+	}
+	if mosi := s.MOSI(); !bytes.Equal(mosi, data) {
+		t.Error("MOSI isn't the data written")
+	}
+}
+
+func TestReadFrom(t *testing.T) {
+	conn, s := configured(t, spi.Mode0, spi.MSBFirst)
+
+	data := make([]byte, 3*ftdi.MaxShiftLength/2)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	n, err := io.Copy(conn, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(data)) {
+		t.Errorf("copied %d bytes, want %d", n, len(data))
+	}
+
+	// One chip select frame around the whole stream.
+	ops := opcodes(s.Ops)
+	if ops[0] != 0x80 || !selected(s.Ops[0]) || ops[len(ops)-2] != 0x80 || selected(s.Ops[len(ops)-2]) {
+		t.Errorf("ops % x, want the stream inside one chip select frame", ops)
+	}
+	for _, op := range s.Ops[2 : len(ops)-2] {
+		if op.Opcode != 0x11 {
+			t.Errorf("op %v inside the frame", op)
+		}
+	}
+	if mosi := s.MOSI(); !bytes.Equal(mosi, data) {
+		t.Error("MOSI isn't the data copied")
+	}
+}
+
+func TestRead(t *testing.T) {
+	conn, s := configured(t, spi.Mode0, spi.MSBFirst)
+
+	s.QueueMISO(0xde, 0xad)
+	data, err := conn.Read(2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, []byte{0xde, 0xad}) {
+		t.Errorf("read % x, want de ad", data)
+	}
+
+	// The response is pushed back with send-immediate.
+	want := []byte{0x80, 0x82, 0x20, 0x80, 0x82, 0x87}
+	if got := opcodes(s.Ops); !bytes.Equal(got, want) {
+		t.Fatalf("ops % x, want % x", got, want)
+	}
+	if mosi := s.MOSI(); len(mosi) != 0 {
+		t.Errorf("a read clocked % x out", mosi)
+	}
+}
+
+func TestTransfer(t *testing.T) {
+	// The transfer opcode for each mode and bit order, see AN_108.
+	tests := []struct {
+		mode   spi.CaptureMode
+		order  spi.BitOrder
+		opcode byte
+	}{
+		{spi.Mode0, spi.MSBFirst, 0x31},
+		{spi.Mode1, spi.MSBFirst, 0x34},
+		{spi.Mode2, spi.MSBFirst, 0x34},
+		{spi.Mode3, spi.MSBFirst, 0x31},
+		{spi.Mode0, spi.LSBFirst, 0x39},
+	}
+
+	for _, test := range tests {
+		conn, s := configured(t, test.mode, test.order)
+
+		s.QueueMISO(0xca, 0xfe)
+		data, err := conn.Transfer([]byte{0x12, 0x34}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, []byte{0xca, 0xfe}) {
+			t.Errorf("mode %d order %d: MISO % x, want ca fe", test.mode, test.order, data)
+		}
+		if mosi := s.MOSI(); !bytes.Equal(mosi, []byte{0x12, 0x34}) {
+			t.Errorf("mode %d order %d: MOSI % x, want 12 34", test.mode, test.order, mosi)
+		}
+
+		found := false
+		for _, op := range s.Ops {
+			if op.Opcode == test.opcode {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("mode %d order %d: ops % x, want opcode %#02x", test.mode, test.order, opcodes(s.Ops), test.opcode)
+		}
+	}
+}
+
+func TestWriteByte(t *testing.T) {
+	conn, s := configured(t, spi.Mode0, spi.MSBFirst)
+
+	err := conn.WriteByte(0x5a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x80, 0x82, 0x11, 0x80, 0x82}
+	if got := opcodes(s.Ops); !bytes.Equal(got, want) {
+		t.Fatalf("ops % x, want % x", got, want)
+	}
+
+	// Chip select is left alone while the caller controls it.
+	s.Reset()
+	conn.TakeControlOfCS()
+	err = conn.WriteByte(0xa5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := opcodes(s.Ops); !bytes.Equal(got, []byte{0x11}) {
+		t.Errorf("ops % x, want 11", got)
+	}
+	if mosi := s.MOSI(); !bytes.Equal(mosi, []byte{0xa5}) {
+		t.Errorf("MOSI % x, want a5", mosi)
+	}
+}
+
+func TestReadLong(t *testing.T) {
+	conn, s := configured(t, spi.Mode0, spi.MSBFirst)
+
+	miso := make([]byte, 3000)
+	for i := range miso {
+		miso[i] = byte(i * 3)
+	}
+	s.QueueMISO(miso...)
+
+	data, err := conn.Read(len(miso), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, miso) {
+		t.Error("read isn't the MISO data")
+	}
+	// The chip's receive buffer holds 1 KiB.
+	if s.MaxResponse > 1024 {
+		t.Errorf("%d response bytes waited for the host", s.MaxResponse)
+	}
+}
+
+func TestTransferLong(t *testing.T) {
+	conn, s := configured(t, spi.Mode0, spi.MSBFirst)
+
+	mosi := make([]byte, 3000)
+	miso := make([]byte, len(mosi))
+	for i := range mosi {
+		mosi[i] = byte(i)
+		miso[i] = byte(i * 5)
+	}
+	s.QueueMISO(miso...)
+
+	data, err := conn.Transfer(mosi, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, miso) {
+		t.Error("transfer read isn't the MISO data")
+	}
+	if got := s.MOSI(); !bytes.Equal(got, mosi) {
+		t.Error("MOSI isn't the data transferred")
+	}
+	if s.MaxResponse > 1024 {
+		t.Errorf("%d response bytes waited for the host", s.MaxResponse)
+	}
+}
+
+func TestTxBits(t *testing.T) {
+	tests := []struct {
+		order spi.BitOrder
+		bits  int
+		want  []byte
+	}{
+		// The slave's first bits end up at the top of the partial byte
+		// MSB first, at the bottom LSB first.
+		{spi.MSBFirst, 13, []byte{0x12, 0xa0}},
+		{spi.LSBFirst, 13, []byte{0x12, 0x05}},
+		{spi.MSBFirst, 3, []byte{0x00}},
+		{spi.LSBFirst, 3, []byte{0x02}},
+		{spi.MSBFirst, 16, []byte{0x12, 0xa5}},
+	}
+
+	for _, test := range tests {
+		conn, s := configured(t, spi.Mode0, test.order)
+		miso := []byte{0x12, 0xa5}
+		if test.bits < 8 {
+			miso = miso[:1]
+		}
+		s.QueueMISO(miso...)
+
+		w := []byte{0xc3, 0x3c}
+		r := make([]byte, len(test.want))
+		err := conn.TxBits(w[:len(r)], test.bits, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(r, test.want) {
+			t.Errorf("order %d, %d bits: read % x, want % x", test.order, test.bits, r, test.want)
+		}
+
+		// Whole bytes, then the rest in bit mode.
+		bits := 0
+		for _, op := range s.Ops {
+			if op.Opcode < 0x80 {
+				bits += op.Bits
+				if op.Opcode&0x02 != 0 && op.Out[0] != w[test.bits/8] {
+					t.Errorf("order %d, %d bits: partial byte %#02x, want %#02x", test.order, test.bits, op.Out[0], w[test.bits/8])
+				}
+			}
+		}
+		if bits != test.bits {
+			t.Errorf("order %d: shifted %d bits, want %d", test.order, bits, test.bits)
+		}
+	}
+}
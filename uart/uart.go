@@ -0,0 +1,146 @@
+package uart
+
+import (
+	"log"
+	"time"
+
+	"github.com/wdevore/hardware/ftdi"
+)
+
+// When using the UART with the FT232H the following pins will have a special meaning:
+// D0 - TXD. Wire to the device's RX.
+// D1 - RXD. Wire to the device's TX.
+// D2 - RTS#. Wire to the device's CTS when using RTS/CTS flow control.
+// D3 - CTS#. Wire to the device's RTS when using RTS/CTS flow control.
+
+// How long Read sleeps between polls of an empty receive buffer.
+const pollInterval = time.Millisecond
+
+// UART is the FT232H's asynchronous serial function. It implements
+// io.ReadWriteCloser so it can be handed to bufio, io.Copy and friends.
+type UART struct {
+	ftdi *ftdi.FTDI232H
+
+	// Only Close a device we opened.
+	owned bool
+
+	config      ftdi.SerialConfig
+	readTimeout time.Duration
+}
+
+// NewUART creates a UART FTDI component
+func NewUART(vender, product int, disableDrivers bool, options ...ftdi.Option) (*UART, error) {
+	uart := new(UART)
+	uart.ftdi = ftdi.NewFTDI232H(vender, product, options...)
+	uart.owned = true
+
+	err := uart.ftdi.Initialize(disableDrivers)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return uart, nil
+}
+
+// NewUARTFromFTDI creates a UART on top of an existing FTDI232H, for example
+// the one behind a FtdiSPI (see GetFTDI). Configure switches the chip to
+// UART mode and the SPI's Configure switches it back. Close doesn't close
+// a device it didn't open.
+func NewUARTFromFTDI(fi *ftdi.FTDI232H) *UART {
+	uart := new(UART)
+	uart.ftdi = fi
+	return uart
+}
+
+// Configure opens the device and sets up the line. A zero config means
+// ftdi.DefaultSerialConfig (115200 8N1).
+func (uart *UART) Configure(config ftdi.SerialConfig) error {
+	if config.Baudrate == 0 {
+		config = ftdi.DefaultSerialConfig
+	}
+
+	err := uart.ftdi.SerialConfigure(config)
+	if err != nil {
+		log.Println("UART failed to configure.")
+		return err
+	}
+
+	uart.config = config
+
+	return nil
+}
+
+// GetFTDI returns the FTDI component
+func (uart *UART) GetFTDI() *ftdi.FTDI232H {
+	return uart.ftdi
+}
+
+// Config returns the line setup.
+func (uart *UART) Config() ftdi.SerialConfig {
+	return uart.config
+}
+
+// SetReadTimeout bounds how long Read waits for the first byte. A timeout
+// of 0 (the default) waits forever.
+func (uart *UART) SetReadTimeout(timeout time.Duration) {
+	uart.readTimeout = timeout
+}
+
+// Read reads up to len(data) bytes. It returns as soon as at least one
+// byte has arrived, or a *ftdi.TimeoutError (matching ftdi.ErrReadTimeout)
+// when none arrive within the read timeout.
+func (uart *UART) Read(data []byte) (int, error) {
+	if len(data) == 0 {
+		return 0, nil
+	}
+
+	start := time.Now()
+	for {
+		n, err := uart.ftdi.Read(data)
+		if n > 0 || err != nil {
+			return n, err
+		}
+
+		if uart.readTimeout > 0 && time.Since(start) >= uart.readTimeout {
+			return 0, &ftdi.TimeoutError{Expected: len(data), Timeout: uart.readTimeout}
+		}
+
+		time.Sleep(pollInterval)
+	}
+}
+
+// Write transmits data. With RTS/CTS flow control the chip holds bytes
+// back while CTS# is deasserted.
+func (uart *UART) Write(data []byte) (int, error) {
+	return uart.ftdi.Write(data)
+}
+
+// Break holds TXD low for [duration], for example to get the attention of
+// a bootloader or a console's magic SysRq.
+func (uart *UART) Break(duration time.Duration) error {
+	err := uart.ftdi.SetBreak(true)
+	if err != nil {
+		return err
+	}
+
+	time.Sleep(duration)
+
+	return uart.ftdi.SetBreak(false)
+}
+
+// SetDTRRTS drives the DTR# and RTS# lines. True asserts the line (low).
+// Not needed for flow control, the chip drives RTS# itself.
+func (uart *UART) SetDTRRTS(dtr, rts bool) error {
+	return uart.ftdi.SetDTRRTS(dtr, rts)
+}
+
+// Close closes the FTDI232 device if the UART opened it.
+func (uart *UART) Close() error {
+	if !uart.owned {
+		return nil
+	}
+
+	log.Println("UART closing FTDI device")
+	return uart.ftdi.Close()
+}